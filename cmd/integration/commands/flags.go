@@ -2,6 +2,7 @@ package commands
 
 import (
 	"github.com/ledgerwatch/turbo-geth/node"
+	"github.com/ledgerwatch/turbo-geth/turbo/fsck"
 	"github.com/spf13/cobra"
 )
 
@@ -17,16 +18,23 @@ var (
 	unwind             uint64
 	unwindEvery        uint64
 	batchSizeStr       string
+	cacheDir           string
 	reset              bool
 	bucket             string
 	datadir            string
 	mapSizeStr         string
 	freelistReuse      int
 	migration          string
+	migrationRevert    bool
+	migrationDryRun    bool
+	migrationBackup    bool
 	dispatcherAddr     string
 	dispatcherLatency  int
 	shardBits          int
 	shardID            int
+	fsckChecks         []string
+	fsckRepair         bool
+	fsckMaxFindings    int
 )
 
 func must(err error) {
@@ -91,8 +99,18 @@ func withBatchSize(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&batchSizeStr, "batchSize", "512M", "batch size for execution stage")
 }
 
+func withCacheDir(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&cacheDir, "cacheDir", "", "directory to save warm state caches of execution stage, empty string means not to save them")
+}
+
 func withMigration(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&migration, "migration", "", "action to apply to given migration")
+	cmd.Flags().BoolVar(&migrationRevert, "revert", true, "undo the changes of the migration - by its Down function or from the backup, otherwise it is only marked as not applied")
+}
+
+func withMigrationsPlan(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&migrationDryRun, "dry-run", false, "print buckets which pending migrations touch and their sizes, without applying them")
+	cmd.Flags().BoolVar(&migrationBackup, "backup", true, "copy buckets which migration touches before applying it, so remove_migration can restore them")
 }
 
 func withDispatcher(cmd *cobra.Command) {
@@ -106,3 +124,9 @@ func withShard(cmd *cobra.Command) {
 	cmd.Flags().IntVar(&shardBits, "shard_bits", 2, "number of bits in the key used to derive shardID")
 	cmd.Flags().IntVar(&shardID, "shard_id", 0, "shard ID")
 }
+
+func withFsck(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&fsckChecks, "checks", nil, "comma-separated checks to run, all of them by default")
+	cmd.Flags().BoolVar(&fsckRepair, "repair", false, "reset the stages producing the buckets where the failed checks found inconsistencies")
+	cmd.Flags().IntVar(&fsckMaxFindings, "max_findings", fsck.DefaultMaxFindings, "check stops after that many findings")
+}
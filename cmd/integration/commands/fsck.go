@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ledgerwatch/turbo-geth/cmd/utils"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+	"github.com/ledgerwatch/turbo-geth/turbo/fsck"
+	"github.com/spf13/cobra"
+)
+
+var cmdFsck = &cobra.Command{
+	Use:   "fsck",
+	Short: "check invariants between the buckets in one read transaction, the node can keep running",
+	Long:  fsckDescription(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := utils.RootContext()
+		db := openDatabase(chaindata, false)
+		defer db.Close()
+
+		if err := runFsck(db, ctx); err != nil {
+			log.Error("Error", "err", err)
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	withChaindata(cmdFsck)
+	withFsck(cmdFsck)
+
+	rootCmd.AddCommand(cmdFsck)
+}
+
+func fsckDescription() string {
+	var sb strings.Builder
+	sb.WriteString("Checks invariants between the buckets in one read transaction, the node can keep running.\n")
+	sb.WriteString("With --repair, the stages producing the inconsistent buckets are reset, the buckets are regenerated on the next sync cycle.\n\nChecks:\n")
+	w := tabwriter.NewWriter(&sb, 8, 8, 1, ' ', 0)
+	for _, check := range fsck.Checks {
+		fmt.Fprintf(w, "  %s\t%s\n", check.Name, check.Description)
+	}
+	w.Flush()
+	return sb.String()
+}
+
+func runFsck(db ethdb.Database, ctx context.Context) error {
+	checks, err := fsck.Lookup(fsckChecks)
+	if err != nil {
+		return err
+	}
+	report, err := fsck.Run(db, checks, fsck.Options{MaxFindings: fsckMaxFindings, Quit: ctx.Done()})
+	if err != nil {
+		return err
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 8, 8, 0, '\t', 0)
+	for _, f := range report.Findings {
+		fmt.Fprintf(w, "%s \t %s \t %d \t %x \t %s\n", f.Check, f.Bucket, f.Block, f.Key, f.Msg)
+	}
+	for _, check := range checks {
+		if reason, ok := report.Skipped[check.Name]; ok {
+			fmt.Fprintf(w, "%s \t skipped: %s\n", check.Name, reason)
+		}
+	}
+	w.Flush()
+
+	failed := report.Failed()
+	if len(failed) == 0 {
+		fmt.Printf("No inconsistencies found\n")
+		return nil
+	}
+	if !fsckRepair {
+		return fmt.Errorf("inconsistencies found by checks: %s", strings.Join(failed, ", "))
+	}
+	log.Info("Repairing, waits for the write transaction of the running node", "checks", strings.Join(failed, ", "))
+	unrepairable, err := fsck.Repair(db, report)
+	if err != nil {
+		return err
+	}
+	if len(unrepairable) > 0 {
+		return fmt.Errorf("buckets are not derivable, can't repair: %s", strings.Join(unrepairable, ", "))
+	}
+	fmt.Printf("Repaired, the stages of the failed checks are reset\n")
+	return nil
+}
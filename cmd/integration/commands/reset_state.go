@@ -214,6 +214,7 @@ func resetCallTraces(db rawdb.DatabaseWriter) error {
 	if err := db.(ethdb.BucketsMigrator).ClearBuckets(
 		dbutils.CallFromIndex,
 		dbutils.CallToIndex,
+		dbutils.CallTraceSet,
 	); err != nil {
 		return err
 	}
@@ -265,13 +266,31 @@ func resetFinish(db ethdb.Putter) error {
 	return nil
 }
 
-func printStages(db rawdb.DatabaseReader) error {
+func printStages(db ethdb.Getter) error {
 	var err error
 	var progress uint64
 	w := new(tabwriter.Writer)
 	defer w.Flush()
 	w.Init(os.Stdout, 8, 8, 0, '\t', 0)
+	known := make(map[string]struct{}, len(stages.AllStages))
 	for _, stage := range stages.AllStages {
+		known[string(stage)] = struct{}{}
+		if progress, _, err = stages.GetStageProgress(db, stage); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s \t %d\n", string(stage), progress)
+	}
+	// third-party stages (plugins) keep their progress in the same bucket
+	var extra []stages.SyncStage
+	if err = db.Walk(dbutils.SyncStageProgress, nil, 0, func(k, _ []byte) (bool, error) {
+		if _, ok := known[string(k)]; !ok {
+			extra = append(extra, common.CopyBytes(k))
+		}
+		return true, nil
+	}); err != nil {
+		return err
+	}
+	for _, stage := range extra {
 		if progress, _, err = stages.GetStageProgress(db, stage); err != nil {
 			return err
 		}
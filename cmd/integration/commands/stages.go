@@ -4,12 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"os"
 	"path"
 	"runtime"
 	"sort"
 	"strings"
-	"sync"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/VictoriaMetrics/fastcache"
@@ -18,11 +19,11 @@ import (
 	grpc_recovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/ledgerwatch/turbo-geth/cmd/utils"
+	"github.com/ledgerwatch/turbo-geth/common"
 	"github.com/ledgerwatch/turbo-geth/common/dbutils"
 	"github.com/ledgerwatch/turbo-geth/common/etl"
 	"github.com/ledgerwatch/turbo-geth/consensus/ethash"
 	"github.com/ledgerwatch/turbo-geth/core"
-	"github.com/ledgerwatch/turbo-geth/core/rawdb"
 	"github.com/ledgerwatch/turbo-geth/core/state"
 	"github.com/ledgerwatch/turbo-geth/core/vm"
 	"github.com/ledgerwatch/turbo-geth/eth/stagedsync"
@@ -203,7 +204,7 @@ var cmdRemoveMigration = &cobra.Command{
 	Short: "",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := utils.RootContext()
-		db := openDatabase(chaindata, false)
+		db := ethdb.NewObjectDatabase(openKV(chaindata, true))
 		defer db.Close()
 		if err := removeMigration(db, ctx); err != nil {
 			log.Error("Error", "err", err)
@@ -217,9 +218,13 @@ var cmdRunMigrations = &cobra.Command{
 	Use:   "run_migrations",
 	Short: "",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		db := openDatabase(chaindata, false)
+		ctx := utils.RootContext()
+		db := ethdb.NewObjectDatabase(openKV(chaindata, true))
 		defer db.Close()
-		// Nothing to do, migrations will be applied automatically
+		if err := runMigrations(db, ctx); err != nil {
+			log.Error("Error", "err", err)
+			return err
+		}
 		return nil
 	},
 }
@@ -257,6 +262,8 @@ func init() {
 	withBlock(cmdStageExec)
 	withUnwind(cmdStageExec)
 	withBatchSize(cmdStageExec)
+	withCacheDir(cmdStageExec)
+	withShard(cmdStageExec)
 
 	rootCmd.AddCommand(cmdStageExec)
 
@@ -321,11 +328,13 @@ func init() {
 	withChaindata(cmdRemoveMigration)
 	withLmdbFlags(cmdRemoveMigration)
 	withMigration(cmdRemoveMigration)
+	withDatadir(cmdRemoveMigration)
 	rootCmd.AddCommand(cmdRemoveMigration)
 
 	withChaindata(cmdRunMigrations)
 	withLmdbFlags(cmdRunMigrations)
 	withDatadir(cmdRunMigrations)
+	withMigrationsPlan(cmdRunMigrations)
 	rootCmd.AddCommand(cmdRunMigrations)
 
 	withDispatcher(cmdShardDispatcher)
@@ -374,7 +383,7 @@ func stageExec(db ethdb.Database, ctx context.Context) error {
 		panic(err)
 	}
 
-	cc, bc, _, progress := newSync(ctx.Done(), db, db, nil)
+	cc, bc, st, progress := newSync(ctx.Done(), db, db, nil)
 	defer bc.Stop()
 
 	if reset { //nolint:staticcheck
@@ -390,17 +399,53 @@ func stageExec(db ethdb.Database, ctx context.Context) error {
 	}
 	var batchSize datasize.ByteSize
 	must(batchSize.UnmarshalText([]byte(batchSizeStr)))
+	params := stagedsync.ExecuteBlockStageParams{
+		ToBlock:       block, // limit execution to the specified block
+		WriteReceipts: sm.Receipts,
+		BatchSize:     int(batchSize),
+		CacheDir:      cacheDir,
+	}
+	if dispatcherAddr != "" {
+		executeBlocks, err := shards.NewExecutor(dispatcherAddr, shardBits, shardID)
+		if err != nil {
+			return err
+		}
+		// the unwind to the progress of other shards is applied by the next run of the sync
+		return executeBlocks(stage4, st, db, bc.Config(), cc, bc.GetVMConfig(), ch, params)
+	}
 	return stagedsync.SpawnExecuteBlocksStage(stage4, db,
 		bc.Config(), cc, bc.GetVMConfig(),
 		ch,
-		stagedsync.ExecuteBlockStageParams{
-			ToBlock:       block, // limit execution to the specified block
-			WriteReceipts: sm.Receipts,
-			BatchSize:     int(batchSize),
-		})
+		params)
 
 }
 
+// startDispatch connects to the shard dispatcher
+func startDispatch(ctx context.Context) (shards.Dispatcher_StartDispatchClient, error) {
+	// CREATING GRPC CLIENT CONNECTION
+	var dialOpts []grpc.DialOption
+	dialOpts = []grpc.DialOption{
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: backoff.DefaultConfig, MinConnectTimeout: 10 * time.Minute}),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(int(5 * datasize.MB))),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Timeout: 10 * time.Minute,
+		}),
+	}
+
+	dialOpts = append(dialOpts, grpc.WithInsecure())
+
+	conn, err := grpc.DialContext(ctx, dispatcherAddr, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating client connection to shard dispatcher: %w", err)
+	}
+	dispatcherClient := shards.NewDispatcherClient(conn)
+	client, err := dispatcherClient.StartDispatch(ctx, &grpc.EmptyCallOption{})
+	if err != nil {
+		return nil, fmt.Errorf("starting shard dispatch: %w", err)
+	}
+	return client, nil
+}
+
 func stageIHash(db ethdb.Database, ctx context.Context) error {
 	core.UsePlainStateExecution = true
 	tmpdir := path.Join(datadir, etl.TmpDirName)
@@ -530,27 +575,9 @@ func stageCallTraces(db ethdb.Database, ctx context.Context) error {
 	var accessBuilder stagedsync.StateAccessBuilder
 	var toBlock uint64
 	if dispatcherAddr != "" {
-		// CREATING GRPC CLIENT CONNECTION
-		var dialOpts []grpc.DialOption
-		dialOpts = []grpc.DialOption{
-			grpc.WithConnectParams(grpc.ConnectParams{Backoff: backoff.DefaultConfig, MinConnectTimeout: 10 * time.Minute}),
-			grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(int(5 * datasize.MB))),
-			grpc.WithKeepaliveParams(keepalive.ClientParameters{
-				Timeout: 10 * time.Minute,
-			}),
-		}
-
-		dialOpts = append(dialOpts, grpc.WithInsecure())
-
-		conn, err := grpc.DialContext(ctx, dispatcherAddr, dialOpts...)
-		if err != nil {
-			return fmt.Errorf("creating client connection to shard dispatcher: %w", err)
-		}
-		dispatcherClient := shards.NewDispatcherClient(conn)
-		var client shards.Dispatcher_StartDispatchClient
-		client, err = dispatcherClient.StartDispatch(ctx, &grpc.EmptyCallOption{})
+		client, err := startDispatch(ctx)
 		if err != nil {
-			return fmt.Errorf("starting shard dispatch: %w", err)
+			return err
 		}
 		accessBuilder = func(db ethdb.Database, blockNumber uint64, accountCache, storageCache, codeCache, codeSizeCache *fastcache.Cache) (state.StateReader, state.WriterWithChangeSets) {
 			shard := shards.NewShard(db.(ethdb.HasTx).Tx(), blockNumber, client, accountCache, storageCache, codeCache, codeSizeCache, shardBits, byte(shardID))
@@ -640,7 +667,7 @@ func stageTxLookup(db ethdb.Database, ctx context.Context) error {
 	return stagedsync.SpawnTxLookup(stage9, db, tmpdir, ch)
 }
 
-func printAllStages(db rawdb.DatabaseReader, _ context.Context) error {
+func printAllStages(db ethdb.Getter, _ context.Context) error {
 	return printStages(db)
 }
 
@@ -660,13 +687,44 @@ func printAppliedMigrations(db ethdb.Database, _ context.Context) error {
 	return nil
 }
 
-func removeMigration(db rawdb.DatabaseDeleter, _ context.Context) error {
+func removeMigration(db ethdb.Database, _ context.Context) error {
+	if migrationRevert {
+		return migrations.NewMigrator().Revert(db, migration, path.Join(datadir, etl.TmpDirName))
+	}
 	if err := db.Delete(dbutils.Migrations, []byte(migration), nil); err != nil {
 		return err
 	}
 	return nil
 }
 
+func runMigrations(db ethdb.Database, _ context.Context) error {
+	migrator := migrations.NewMigrator()
+	migrator.Backup = migrationBackup
+	if !migrationDryRun {
+		return migrator.Apply(db, path.Join(datadir, etl.TmpDirName))
+	}
+
+	plans, err := migrator.DryRun(db)
+	if err != nil {
+		return err
+	}
+	w := new(tabwriter.Writer)
+	defer w.Flush()
+	w.Init(os.Stdout, 8, 8, 0, '\t', 0)
+	var total uint64
+	for _, plan := range plans {
+		fmt.Fprintf(w, "%s \t reversible: %t\n", plan.Name, plan.Reversible)
+		for i, bucket := range plan.Buckets {
+			fmt.Fprintf(w, " \t %s \t %s\n", bucket, common.StorageSize(plan.Sizes[i]))
+			total += plan.Sizes[i]
+		}
+	}
+	if migrationBackup {
+		fmt.Fprintf(w, "backup \t \t %s\n", common.StorageSize(total))
+	}
+	return nil
+}
+
 func shardDispatcher(ctx context.Context) error {
 	// STARTING GRPC SERVER
 	log.Info("Starting Shard Dispatcher", "on", dispatcherAddr)
@@ -704,7 +762,7 @@ func shardDispatcher(ctx context.Context) error {
 		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(unaryInterceptors...)),
 	}
 	grpcServer = grpc.NewServer(opts...)
-	dispatcherServer := NewDispatcherServerImpl(1 << shardBits)
+	dispatcherServer := shards.NewDispatcher(1<<shardBits, time.Duration(dispatcherLatency)*time.Millisecond)
 	shards.RegisterDispatcherServer(grpcServer, dispatcherServer)
 	if metrics.Enabled {
 		grpc_prometheus.Register(grpcServer)
@@ -719,70 +777,6 @@ func shardDispatcher(ctx context.Context) error {
 	return nil
 }
 
-type DispatcherServerImpl struct {
-	shards.UnimplementedDispatcherServer
-	connMutex           sync.RWMutex
-	expectedConnections int
-	connections         map[int]shards.Dispatcher_StartDispatchServer
-	nextConnID          int
-}
-
-func NewDispatcherServerImpl(expectedConnections int) *DispatcherServerImpl {
-	return &DispatcherServerImpl{
-		expectedConnections: expectedConnections,
-		connections:         make(map[int]shards.Dispatcher_StartDispatchServer),
-	}
-}
-
-func (ds *DispatcherServerImpl) addConnection(connection shards.Dispatcher_StartDispatchServer) int {
-	ds.connMutex.Lock()
-	defer ds.connMutex.Unlock()
-	connID := ds.nextConnID
-	ds.nextConnID++
-	ds.connections[connID] = connection
-	return connID
-}
-
-func (ds *DispatcherServerImpl) removeConnection(connID int) {
-	ds.connMutex.Lock()
-	defer ds.connMutex.Unlock()
-	delete(ds.connections, connID)
-}
-
-func (ds *DispatcherServerImpl) makeBroadcastList(connID int) []shards.Dispatcher_StartDispatchServer {
-	ds.connMutex.RLock()
-	defer ds.connMutex.RUnlock()
-	var list []shards.Dispatcher_StartDispatchServer
-	for id, conn := range ds.connections {
-		if id != connID {
-			list = append(list, conn)
-		}
-	}
-	return list
-}
-
-func (ds *DispatcherServerImpl) StartDispatch(connection shards.Dispatcher_StartDispatchServer) error {
-	connID := ds.addConnection(connection)
-	defer ds.removeConnection(connID)
-	for stateRead, recvErr := connection.Recv(); recvErr == nil; stateRead, recvErr = connection.Recv() {
-		// Get list of connections to broadcast to
-		var broadcastList []shards.Dispatcher_StartDispatchServer
-		for broadcastList = ds.makeBroadcastList(connID); len(broadcastList) < ds.expectedConnections-1; broadcastList = ds.makeBroadcastList(connID) {
-			log.Info("Waiting for more connections before broadcasting", "connections left", ds.expectedConnections-len(broadcastList)-1)
-			time.Sleep(5 * time.Second)
-		}
-		if dispatcherLatency > 0 {
-			time.Sleep(time.Duration(dispatcherLatency) * time.Millisecond)
-		}
-		for _, conn := range broadcastList {
-			if err := conn.Send(stateRead); err != nil {
-				return fmt.Errorf("could not send broadcas for connection id %d: %w", connID, err)
-			}
-		}
-	}
-	return nil
-}
-
 type progressFunc func(stage stages.SyncStage) *stagedsync.StageState
 
 func newSync(quitCh <-chan struct{}, db ethdb.Database, tx ethdb.Database, hook stagedsync.ChangeSetHook) (*core.TinyChainContext, *core.BlockChain, *stagedsync.State, progressFunc) {
@@ -804,7 +798,7 @@ func newSync(quitCh <-chan struct{}, db ethdb.Database, tx ethdb.Database, hook
 		stagedsync.DefaultStages(),
 		stagedsync.DefaultUnwindOrder(),
 		stagedsync.OptionalParameters{},
-	).Prepare(nil, chainConfig, cc, bc.GetVMConfig(), db, tx, "integration_test", sm, path.Join(datadir, etl.TmpDirName), int(batchSize), quitCh, nil, nil, func() error { return nil }, hook)
+	).Prepare(nil, chainConfig, cc, bc.GetVMConfig(), db, tx, "integration_test", sm, path.Join(datadir, etl.TmpDirName), int(batchSize), "", quitCh, nil, nil, func() error { return nil }, hook, nil)
 	if err != nil {
 		panic(err)
 	}
@@ -6,6 +6,7 @@ import (
 	"math/big"
 
 	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/internal/ethapi"
 	"github.com/ledgerwatch/turbo-geth/turbo/adapter"
 	"github.com/ledgerwatch/turbo-geth/turbo/rpchelper"
 
@@ -109,3 +110,19 @@ func (api *APIImpl) GetStorageAt(ctx context.Context, address common.Address, in
 	}
 	return hexutil.Encode(common.LeftPadBytes(res[:], 32)), err
 }
+
+// GetProof implements eth_getProof. Returns the account and storage values of the specified account including the Merkle-proof (EIP-1186).
+func (api *APIImpl) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*ethapi.AccountResult, error) {
+	blockNumber, _, err := rpchelper.GetBlockNumber(blockNrOrHash, api.dbReader)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err1 := api.dbReader.Begin(ctx, ethdb.RO)
+	if err1 != nil {
+		return nil, fmt.Errorf("getProof cannot open tx: %v", err1)
+	}
+	defer tx.Rollback()
+
+	return ethapi.GetProof(tx, address, storageKeys, blockNumber)
+}
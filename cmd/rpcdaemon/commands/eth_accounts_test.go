@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/hexutil"
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/crypto"
+	"github.com/ledgerwatch/turbo-geth/internal/ethapi"
+	"github.com/ledgerwatch/turbo-geth/rlp"
+	"github.com/ledgerwatch/turbo-geth/rpc"
+	"github.com/ledgerwatch/turbo-geth/turbo/trie"
+	"github.com/stretchr/testify/require"
+)
+
+// verifyProof checks that the proof is the path from the root to the leaf, and returns the value of the leaf
+func verifyProof(t *testing.T, root common.Hash, proof []string) []byte {
+	require.NotEmpty(t, proof)
+	nodes := make([][]byte, len(proof))
+	for i, p := range proof {
+		nodes[i] = hexutil.MustDecode(p)
+	}
+	require.Equal(t, root, crypto.Keccak256Hash(nodes[0]), "root")
+	for i := 1; i < len(nodes); i++ {
+		// the nodes shorter than the hash are embedded into the parent
+		ref := nodes[i]
+		if len(ref) >= common.HashLength {
+			ref = crypto.Keccak256(ref)
+		}
+		require.Contains(t, string(nodes[i-1]), string(ref), "node %d is not referenced by its parent", i)
+	}
+	var leaf [][]byte
+	require.NoError(t, rlp.DecodeBytes(nodes[len(nodes)-1], &leaf))
+	require.Len(t, leaf, 2, "last node is not a leaf")
+	return leaf[1]
+}
+
+func verifyAccountProof(t *testing.T, header *types.Header, result *ethapi.AccountResult) {
+	var acc struct {
+		Nonce    uint64
+		Balance  *big.Int
+		Root     common.Hash
+		CodeHash common.Hash
+	}
+	require.NoError(t, rlp.DecodeBytes(verifyProof(t, header.Root, result.AccountProof), &acc))
+	require.Equal(t, uint64(result.Nonce), acc.Nonce)
+	require.Equal(t, result.Balance.ToInt().String(), acc.Balance.String())
+	require.Equal(t, result.StorageHash, acc.Root)
+	require.Equal(t, result.CodeHash, acc.CodeHash)
+}
+
+func TestGetProof(t *testing.T) {
+	db, blocks, bank, contract := createTraceTestChain(t)
+	defer db.Close()
+	api := NewEthAPI(db.KV(), db, nil, nil, 5000000)
+	defer api.pollingFilters.Stop()
+	slot := common.Hash{}.Hex()
+
+	// the latest block: the slot is set by the call of the contract
+	result, err := api.GetProof(context.Background(), contract, []string{slot}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	require.NoError(t, err)
+	verifyAccountProof(t, blocks[1].Header(), result)
+	require.Equal(t, contract, result.Address)
+	require.Equal(t, "10", result.Balance.ToInt().String())
+	require.NotEqual(t, trie.EmptyRoot, result.StorageHash)
+	require.Len(t, result.StorageProof, 1)
+	require.Equal(t, slot, result.StorageProof[0].Key)
+	require.Equal(t, "7", result.StorageProof[0].Value.ToInt().String())
+	var value []byte
+	require.NoError(t, rlp.DecodeBytes(verifyProof(t, result.StorageHash, result.StorageProof[0].Proof), &value))
+	require.Equal(t, []byte{7}, value)
+
+	result, err = api.GetProof(context.Background(), bank, nil, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	require.NoError(t, err)
+	verifyAccountProof(t, blocks[1].Header(), result)
+	require.Equal(t, uint64(3), uint64(result.Nonce))
+	require.Equal(t, trie.EmptyRoot, result.StorageHash)
+
+	// the historical block: the contract is deployed, its storage is empty
+	result, err = api.GetProof(context.Background(), contract, []string{slot}, rpc.BlockNumberOrHashWithNumber(1))
+	require.NoError(t, err)
+	verifyAccountProof(t, blocks[0].Header(), result)
+	require.Equal(t, "0", result.Balance.ToInt().String())
+	require.Equal(t, trie.EmptyRoot, result.StorageHash)
+	require.Equal(t, "0", result.StorageProof[0].Value.ToInt().String())
+
+	result, err = api.GetProof(context.Background(), bank, nil, rpc.BlockNumberOrHashWithNumber(1))
+	require.NoError(t, err)
+	verifyAccountProof(t, blocks[0].Header(), result)
+	require.Equal(t, uint64(1), uint64(result.Nonce))
+}
@@ -4,12 +4,12 @@ import (
 	"context"
 	"math/big"
 
-	"github.com/ledgerwatch/turbo-geth/eth/filters"
-
+	"github.com/ledgerwatch/turbo-geth/cmd/rpcdaemon/filters"
 	"github.com/ledgerwatch/turbo-geth/common"
 	"github.com/ledgerwatch/turbo-geth/common/hexutil"
 	"github.com/ledgerwatch/turbo-geth/core"
 	"github.com/ledgerwatch/turbo-geth/core/types"
+	ethFilters "github.com/ledgerwatch/turbo-geth/eth/filters"
 	"github.com/ledgerwatch/turbo-geth/ethdb"
 	"github.com/ledgerwatch/turbo-geth/internal/ethapi"
 	"github.com/ledgerwatch/turbo-geth/rpc"
@@ -30,7 +30,7 @@ type EthAPI interface {
 
 	// Receipt related (see ./eth_receipts.go)
 	GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error)
-	GetLogs(ctx context.Context, crit filters.FilterCriteria) ([]*types.Log, error)
+	GetLogs(ctx context.Context, crit ethFilters.FilterCriteria) ([]*types.Log, error)
 
 	// Uncle related (see ./eth_uncles.go)
 	GetUncleByBlockNumberAndIndex(ctx context.Context, blockNr rpc.BlockNumber, index hexutil.Uint) (map[string]interface{}, error)
@@ -39,11 +39,15 @@ type EthAPI interface {
 	GetUncleCountByBlockHash(ctx context.Context, hash common.Hash) (*hexutil.Uint, error)
 
 	// Filter related (see ./eth_filters.go)
-	// newPendingTransactionFilter(ctx context.Context) (string, error)
-	// newBlockFilter(ctx context.Context) (string, error)
-	// newFilter(ctx context.Context) (string, error)
-	// uninstallFilter(ctx context.Context) (string, error)
-	// getFilterChanges(ctx context.Context) (string, error)
+	NewPendingTransactionFilter(_ context.Context) (rpc.ID, error)
+	NewBlockFilter(_ context.Context) (rpc.ID, error)
+	NewFilter(_ context.Context, crit ethFilters.FilterCriteria) (rpc.ID, error)
+	UninstallFilter(_ context.Context, id rpc.ID) (bool, error)
+	GetFilterChanges(ctx context.Context, id rpc.ID) (interface{}, error)
+	GetFilterLogs(ctx context.Context, id rpc.ID) ([]*types.Log, error)
+	NewHeads(ctx context.Context) (*rpc.Subscription, error)
+	NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error)
+	Logs(ctx context.Context, crit ethFilters.FilterCriteria) (*rpc.Subscription, error)
 
 	// Account related (see ./eth_accounts.go)
 	Accounts(ctx context.Context) ([]common.Address, error)
@@ -51,6 +55,7 @@ type EthAPI interface {
 	GetTransactionCount(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Uint64, error)
 	GetStorageAt(ctx context.Context, address common.Address, index string, blockNrOrHash rpc.BlockNumberOrHash) (string, error)
 	GetCode(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error)
+	GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*ethapi.AccountResult, error)
 
 	// System related (see ./eth_system.go)
 	BlockNumber(ctx context.Context) (hexutil.Uint64, error)
@@ -88,15 +93,21 @@ type APIImpl struct {
 	dbReader     ethdb.Database
 	chainContext core.ChainContext
 	GasCap       uint64
+	filters      *filters.Filters
+
+	pollingFilters *filters.PollingFilters
 }
 
 // NewEthAPI returns APIImpl instance
-func NewEthAPI(db ethdb.KV, dbReader ethdb.Database, eth ethdb.Backend, gascap uint64) *APIImpl {
+func NewEthAPI(db ethdb.KV, dbReader ethdb.Database, eth ethdb.Backend, ff *filters.Filters, gascap uint64) *APIImpl {
 	return &APIImpl{
 		db:         db,
 		dbReader:   dbReader,
 		ethBackend: eth,
 		GasCap:     gascap,
+		filters:    ff,
+
+		pollingFilters: filters.NewPollingFilters(filterTimeout),
 	}
 }
 
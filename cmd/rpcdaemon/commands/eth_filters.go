@@ -1,16 +1,73 @@
 package commands
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	rpcfilters "github.com/ledgerwatch/turbo-geth/cmd/rpcdaemon/filters"
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/eth/filters"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+	"github.com/ledgerwatch/turbo-geth/rpc"
+)
+
+// filterTimeout is the time after which a polling filter which is not polled gets uninstalled
+const filterTimeout = 5 * time.Minute
+
+var errFilterNotFound = errors.New("filter not found")
+
 // NewPendingTransactionFilter implements eth_newPendingTransactionFilter. Creates a pending transaction filter in the node. To check if the state has changed, call eth_getFilterChanges.
 // Parameters:
 //   None
 // Returns:
 //   QUANTITY - A filter id
+func (api *APIImpl) NewPendingTransactionFilter(_ context.Context) (rpc.ID, error) {
+	if api.filters == nil {
+		return "", rpc.ErrNotificationsUnsupported
+	}
+	id, f := api.pollingFilters.Install(rpcfilters.PendingTxsFilter, filters.FilterCriteria{}, 0)
+
+	go func() {
+		txsHashes := make(chan []common.Hash, 1)
+		subID := api.filters.SubscribePendingTxs(txsHashes)
+		defer api.filters.UnsubscribePendingTxs(subID)
+
+		for {
+			select {
+			case hashes, ok := <-txsHashes:
+				if !ok { // the filter is not polled fast enough to keep up with the transactions
+					api.pollingFilters.Uninstall(id)
+					return
+				}
+				api.pollingFilters.AddPendingTxs(f, hashes)
+			case <-f.Done():
+				return
+			}
+		}
+	}()
+
+	return id, nil
+}
 
 // NewBlockFilter implements eth_newBlockFilter. Creates a block filter in the node, to notify when a new block arrives. To check if the state has changed, call eth_getFilterChanges.
 // Parameters:
 //   None
 // Returns:
 //   QUANTITY - A filter id
+func (api *APIImpl) NewBlockFilter(_ context.Context) (rpc.ID, error) {
+	latest, err := getLatestBlockNumber(api.dbReader)
+	if err != nil {
+		return "", err
+	}
+	id, _ := api.pollingFilters.Install(rpcfilters.BlocksFilter, filters.FilterCriteria{}, latest)
+	return id, nil
+}
 
 // NewFilter implements eth_newFilter. Creates an arbitrary filter object, based on filter options, to notify when the state changes (logs). To check if the state has changed, call eth_getFilterChanges.
 // Parameters:
@@ -22,6 +79,17 @@ package commands
 //   topics: Array of DATA, - (optional) Array of 32 Bytes DATA topics. Topics are order-dependent. Each topic can also be an array of DATA with 'or' options
 // Returns:
 //   QUANTITY - A filter id
+func (api *APIImpl) NewFilter(_ context.Context, crit filters.FilterCriteria) (rpc.ID, error) {
+	if crit.BlockHash != nil {
+		return "", fmt.Errorf("blockHash is not supported by eth_newFilter, use eth_getLogs instead")
+	}
+	latest, err := getLatestBlockNumber(api.dbReader)
+	if err != nil {
+		return "", err
+	}
+	id, _ := api.pollingFilters.Install(rpcfilters.LogsFilter, crit, latest)
+	return id, nil
+}
 
 // UninstallFilter implements eth_uninstallFilter. Uninstalls a previously-created filter given the filter's id. Always uninstall filters when no longer needed.
 // Note: Filters timeout when they are not requested with eth_getFilterChanges for a period of time.
@@ -29,9 +97,219 @@ package commands
 //   QUANTITY - The filter id
 // Returns:
 //   Boolean - true if the filter was successfully uninstalled, false otherwise
+func (api *APIImpl) UninstallFilter(_ context.Context, id rpc.ID) (bool, error) {
+	return api.pollingFilters.Uninstall(id), nil
+}
 
 // GetFilterChanges implements eth_getFilterChanges. Polling method for a previously-created filter, which returns an array of logs which occurred since last poll.
 // Parameters:
 //   QUANTITY - The filter id
 // Returns:
 //   Array - Array of log objects, or an empty array if nothing has changed since last poll
+//   For block and pending transaction filters - Array of block or transaction hashes
+func (api *APIImpl) GetFilterChanges(ctx context.Context, id rpc.ID) (interface{}, error) {
+	f, ok := api.pollingFilters.Poll(id)
+	if !ok {
+		return nil, errFilterNotFound
+	}
+	f.Lock()
+	defer f.Unlock()
+
+	if f.Type == rpcfilters.PendingTxsFilter {
+		return returnHashes(api.pollingFilters.TakePendingTxs(f)), nil
+	}
+
+	tx, err := api.dbReader.Begin(ctx, ethdb.RO)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	latest, err := getLatestBlockNumber(tx)
+	if err != nil {
+		return nil, err
+	}
+	begin := f.LastBlock + 1
+	// after an unwind the blocks above latest are not canonical anymore, they have never been reported to the client
+	f.LastBlock = latest
+
+	switch f.Type {
+	case rpcfilters.BlocksFilter:
+		var hashes []common.Hash
+		for n := begin; n <= latest; n++ {
+			hash, err := rawdb.ReadCanonicalHash(tx, n)
+			if err != nil {
+				return nil, err
+			}
+			hashes = append(hashes, hash)
+		}
+		return returnHashes(hashes), nil
+	default:
+		end := latest
+		if from := f.Crit.FromBlock; from != nil && from.Sign() >= 0 && from.Uint64() > begin {
+			begin = from.Uint64()
+		}
+		if to := f.Crit.ToBlock; to != nil && to.Sign() >= 0 && to.Uint64() < end {
+			end = to.Uint64()
+		}
+		if begin > end {
+			return returnLogs(nil), nil
+		}
+		crit := f.Crit
+		crit.FromBlock = new(big.Int).SetUint64(begin)
+		crit.ToBlock = new(big.Int).SetUint64(end)
+		return api.GetLogs(ctx, crit)
+	}
+}
+
+// GetFilterLogs implements eth_getFilterLogs. Returns an array of all logs matching the filter with the given id.
+// Parameters:
+//   QUANTITY - The filter id
+// Returns:
+//   Array - Array of log objects
+func (api *APIImpl) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*types.Log, error) {
+	f, ok := api.pollingFilters.Poll(id)
+	if !ok || f.Type != rpcfilters.LogsFilter {
+		return nil, errFilterNotFound
+	}
+	crit := f.Crit
+	// 'latest' and 'pending' tags are negative numbers, eth_getLogs treats missing bounds as latest
+	if crit.FromBlock != nil && crit.FromBlock.Sign() < 0 {
+		crit.FromBlock = nil
+	}
+	if crit.ToBlock != nil && crit.ToBlock.Sign() < 0 {
+		crit.ToBlock = nil
+	}
+	return api.GetLogs(ctx, crit)
+}
+
+func returnHashes(hashes []common.Hash) []common.Hash {
+	if hashes == nil {
+		return []common.Hash{}
+	}
+	return hashes
+}
+
+// NewHeads implements eth_subscribe("newHeads"). Sends a notification each time a new header is appended to the chain.
+// Parameters:
+//   None
+// Returns:
+//   Subscription - the subscription id, the notifications contain header objects
+func (api *APIImpl) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	if api.filters == nil {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		headers := make(chan *types.Header, 1)
+		id := api.filters.SubscribeNewHeads(headers)
+		defer api.filters.UnsubscribeHeads(id)
+
+		for {
+			select {
+			case h, ok := <-headers:
+				if !ok {
+					log.Warn("newHeads subscription is closed, the client doesn't keep up with the notifications", "id", rpcSub.ID)
+					return
+				}
+				if err := notifier.Notify(rpcSub.ID, h); err != nil {
+					log.Warn("error while notifying subscription", "err", err)
+				}
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// NewPendingTransactions implements eth_subscribe("newPendingTransactions"). Sends a notification each time a transaction is added to the transaction pool of the node.
+// Parameters:
+//   None
+// Returns:
+//   Subscription - the subscription id, the notifications contain transaction hashes
+func (api *APIImpl) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	if api.filters == nil {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		txsHashes := make(chan []common.Hash, 1)
+		id := api.filters.SubscribePendingTxs(txsHashes)
+		defer api.filters.UnsubscribePendingTxs(id)
+
+		for {
+			select {
+			case hashes, ok := <-txsHashes:
+				if !ok {
+					log.Warn("newPendingTransactions subscription is closed, the client doesn't keep up with the notifications", "id", rpcSub.ID)
+					return
+				}
+				for _, h := range hashes {
+					if err := notifier.Notify(rpcSub.ID, h); err != nil {
+						log.Warn("error while notifying subscription", "err", err)
+					}
+				}
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// Logs implements eth_subscribe("logs"). Sends a notification for each log of the newly appended blocks which matches the filter criteria.
+// Parameters:
+//   Object - The filter options, only address and topics are taken into account
+// Returns:
+//   Subscription - the subscription id, the notifications contain log objects
+func (api *APIImpl) Logs(ctx context.Context, crit filters.FilterCriteria) (*rpc.Subscription, error) {
+	if api.filters == nil {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		logs := make(chan types.Logs, 1)
+		id := api.filters.SubscribeLogs(logs, crit)
+		defer api.filters.UnsubscribeLogs(id)
+
+		for {
+			select {
+			case matched, ok := <-logs:
+				if !ok {
+					log.Warn("logs subscription is closed, the client doesn't keep up with the notifications", "id", rpcSub.ID)
+					return
+				}
+				for _, l := range matched {
+					if err := notifier.Notify(rpcSub.ID, l); err != nil {
+						log.Warn("error while notifying subscription", "err", err)
+					}
+				}
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
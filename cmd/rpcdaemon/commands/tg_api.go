@@ -4,8 +4,10 @@ import (
 	"context"
 
 	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/hexutil"
 	"github.com/ledgerwatch/turbo-geth/core/types"
 	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/internal/ethapi"
 	"github.com/ledgerwatch/turbo-geth/rpc"
 )
 
@@ -26,6 +28,12 @@ type TgAPI interface {
 	// BlockReward(ctx context.Context, blockNr rpc.BlockNumber) (Issuance, error)
 	// UncleReward(ctx context.Context, blockNr rpc.BlockNumber) (Issuance, error)
 	Issuance(ctx context.Context, blockNr rpc.BlockNumber) (Issuance, error)
+
+	// Witness related (see ./tg_witness.go)
+	GetBlockWitness(ctx context.Context, blockNr rpc.BlockNumber) (hexutil.Bytes, error)
+
+	// Binary trie related (see ./tg_binary_proof.go)
+	GetBinaryProof(ctx context.Context, address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*ethapi.AccountResult, error)
 }
 
 // TgImpl is implementation of the TgAPI interface
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/internal/ethapi"
+	"github.com/ledgerwatch/turbo-geth/rpc"
+)
+
+// GetBinaryProof implements tg_getBinaryProof. Returns the account and storage values of the specified account including
+// the merkle proofs in the binary trie commitment of the state, which is maintained by the node with `b` in --storage-mode.
+// Only the block the binary trie has been built for can be requested.
+func (api *TgImpl) GetBinaryProof(ctx context.Context, address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*ethapi.AccountResult, error) {
+	tx, err := api.dbReader.Begin(ctx, ethdb.RO)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	blockNumber, err := getBlockNumber(blockNr, tx)
+	if err != nil {
+		return nil, err
+	}
+	builtAt, _, err := stages.GetStageProgress(tx, stages.BinaryHashes)
+	if err != nil {
+		return nil, err
+	}
+	if blockNumber != builtAt {
+		return nil, fmt.Errorf("binary trie is only available for block %d, the node builds it with `b` in --storage-mode", builtAt)
+	}
+	return ethapi.GetBinaryProof(tx, address, storageKeys)
+}
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/turbo-geth/common/hexutil"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/rpc"
+)
+
+// GetBlockWitness implements tg_getBlockWitness. Returns the serialized witness of the block, which is enough to
+// re-execute the block with state.Stateless. Witnesses are built by the node with `w` in --storage-mode.
+func (api *TgImpl) GetBlockWitness(ctx context.Context, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
+	tx, err := api.dbReader.Begin(ctx, ethdb.RO)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	blockNumber, err := getBlockNumber(blockNr, tx)
+	if err != nil {
+		return nil, err
+	}
+	witness, err := rawdb.ReadBlockWitness(tx, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if witness == nil {
+		return nil, fmt.Errorf("witness of block %d not found, the node builds witnesses with `w` in --storage-mode", blockNumber)
+	}
+	return witness, nil
+}
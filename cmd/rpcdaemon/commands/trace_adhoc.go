@@ -2,48 +2,692 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math/big"
+	"strings"
+	"time"
 
+	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/hexutil"
+	"github.com/ledgerwatch/turbo-geth/core"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/core/state"
 	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/core/types/accounts"
+	"github.com/ledgerwatch/turbo-geth/core/vm"
+	"github.com/ledgerwatch/turbo-geth/core/vm/stack"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/internal/ethapi"
+	"github.com/ledgerwatch/turbo-geth/params"
+	"github.com/ledgerwatch/turbo-geth/rlp"
 	"github.com/ledgerwatch/turbo-geth/rpc"
+	"github.com/ledgerwatch/turbo-geth/turbo/adapter"
+	"github.com/ledgerwatch/turbo-geth/turbo/transactions"
 )
 
-// CallParam a parameter for a trace_callMany routine
+const callTimeout = 5 * time.Minute
+
+const (
+	TraceTypeTrace     = "trace"
+	TraceTypeStateDiff = "stateDiff"
+	TraceTypeVmTrace   = "vmTrace"
+)
+
+// CallParam a parameter for a trace_callMany routine: a pair of the call object and the requested trace types
 type CallParam struct {
-	_ types.Transaction
-	_ []string
+	Call       ethapi.CallArgs
+	TraceTypes []string
 }
 
 // CallParams array of callMany structs
 type CallParams []CallParam
 
-// Call implements trace_call.
-func (api *TraceAPIImpl) Call(ctx context.Context, call CallParam, blockNr rpc.BlockNumber) ([]interface{}, error) {
-	var stub []interface{}
-	return stub, fmt.Errorf(NotImplemented, "trace_call")
+// UnmarshalJSON decodes the [callObject, traceTypes] pair
+func (cp *CallParam) UnmarshalJSON(input []byte) error {
+	var pair []json.RawMessage
+	if err := json.Unmarshal(input, &pair); err != nil {
+		return err
+	}
+	if len(pair) != 2 {
+		return fmt.Errorf("expected [callObject, traceTypes] pair, got %d elements", len(pair))
+	}
+	if err := json.Unmarshal(pair[0], &cp.Call); err != nil {
+		return err
+	}
+	return json.Unmarshal(pair[1], &cp.TraceTypes)
+}
+
+// traceTypesFlags parses the list of requested trace types of the ad-hoc tracing methods
+func traceTypesFlags(traceTypes []string) (traceCalls, stateDiff, traceVm bool, err error) {
+	for _, traceType := range traceTypes {
+		switch traceType {
+		case TraceTypeTrace:
+			traceCalls = true
+		case TraceTypeStateDiff:
+			stateDiff = true
+		case TraceTypeVmTrace:
+			traceVm = true
+		default:
+			return false, false, false, fmt.Errorf("unrecognized trace type: %s", traceType)
+		}
+	}
+	return traceCalls, stateDiff, traceVm, nil
+}
+
+// OeTracer is a vm.Tracer which produces the OpenEthereum (Parity) style "trace" and "vmTrace" outputs
+type OeTracer struct {
+	r           *TraceCallResult
+	ibs         vm.IntraBlockState
+	precompiles map[common.Address]vm.PrecompiledContract
+	traceCalls  bool
+	traceVm     bool
+	traceStack  []*ParityTrace // Stack of call traces as the call depth increases
+	skipStack   []bool         // Whether the frame at the given depth was left out (calls to precompiles)
+	frames      []*vmFrame     // Stack of vmTrace frames as the call depth increases
+	lastOp      vm.OpCode      // Last executed opcode, used to determine the type of the next call
+}
+
+// vmFrame holds the vmTrace of one call frame together with the instruction that is awaiting its effects
+type vmFrame struct {
+	trace    *VmTrace
+	startGas uint64
+	op       vm.OpCode
+	vmOp     *VmTraceOp
+	memOff   uint64
+	memLen   uint64
+	store    *VmTraceStore
+}
+
+func NewOeTracer(r *TraceCallResult, ibs vm.IntraBlockState, rules params.Rules, traceCalls, traceVm bool) *OeTracer {
+	var precompiles map[common.Address]vm.PrecompiledContract
+	switch {
+	case rules.IsYoloV1:
+		precompiles = vm.PrecompiledContractsYoloV1
+	case rules.IsIstanbul:
+		precompiles = vm.PrecompiledContractsIstanbul
+	case rules.IsByzantium:
+		precompiles = vm.PrecompiledContractsByzantium
+	default:
+		precompiles = vm.PrecompiledContractsHomestead
+	}
+	return &OeTracer{
+		r:           r,
+		ibs:         ibs,
+		precompiles: precompiles,
+		traceCalls:  traceCalls,
+		traceVm:     traceVm,
+	}
+}
+
+func (ot *OeTracer) CaptureStart(depth int, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	// Parity does not trace calls into precompiles unless they transfer value
+	if _, isPrecompile := ot.precompiles[to]; isPrecompile && depth > 0 && value.Sign() <= 0 {
+		ot.skipStack = append(ot.skipStack, true)
+		return nil
+	}
+	ot.skipStack = append(ot.skipStack, false)
+	if ot.traceCalls {
+		ot.captureCallStart(depth, from, to, create, input, gas, value)
+	}
+	if ot.traceVm {
+		var code []byte
+		if create {
+			code = input
+		} else {
+			code = ot.ibs.GetCode(to)
+		}
+		vmTrace := &VmTrace{Code: code, Ops: []*VmTraceOp{}}
+		if len(ot.frames) == 0 {
+			ot.r.VmTrace = vmTrace
+		} else if parent := ot.frames[len(ot.frames)-1]; parent.vmOp != nil {
+			parent.vmOp.Sub = vmTrace
+		}
+		ot.frames = append(ot.frames, &vmFrame{trace: vmTrace, startGas: gas})
+	}
+	return nil
+}
+
+func (ot *OeTracer) captureCallStart(depth int, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	trace := &ParityTrace{TraceAddress: []int{}}
+	var parent *ParityTrace
+	if len(ot.traceStack) > 0 {
+		parent = ot.traceStack[len(ot.traceStack)-1]
+		trace.TraceAddress = make([]int, len(parent.TraceAddress)+1)
+		copy(trace.TraceAddress, parent.TraceAddress)
+		trace.TraceAddress[len(parent.TraceAddress)] = parent.Subtraces
+		parent.Subtraces++
+	}
+	trace.Action.From = strings.ToLower(from.Hex())
+	trace.Action.Gas = hexutil.EncodeUint64(gas)
+	if create {
+		trace.Type = "create"
+		trace.Result.Address = strings.ToLower(to.Hex())
+		trace.Action.Init = hexutil.Encode(input)
+		trace.Action.Value = hexutil.EncodeBig(value)
+	} else {
+		trace.Type = "call"
+		trace.Action.To = strings.ToLower(to.Hex())
+		trace.Action.Input = hexutil.Encode(input)
+		trace.Action.CallType = "call"
+		if depth > 0 {
+			switch ot.lastOp {
+			case vm.CALLCODE:
+				trace.Action.CallType = "callcode"
+			case vm.DELEGATECALL:
+				trace.Action.CallType = "delegatecall"
+			case vm.STATICCALL:
+				trace.Action.CallType = "staticcall"
+			}
+		}
+		switch {
+		case trace.Action.CallType == "delegatecall" && parent != nil:
+			// Delegate calls carry the value of the calling context
+			trace.Action.Value = parent.Action.Value
+		case value.Sign() < 0:
+			trace.Action.Value = hexutil.EncodeBig(common.Big0)
+		default:
+			trace.Action.Value = hexutil.EncodeBig(value)
+		}
+	}
+	ot.r.Trace = append(ot.r.Trace, trace)
+	ot.traceStack = append(ot.traceStack, trace)
+}
+
+func (ot *OeTracer) CaptureEnd(depth int, output []byte, gasUsed uint64, t time.Duration, err error) error {
+	skipped := ot.skipStack[len(ot.skipStack)-1]
+	ot.skipStack = ot.skipStack[:len(ot.skipStack)-1]
+	if skipped {
+		return nil
+	}
+	if depth == 0 {
+		ot.r.Output = common.CopyBytes(output)
+	}
+	if ot.traceCalls {
+		trace := ot.traceStack[len(ot.traceStack)-1]
+		ot.traceStack = ot.traceStack[:len(ot.traceStack)-1]
+		if err != nil {
+			trace.Error = toParityError(err.Error())
+			trace.Result = TraceResult{GasUsed: "0"}
+		} else {
+			trace.Result.GasUsed = hexutil.EncodeUint64(gasUsed)
+			if trace.Type == "create" {
+				trace.Result.Code = hexutil.Encode(output)
+			} else {
+				trace.Result.Output = hexutil.Encode(output)
+			}
+		}
+	}
+	if ot.traceVm {
+		frame := ot.frames[len(ot.frames)-1]
+		ot.frames = ot.frames[:len(ot.frames)-1]
+		// The effects of the last instruction of the frame are only known once the frame is finished
+		if frame.vmOp != nil && (err == nil || err == vm.ErrExecutionReverted) {
+			frame.vmOp.Ex = &VmTraceEx{Push: []string{}, Used: frame.startGas - gasUsed}
+		}
+	}
+	return nil
+}
+
+func (ot *OeTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, st *stack.Stack, rStack *stack.ReturnStack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	if ot.traceCalls && op == vm.SELFDESTRUCT && err == nil && len(ot.traceStack) > 0 {
+		parent := ot.traceStack[len(ot.traceStack)-1]
+		trace := &ParityTrace{Type: "suicide"}
+		trace.TraceAddress = make([]int, len(parent.TraceAddress)+1)
+		copy(trace.TraceAddress, parent.TraceAddress)
+		trace.TraceAddress[len(parent.TraceAddress)] = parent.Subtraces
+		parent.Subtraces++
+		refundAddress := common.Address(st.Back(0).Bytes20())
+		trace.Action.SelfDestructed = strings.ToLower(contract.Address().Hex())
+		trace.Action.RefundAddress = strings.ToLower(refundAddress.Hex())
+		trace.Action.Balance = hexutil.EncodeBig(env.IntraBlockState.GetBalance(contract.Address()).ToBig())
+		ot.r.Trace = append(ot.r.Trace, trace)
+	}
+	if ot.traceVm && len(ot.frames) > 0 {
+		frame := ot.frames[len(ot.frames)-1]
+		// Now that the previous instruction of this frame has executed, its effects can be recorded
+		if frame.vmOp != nil {
+			ex := &VmTraceEx{Used: gas, Store: frame.store}
+			showStack := pushCount(frame.op)
+			if showStack > st.Len() {
+				showStack = st.Len()
+			}
+			ex.Push = make([]string, showStack)
+			for i := 0; i < showStack; i++ {
+				ex.Push[i] = st.Back(showStack - 1 - i).Hex()
+			}
+			if frame.memLen > 0 && frame.memOff+frame.memLen <= uint64(memory.Len()) {
+				ex.Mem = &VmTraceMem{Off: frame.memOff, Data: hexutil.Encode(memory.GetCopy(frame.memOff, frame.memLen))}
+			}
+			frame.vmOp.Ex = ex
+		}
+		vmOp := &VmTraceOp{Pc: pc, Cost: cost}
+		frame.trace.Ops = append(frame.trace.Ops, vmOp)
+		frame.op, frame.vmOp, frame.store = op, vmOp, nil
+		frame.memOff, frame.memLen = memoryWrite(op, st)
+		if op == vm.SSTORE && st.Len() >= 2 {
+			frame.store = &VmTraceStore{Key: st.Back(0).Hex(), Val: st.Back(1).Hex()}
+		}
+		if err != nil {
+			// The instruction has not been executed, so there are no effects to wait for
+			frame.vmOp = nil
+		}
+	}
+	ot.lastOp = op
+	return nil
+}
+
+func (ot *OeTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, st *stack.Stack, rStack *stack.ReturnStack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+func (ot *OeTracer) CaptureCreate(creator common.Address, creation common.Address) error {
+	return nil
+}
+
+func (ot *OeTracer) CaptureAccountRead(account common.Address) error {
+	return nil
+}
+
+func (ot *OeTracer) CaptureAccountWrite(account common.Address) error {
+	return nil
+}
+
+// memoryWrite returns the region of memory which is about to be written to by the given instruction
+func memoryWrite(op vm.OpCode, st *stack.Stack) (offset, length uint64) {
+	back := func(n int) uint64 {
+		if st.Len() <= n {
+			return 0
+		}
+		return st.Back(n).Uint64()
+	}
+	switch op {
+	case vm.MSTORE:
+		return back(0), 32
+	case vm.MSTORE8:
+		return back(0), 1
+	case vm.CALLDATACOPY, vm.CODECOPY, vm.RETURNDATACOPY:
+		return back(0), back(2)
+	case vm.EXTCODECOPY:
+		return back(1), back(3)
+	case vm.CALL, vm.CALLCODE:
+		return back(5), back(6)
+	case vm.DELEGATECALL, vm.STATICCALL:
+		return back(4), back(5)
+	}
+	return 0, 0
+}
+
+// pushCount returns the number of stack items shown as "pushed" by the given instruction
+func pushCount(op vm.OpCode) int {
+	switch {
+	case op.IsPush():
+		return 1
+	case op >= vm.DUP1 && op <= vm.DUP16:
+		return int(op-vm.DUP1) + 2
+	case op >= vm.SWAP1 && op <= vm.SWAP16:
+		return int(op-vm.SWAP1) + 2
+	}
+	switch op {
+	case vm.ADD, vm.MUL, vm.SUB, vm.DIV, vm.SDIV, vm.MOD, vm.SMOD, vm.ADDMOD, vm.MULMOD, vm.EXP, vm.SIGNEXTEND,
+		vm.LT, vm.GT, vm.SLT, vm.SGT, vm.EQ, vm.ISZERO, vm.AND, vm.OR, vm.XOR, vm.NOT, vm.BYTE, vm.SHL, vm.SHR, vm.SAR,
+		vm.SHA3,
+		vm.ADDRESS, vm.BALANCE, vm.ORIGIN, vm.CALLER, vm.CALLVALUE, vm.CALLDATALOAD, vm.CALLDATASIZE, vm.CODESIZE,
+		vm.GASPRICE, vm.EXTCODESIZE, vm.RETURNDATASIZE, vm.EXTCODEHASH,
+		vm.BLOCKHASH, vm.COINBASE, vm.TIMESTAMP, vm.NUMBER, vm.DIFFICULTY, vm.GASLIMIT, vm.CHAINID, vm.SELFBALANCE,
+		vm.MLOAD, vm.SLOAD, vm.PC, vm.MSIZE, vm.GAS,
+		vm.CREATE, vm.CREATE2, vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		return 1
+	}
+	return 0
+}
+
+// StateDiff is a state.StateWriter which remembers the accounts and storage items modified by a transaction,
+// so that their values before and after the transaction can be compared
+type StateDiff struct {
+	touched map[common.Address]map[common.Hash]struct{}
+}
+
+func NewStateDiff() *StateDiff {
+	return &StateDiff{touched: make(map[common.Address]map[common.Hash]struct{})}
+}
+
+func (sd *StateDiff) touch(address common.Address) map[common.Hash]struct{} {
+	m, ok := sd.touched[address]
+	if !ok {
+		m = make(map[common.Hash]struct{})
+		sd.touched[address] = m
+	}
+	return m
+}
+
+func (sd *StateDiff) UpdateAccountData(_ context.Context, address common.Address, original, account *accounts.Account) error {
+	sd.touch(address)
+	return nil
+}
+
+func (sd *StateDiff) UpdateAccountCode(address common.Address, incarnation uint64, codeHash common.Hash, code []byte) error {
+	sd.touch(address)
+	return nil
+}
+
+func (sd *StateDiff) DeleteAccount(_ context.Context, address common.Address, original *accounts.Account) error {
+	sd.touch(address)
+	return nil
+}
+
+func (sd *StateDiff) WriteAccountStorage(_ context.Context, address common.Address, incarnation uint64, key *common.Hash, original, value *uint256.Int) error {
+	sd.touch(address)[*key] = struct{}{}
+	return nil
+}
+
+func (sd *StateDiff) CreateContract(address common.Address) error {
+	sd.touch(address)
+	return nil
+}
+
+// Compare produces the "stateDiff" output by comparing the states before and after the transaction
+func (sd *StateDiff) Compare(before, after *state.IntraBlockState) map[common.Address]*StateDiffAccount {
+	result := make(map[common.Address]*StateDiffAccount)
+	for addr, keys := range sd.touched {
+		existedBefore, existsAfter := before.Exist(addr), after.Exist(addr)
+		if !existedBefore && !existsAfter {
+			continue
+		}
+		var account *StateDiffAccount
+		switch {
+		case !existedBefore:
+			account = &StateDiffAccount{
+				Balance: map[string]*hexutil.Big{"+": (*hexutil.Big)(after.GetBalance(addr).ToBig())},
+				Code:    map[string]hexutil.Bytes{"+": after.GetCode(addr)},
+				Nonce:   map[string]hexutil.Uint64{"+": hexutil.Uint64(after.GetNonce(addr))},
+				Storage: make(map[common.Hash]map[string]interface{}),
+			}
+			for key := range keys {
+				key := key
+				var value uint256.Int
+				after.GetState(addr, &key, &value)
+				if !value.IsZero() {
+					account.Storage[key] = map[string]interface{}{"+": common.Hash(value.Bytes32())}
+				}
+			}
+		case !existsAfter:
+			account = &StateDiffAccount{
+				Balance: map[string]*hexutil.Big{"-": (*hexutil.Big)(before.GetBalance(addr).ToBig())},
+				Code:    map[string]hexutil.Bytes{"-": before.GetCode(addr)},
+				Nonce:   map[string]hexutil.Uint64{"-": hexutil.Uint64(before.GetNonce(addr))},
+				Storage: make(map[common.Hash]map[string]interface{}),
+			}
+			for key := range keys {
+				key := key
+				var value uint256.Int
+				before.GetState(addr, &key, &value)
+				if !value.IsZero() {
+					account.Storage[key] = map[string]interface{}{"-": common.Hash(value.Bytes32())}
+				}
+			}
+		default:
+			account = &StateDiffAccount{Balance: "=", Code: "=", Nonce: "=", Storage: make(map[common.Hash]map[string]interface{})}
+			changed := false
+			if fromBalance, toBalance := before.GetBalance(addr), after.GetBalance(addr); !fromBalance.Eq(toBalance) {
+				account.Balance = map[string]*StateDiffBalance{"*": {From: (*hexutil.Big)(fromBalance.ToBig()), To: (*hexutil.Big)(toBalance.ToBig())}}
+				changed = true
+			}
+			if fromCode, toCode := before.GetCode(addr), after.GetCode(addr); before.GetCodeHash(addr) != after.GetCodeHash(addr) {
+				account.Code = map[string]*StateDiffCode{"*": {From: fromCode, To: toCode}}
+				changed = true
+			}
+			if fromNonce, toNonce := before.GetNonce(addr), after.GetNonce(addr); fromNonce != toNonce {
+				account.Nonce = map[string]*StateDiffNonce{"*": {From: hexutil.Uint64(fromNonce), To: hexutil.Uint64(toNonce)}}
+				changed = true
+			}
+			for key := range keys {
+				key := key
+				var fromValue, toValue uint256.Int
+				before.GetState(addr, &key, &fromValue)
+				after.GetState(addr, &key, &toValue)
+				if !fromValue.Eq(&toValue) {
+					account.Storage[key] = map[string]interface{}{"*": &StateDiffStorage{From: common.Hash(fromValue.Bytes32()), To: common.Hash(toValue.Bytes32())}}
+					changed = true
+				}
+			}
+			if !changed {
+				continue
+			}
+		}
+		result[addr] = account
+	}
+	return result
+}
+
+// traceMessage executes the message on top of the given state, producing the requested kinds of traces. The effects
+// of the message are finalized into the state, so that further messages can be applied on top of it
+func traceMessage(ctx context.Context, msg core.Message, vmctx vm.Context, ibs *state.IntraBlockState, chainConfig *params.ChainConfig, traceTypes []string) (*TraceCallResult, error) {
+	traceCalls, stateDiff, traceVm, err := traceTypesFlags(traceTypes)
+	if err != nil {
+		return nil, err
+	}
+	result := &TraceCallResult{Trace: []*ParityTrace{}}
+	var before *state.IntraBlockState
+	if stateDiff {
+		before = ibs.Copy()
+	}
+	var vmConfig vm.Config
+	if traceCalls || traceVm {
+		vmConfig.Debug = true
+		vmConfig.Tracer = NewOeTracer(result, ibs, chainConfig.Rules(vmctx.BlockNumber), traceCalls, traceVm)
+	}
+	evm := vm.NewEVM(vmctx, ibs, chainConfig, vmConfig)
+
+	// Wait for the context to be done and cancel the evm. Even if the
+	// EVM has finished, cancelling may be done (repeatedly)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		evm.Cancel()
+	}()
+
+	execResult, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(msg.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+	if evm.Cancelled() {
+		return nil, fmt.Errorf("execution aborted: %v", ctx.Err())
+	}
+	result.Output = common.CopyBytes(execResult.ReturnData)
+
+	var stateWriter state.StateWriter = state.NewNoopWriter()
+	var sd *StateDiff
+	if stateDiff {
+		sd = NewStateDiff()
+		stateWriter = sd
+	}
+	if err = ibs.FinalizeTx(chainConfig.WithEIPsFlags(ctx, vmctx.BlockNumber), stateWriter); err != nil {
+		return nil, err
+	}
+	if sd != nil {
+		result.StateDiff = sd.Compare(before, ibs)
+	}
+	if !traceCalls {
+		result.Trace = nil
+	}
+	return result, nil
+}
+
+// replayBlock re-executes the transactions of the block on top of the historical state of its parent. Only the
+// transaction at index txIndex gets traced, unless txIndex is negative, in which case all of them are
+func (api *TraceAPIImpl) replayBlock(ctx context.Context, tx ethdb.Database, block *types.Block, txIndex int, traceTypes []string) ([]*TraceCallResult, error) {
+	chainConfig, err := getChainConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+	parent := rawdb.ReadBlock(tx, block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
+	}
+	ibs, _ := adapter.ComputeIntraBlockState(tx.(ethdb.HasTx).Tx(), parent)
+	chainContext := adapter.NewChainContext(tx)
+	signer := types.MakeSigner(chainConfig, block.Number())
+
+	var results []*TraceCallResult
+	for idx, txn := range block.Transactions() {
+		select {
+		default:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		ibs.Prepare(txn.Hash(), block.Hash(), idx)
+		msg, err := txn.AsMessage(signer)
+		if err != nil {
+			return nil, err
+		}
+		vmctx := core.NewEVMContext(msg, block.Header(), chainContext, nil)
+		var txTraceTypes []string
+		if txIndex < 0 || idx == txIndex {
+			txTraceTypes = traceTypes
+		}
+		result, err := traceMessage(ctx, msg, vmctx, ibs, chainConfig, txTraceTypes)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %x failed: %v", txn.Hash(), err)
+		}
+		if txIndex < 0 {
+			txHash := txn.Hash()
+			result.TransactionHash = &txHash
+			results = append(results, result)
+		} else if idx == txIndex {
+			return []*TraceCallResult{result}, nil
+		}
+	}
+	if txIndex >= 0 {
+		return nil, fmt.Errorf("transaction index %d out of range for block %x", txIndex, block.Hash())
+	}
+	return results, nil
+}
+
+// Call implements trace_call. Traces a call on top of the state of the given block.
+func (api *TraceAPIImpl) Call(ctx context.Context, call ethapi.CallArgs, traceTypes []string, blockNrOrHash *rpc.BlockNumberOrHash) (*TraceCallResult, error) {
+	results, err := api.CallMany(ctx, CallParams{{Call: call, TraceTypes: traceTypes}}, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
 }
 
-// CallMany implements trace_callMany.
-func (api *TraceAPIImpl) CallMany(ctx context.Context, calls CallParams) ([]interface{}, error) {
-	var stub []interface{}
-	return stub, fmt.Errorf(NotImplemented, "trace_callMany")
+// CallMany implements trace_callMany. Each of the calls is traced on top of the state changes made by the previous ones.
+func (api *TraceAPIImpl) CallMany(ctx context.Context, calls CallParams, blockNrOrHash *rpc.BlockNumberOrHash) ([]*TraceCallResult, error) {
+	dbtx, err := api.db.Begin(ctx, nil, ethdb.RO)
+	if err != nil {
+		return nil, fmt.Errorf("traceCallMany cannot open tx: %v", err)
+	}
+	defer dbtx.Rollback()
+
+	if blockNrOrHash == nil {
+		latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		blockNrOrHash = &latest
+	}
+	chainConfig, err := getChainConfig(api.dbReader)
+	if err != nil {
+		return nil, err
+	}
+	ibs, header, err := transactions.CallState(dbtx, api.dbReader, *blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup context so that the calls are aborted after the timeout
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	results := make([]*TraceCallResult, 0, len(calls))
+	for i, call := range calls {
+		msg := call.Call.ToMessage(api.gasCap)
+		vmctx := transactions.GetEvmContext(msg, header, blockNrOrHash.RequireCanonical, api.dbReader)
+		ibs.Prepare(common.Hash{}, header.Hash(), i)
+		result, err := traceMessage(ctx, msg, vmctx, ibs, chainConfig, call.TraceTypes)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
 }
 
-// RawTransaction implements trace_rawTransaction.
-func (api *TraceAPIImpl) RawTransaction(ctx context.Context, txHash common.Hash, traceTypes []string) ([]interface{}, error) {
-	var stub []interface{}
-	return stub, fmt.Errorf(NotImplemented, "trace_rawTransaction")
+// RawTransaction implements trace_rawTransaction. Traces a signed transaction on top of the latest state.
+func (api *TraceAPIImpl) RawTransaction(ctx context.Context, encodedTx hexutil.Bytes, traceTypes []string) (*TraceCallResult, error) {
+	txn := new(types.Transaction)
+	if err := rlp.DecodeBytes(encodedTx, txn); err != nil {
+		return nil, err
+	}
+	chainConfig, err := getChainConfig(api.dbReader)
+	if err != nil {
+		return nil, err
+	}
+	blockNumber, err := getBlockNumber(rpc.LatestBlockNumber, api.dbReader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := rawdb.ReadBlockByNumber(api.dbReader, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", blockNumber)
+	}
+	msg, err := txn.AsMessage(types.MakeSigner(chainConfig, block.Number()))
+	if err != nil {
+		return nil, err
+	}
+	ibs := state.New(state.NewPlainStateReader(api.dbReader))
+	vmctx := core.NewEVMContext(msg, block.Header(), adapter.NewChainContext(api.dbReader), nil)
+	return traceMessage(ctx, msg, vmctx, ibs, chainConfig, traceTypes)
 }
 
 // ReplayBlockTransactions implements trace_replayBlockTransactions.
-func (api *TraceAPIImpl) ReplayBlockTransactions(ctx context.Context, blockNr rpc.BlockNumber, traceTypes []string) ([]interface{}, error) {
-	var stub []interface{}
-	return stub, fmt.Errorf(NotImplemented, "trace_replayBlockTransactions")
+func (api *TraceAPIImpl) ReplayBlockTransactions(ctx context.Context, blockNr rpc.BlockNumber, traceTypes []string) ([]*TraceCallResult, error) {
+	tx, err := api.dbReader.Begin(ctx, ethdb.RO)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	blockNumber, err := getBlockNumber(blockNr, tx)
+	if err != nil {
+		return nil, err
+	}
+	block, err := rawdb.ReadBlockByNumber(tx, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", blockNumber)
+	}
+	return api.replayBlock(ctx, tx, block, -1, traceTypes)
 }
 
 // ReplayTransaction implements trace_replayTransaction.
-func (api *TraceAPIImpl) ReplayTransaction(ctx context.Context, txHash common.Hash, traceTypes []string) ([]interface{}, error) {
-	var stub []interface{}
-	return stub, fmt.Errorf(NotImplemented, "trace_replayTransaction")
+func (api *TraceAPIImpl) ReplayTransaction(ctx context.Context, txHash common.Hash, traceTypes []string) (*TraceCallResult, error) {
+	tx, err := api.dbReader.Begin(ctx, ethdb.RO)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	txn, blockHash, _, txIndex := rawdb.ReadTransaction(tx, txHash)
+	if txn == nil {
+		return nil, fmt.Errorf("transaction %#x not found", txHash)
+	}
+	block, err := rawdb.ReadBlockByHash(tx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", blockHash)
+	}
+	results, err := api.replayBlock(ctx, tx, block, int(txIndex), traceTypes)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
 }
@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/turbo-geth/cmd/rpcdaemon/cli"
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/hexutil"
+	"github.com/ledgerwatch/turbo-geth/consensus/ethash"
+	"github.com/ledgerwatch/turbo-geth/core"
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/core/vm"
+	"github.com/ledgerwatch/turbo-geth/crypto"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/internal/ethapi"
+	"github.com/ledgerwatch/turbo-geth/params"
+	"github.com/ledgerwatch/turbo-geth/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// createTraceTestChain makes the chain of 2 blocks: the first one deploys the contract which stores
+// the first word of the call data into the slot 0, the second one calls it with 7 and transfers to the receiver
+func createTraceTestChain(t *testing.T) (db *ethdb.ObjectDatabase, blocks []*types.Block, bank, contract common.Address) {
+	db = ethdb.NewMemDatabase()
+
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	bank = crypto.PubkeyToAddress(key.PublicKey)
+	receiver := common.HexToAddress("0x1234567890")
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{bank: {Balance: big.NewInt(1000000000000000000)}},
+	}
+	genesis := gspec.MustCommit(db)
+	signer := types.HomesteadSigner{}
+
+	runtimeCode := []byte{byte(vm.PUSH1), 0, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+	deploy := append([]byte{
+		byte(vm.PUSH1), byte(len(runtimeCode)), byte(vm.PUSH1), 12, byte(vm.PUSH1), 0, byte(vm.CODECOPY),
+		byte(vm.PUSH1), byte(len(runtimeCode)), byte(vm.PUSH1), 0, byte(vm.RETURN),
+	}, runtimeCode...)
+	contract = crypto.CreateAddress(bank, 0)
+
+	engine := ethash.NewFaker()
+	blocks, _, err := core.GenerateChain(gspec.Config, genesis, engine, db, 2, func(i int, block *core.BlockGen) {
+		var txn *types.Transaction
+		if i == 0 {
+			txn = types.NewContractCreation(block.TxNonce(bank), new(uint256.Int), 100000, uint256.NewInt().SetUint64(1), deploy)
+		} else {
+			txn = types.NewTransaction(block.TxNonce(bank), contract, uint256.NewInt().SetUint64(10), 100000, uint256.NewInt().SetUint64(1), common.LeftPadBytes([]byte{7}, 32))
+		}
+		signedTx, err1 := types.SignTx(txn, signer, key)
+		require.NoError(t, err1)
+		block.AddTx(signedTx)
+		if i == 1 {
+			signedTx, err1 = types.SignTx(types.NewTransaction(block.TxNonce(bank), receiver, uint256.NewInt().SetUint64(1000), params.TxGas, uint256.NewInt().SetUint64(1), nil), signer, key)
+			require.NoError(t, err1)
+			block.AddTx(signedTx)
+		}
+	}, false /* intermediateHashes */)
+	require.NoError(t, err)
+
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil, core.NewTxSenderCacher(runtime.NumCPU()))
+	require.NoError(t, err)
+	defer chain.Stop()
+	_, err = stagedsync.InsertBlocksInStages(db, gspec.Config, engine, blocks, chain)
+	require.NoError(t, err)
+	return db, blocks, bank, contract
+}
+
+func TestReplayTransaction(t *testing.T) {
+	db, blocks, _, _ := createTraceTestChain(t)
+	defer db.Close()
+
+	api := NewTraceAPI(db.KV(), db, &cli.Flags{})
+	// the call of the contract, first in its block
+	result, err := api.ReplayTransaction(context.Background(), blocks[1].Transactions()[0].Hash(), []string{TraceTypeTrace, TraceTypeStateDiff})
+	require.NoError(t, err)
+	out, err := json.Marshal(result)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"output": "0x",
+		"stateDiff": {
+			"0x0000000000000000000000000000000000000000": {
+				"balance": {"*": {"from": "0x1bc16d674ec8d58c", "to": "0x1bc16d674ec97649"}},
+				"code": "=", "nonce": "=", "storage": {}
+			},
+			"0x3a220f351252089d385b29beca14e27f204c296a": {
+				"balance": {"*": {"from": "0x0", "to": "0xa"}},
+				"code": "=", "nonce": "=",
+				"storage": {"0x0000000000000000000000000000000000000000000000000000000000000000": {"*": {
+					"from": "0x0000000000000000000000000000000000000000000000000000000000000000",
+					"to": "0x0000000000000000000000000000000000000000000000000000000000000007"
+				}}}
+			},
+			"0x71562b71999873db5b286df957af199ec94617f7": {
+				"balance": {"*": {"from": "0xde0b6b3a7632a74", "to": "0xde0b6b3a76289ad"}},
+				"code": "=", "nonce": {"*": {"from": "0x1", "to": "0x2"}}, "storage": {}
+			}
+		},
+		"trace": [{
+			"action": {
+				"callType": "call",
+				"from": "0x71562b71999873db5b286df957af199ec94617f7",
+				"gas": "0x1340c",
+				"input": "0x0000000000000000000000000000000000000000000000000000000000000007",
+				"to": "0x3a220f351252089d385b29beca14e27f204c296a",
+				"value": "0xa"
+			},
+			"result": {"gasUsed": "0x4e29", "output": "0x"},
+			"subtraces": 0, "traceAddress": [], "type": "call"
+		}],
+		"vmTrace": null
+	}`, string(out))
+
+	// the transfer is replayed on top of the state changed by the call
+	result, err = api.ReplayTransaction(context.Background(), blocks[1].Transactions()[1].Hash(), []string{TraceTypeStateDiff})
+	require.NoError(t, err)
+	out, err = json.Marshal(result)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"output": "0x",
+		"stateDiff": {
+			"0x0000000000000000000000000000000000000000": {
+				"balance": {"*": {"from": "0x1bc16d674ec97649", "to": "0x1bc16d674ec9c851"}},
+				"code": "=", "nonce": "=", "storage": {}
+			},
+			"0x0000000000000000000000000000001234567890": {
+				"balance": {"+": "0x3e8"}, "code": {"+": "0x"}, "nonce": {"+": "0x0"}, "storage": {}
+			},
+			"0x71562b71999873db5b286df957af199ec94617f7": {
+				"balance": {"*": {"from": "0xde0b6b3a76289ad", "to": "0xde0b6b3a76233bd"}},
+				"code": "=", "nonce": {"*": {"from": "0x2", "to": "0x3"}}, "storage": {}
+			}
+		},
+		"trace": null,
+		"vmTrace": null
+	}`, string(out))
+}
+
+// storageDiff returns the change of the slot 0 of the contract in the state diff
+func storageDiff(t *testing.T, result *TraceCallResult, contract common.Address) *StateDiffStorage {
+	require.NotNil(t, result.StateDiff[contract])
+	diff, ok := result.StateDiff[contract].Storage[common.Hash{}]["*"].(*StateDiffStorage)
+	require.True(t, ok)
+	return diff
+}
+
+func callContract(from, contract common.Address, input byte) ethapi.CallArgs {
+	data := hexutil.Bytes(common.LeftPadBytes([]byte{input}, 32))
+	return ethapi.CallArgs{From: &from, To: &contract, Data: &data}
+}
+
+func TestTraceCall(t *testing.T) {
+	db, _, bank, contract := createTraceTestChain(t)
+	defer db.Close()
+	api := NewTraceAPI(db.KV(), db, &cli.Flags{})
+
+	// on top of the latest block, the slot has the value stored by the call in the block 2
+	result, err := api.Call(context.Background(), callContract(bank, contract, 9), []string{TraceTypeTrace, TraceTypeStateDiff}, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Trace, 1)
+	action := result.Trace[0].Action
+	require.Equal(t, "call", action.CallType)
+	require.Equal(t, strings.ToLower(bank.Hex()), action.From)
+	require.Equal(t, strings.ToLower(contract.Hex()), action.To)
+	require.Equal(t, hexutil.Encode(common.LeftPadBytes([]byte{9}, 32)), action.Input)
+	require.Equal(t, &StateDiffStorage{From: common.BytesToHash([]byte{7}), To: common.BytesToHash([]byte{9})}, storageDiff(t, result, contract))
+	require.Nil(t, result.VmTrace)
+
+	// on top of the block 1, the slot is not set yet
+	blockNr := rpc.BlockNumberOrHashWithNumber(1)
+	result, err = api.Call(context.Background(), callContract(bank, contract, 9), []string{TraceTypeStateDiff}, &blockNr)
+	require.NoError(t, err)
+	require.Nil(t, result.Trace)
+	require.Equal(t, &StateDiffStorage{From: common.Hash{}, To: common.BytesToHash([]byte{9})}, storageDiff(t, result, contract))
+
+	_, err = api.Call(context.Background(), callContract(bank, contract, 9), []string{"unknown"}, nil)
+	require.Error(t, err)
+}
+
+func TestTraceCallMany(t *testing.T) {
+	db, _, bank, contract := createTraceTestChain(t)
+	defer db.Close()
+	api := NewTraceAPI(db.KV(), db, &cli.Flags{})
+
+	results, err := api.CallMany(context.Background(), CallParams{
+		{Call: callContract(bank, contract, 9), TraceTypes: []string{TraceTypeStateDiff}},
+		{Call: callContract(bank, contract, 5), TraceTypes: []string{TraceTypeStateDiff, TraceTypeVmTrace}},
+		{Call: callContract(bank, contract, 3), TraceTypes: []string{TraceTypeTrace}},
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	require.Equal(t, &StateDiffStorage{From: common.BytesToHash([]byte{7}), To: common.BytesToHash([]byte{9})}, storageDiff(t, results[0], contract))
+	require.Nil(t, results[0].Trace)
+	require.Nil(t, results[0].VmTrace)
+
+	// the second call sees the value written by the first one
+	require.Equal(t, &StateDiffStorage{From: common.BytesToHash([]byte{9}), To: common.BytesToHash([]byte{5})}, storageDiff(t, results[1], contract))
+	require.Nil(t, results[1].Trace)
+	require.NotNil(t, results[1].VmTrace)
+	require.NotEmpty(t, results[1].VmTrace.Ops)
+
+	require.Nil(t, results[2].StateDiff)
+	require.Len(t, results[2].Trace, 1)
+	require.Nil(t, results[2].VmTrace)
+}
@@ -7,17 +7,18 @@ import (
 	"github.com/ledgerwatch/turbo-geth/common"
 	"github.com/ledgerwatch/turbo-geth/common/hexutil"
 	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/internal/ethapi"
 	"github.com/ledgerwatch/turbo-geth/rpc"
 )
 
 // TraceAPI RPC interface into tracing API
 type TraceAPI interface {
 	// Ad-hoc (see ./trace_adhoc.go)
-	ReplayBlockTransactions(ctx context.Context, blockNr rpc.BlockNumber, traceTypes []string) ([]interface{}, error)
-	ReplayTransaction(ctx context.Context, txHash common.Hash, traceTypes []string) ([]interface{}, error)
-	Call(ctx context.Context, call CallParam, blockNr rpc.BlockNumber) ([]interface{}, error)
-	CallMany(ctx context.Context, calls CallParams) ([]interface{}, error)
-	RawTransaction(ctx context.Context, txHash common.Hash, traceTypes []string) ([]interface{}, error)
+	ReplayBlockTransactions(ctx context.Context, blockNr rpc.BlockNumber, traceTypes []string) ([]*TraceCallResult, error)
+	ReplayTransaction(ctx context.Context, txHash common.Hash, traceTypes []string) (*TraceCallResult, error)
+	Call(ctx context.Context, call ethapi.CallArgs, traceTypes []string, blockNr *rpc.BlockNumberOrHash) (*TraceCallResult, error)
+	CallMany(ctx context.Context, calls CallParams, blockNr *rpc.BlockNumberOrHash) ([]*TraceCallResult, error)
+	RawTransaction(ctx context.Context, encodedTx hexutil.Bytes, traceTypes []string) (*TraceCallResult, error)
 
 	// Filtering (see ./trace_filtering.go)
 	Transaction(ctx context.Context, txHash common.Hash) (ParityTraces, error)
@@ -32,6 +33,7 @@ type TraceAPIImpl struct {
 	dbReader  ethdb.Database
 	maxTraces uint64
 	traceType string
+	gasCap    uint64
 }
 
 // NewTraceAPI returns NewTraceAPI instance
@@ -41,5 +43,6 @@ func NewTraceAPI(db ethdb.KV, dbReader ethdb.Database, cfg *cli.Flags) *TraceAPI
 		dbReader:  dbReader,
 		maxTraces: cfg.MaxTraces,
 		traceType: cfg.TraceType,
+		gasCap:    cfg.Gascap,
 	}
 }
@@ -274,8 +274,10 @@ func (api *TraceAPIImpl) Filter(ctx context.Context, req TraceFilterRequest) (Pa
 			tr.Action.Author = strings.ToLower(block.Coinbase().String())
 			tr.Action.RewardType = "block" // goconst
 			tr.Action.Value = minerReward.String()
-			tr.BlockHash = block.Hash()
-			tr.BlockNumber = block.NumberU64()
+			blockHash := block.Hash()
+			blockNumber := block.NumberU64()
+			tr.BlockHash = &blockHash
+			tr.BlockNumber = &blockNumber
 			tr.Type = "reward" // nolint: goconst
 			traces = append(traces, tr)
 			for i, uncle := range block.Uncles() {
@@ -284,8 +286,8 @@ func (api *TraceAPIImpl) Filter(ctx context.Context, req TraceFilterRequest) (Pa
 					tr.Action.Author = strings.ToLower(uncle.Coinbase.String())
 					tr.Action.RewardType = "uncle" // goconst
 					tr.Action.Value = uncleRewards[i].String()
-					tr.BlockHash = block.Hash()
-					tr.BlockNumber = block.NumberU64()
+					tr.BlockHash = &blockHash
+					tr.BlockNumber = &blockNumber
 					tr.Type = "reward" // nolint: goconst
 					traces = append(traces, tr)
 				}
@@ -39,16 +39,17 @@ type GethTraces []*GethTrace
 // ParityTrace A trace in the desired format (Parity/OpenEtherum) See: https://openethereum.github.io/wiki/JSONRPC-trace-module
 type ParityTrace struct {
 	// Do not change the ordering of these fields -- allows for easier comparison with other clients
-	Action              TraceAction `json:"action"`
-	BlockHash           common.Hash `json:"blockHash"`
-	BlockNumber         uint64      `json:"blockNumber"`
-	Error               string      `json:"error,omitempty"`
-	Result              TraceResult `json:"result"`
-	Subtraces           int         `json:"subtraces"`
-	TraceAddress        []int       `json:"traceAddress"`
-	TransactionHash     common.Hash `json:"transactionHash"`
-	TransactionPosition uint64      `json:"transactionPosition"`
-	Type                string      `json:"type"`
+	// Block and transaction fields are left out of the ad-hoc (replay / call) traces, hence the pointers
+	Action              TraceAction  `json:"action"`
+	BlockHash           *common.Hash `json:"blockHash,omitempty"`
+	BlockNumber         *uint64      `json:"blockNumber,omitempty"`
+	Error               string       `json:"error,omitempty"`
+	Result              TraceResult  `json:"result"`
+	Subtraces           int          `json:"subtraces"`
+	TraceAddress        []int        `json:"traceAddress"`
+	TransactionHash     *common.Hash `json:"transactionHash,omitempty"`
+	TransactionPosition *uint64      `json:"transactionPosition,omitempty"`
+	Type                string       `json:"type"`
 }
 
 // ParityTraces An array of parity traces
@@ -80,6 +81,83 @@ type TraceResult struct {
 	Output  string `json:"output,omitempty"`
 }
 
+// TraceCallResult is the response to the ad-hoc tracing methods (trace_call, trace_replayTransaction, etc.)
+type TraceCallResult struct {
+	// Do not change the ordering of these fields -- allows for easier comparison with other clients
+	Output          hexutil.Bytes                        `json:"output"`
+	StateDiff       map[common.Address]*StateDiffAccount `json:"stateDiff"`
+	Trace           []*ParityTrace                       `json:"trace"`
+	VmTrace         *VmTrace                             `json:"vmTrace"`
+	TransactionHash *common.Hash                         `json:"transactionHash,omitempty"`
+}
+
+// StateDiffAccount is the part of the ad-hoc trace response that is under the "stateDiff" tag. Each of the
+// fields is either the string "=" (unchanged), or a map with a single key "+" (born), "-" (died) or "*" (changed)
+type StateDiffAccount struct {
+	Balance interface{}                            `json:"balance"`
+	Code    interface{}                            `json:"code"`
+	Nonce   interface{}                            `json:"nonce"`
+	Storage map[common.Hash]map[string]interface{} `json:"storage"`
+}
+
+// StateDiffBalance is the "*" entry of a changed balance
+type StateDiffBalance struct {
+	From *hexutil.Big `json:"from"`
+	To   *hexutil.Big `json:"to"`
+}
+
+// StateDiffCode is the "*" entry of a changed code
+type StateDiffCode struct {
+	From hexutil.Bytes `json:"from"`
+	To   hexutil.Bytes `json:"to"`
+}
+
+// StateDiffNonce is the "*" entry of a changed nonce
+type StateDiffNonce struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// StateDiffStorage is the "*" entry of a changed storage item
+type StateDiffStorage struct {
+	From common.Hash `json:"from"`
+	To   common.Hash `json:"to"`
+}
+
+// VmTrace is the part of the ad-hoc trace response that is under the "vmTrace" tag
+type VmTrace struct {
+	Code hexutil.Bytes `json:"code"`
+	Ops  []*VmTraceOp  `json:"ops"`
+}
+
+// VmTraceOp is one executed instruction inside of a VmTrace
+type VmTraceOp struct {
+	Cost uint64     `json:"cost"`
+	Ex   *VmTraceEx `json:"ex"`
+	Pc   uint64     `json:"pc"`
+	Sub  *VmTrace   `json:"sub"`
+}
+
+// VmTraceEx describes the effects of an executed instruction
+type VmTraceEx struct {
+	Mem   *VmTraceMem   `json:"mem"`
+	Push  []string      `json:"push"`
+	Store *VmTraceStore `json:"store"`
+	Used  uint64        `json:"used"`
+}
+
+// VmTraceMem is the memory region written to by an instruction
+type VmTraceMem struct {
+	Data string `json:"data"`
+	Off  uint64 `json:"off"`
+}
+
+// VmTraceStore is the storage item written to by an instruction
+type VmTraceStore struct {
+	Key string `json:"key"`
+	Val string `json:"val"`
+}
+
 // Allows for easy printing of a geth trace for debugging
 func (p GethTrace) String() string {
 	var ret string
@@ -107,16 +185,24 @@ func (t ParityTrace) String() string {
 	ret += fmt.Sprintf("Action.RefundAddress: %s\n", t.Action.RefundAddress)
 	ret += fmt.Sprintf("Action.To: %s\n", t.Action.To)
 	ret += fmt.Sprintf("Action.Value: %s\n", t.Action.Value)
-	ret += fmt.Sprintf("BlockHash: %v\n", t.BlockHash)
-	ret += fmt.Sprintf("BlockNumber: %d\n", t.BlockNumber)
+	if t.BlockHash != nil {
+		ret += fmt.Sprintf("BlockHash: %v\n", *t.BlockHash)
+	}
+	if t.BlockNumber != nil {
+		ret += fmt.Sprintf("BlockNumber: %d\n", *t.BlockNumber)
+	}
 	ret += fmt.Sprintf("Result.Address: %s\n", t.Result.Address)
 	ret += fmt.Sprintf("Result.Code: %s\n", t.Result.Code)
 	ret += fmt.Sprintf("Result.GasUsed: %s\n", t.Result.GasUsed)
 	ret += fmt.Sprintf("Result.Output: %s\n", t.Result.Output)
 	ret += fmt.Sprintf("Subtraces: %d\n", t.Subtraces)
 	//ret += fmt.Sprintf("TraceAddress: %s\n", t.TraceAddress)
-	ret += fmt.Sprintf("TransactionHash: %v\n", t.TransactionHash)
-	ret += fmt.Sprintf("TransactionPosition: %d\n", t.TransactionPosition)
+	if t.TransactionHash != nil {
+		ret += fmt.Sprintf("TransactionHash: %v\n", *t.TransactionHash)
+	}
+	if t.TransactionPosition != nil {
+		ret += fmt.Sprintf("TransactionPosition: %d\n", *t.TransactionPosition)
+	}
 	ret += fmt.Sprintf("Type: %s\n", t.Type)
 	return ret
 }
@@ -170,32 +256,18 @@ func (api *TraceAPIImpl) convertToParityTrace(gethTrace GethTrace, blockHash com
 		}
 	}
 
-	// This ugly code is here to convert Geth error messages to Parity error message. One day, when
-	// we figure out what we want to do, it will be removed
-	var (
-		ErrInvalidJumpParity       = "Bad jump destination"
-		ErrExecutionRevertedParity = "Reverted"
-	)
-	gethError := gethTrace.Error
-	if gethError == vm.ErrInvalidJump.Error() {
-		pt.Error = ErrInvalidJumpParity
-	} else if gethError == vm.ErrExecutionReverted.Error() {
-		pt.Error = ErrExecutionRevertedParity
-	} else {
-		pt.Error = gethTrace.Error
-	}
+	pt.Error = toParityError(gethTrace.Error)
 	if pt.Error != "" {
 		pt.Result.GasUsed = "0"
 	}
-	// This ugly code is here to convert Geth error messages to Parity error message. One day, when
-	// we figure out what we want to do, it will be removed
 
-	pt.BlockHash = blockHash
-	pt.BlockNumber = blockNumber
+	txHash := tx.Hash()
+	pt.BlockHash = &blockHash
+	pt.BlockNumber = &blockNumber
 	pt.Subtraces = len(gethTrace.Calls)
 	pt.TraceAddress = depth
-	pt.TransactionHash = tx.Hash()
-	pt.TransactionPosition = txIndex
+	pt.TransactionHash = &txHash
+	pt.TransactionPosition = &txIndex
 	pt.Type = callType
 	if pt.Type == "delegatecall" || pt.Type == "staticcall" {
 		pt.Type = "call"
@@ -211,3 +283,18 @@ func (api *TraceAPIImpl) convertToParityTrace(gethTrace GethTrace, blockHash com
 
 	return traces
 }
+
+// This ugly code is here to convert Geth error messages to Parity error message. One day, when
+// we figure out what we want to do, it will be removed
+func toParityError(gethError string) string {
+	var (
+		ErrInvalidJumpParity       = "Bad jump destination"
+		ErrExecutionRevertedParity = "Reverted"
+	)
+	if gethError == vm.ErrInvalidJump.Error() {
+		return ErrInvalidJumpParity
+	} else if gethError == vm.ErrExecutionReverted.Error() {
+		return ErrExecutionRevertedParity
+	}
+	return gethError
+}
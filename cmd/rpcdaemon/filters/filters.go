@@ -0,0 +1,312 @@
+package filters
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/eth/filters"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/ethdb/remote"
+	"github.com/ledgerwatch/turbo-geth/log"
+	"github.com/ledgerwatch/turbo-geth/rlp"
+)
+
+type (
+	SubscriptionID   string
+	HeadsSubID       SubscriptionID
+	PendingTxsSubID  SubscriptionID
+	LogsSubID        SubscriptionID
+	logsSubscription struct {
+		crit  filters.FilterCriteria
+		queue *eventQueue
+	}
+)
+
+// maxQueuedEvents - amount of events kept for a subscriber which doesn't receive them,
+// the subscription is closed when it's exceeded
+const maxQueuedEvents = 10_000
+
+// Filters receives the chain events streamed by the node over the private API
+// and fans them out to the local subscribers (i.e. eth_subscribe clients)
+type Filters struct {
+	mu sync.RWMutex
+
+	headsSubs      map[HeadsSubID]*eventQueue
+	logsSubs       map[LogsSubID]logsSubscription
+	pendingTxsSubs map[PendingTxsSubID]*eventQueue
+}
+
+// New creates Filters and keeps it subscribed to the node events until ctx is cancelled
+func New(ctx context.Context, ethBackend ethdb.Backend) *Filters {
+	log.Info("rpc filters: subscribing to tg events")
+
+	ff := &Filters{
+		headsSubs:      make(map[HeadsSubID]*eventQueue),
+		logsSubs:       make(map[LogsSubID]logsSubscription),
+		pendingTxsSubs: make(map[PendingTxsSubID]*eventQueue),
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+			if err := ethBackend.Subscribe(ctx, ff.OnNewEvent); err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+				log.Warn("rpc filters: error subscribing to events", "err", err)
+				time.Sleep(time.Second)
+			}
+		}
+	}()
+
+	return ff
+}
+
+// SubscribeNewHeads delivers the new headers to out, until the subscription is removed or overflows, then out is closed
+func (ff *Filters) SubscribeNewHeads(out chan *types.Header) HeadsSubID {
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+	id := HeadsSubID(generateSubscriptionID())
+	ff.headsSubs[id] = newEventQueue(func(event interface{}, quit <-chan struct{}) bool {
+		select {
+		case out <- event.(*types.Header):
+			return true
+		case <-quit:
+			return false
+		}
+	}, func() { close(out) })
+	return id
+}
+
+func (ff *Filters) UnsubscribeHeads(id HeadsSubID) {
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+	if q, ok := ff.headsSubs[id]; ok {
+		q.stop()
+		delete(ff.headsSubs, id)
+	}
+}
+
+// SubscribeLogs delivers the new logs matching crit to out, until the subscription is removed or overflows, then out is closed
+func (ff *Filters) SubscribeLogs(out chan types.Logs, crit filters.FilterCriteria) LogsSubID {
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+	id := LogsSubID(generateSubscriptionID())
+	ff.logsSubs[id] = logsSubscription{crit: crit, queue: newEventQueue(func(event interface{}, quit <-chan struct{}) bool {
+		select {
+		case out <- event.(types.Logs):
+			return true
+		case <-quit:
+			return false
+		}
+	}, func() { close(out) })}
+	return id
+}
+
+func (ff *Filters) UnsubscribeLogs(id LogsSubID) {
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+	if sub, ok := ff.logsSubs[id]; ok {
+		sub.queue.stop()
+		delete(ff.logsSubs, id)
+	}
+}
+
+// SubscribePendingTxs delivers the hashes of new pending transactions to out, until the subscription is removed or overflows, then out is closed
+func (ff *Filters) SubscribePendingTxs(out chan []common.Hash) PendingTxsSubID {
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+	id := PendingTxsSubID(generateSubscriptionID())
+	ff.pendingTxsSubs[id] = newEventQueue(func(event interface{}, quit <-chan struct{}) bool {
+		select {
+		case out <- event.([]common.Hash):
+			return true
+		case <-quit:
+			return false
+		}
+	}, func() { close(out) })
+	return id
+}
+
+func (ff *Filters) UnsubscribePendingTxs(id PendingTxsSubID) {
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+	if q, ok := ff.pendingTxsSubs[id]; ok {
+		q.stop()
+		delete(ff.pendingTxsSubs, id)
+	}
+}
+
+// OnNewEvent decodes an event received from the node and queues it for the matching subscribers.
+// Subscribers receive the queued events at their own pace, so a slow client can't stall the stream.
+// The subscription of a client which is too slow to keep up is closed.
+func (ff *Filters) OnNewEvent(event *remote.SubscribeReply) {
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+
+	switch event.Type {
+	case remote.Event_HEADER:
+		header := &types.Header{}
+		if err := rlp.DecodeBytes(event.Data, header); err != nil {
+			log.Warn("rpc filters: error decoding header", "err", err)
+			return
+		}
+		for id, q := range ff.headsSubs {
+			if !q.push(header) {
+				log.Warn("rpc filters: subscriber is too slow, closing subscription", "type", "newHeads", "queued", maxQueuedEvents)
+				q.stop()
+				delete(ff.headsSubs, id)
+			}
+		}
+	case remote.Event_LOGS:
+		var logs types.Logs
+		if err := json.Unmarshal(event.Data, &logs); err != nil {
+			log.Warn("rpc filters: error decoding logs", "err", err)
+			return
+		}
+		for id, sub := range ff.logsSubs {
+			matched := filterLogs(logs, sub.crit.Addresses, sub.crit.Topics)
+			if len(matched) == 0 {
+				continue
+			}
+			if !sub.queue.push(matched) {
+				log.Warn("rpc filters: subscriber is too slow, closing subscription", "type", "logs", "queued", maxQueuedEvents)
+				sub.queue.stop()
+				delete(ff.logsSubs, id)
+			}
+		}
+	case remote.Event_PENDING_TX:
+		txHash := common.BytesToHash(event.Data)
+		for id, q := range ff.pendingTxsSubs {
+			if !q.push([]common.Hash{txHash}) {
+				log.Warn("rpc filters: subscriber is too slow, closing subscription", "type", "newPendingTransactions", "queued", maxQueuedEvents)
+				q.stop()
+				delete(ff.pendingTxsSubs, id)
+			}
+		}
+	default:
+		log.Warn("rpc filters: unsupported event type", "type", event.Type)
+	}
+}
+
+// eventQueue keeps the events of a subscriber until they are delivered, in the order they arrived
+type eventQueue struct {
+	mu     sync.Mutex
+	events []interface{}
+	wake   chan struct{}
+	quit   chan struct{}
+}
+
+// newEventQueue starts delivering the queued events, `deliver` returns false if quit is closed before the event
+// is delivered. `done` is called once the queue is stopped.
+func newEventQueue(deliver func(event interface{}, quit <-chan struct{}) bool, done func()) *eventQueue {
+	q := &eventQueue{wake: make(chan struct{}, 1), quit: make(chan struct{})}
+	go func() {
+		defer done()
+		for {
+			select {
+			case <-q.wake:
+			case <-q.quit:
+				return
+			}
+			for event, ok := q.pop(); ok; event, ok = q.pop() {
+				if !deliver(event, q.quit) {
+					return
+				}
+			}
+		}
+	}()
+	return q
+}
+
+// push adds the event to the queue, it returns false if the queue is full
+func (q *eventQueue) push(event interface{}) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.events) >= maxQueuedEvents {
+		return false
+	}
+	q.events = append(q.events, event)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (q *eventQueue) pop() (interface{}, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.events) == 0 {
+		return nil, false
+	}
+	event := q.events[0]
+	q.events[0] = nil
+	q.events = q.events[1:]
+	return event, true
+}
+
+// stop drops the queued events and stops the delivery, it must be called once
+func (q *eventQueue) stop() {
+	close(q.quit)
+}
+
+// filterLogs returns the logs matching the given addresses and topics,
+// the same way as eth_getLogs does
+func filterLogs(logs types.Logs, addresses []common.Address, topics [][]common.Hash) types.Logs {
+	var ret types.Logs
+Logs:
+	for _, log := range logs {
+		if len(addresses) > 0 && !includes(addresses, log.Address) {
+			continue
+		}
+		// If the to filtered topics is greater than the amount of topics in logs, skip.
+		if len(topics) > len(log.Topics) {
+			continue
+		}
+		for i, sub := range topics {
+			match := len(sub) == 0 // empty rule set == wildcard
+			for _, topic := range sub {
+				if log.Topics[i] == topic {
+					match = true
+					break
+				}
+			}
+			if !match {
+				continue Logs
+			}
+		}
+		ret = append(ret, log)
+	}
+	return ret
+}
+
+func includes(addresses []common.Address, a common.Address) bool {
+	for _, addr := range addresses {
+		if addr == a {
+			return true
+		}
+	}
+	return false
+}
+
+func generateSubscriptionID() SubscriptionID {
+	var id [16]byte
+	_, err := rand.Read(id[:])
+	if err != nil {
+		log.Crit("rpc filters: error creating random id", "err", err)
+	}
+	return SubscriptionID(hex.EncodeToString(id[:]))
+}
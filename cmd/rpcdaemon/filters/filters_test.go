@@ -0,0 +1,172 @@
+package filters
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/eth/filters"
+	"github.com/ledgerwatch/turbo-geth/ethdb/remote"
+	"github.com/ledgerwatch/turbo-geth/rlp"
+	"github.com/stretchr/testify/require"
+)
+
+// testBackend streams the given events once the subscribers are registered (start is closed)
+type testBackend struct {
+	start  chan struct{}
+	events []*remote.SubscribeReply
+}
+
+func (b *testBackend) AddLocal([]byte) ([]byte, error)    { return nil, nil }
+func (b *testBackend) Etherbase() (common.Address, error) { return common.Address{}, nil }
+func (b *testBackend) NetVersion() (uint64, error)        { return 1, nil }
+func (b *testBackend) Subscribe(ctx context.Context, cb func(*remote.SubscribeReply)) error {
+	select {
+	case <-b.start:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for _, e := range b.events {
+		cb(e)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestFilters(t *testing.T) {
+	addr1, addr2 := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	topic := common.HexToHash("0xaa")
+	header, err := rlp.EncodeToBytes(&types.Header{Number: big.NewInt(5)})
+	require.NoError(t, err)
+	logs, err := json.Marshal(types.Logs{
+		{Address: addr1, Topics: []common.Hash{topic}, BlockNumber: 5},
+		{Address: addr2, Topics: []common.Hash{}, BlockNumber: 5},
+	})
+	require.NoError(t, err)
+	txHash := common.HexToHash("0xbb")
+
+	backend := &testBackend{start: make(chan struct{}), events: []*remote.SubscribeReply{
+		{Type: remote.Event_HEADER, Data: header},
+		{Type: remote.Event_LOGS, Data: logs},
+		{Type: remote.Event_PENDING_TX, Data: txHash.Bytes()},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ff := New(ctx, backend)
+
+	headsCh := make(chan *types.Header, 1)
+	ff.SubscribeNewHeads(headsCh)
+	logsCh := make(chan types.Logs, 1)
+	ff.SubscribeLogs(logsCh, filters.FilterCriteria{Addresses: []common.Address{addr1}})
+	txsCh := make(chan []common.Hash, 1)
+	ff.SubscribePendingTxs(txsCh)
+	unsubscribedCh := make(chan *types.Header, 1)
+	ff.UnsubscribeHeads(ff.SubscribeNewHeads(unsubscribedCh))
+	// nobody reads it, it must not block the others
+	ff.SubscribeNewHeads(make(chan *types.Header))
+	close(backend.start)
+
+	select {
+	case h := <-headsCh:
+		require.Equal(t, uint64(5), h.Number.Uint64())
+	case <-time.After(5 * time.Second):
+		t.Fatal("no header")
+	}
+	select {
+	case l := <-logsCh:
+		require.Len(t, l, 1)
+		require.Equal(t, addr1, l[0].Address)
+		require.Equal(t, []common.Hash{topic}, l[0].Topics)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no logs")
+	}
+	select {
+	case txs := <-txsCh:
+		require.Equal(t, []common.Hash{txHash}, txs)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pending tx")
+	}
+	require.Len(t, unsubscribedCh, 0)
+}
+
+func TestFilterLogs(t *testing.T) {
+	addr1, addr2 := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	t1, t2, t3 := common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")
+	logs := types.Logs{
+		{Address: addr1, Topics: []common.Hash{t1, t2}},
+		{Address: addr2, Topics: []common.Hash{t1}},
+		{Address: addr2, Topics: []common.Hash{t3, t2}},
+	}
+	for _, tc := range []struct {
+		name      string
+		addresses []common.Address
+		topics    [][]common.Hash
+		expected  types.Logs
+	}{
+		{"no criteria", nil, nil, logs},
+		{"address", []common.Address{addr2}, nil, logs[1:]},
+		{"first topic", nil, [][]common.Hash{{t1}}, logs[:2]},
+		{"wildcard and second topic", nil, [][]common.Hash{{}, {t2}}, types.Logs{logs[0], logs[2]}},
+		{"alternative topics", nil, [][]common.Hash{{t1, t3}, {t2}}, types.Logs{logs[0], logs[2]}},
+		{"address and topic", []common.Address{addr2}, [][]common.Hash{{t3}}, logs[2:]},
+		{"too many topics", nil, [][]common.Hash{{}, {}, {}}, nil},
+	} {
+		require.Equal(t, tc.expected, filterLogs(logs, tc.addresses, tc.topics), tc.name)
+	}
+}
+
+func TestFiltersSlowSubscriber(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ff := New(ctx, &testBackend{start: make(chan struct{})})
+
+	header := func(n int64) *remote.SubscribeReply {
+		data, err := rlp.EncodeToBytes(&types.Header{Number: big.NewInt(n)})
+		require.NoError(t, err)
+		return &remote.SubscribeReply{Type: remote.Event_HEADER, Data: data}
+	}
+
+	// the subscriber which doesn't receive in time gets all the events in order
+	slowCh := make(chan *types.Header)
+	slowID := ff.SubscribeNewHeads(slowCh)
+	for i := int64(1); i <= 3; i++ {
+		ff.OnNewEvent(header(i))
+	}
+	for i := int64(1); i <= 3; i++ {
+		select {
+		case h := <-slowCh:
+			require.Equal(t, i, h.Number.Int64())
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no header %d", i)
+		}
+	}
+	ff.UnsubscribeHeads(slowID)
+
+	// the subscription is closed once too many events are queued
+	stuckCh := make(chan *types.Header)
+	ff.SubscribeNewHeads(stuckCh)
+	for i := int64(0); i <= maxQueuedEvents+1; i++ {
+		ff.OnNewEvent(header(i))
+	}
+	received := 0
+	timeout := time.After(5 * time.Second)
+	for closed := false; !closed; {
+		select {
+		case _, ok := <-stuckCh:
+			if ok {
+				received++
+			}
+			closed = !ok
+		case <-timeout:
+			t.Fatal("overflowed subscription is not closed")
+		}
+	}
+	require.Less(t, received, maxQueuedEvents+2)
+	ff.mu.RLock()
+	defer ff.mu.RUnlock()
+	require.Empty(t, ff.headsSubs)
+}
@@ -0,0 +1,149 @@
+package filters
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/eth/filters"
+	"github.com/ledgerwatch/turbo-geth/rpc"
+)
+
+// PollingFilterType defines what a polling filter reports on eth_getFilterChanges
+type PollingFilterType int
+
+const (
+	// LogsFilter reports the logs matching the filter criteria
+	LogsFilter PollingFilterType = iota
+	// BlocksFilter reports the hashes of the new canonical blocks
+	BlocksFilter
+	// PendingTxsFilter reports the hashes of the transactions added to the pool of the node
+	PendingTxsFilter
+)
+
+// PollingFilter is the state of a filter installed by eth_newFilter, eth_newBlockFilter or eth_newPendingTransactionFilter
+type PollingFilter struct {
+	sync.Mutex // serializes the polls of the same filter
+
+	Type PollingFilterType
+	Crit filters.FilterCriteria
+	// LastBlock is the last block which has been reported to the client
+	LastBlock uint64
+
+	lastPoll   time.Time
+	pendingTxs []common.Hash
+	done       chan struct{}
+}
+
+// Done is closed when the filter is uninstalled or expired
+func (f *PollingFilter) Done() <-chan struct{} {
+	return f.done
+}
+
+// PollingFilters keeps the polling filters of the clients, the filters which
+// are not polled for longer than timeout get uninstalled
+type PollingFilters struct {
+	mu      sync.Mutex
+	filters map[rpc.ID]*PollingFilter
+	timeout time.Duration
+	quit    chan struct{}
+}
+
+func NewPollingFilters(timeout time.Duration) *PollingFilters {
+	pf := &PollingFilters{
+		filters: make(map[rpc.ID]*PollingFilter),
+		timeout: timeout,
+		quit:    make(chan struct{}),
+	}
+	go pf.timeoutLoop()
+	return pf
+}
+
+// Stop terminates the expiration of the filters and uninstalls all of them
+func (pf *PollingFilters) Stop() {
+	close(pf.quit)
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	for id, f := range pf.filters {
+		delete(pf.filters, id)
+		close(f.done)
+	}
+}
+
+// timeoutLoop runs every timeout and uninstalls the filters that have not been recently polled, until Stop
+func (pf *PollingFilters) timeoutLoop() {
+	ticker := time.NewTicker(pf.timeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pf.quit:
+			return
+		case <-ticker.C:
+		}
+		pf.mu.Lock()
+		for id, f := range pf.filters {
+			if time.Since(f.lastPoll) >= pf.timeout {
+				delete(pf.filters, id)
+				close(f.done)
+			}
+		}
+		pf.mu.Unlock()
+	}
+}
+
+// Install registers a new filter, reporting changes after lastBlock
+func (pf *PollingFilters) Install(filterType PollingFilterType, crit filters.FilterCriteria, lastBlock uint64) (rpc.ID, *PollingFilter) {
+	f := &PollingFilter{
+		Type:      filterType,
+		Crit:      crit,
+		LastBlock: lastBlock,
+		lastPoll:  time.Now(),
+		done:      make(chan struct{}),
+	}
+	id := rpc.NewID()
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	pf.filters[id] = f
+	return id, f
+}
+
+// Uninstall removes the filter, it returns false if there is no such filter
+func (pf *PollingFilters) Uninstall(id rpc.ID) bool {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	f, ok := pf.filters[id]
+	if !ok {
+		return false
+	}
+	delete(pf.filters, id)
+	close(f.done)
+	return true
+}
+
+// Poll returns the filter and postpones its expiration
+func (pf *PollingFilters) Poll(id rpc.ID) (*PollingFilter, bool) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	f, ok := pf.filters[id]
+	if !ok {
+		return nil, false
+	}
+	f.lastPoll = time.Now()
+	return f, true
+}
+
+// AddPendingTxs appends the hashes to be reported on the next poll of the filter
+func (pf *PollingFilters) AddPendingTxs(f *PollingFilter, hashes []common.Hash) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	f.pendingTxs = append(f.pendingTxs, hashes...)
+}
+
+// TakePendingTxs returns the hashes collected since the previous poll of the filter
+func (pf *PollingFilters) TakePendingTxs(f *PollingFilter) []common.Hash {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	hashes := f.pendingTxs
+	f.pendingTxs = nil
+	return hashes
+}
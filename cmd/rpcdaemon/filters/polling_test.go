@@ -0,0 +1,67 @@
+package filters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/eth/filters"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollingFilters(t *testing.T) {
+	pf := NewPollingFilters(time.Hour)
+	defer pf.Stop()
+
+	crit := filters.FilterCriteria{Addresses: []common.Address{common.HexToAddress("0x1")}}
+	id, f := pf.Install(LogsFilter, crit, 10)
+	polled, ok := pf.Poll(id)
+	require.True(t, ok)
+	require.Equal(t, f, polled)
+	require.Equal(t, LogsFilter, polled.Type)
+	require.Equal(t, crit, polled.Crit)
+	require.Equal(t, 10, int(polled.LastBlock))
+
+	pf.AddPendingTxs(f, []common.Hash{{1}})
+	pf.AddPendingTxs(f, []common.Hash{{2}})
+	require.Equal(t, []common.Hash{{1}, {2}}, pf.TakePendingTxs(f))
+	require.Empty(t, pf.TakePendingTxs(f))
+
+	require.True(t, pf.Uninstall(id))
+	require.False(t, pf.Uninstall(id))
+	_, ok = pf.Poll(id)
+	require.False(t, ok)
+	select {
+	case <-f.Done():
+	default:
+		t.Fatal("uninstalled filter is not done")
+	}
+}
+
+func TestPollingFiltersTimeout(t *testing.T) {
+	pf := NewPollingFilters(10 * time.Millisecond)
+	defer pf.Stop()
+
+	id, f := pf.Install(BlocksFilter, filters.FilterCriteria{}, 0)
+	select {
+	case <-f.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("filter which is not polled does not expire")
+	}
+	_, ok := pf.Poll(id)
+	require.False(t, ok)
+	require.False(t, pf.Uninstall(id))
+}
+
+func TestPollingFiltersStop(t *testing.T) {
+	pf := NewPollingFilters(time.Hour)
+	id, f := pf.Install(PendingTxsFilter, filters.FilterCriteria{}, 0)
+	pf.Stop()
+	select {
+	case <-f.Done():
+	default:
+		t.Fatal("filter is not uninstalled on stop")
+	}
+	_, ok := pf.Poll(id)
+	require.False(t, ok)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/ledgerwatch/turbo-geth/cmd/rpcdaemon/cli"
 	"github.com/ledgerwatch/turbo-geth/cmd/rpcdaemon/commands"
+	"github.com/ledgerwatch/turbo-geth/cmd/rpcdaemon/filters"
 	"github.com/ledgerwatch/turbo-geth/cmd/utils"
 	"github.com/ledgerwatch/turbo-geth/log"
 	"github.com/spf13/cobra"
@@ -24,7 +25,12 @@ func main() {
 		}
 		defer db.Close()
 
-		var apiList = commands.APIList(db, backend, *cfg, nil)
+		var ff *filters.Filters
+		if backend != nil {
+			ff = filters.New(cmd.Context(), backend)
+		}
+
+		var apiList = commands.APIList(db, backend, ff, *cfg, nil)
 		return cli.StartRpcServer(cmd.Context(), *cfg, apiList)
 	}
 
@@ -14,7 +14,7 @@ func init() {
 
 var checkIndexCMD = &cobra.Command{
 	Use:   "checkIndex",
-	Short: "Index checker",
+	Short: "Checks that the keys of the changeset bucket are in the history index bucket",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return verify.CheckIndex(chaindata, changeSetBucket, indexBucket)
 	},
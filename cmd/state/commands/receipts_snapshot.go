@@ -0,0 +1,22 @@
+package commands
+
+import (
+	"github.com/ledgerwatch/turbo-geth/cmd/state/generate"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	withChaindata(generateReceiptsSnapshotCmd)
+	withSnapshotFile(generateReceiptsSnapshotCmd)
+	withSnapshotData(generateReceiptsSnapshotCmd)
+	withBlock(generateReceiptsSnapshotCmd)
+	rootCmd.AddCommand(generateReceiptsSnapshotCmd)
+}
+
+var generateReceiptsSnapshotCmd = &cobra.Command{
+	Use:   "receiptsSnapshot",
+	Short: "Generate receipts snapshot",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return generate.ReceiptsSnapshot(chaindata, snapshotFile, block, snapshotDir, snapshotMode)
+	},
+}
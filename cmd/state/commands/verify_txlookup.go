@@ -12,8 +12,8 @@ func init() {
 
 var verifyTxLookupCmd = &cobra.Command{
 	Use:   "verifyTxLookup",
-	Short: "Generate tx lookup index",
+	Short: "Checks that the transactions of canonical blocks are in tx lookup index",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return verify.ValidateTxLookups(chaindata)
+		return verify.Fsck(chaindata, "tx_lookup")
 	},
 }
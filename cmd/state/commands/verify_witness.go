@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"github.com/ledgerwatch/turbo-geth/cmd/state/verify"
+	"github.com/spf13/cobra"
+)
+
+var (
+	blockRlpFile string
+	witnessFile  string
+	witnessRpc   string
+	witnessRoot  string
+)
+
+func init() {
+	verifyWitnessCmd.Flags().StringVar(&blockRlpFile, "blockRlp", "", "path to the file with the RLP of the block (binary or 0x-prefixed hex)")
+	verifyWitnessCmd.Flags().StringVar(&witnessFile, "witness", "", "path to the file with the witness of the block (binary or 0x-prefixed hex), if empty the witness is requested from --rpc")
+	verifyWitnessCmd.Flags().StringVar(&witnessRpc, "rpc", "", "url of the node serving tg_getBlockWitness, eth_getBlockByNumber and eth_getBlockByHash, for example: http://127.0.0.1:8545")
+	verifyWitnessCmd.Flags().StringVar(&witnessRoot, "preroot", "", "state root before the block, if empty the state root of the parent block is requested from --rpc")
+	must(verifyWitnessCmd.MarkFlagRequired("blockRlp"))
+	must(verifyWitnessCmd.MarkFlagFilename("blockRlp", ""))
+	must(verifyWitnessCmd.MarkFlagFilename("witness", ""))
+	rootCmd.AddCommand(verifyWitnessCmd)
+}
+
+var verifyWitnessCmd = &cobra.Command{
+	Use:   "verifyWitness",
+	Short: "Execute a block statelessly from its witness and check the state root",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return verify.VerifyWitness(genesis.Config, blockRlpFile, witnessFile, witnessRpc, witnessRoot)
+	},
+}
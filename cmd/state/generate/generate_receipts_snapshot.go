@@ -0,0 +1,82 @@
+package generate
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+	"github.com/ledgerwatch/turbo-geth/turbo/torrent"
+)
+
+// ReceiptsSnapshot generates snapshot of receipts and logs of the blocks up to `toBlock`.
+// Receipts are written by the Execution stage, so the node must not prune them (see `--storage-mode`).
+func ReceiptsSnapshot(dbPath, snapshotPath string, toBlock uint64, snapshotDir string, snapshotMode string) error {
+	kv := ethdb.NewLMDB().Path(dbPath).MustOpen()
+	var err error
+	if snapshotDir != "" {
+		var mode torrent.SnapshotMode
+		mode, err = torrent.SnapshotModeFromString(snapshotMode)
+		if err != nil {
+			return err
+		}
+
+		kv, err = torrent.WrapBySnapshots(kv, snapshotDir, mode)
+		if err != nil {
+			return err
+		}
+	}
+	snkv := ethdb.NewLMDB().WithBucketsConfig(torrent.ReceiptsSnapshotBuckets).Path(snapshotPath).MustOpen()
+	db := ethdb.NewObjectDatabase(kv)
+	defer db.Close()
+	sndb := ethdb.NewObjectDatabase(snkv)
+
+	t := time.Now()
+	executedTo, _, err := stages.GetStageProgress(db, stages.Execution)
+	if err != nil {
+		return err
+	}
+	if toBlock > executedTo {
+		return fmt.Errorf("block %d is not executed yet, last executed block: %d", toBlock, executedTo)
+	}
+	hash, err := rawdb.ReadCanonicalHash(db, toBlock)
+	if err != nil {
+		return fmt.Errorf("getting canonical hash for block %d: %v", toBlock, err)
+	}
+
+	// keys of both buckets start with the block number
+	for _, bucket := range []string{dbutils.BlockReceiptsPrefix, dbutils.Log} {
+		if err = copyBucket(db, sndb, bucket, nil, dbutils.ReceiptsKey(toBlock+1)); err != nil {
+			return err
+		}
+	}
+
+	err = sndb.Put(dbutils.SnapshotInfoBucket, []byte(dbutils.SnapshotReceiptsHeadNumber), big.NewInt(0).SetUint64(toBlock).Bytes())
+	if err != nil {
+		log.Crit("SnapshotReceiptsHeadNumber error", "err", err)
+		return err
+	}
+	err = sndb.Put(dbutils.SnapshotInfoBucket, []byte(dbutils.SnapshotReceiptsHeadHash), hash.Bytes())
+	if err != nil {
+		log.Crit("SnapshotReceiptsHeadHash error", "err", err)
+		return err
+	}
+	sndb.Close()
+	err = os.Remove(snapshotPath + "/lock.mdb")
+	if err != nil {
+		log.Warn("Remove lock", "err", err)
+		return err
+	}
+
+	infoHash, err := torrent.SnapshotInfoHash(snapshotPath)
+	if err != nil {
+		return err
+	}
+	log.Info("Finished", "block", toBlock, "metainfo hash", infoHash.HexString(), "duration", time.Since(t))
+	return nil
+}
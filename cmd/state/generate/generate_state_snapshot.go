@@ -0,0 +1,133 @@
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+	"github.com/ledgerwatch/turbo-geth/turbo/torrent"
+)
+
+// StateSnapshot generates snapshot of the plain state and contract codes at the block `toBlock`.
+// The state is copied as of the last executed block and then unwound to `toBlock` with change sets,
+// in the same way as the Execution stage unwinds it.
+func StateSnapshot(dbPath, snapshotPath string, toBlock uint64, snapshotDir string, snapshotMode string) error {
+	kv := ethdb.NewLMDB().Path(dbPath).MustOpen()
+	var err error
+	if snapshotDir != "" {
+		var mode torrent.SnapshotMode
+		mode, err = torrent.SnapshotModeFromString(snapshotMode)
+		if err != nil {
+			return err
+		}
+
+		kv, err = torrent.WrapBySnapshots(kv, snapshotDir, mode)
+		if err != nil {
+			return err
+		}
+	}
+	snkv := ethdb.NewLMDB().WithBucketsConfig(func(defaultBuckets dbutils.BucketsCfg) dbutils.BucketsCfg {
+		cfg := torrent.StateSnapshotBuckets(defaultBuckets)
+		// required to unwind the state
+		cfg[dbutils.PlainAccountChangeSetBucket] = dbutils.BucketConfigItem{}
+		cfg[dbutils.PlainStorageChangeSetBucket] = dbutils.BucketConfigItem{}
+		cfg[dbutils.SyncStageProgress] = dbutils.BucketConfigItem{}
+		return cfg
+	}).Path(snapshotPath).MustOpen()
+	db := ethdb.NewObjectDatabase(kv)
+	defer db.Close()
+	sndb := ethdb.NewObjectDatabase(snkv)
+
+	t := time.Now()
+	executedTo, _, err := stages.GetStageProgress(db, stages.Execution)
+	if err != nil {
+		return err
+	}
+	if toBlock > executedTo {
+		return fmt.Errorf("block %d is not executed yet, last executed block: %d", toBlock, executedTo)
+	}
+	hash, err := rawdb.ReadCanonicalHash(db, toBlock)
+	if err != nil {
+		return fmt.Errorf("getting canonical hash for block %d: %v", toBlock, err)
+	}
+
+	for _, bucket := range []string{dbutils.PlainStateBucket, dbutils.PlainContractCodeBucket, dbutils.CodeBucket} {
+		if err = copyBucket(db, sndb, bucket, nil, nil); err != nil {
+			return err
+		}
+	}
+	if toBlock < executedTo {
+		log.Info("Unwinding state", "from", executedTo, "to", toBlock)
+		for _, bucket := range []string{dbutils.PlainAccountChangeSetBucket, dbutils.PlainStorageChangeSetBucket} {
+			if err = copyBucket(db, sndb, bucket, dbutils.EncodeTimestamp(toBlock+1), nil); err != nil {
+				return err
+			}
+		}
+		u := &stagedsync.UnwindState{Stage: stages.Execution, UnwindPoint: toBlock}
+		s := &stagedsync.StageState{Stage: stages.Execution, BlockNumber: executedTo}
+		if err = stagedsync.UnwindExecutionStage(u, s, sndb, false); err != nil {
+			return err
+		}
+	}
+
+	err = sndb.Put(dbutils.SnapshotInfoBucket, []byte(dbutils.SnapshotStateHeadNumber), big.NewInt(0).SetUint64(toBlock).Bytes())
+	if err != nil {
+		log.Crit("SnapshotStateHeadNumber error", "err", err)
+		return err
+	}
+	err = sndb.Put(dbutils.SnapshotInfoBucket, []byte(dbutils.SnapshotStateHeadHash), hash.Bytes())
+	if err != nil {
+		log.Crit("SnapshotStateHeadHash error", "err", err)
+		return err
+	}
+	sndb.Close()
+	err = os.Remove(snapshotPath + "/lock.mdb")
+	if err != nil {
+		log.Warn("Remove lock", "err", err)
+		return err
+	}
+
+	infoHash, err := torrent.SnapshotInfoHash(snapshotPath)
+	if err != nil {
+		return err
+	}
+	log.Info("Finished", "block", toBlock, "metainfo hash", infoHash.HexString(), "duration", time.Since(t))
+	return nil
+}
+
+// copyBucket copies keys in range [startKey, endKey) of the bucket, nil endKey means the end of the bucket
+func copyBucket(from ethdb.Getter, to ethdb.Database, bucket string, startKey []byte, endKey []byte) error {
+	chunkFile := 30000
+	tuples := make(ethdb.MultiPutTuples, 0, chunkFile*3+100)
+	if err := from.Walk(bucket, startKey, 0, func(k, v []byte) (bool, error) {
+		if endKey != nil && bytes.Compare(k, endKey) >= 0 {
+			return false, nil
+		}
+		tuples = append(tuples, []byte(bucket), common.CopyBytes(k), common.CopyBytes(v))
+		if len(tuples) >= chunkFile {
+			if _, err := to.MultiPut(tuples...); err != nil {
+				return false, err
+			}
+			log.Info("Committed", "bucket", bucket, "key", fmt.Sprintf("%x", k))
+			tuples = tuples[:0]
+		}
+		return true, nil
+	}); err != nil {
+		return err
+	}
+	if len(tuples) > 0 {
+		if _, err := to.MultiPut(tuples...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package verify
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/turbo/fsck"
+)
+
+// Fsck runs the checks of the database consistency, see `integration fsck` for all of them and the repair
+func Fsck(chaindata string, names ...string) error {
+	db := ethdb.MustOpen(chaindata)
+	defer db.Close()
+
+	checks, err := fsck.Lookup(names)
+	if err != nil {
+		return err
+	}
+	return runChecks(db, checks)
+}
+
+// CheckIndex checks that the keys of the changeset bucket are in the history index of the index bucket
+func CheckIndex(chaindata string, changeSetBucket string, indexBucket string) error {
+	db := ethdb.MustOpen(chaindata)
+	defer db.Close()
+
+	check, err := fsck.HistoryIndexCheck(changeSetBucket, indexBucket)
+	if err != nil {
+		return err
+	}
+	return runChecks(db, []*fsck.Check{check})
+}
+
+func runChecks(db ethdb.Database, checks []*fsck.Check) error {
+	ch := make(chan os.Signal, 1)
+	quitCh := make(chan struct{})
+	signal.Notify(ch, os.Interrupt)
+	go func() {
+		<-ch
+		close(quitCh)
+	}()
+	report, err := fsck.Run(db, checks, fsck.Options{Quit: quitCh})
+	if err != nil {
+		return err
+	}
+	for _, f := range report.Findings {
+		fmt.Println(f)
+	}
+	for name, reason := range report.Skipped {
+		fmt.Printf("%s skipped: %s\n", name, reason)
+	}
+	if len(report.Findings) > 0 {
+		return fmt.Errorf("%d inconsistencies found", len(report.Findings))
+	}
+	fmt.Println("Check was succesful")
+	return nil
+}
@@ -0,0 +1,150 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/hexutil"
+	"github.com/ledgerwatch/turbo-geth/consensus"
+	"github.com/ledgerwatch/turbo-geth/consensus/ethash"
+	"github.com/ledgerwatch/turbo-geth/core"
+	"github.com/ledgerwatch/turbo-geth/core/state"
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/core/vm"
+	"github.com/ledgerwatch/turbo-geth/log"
+	"github.com/ledgerwatch/turbo-geth/params"
+	"github.com/ledgerwatch/turbo-geth/rlp"
+	"github.com/ledgerwatch/turbo-geth/rpc"
+	"github.com/ledgerwatch/turbo-geth/turbo/trie"
+)
+
+// VerifyWitness executes the block from `blockFile` (RLP, binary or hex) on top of the state given by its witness only,
+// and checks the state root (and the receipts root after Byzantium) the block commits to.
+// The witness is read from `witnessFile` (as written by `trie.Witness.WriteTo`, binary or hex), or requested via
+// `tg_getBlockWitness` from the node at `rpcURL` if the file is not given. The state root before the block is `preRoot`,
+// or the state root of the parent block requested from `rpcURL` if `preRoot` is empty.
+// The headers for the BLOCKHASH opcode are requested from `rpcURL` as well, without it BLOCKHASH returns zero hashes
+// for all blocks but the parent.
+func VerifyWitness(chainConfig *params.ChainConfig, blockFile, witnessFile, rpcURL, preRoot string) error {
+	blockRlp, err := readBytes(blockFile)
+	if err != nil {
+		return fmt.Errorf("reading block: %w", err)
+	}
+	block := new(types.Block)
+	if err = rlp.DecodeBytes(blockRlp, block); err != nil {
+		return fmt.Errorf("decoding block: %w", err)
+	}
+	blockNum := block.NumberU64()
+	if blockNum == 0 {
+		return fmt.Errorf("genesis block has no witness")
+	}
+
+	var client *rpc.Client
+	if rpcURL != "" {
+		if client, err = rpc.Dial(rpcURL); err != nil {
+			return err
+		}
+		defer client.Close()
+	}
+
+	var witnessBytes []byte
+	switch {
+	case witnessFile != "":
+		if witnessBytes, err = readBytes(witnessFile); err != nil {
+			return fmt.Errorf("reading witness: %w", err)
+		}
+	case client != nil:
+		var result hexutil.Bytes
+		if err = client.Call(&result, "tg_getBlockWitness", hexutil.Uint64(blockNum)); err != nil {
+			return fmt.Errorf("requesting witness: %w", err)
+		}
+		witnessBytes = result
+	default:
+		return fmt.Errorf("either a witness file or an rpc url is required")
+	}
+
+	var root common.Hash
+	switch {
+	case preRoot != "":
+		root = common.HexToHash(preRoot)
+	case client != nil:
+		var parent *types.Header
+		if err = client.Call(&parent, "eth_getBlockByNumber", hexutil.Uint64(blockNum-1), false); err != nil {
+			return fmt.Errorf("requesting parent header: %w", err)
+		}
+		if parent == nil {
+			return fmt.Errorf("parent block %d not found", blockNum-1)
+		}
+		root = parent.Root
+	default:
+		return fmt.Errorf("either the state root before the block or an rpc url is required")
+	}
+
+	witness, err := trie.NewWitnessFromReader(bytes.NewReader(witnessBytes), false /* trace */)
+	if err != nil {
+		return fmt.Errorf("decoding witness: %w", err)
+	}
+	// Serializing the witness back gives the breakdown of its size
+	stats, err := witness.WriteTo(ioutil.Discard)
+	if err != nil {
+		return err
+	}
+	log.Info("Witness size", "block", blockNum,
+		"total", stats.BlockWitnessSize(),
+		"codes", stats.CodesSize(),
+		"leafKeys", stats.LeafKeysSize(),
+		"leafValues", stats.LeafValuesSize(),
+		"structure", stats.StructureSize(),
+		"hashes", stats.HashesSize())
+
+	s, err := state.NewStateless(root, witness, blockNum-1, false /* trace */, false /* isBinary */)
+	if err != nil {
+		return fmt.Errorf("building state from witness: %w", err)
+	}
+	s.SetBlockNr(blockNum)
+	chainContext := &remoteChainContext{engine: ethash.NewFullFaker(), client: client}
+	if _, err = core.ExecuteBlockEphemerally(chainConfig, &vm.Config{}, chainContext, chainContext.engine, block, s, s); err != nil {
+		return fmt.Errorf("executing block %d: %w", blockNum, err)
+	}
+	if err = s.CheckRoot(block.Root()); err != nil {
+		return fmt.Errorf("block %d: %w", blockNum, err)
+	}
+	log.Info("Witness verified", "block", blockNum, "hash", block.Hash(), "root", block.Root())
+	return nil
+}
+
+// readBytes reads a file with either raw bytes, or bytes hex encoded with the 0x prefix (as returned by the RPC)
+func readBytes(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("0x")) {
+		return hexutil.Decode(string(trimmed))
+	}
+	return data, nil
+}
+
+// remoteChainContext serves the headers from the remote node, if any
+type remoteChainContext struct {
+	engine consensus.Engine
+	client *rpc.Client
+}
+
+func (c *remoteChainContext) Engine() consensus.Engine {
+	return c.engine
+}
+
+func (c *remoteChainContext) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if c.client == nil {
+		return nil
+	}
+	var header *types.Header
+	if err := c.client.Call(&header, "eth_getBlockByHash", hash, false); err != nil {
+		log.Warn("Failed to request header", "number", number, "hash", hash, "err", err)
+		return nil
+	}
+	return header
+}
@@ -0,0 +1,108 @@
+package verify
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/hexutil"
+	"github.com/ledgerwatch/turbo-geth/consensus/ethash"
+	"github.com/ledgerwatch/turbo-geth/core"
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/core/vm"
+	"github.com/ledgerwatch/turbo-geth/crypto"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/params"
+	"github.com/ledgerwatch/turbo-geth/rlp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyWitness(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	dir, err := ioutil.TempDir("", "verifywitness")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	bank := crypto.PubkeyToAddress(key.PublicKey)
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{bank: {Balance: big.NewInt(1000000000000000000)}},
+	}
+	genesis := gspec.MustCommit(db)
+	signer := types.HomesteadSigner{}
+
+	// Stores the first word of the call data into the slot 0
+	runtimeCode := []byte{byte(vm.PUSH1), 0, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+	deploy := append([]byte{
+		byte(vm.PUSH1), byte(len(runtimeCode)), byte(vm.PUSH1), 12, byte(vm.PUSH1), 0, byte(vm.CODECOPY),
+		byte(vm.PUSH1), byte(len(runtimeCode)), byte(vm.PUSH1), 0, byte(vm.RETURN),
+	}, runtimeCode...)
+	contract := crypto.CreateAddress(bank, 0)
+
+	engine := ethash.NewFaker()
+	blocks, _, err := core.GenerateChain(gspec.Config, genesis, engine, db, 2, func(i int, block *core.BlockGen) {
+		txn := types.NewContractCreation(block.TxNonce(bank), new(uint256.Int), 100000, new(uint256.Int), deploy)
+		if i == 1 {
+			txn = types.NewTransaction(block.TxNonce(bank), contract, new(uint256.Int), 100000, new(uint256.Int), common.LeftPadBytes([]byte{7}, 32))
+		}
+		signedTx, err1 := types.SignTx(txn, signer, key)
+		require.NoError(t, err1)
+		block.AddTx(signedTx)
+	}, false /* intermediateHashes */)
+	require.NoError(t, err)
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil, core.NewTxSenderCacher(runtime.NumCPU()))
+	require.NoError(t, err)
+	defer chain.Stop()
+	_, err = stagedsync.InsertBlocksInStages(db, gspec.Config, engine, blocks, chain)
+	require.NoError(t, err)
+
+	tx, err := db.Begin(context.Background(), ethdb.RO)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	cc := &core.TinyChainContext{}
+	cc.SetDB(tx)
+	cc.SetEngine(engine)
+	witness, err := stagedsync.BuildBlockWitness(tx, 2, gspec.Config, cc)
+	require.NoError(t, err)
+
+	blockRlp, err := rlp.EncodeToBytes(blocks[1])
+	require.NoError(t, err)
+	blockFile := filepath.Join(dir, "block.rlp")
+	// hex encoded, as returned by the RPC
+	require.NoError(t, ioutil.WriteFile(blockFile, []byte(hexutil.Encode(blockRlp)), 0644))
+	witnessFile := filepath.Join(dir, "witness.bin")
+	f, err := os.Create(witnessFile)
+	require.NoError(t, err)
+	_, err = witness.WriteTo(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	witnessBytes, err := ioutil.ReadFile(witnessFile)
+	require.NoError(t, err)
+	preRoot := blocks[0].Root().Hex()
+
+	require.NoError(t, VerifyWitness(gspec.Config, blockFile, witnessFile, "", preRoot))
+
+	// the state before the block is not the one the witness was built for
+	require.Error(t, VerifyWitness(gspec.Config, blockFile, witnessFile, "", genesis.Root().Hex()))
+
+	// a byte in the middle or the last byte of the witness is changed
+	corrupted := filepath.Join(dir, "corrupted.bin")
+	for _, i := range []int{len(witnessBytes) / 2, len(witnessBytes) - 1} {
+		data := common.CopyBytes(witnessBytes)
+		data[i] ^= 0xff
+		require.NoError(t, ioutil.WriteFile(corrupted, data, 0644))
+		require.Error(t, VerifyWitness(gspec.Config, blockFile, corrupted, "", preRoot), "byte %d", i)
+	}
+
+	// either the witness or the rpc url is required
+	require.Error(t, VerifyWitness(gspec.Config, blockFile, "", "", preRoot))
+}
@@ -7,9 +7,12 @@ import (
 
 	"github.com/ledgerwatch/turbo-geth/cmd/utils"
 	"github.com/ledgerwatch/turbo-geth/eth/stagedsync"
+	"github.com/ledgerwatch/turbo-geth/internal/flags"
 	"github.com/ledgerwatch/turbo-geth/log"
 	turbocli "github.com/ledgerwatch/turbo-geth/turbo/cli"
 	"github.com/ledgerwatch/turbo-geth/turbo/node"
+	"github.com/ledgerwatch/turbo-geth/turbo/plugins"
+	"github.com/ledgerwatch/turbo-geth/turbo/shards"
 	"github.com/urfave/cli"
 )
 
@@ -21,6 +24,8 @@ var (
 func main() {
 	// creating a turbo-api app with all defaults
 	app := turbocli.MakeApp(runTurboGeth, turbocli.DefaultFlags)
+	app.Commands = []cli.Command{turbocli.DatabaseCommand}
+	cli.CommandHelpTemplate = flags.OriginCommandHelpTemplate
 	if err := app.Run(os.Args); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -28,23 +33,43 @@ func main() {
 }
 
 func runTurboGeth(cliCtx *cli.Context) {
+	// loading third-party stages from plugins and adding them to the default ones
+	extraStages, err := plugins.Load(cliCtx.GlobalString(turbocli.StagePluginsFlag.Name), cliCtx.GlobalString(turbocli.RemoteStagesFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to load stages: %v", err)
+	}
+	stageBuilders, unwindOrder, err := stagedsync.WithExtraStages(stagedsync.DefaultStages(), stagedsync.DefaultUnwindOrder(), extraStages)
+	if err != nil {
+		utils.Fatalf("Failed to add stages: %v", err)
+	}
+
+	var syncParams stagedsync.OptionalParameters
+	if addr := cliCtx.GlobalString(turbocli.ShardDispatcherFlag.Name); addr != "" {
+		// the blocks are executed together with other nodes, each one keeps only its shard of the state
+		syncParams.PartialState = true
+		syncParams.ExecuteBlocks, err = shards.NewExecutor(addr, cliCtx.GlobalInt(turbocli.ShardBitsFlag.Name), cliCtx.GlobalInt(turbocli.ShardIDFlag.Name))
+		if err != nil {
+			utils.Fatalf("Failed to set up sharded execution: %v", err)
+		}
+	}
+
 	// creating staged sync with all default parameters
 	sync := stagedsync.New(
-		stagedsync.DefaultStages(),
-		stagedsync.DefaultUnwindOrder(),
-		stagedsync.OptionalParameters{},
+		stageBuilders,
+		unwindOrder,
+		syncParams,
 	)
 
 	ctx := utils.RootContext()
 
-	// initializing the node and providing the current git commit there
-	tg := node.New(cliCtx, sync, node.Params{GitCommit: gitCommit})
+	// initializing the node and providing the current git commit there, buckets of the third-party stages are created as well
+	tg := node.New(cliCtx, sync, node.Params{GitCommit: gitCommit, CustomBuckets: stagedsync.ExtraBuckets(extraStages)})
 	tg.SetP2PListenFunc(func(network, addr string) (net.Listener, error) {
 		var lc net.ListenConfig
 		return lc.Listen(ctx, network, addr)
 	})
 	// running the node
-	err := tg.Serve()
+	err = tg.Serve()
 
 	if err != nil {
 		log.Error("error while serving a turbo-geth node", "err", err)
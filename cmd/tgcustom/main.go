@@ -40,44 +40,49 @@ func main() {
 	}
 }
 
-func syncStages(ctx *cli.Context) stagedsync.StageBuilders {
-	return append(
-		stagedsync.DefaultStages(), // adding all default stages
-		stagedsync.StageBuilder{ // adding our custom stage
-			ID: stages.SyncStage("ch.torquem.demo.tgcustom.CUSTOM_STAGE"),
-			Build: func(world stagedsync.StageParameters) *stagedsync.Stage {
-				return &stagedsync.Stage{
-					ID:          stages.SyncStage("ch.torquem.demo.tgcustom.CUSTOM_STAGE"),
-					Description: "Custom Stage",
-					ExecFunc: func(s *stagedsync.StageState, _ stagedsync.Unwinder) error {
-						fmt.Println("hello from the custom stage", ctx.String(flag.Name))
-						val, err := world.TX.Get(customBucketName, []byte("test"))
-						fmt.Println("val", string(val), "err", err)
-						if err := world.TX.Put(customBucketName, []byte("test"), []byte(ctx.String(flag.Name))); err != nil {
-							return err
-						}
-						s.Done()
-						return nil
-					},
-					UnwindFunc: func(u *stagedsync.UnwindState, s *stagedsync.StageState) error {
-						fmt.Println("hello from the custom stage unwind", ctx.String(flag.Name))
-						if err := world.TX.Delete(customBucketName, []byte("test"), nil); err != nil {
-							return err
-						}
-						return u.Done(world.TX)
-					},
-				}
-			},
+// defining our custom stage, it goes right after the Finish stage
+func customStage(ctx *cli.Context) stagedsync.ExtraStage {
+	return stagedsync.ExtraStage{
+		ID:          stages.SyncStage("ch.torquem.demo.tgcustom.CUSTOM_STAGE"),
+		Description: "Custom Stage",
+		After:       stages.Finish,
+		Buckets: dbutils.BucketsCfg{
+			customBucketName: {},
 		},
-	)
+		Forward: func(s *stagedsync.StageState, db ethdb.Database, _ <-chan struct{}) error {
+			fmt.Println("hello from the custom stage", ctx.String(flag.Name))
+			val, err := db.Get(customBucketName, []byte("test"))
+			fmt.Println("val", string(val), "err", err)
+			if err := db.Put(customBucketName, []byte("test"), []byte(ctx.String(flag.Name))); err != nil {
+				return err
+			}
+			s.Done()
+			return nil
+		},
+		Unwind: func(u *stagedsync.UnwindState, s *stagedsync.StageState, db ethdb.Database, _ <-chan struct{}) error {
+			fmt.Println("hello from the custom stage unwind", ctx.String(flag.Name))
+			if err := db.Delete(customBucketName, []byte("test"), nil); err != nil {
+				return err
+			}
+			return u.Done(db)
+		},
+	}
 }
 
 // turbo-geth main function
 func runTurboGeth(ctx *cli.Context) {
+	extraStages := []stagedsync.ExtraStage{customStage(ctx)}
+	// adding our custom stage to all default stages
+	stageBuilders, unwindOrder, err := stagedsync.WithExtraStages(stagedsync.DefaultStages(), stagedsync.DefaultUnwindOrder(), extraStages)
+	if err != nil {
+		log.Error("error while adding the custom stage", "err", err)
+		return
+	}
+
 	// creating a staged sync with our new stage
 	sync := stagedsync.New(
-		syncStages(ctx),
-		stagedsync.DefaultUnwindOrder(),
+		stageBuilders,
+		unwindOrder,
 		stagedsync.OptionalParameters{
 			StateReaderBuilder: func(getter ethdb.Getter) state.StateReader {
 				// put your custom caching code here
@@ -90,14 +95,12 @@ func runTurboGeth(ctx *cli.Context) {
 		},
 	)
 
-	// running a node and initializing a custom bucket with all default settings
+	// running a node and initializing the buckets of our stage with all default settings
 	tg := node.New(ctx, sync, node.Params{
-		CustomBuckets: map[string]dbutils.BucketConfigItem{
-			customBucketName: {},
-		},
+		CustomBuckets: stagedsync.ExtraBuckets(extraStages),
 	})
 
-	err := tg.Serve()
+	err = tg.Serve()
 
 	if err != nil {
 		log.Error("error while serving a turbo-geth node", "err", err)
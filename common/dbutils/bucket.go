@@ -102,6 +102,9 @@ var (
 	// DatabaseInfoBucket is used to store information about data layout.
 	DatabaseInfoBucket = "DBINFO"
 	SnapshotInfoBucket = "SNINFO"
+	// SnapshotDeletesBucket keeps deletions of the entries of snapshots, see ethdb.SnapshotKV
+	// bucket name + 0x00 + key length (uint16 big endian) + key + value (only for DupSort buckets) -> 0x01
+	SnapshotDeletesBucket = "SNDEL"
 
 	// databaseVerisionKey tracks the current database version.
 	DatabaseVerisionKey = "DatabaseVersion"
@@ -132,6 +135,24 @@ var (
 	CallFromIndex = "call_from_index"
 	CallToIndex   = "call_to_index"
 
+	// Addresses seen in the call traces of each block, used to prune CallFromIndex and CallToIndex
+	// block number (uint64 big endian) -> sorted list of [address] + [1 byte of flags: 1 - calls from the address, 2 - calls to the address]
+	CallTraceSet = "call_trace_set"
+
+	// Witnesses of blocks, which are enough to re-execute a block without the state (see state.Stateless)
+	// block number (uint64 big endian) -> witness serialized by trie.Witness.WriteTo
+	BlockWitnessBucket = "block_witness"
+
+	// Binary trie commitment of the state, kept alongside the hexary one (see `b` in --storage-mode)
+	// BinaryIntermediateHashBucket: prefix of hash of address of account (whole bytes) -> hash of the branch node of the binary trie at this prefix
+	// BinaryStorageIntermediateHashBucket: hash of address of account + incarnation + prefix of hash of storage key (whole bytes) -> hash of the branch node of the binary storage trie at this prefix
+	// BinaryStorageRootBucket: hash of address of account + incarnation -> root of the binary trie of the storage of the account
+	// BinaryStateRootBucket: block number (uint64 big endian) -> root of the binary trie of the state after the block
+	BinaryIntermediateHashBucket        = "binary_ih"
+	BinaryStorageIntermediateHashBucket = "binary_storage_ih"
+	BinaryStorageRootBucket             = "binary_storage_root"
+	BinaryStateRootBucket               = "binary_state_root"
+
 	TxLookupPrefix  = "l" // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	BloomBitsPrefix = "B" // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
 
@@ -147,6 +168,8 @@ var (
 	// Position to where to unwind sync stages: stageName -> stageData
 	SyncStageUnwind     = "SSU2"
 	SyncStageUnwindOld1 = "SSU"
+	// Position to which sync stages pruned their data: stageName -> stageData
+	SyncStagePrune = "SSPR"
 
 	CliqueBucket = "clique-"
 
@@ -167,6 +190,11 @@ var (
 	// it stores stages progress to understand in which context was executed migration
 	// in case of bug-report developer can ask content of this bucket
 	Migrations = "migrations"
+
+	// migrationName + 0x00 -> list of the buckets copied before applying the migration
+	// migrationName + 0x00 + bucketName + 0x00 + sequenceNumber -> key and value of the copied bucket
+	// it allows to undo the migration, see migrations.Migrator.Revert
+	MigrationsBackup = "migrationsBackup"
 )
 
 // Keys
@@ -182,13 +210,31 @@ var (
 	StorageModeTxIndex = []byte("smTxIndex")
 	//StorageModeCallTraces - does not build index of call traces
 	StorageModeCallTraces = []byte("smCallTraces")
+	//StorageModeWitnesses - does node build and save block witnesses.
+	StorageModeWitnesses = []byte("smWitnesses")
+	//StorageModeBinaryTrie - does node maintain the binary trie commitment of the state.
+	StorageModeBinaryTrie = []byte("smBinaryTrie")
+	//PruneModeHistory - amount of recent blocks for which node keeps history, 0 - keeps forever.
+	PruneModeHistory = []byte("pmHistory")
+	//PruneModeReceipts - amount of recent blocks for which node keeps receipts.
+	PruneModeReceipts = []byte("pmReceipts")
+	//PruneModeTxIndex - amount of recent blocks for which node keeps transactions index.
+	PruneModeTxIndex = []byte("pmTxIndex")
+	//PruneModeCallTraces - amount of recent blocks for which node keeps index of call traces.
+	PruneModeCallTraces = []byte("pmCallTraces")
+	//PruneModeWitnesses - amount of recent blocks for which node keeps block witnesses.
+	PruneModeWitnesses = []byte("pmWitnesses")
 
 	HeadHeaderKey = "LastHeader"
 
-	SnapshotHeadersHeadNumber = "SnapshotLastHeaderNumber"
-	SnapshotHeadersHeadHash   = "SnapshotLastHeaderHash"
-	SnapshotBodyHeadNumber    = "SnapshotLastBodyNumber"
-	SnapshotBodyHeadHash      = "SnapshotLastBodyHash"
+	SnapshotHeadersHeadNumber  = "SnapshotLastHeaderNumber"
+	SnapshotHeadersHeadHash    = "SnapshotLastHeaderHash"
+	SnapshotBodyHeadNumber     = "SnapshotLastBodyNumber"
+	SnapshotBodyHeadHash       = "SnapshotLastBodyHash"
+	SnapshotStateHeadNumber    = "SnapshotStateBlockNumber"
+	SnapshotStateHeadHash      = "SnapshotStateBlockHash"
+	SnapshotReceiptsHeadNumber = "SnapshotLastReceiptsNumber"
+	SnapshotReceiptsHeadHash   = "SnapshotLastReceiptsHash"
 )
 
 // Metrics
@@ -224,6 +270,7 @@ var Buckets = []string{
 	CliqueBucket,
 	SyncStageProgress,
 	SyncStageUnwind,
+	SyncStagePrune,
 	PlainStateBucket,
 	PlainContractCodeBucket,
 	PlainAccountChangeSetBucket,
@@ -234,12 +281,20 @@ var Buckets = []string{
 	HeadFastBlockKey,
 	HeadHeaderKey,
 	Migrations,
+	MigrationsBackup,
 	LogTopicIndex,
 	LogAddressIndex,
 	SnapshotInfoBucket,
+	SnapshotDeletesBucket,
 	CallFromIndex,
 	CallToIndex,
+	CallTraceSet,
 	Log,
+	BlockWitnessBucket,
+	BinaryIntermediateHashBucket,
+	BinaryStorageIntermediateHashBucket,
+	BinaryStorageRootBucket,
+	BinaryStateRootBucket,
 }
 
 // DeprecatedBuckets - list of buckets which can be programmatically deleted - for example after migration
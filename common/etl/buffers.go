@@ -2,8 +2,10 @@ package etl
 
 import (
 	"bytes"
+	"runtime"
 	"sort"
 	"strconv"
+	"sync"
 
 	"github.com/c2h5oh/datasize"
 	"github.com/ledgerwatch/turbo-geth/common"
@@ -20,6 +22,8 @@ const (
 	SortableOldestAppearedBuffer
 
 	BufIOSize = 64 * 4096 // 64 pages | default is 1 page | increasing further doesn't show speedup on SSD
+
+	minParallelSortLen = 64 * 1024 // smaller buffers are sorted in one goroutine
 )
 
 var BufferOptimalSize = 256 * datasize.MB /*  var because we want to sometimes change it from tests or command-line flags */
@@ -99,7 +103,7 @@ func (b *sortableBuffer) Reset() {
 	b.size = 0
 }
 func (b *sortableBuffer) Sort() {
-	sort.Stable(b)
+	sortEntries(b.entries, b.comparator)
 }
 
 func (b *sortableBuffer) GetEntries() []sortableBufferEntry {
@@ -152,7 +156,7 @@ func (b *appendSortableBuffer) Sort() {
 	for i := range b.entries {
 		b.sortedBuf = append(b.sortedBuf, sortableBufferEntry{key: []byte(i), value: b.entries[i]})
 	}
-	sort.Stable(b)
+	sortEntries(b.sortedBuf, b.comparator)
 }
 
 func (b *appendSortableBuffer) Less(i, j int) bool {
@@ -231,7 +235,7 @@ func (b *oldestEntrySortableBuffer) Sort() {
 	for k, v := range b.entries {
 		b.sortedBuf = append(b.sortedBuf, sortableBufferEntry{key: []byte(k), value: v})
 	}
-	sort.Stable(b)
+	sortEntries(b.sortedBuf, b.comparator)
 }
 
 func (b *oldestEntrySortableBuffer) Less(i, j int) bool {
@@ -262,6 +266,87 @@ func (b *oldestEntrySortableBuffer) CheckFlushSize() bool {
 	return b.size >= b.optimalSize
 }
 
+// sortEntries sorts the entries stably. Big buffers are split into chunks, which are sorted
+// in parallel and then merged pairwise, also in parallel.
+func sortEntries(entries []sortableBufferEntry, cmp dbutils.CmpFunc) {
+	less := func(a, b *sortableBufferEntry) bool {
+		if cmp != nil {
+			return cmp(a.key, b.key, a.value, b.value) < 0
+		}
+		return bytes.Compare(a.key, b.key) < 0
+	}
+	workers := runtime.GOMAXPROCS(0)
+	if len(entries) < minParallelSortLen || workers < 2 {
+		sort.SliceStable(entries, func(i, j int) bool { return less(&entries[i], &entries[j]) })
+		return
+	}
+
+	var wg sync.WaitGroup
+	chunkSize := (len(entries) + workers - 1) / workers
+	var chunks [][]sortableBufferEntry
+	for from := 0; from < len(entries); from += chunkSize {
+		to := from + chunkSize
+		if to > len(entries) {
+			to = len(entries)
+		}
+		chunk := entries[from:to]
+		chunks = append(chunks, chunk)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sort.SliceStable(chunk, func(i, j int) bool { return less(&chunk[i], &chunk[j]) })
+		}()
+	}
+	wg.Wait()
+
+	// the sorted chunks go back and forth between the entries and the scratch slice, every pass halves their number
+	src, dst := entries, make([]sortableBufferEntry, len(entries))
+	for len(chunks) > 1 {
+		merged := make([][]sortableBufferEntry, 0, (len(chunks)+1)/2)
+		offset := 0
+		for i := 0; i < len(chunks); i += 2 {
+			if i+1 == len(chunks) {
+				out := dst[offset : offset+len(chunks[i])]
+				copy(out, chunks[i])
+				merged = append(merged, out)
+				break
+			}
+			a, b := chunks[i], chunks[i+1]
+			out := dst[offset : offset+len(a)+len(b)]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				mergeEntries(out, a, b, less)
+			}()
+			merged = append(merged, out)
+			offset += len(out)
+		}
+		wg.Wait()
+		chunks = merged
+		src, dst = dst, src
+	}
+	if &src[0] != &entries[0] {
+		copy(entries, src)
+	}
+}
+
+// mergeEntries merges two sorted slices, on equal keys the entries of a go first to keep the sort stable
+func mergeEntries(out, a, b []sortableBufferEntry, less func(a, b *sortableBufferEntry) bool) {
+	i, j, k := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		if less(&b[j], &a[i]) {
+			out[k] = b[j]
+			j++
+		} else {
+			out[k] = a[i]
+			i++
+		}
+		k++
+	}
+	k += copy(out[k:], a[i:])
+	copy(out[k:], b[j:])
+}
+
 func getBufferByType(tp int, size datasize.ByteSize) Buffer {
 	switch tp {
 	case SortableSliceBuffer:
@@ -126,6 +126,7 @@ func (c *Collector) Load(logPrefix string, db ethdb.Database, toBucket string, l
 
 func (c *Collector) Close(logPrefix string) {
 	disposeProviders(logPrefix, c.dataProviders)
+	c.dataProviders = nil
 }
 
 func loadFilesIntoBucket(logPrefix string, db ethdb.Database, bucket string, providers []dataProvider, loadFunc LoadFunc, args TransformArgs) error {
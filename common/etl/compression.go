@@ -0,0 +1,91 @@
+package etl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+type CompressionType int
+
+const (
+	NoCompression CompressionType = iota
+	// SnappyCompression - fast, compresses the file in independent blocks of 64KB (snappy framing format)
+	SnappyCompression
+	// ZstdCompression - slower, but makes the files much smaller
+	ZstdCompression
+)
+
+var Compression = NoCompression /* var because we want to sometimes change it from tests or command-line flags */
+
+var (
+	snappyMagic = []byte{0xff, 0x06, 0x00, 0x00, 's', 'N', 'a', 'P', 'p', 'Y'}
+	zstdMagic   = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+func (c CompressionType) String() string {
+	switch c {
+	case NoCompression:
+		return "none"
+	case SnappyCompression:
+		return "snappy"
+	case ZstdCompression:
+		return "zstd"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(c))
+	}
+}
+
+// ParseCompression parses the compression of the temp files: none, snappy or zstd
+func ParseCompression(s string) (CompressionType, error) {
+	for _, c := range []CompressionType{NoCompression, SnappyCompression, ZstdCompression} {
+		if strings.EqualFold(s, c.String()) {
+			return c, nil
+		}
+	}
+	return NoCompression, fmt.Errorf("unknown compression of etl files: %s, expected one of: none, snappy, zstd", s)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// newCompressor wraps the writer of a temp file, Close writes out the last block, but does not close the file
+func newCompressor(w io.Writer, compression CompressionType) (io.WriteCloser, error) {
+	switch compression {
+	case NoCompression:
+		return nopWriteCloser{w}, nil
+	case SnappyCompression:
+		return snappy.NewBufferedWriter(w), nil
+	case ZstdCompression:
+		// concurrency 1 - not to allocate the block encoders for every CPU, for every file
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+	default:
+		return nil, fmt.Errorf("unknown compression of etl files: %s", compression)
+	}
+}
+
+// newDecompressor recognises the compression of the temp file by its first bytes, so that the files
+// left over from the previous run can be read regardless of the current settings.
+// The returned function releases the resources of the decompressor.
+func newDecompressor(r *bufio.Reader) (io.Reader, func(), error) {
+	if prefix, err := r.Peek(len(snappyMagic)); err == nil && bytes.Equal(prefix, snappyMagic) {
+		return snappy.NewReader(r), func() {}, nil
+	}
+	if prefix, err := r.Peek(len(zstdMagic)); err == nil && bytes.Equal(prefix, zstdMagic) {
+		// concurrency 1 - all files are read at once while merging
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.Close, nil
+	}
+	return r, func() {}, nil
+}
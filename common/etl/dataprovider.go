@@ -10,6 +10,7 @@ import (
 
 	"github.com/ledgerwatch/turbo-geth/common"
 	"github.com/ledgerwatch/turbo-geth/log"
+	"github.com/ugorji/go/codec"
 )
 
 type dataProvider interface {
@@ -17,9 +18,25 @@ type dataProvider interface {
 	Dispose() (uint64, error)
 }
 
+const (
+	readAheadBatchSize = 1024 // entries
+	readAheadBatches   = 4
+)
+
+// fileDataProvider reads the file ahead in a separate goroutine, so that the decoding (and decompression)
+// of the files is done in parallel with the merging and loading of the entries
 type fileDataProvider struct {
-	file   *os.File
-	reader io.Reader
+	file    *os.File
+	batches chan readAheadBatch
+	quit    chan struct{}
+	stopped chan struct{}
+	batch   readAheadBatch
+	pos     int
+}
+
+type readAheadBatch struct {
+	entries []sortableBufferEntry
+	err     error // error after the entries, io.EOF at the end of the file
 }
 
 type Encoder interface {
@@ -43,10 +60,6 @@ func FlushToDisk(encoder Encoder, currentKey []byte, b Buffer, tmpdir string) (d
 	if err != nil {
 		return nil, err
 	}
-	defer bufferFile.Sync() //nolint:errcheck
-
-	w := bufio.NewWriterSize(bufferFile, BufIOSize)
-	defer w.Flush() //nolint:errcheck
 
 	defer func() {
 		b.Reset() // run it after buf.flush and file.sync
@@ -55,33 +68,97 @@ func FlushToDisk(encoder Encoder, currentKey []byte, b Buffer, tmpdir string) (d
 		log.Info(
 			"Flushed buffer file",
 			"name", bufferFile.Name(),
+			"compression", Compression,
 			"alloc", common.StorageSize(m.Alloc), "sys", common.StorageSize(m.Sys), "numGC", int(m.NumGC))
 	}()
 
-	encoder.Reset(w)
+	w := bufio.NewWriterSize(bufferFile, BufIOSize)
+	cw, err := newCompressor(w, Compression)
+	if err != nil {
+		return nil, err
+	}
+	encoder.Reset(cw)
 	for _, entry := range b.GetEntries() {
 		err = writeToDisk(encoder, entry.key, entry.value)
 		if err != nil {
 			return nil, fmt.Errorf("error writing entries to disk: %v", err)
 		}
 	}
+	if err = cw.Close(); err != nil {
+		return nil, fmt.Errorf("error compressing entries: %v", err)
+	}
+	if err = w.Flush(); err != nil {
+		return nil, fmt.Errorf("error writing entries to disk: %v", err)
+	}
+	if err = bufferFile.Sync(); err != nil {
+		return nil, err
+	}
 
-	return &fileDataProvider{bufferFile, nil}, nil
+	return &fileDataProvider{file: bufferFile}, nil
 }
 
-func (p *fileDataProvider) Next(decoder Decoder) ([]byte, []byte, error) {
-	if p.reader == nil {
-		_, err := p.file.Seek(0, 0)
-		if err != nil {
+func (p *fileDataProvider) Next(_ Decoder) ([]byte, []byte, error) {
+	if p.batches == nil {
+		if _, err := p.file.Seek(0, 0); err != nil {
 			return nil, nil, err
 		}
-		p.reader = bufio.NewReaderSize(p.file, BufIOSize)
+		p.batches = make(chan readAheadBatch, readAheadBatches)
+		p.quit = make(chan struct{})
+		p.stopped = make(chan struct{})
+		go p.readAhead()
+	}
+	for p.pos == len(p.batch.entries) {
+		if p.batch.err != nil {
+			return nil, nil, p.batch.err
+		}
+		p.batch = <-p.batches
+		p.pos = 0
+	}
+	entry := p.batch.entries[p.pos]
+	p.pos++
+	return entry.key, entry.value, nil
+}
+
+// readAhead decodes the file in batches, until the end of the file, an error or Dispose
+func (p *fileDataProvider) readAhead() {
+	defer close(p.stopped)
+	r, release, err := newDecompressor(bufio.NewReaderSize(p.file, BufIOSize))
+	if err != nil {
+		select {
+		case p.batches <- readAheadBatch{err: err}:
+		case <-p.quit:
+		}
+		return
+	}
+	defer release()
+	decoder := codec.NewDecoder(r, &cbor)
+	for {
+		batch := readAheadBatch{entries: make([]sortableBufferEntry, 0, readAheadBatchSize)}
+		for len(batch.entries) < readAheadBatchSize {
+			k, v, err := readElementFromDisk(decoder)
+			if err != nil {
+				batch.err = err
+				break
+			}
+			batch.entries = append(batch.entries, sortableBufferEntry{k, v})
+		}
+		select {
+		case p.batches <- batch:
+		case <-p.quit:
+			return
+		}
+		if batch.err != nil {
+			return
+		}
 	}
-	decoder.Reset(p.reader)
-	return readElementFromDisk(decoder)
 }
 
 func (p *fileDataProvider) Dispose() (uint64, error) {
+	if p.batches != nil {
+		close(p.quit)
+		<-p.stopped
+		p.batches = nil
+	}
 	info, errStat := os.Stat(p.file.Name())
 	errClose := p.file.Close()
 	errRemove := os.Remove(p.file.Name())
@@ -2,8 +2,10 @@ package etl
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"reflect"
 	"runtime"
 	"time"
@@ -73,6 +75,13 @@ type TransformArgs struct {
 	LogDetailsLoad    AdditionalLogArguments
 
 	Comparator dbutils.CmpFunc
+
+	// Resume (optional) - manifest of the files extracted by the interrupted transformation, they are loaded
+	// without extracting again. If the files are gone, the data is extracted again.
+	Resume *Manifest
+	// OnExtracted (optional) - is called with the manifest of the extracted files before loading them, to persist it,
+	// so that the loading can be resumed after an interruption. The files are kept if the loading fails then.
+	OnExtracted func(*Manifest) error
 }
 
 func Transform(
@@ -89,6 +98,25 @@ func Transform(
 	if args.BufferSize > 0 {
 		bufferSize = datasize.ByteSize(args.BufferSize)
 	}
+	if args.Resume != nil {
+		if args.Resume.BufferType != args.BufferType {
+			log.Warn(fmt.Sprintf("[%s] ETL files of the interrupted run have different buffer type, extracting again", logPrefix), "name", args.Resume.Name)
+			if err := args.Resume.RemoveFiles(); err != nil {
+				return err
+			}
+		} else if collector, err := NewCollectorFromManifest(args.Resume); err == nil {
+			log.Info(fmt.Sprintf("[%s] Resuming loading of the extracted ETL files", logPrefix), "name", args.Resume.Name, "files", len(args.Resume.Files))
+			return loadAndClose(logPrefix, collector, db, toBucket, loadFunc, args)
+		} else if errors.Is(err, os.ErrNotExist) {
+			log.Warn(fmt.Sprintf("[%s] ETL files of the interrupted run are deleted, extracting again", logPrefix), "err", err)
+			if err = args.Resume.RemoveFiles(); err != nil {
+				return err
+			}
+		} else {
+			return err
+		}
+	}
+
 	buffer := getBufferByType(args.BufferType, bufferSize)
 	collector := NewCollector(tmpdir, buffer)
 
@@ -102,9 +130,33 @@ func Transform(
 	defer func(t time.Time) {
 		log.Debug(fmt.Sprintf("[%s] Collection finished", logPrefix), "it took", time.Since(t))
 	}(time.Now())
+	if args.OnExtracted != nil {
+		m, err := collector.Manifest("", args.ExtractEndKey, args.BufferType)
+		if err != nil {
+			disposeProviders(logPrefix, collector.dataProviders)
+			return err
+		}
+		if m != nil {
+			if err := args.OnExtracted(m); err != nil {
+				disposeProviders(logPrefix, collector.dataProviders)
+				return err
+			}
+			collector.autoClean = false
+			return loadAndClose(logPrefix, collector, db, toBucket, loadFunc, args)
+		}
+	}
 	return collector.Load(logPrefix, db, toBucket, loadFunc, args)
 }
 
+// loadAndClose loads the collector, which keeps its files on failure, to resume the loading later
+func loadAndClose(logPrefix string, collector *Collector, db ethdb.Database, toBucket string, loadFunc LoadFunc, args TransformArgs) error {
+	if err := collector.Load(logPrefix, db, toBucket, loadFunc, args); err != nil {
+		return err
+	}
+	collector.Close(logPrefix)
+	return nil
+}
+
 func extractBucketIntoFiles(
 	logPrefix string,
 	db ethdb.Database,
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
 	"testing"
 
@@ -279,6 +281,129 @@ func TestTransformThroughFiles(t *testing.T) {
 	compareBuckets(t, db, sourceBucket, destBucket, nil)
 }
 
+func TestTransformThroughCompressedFiles(t *testing.T) {
+	defer func(c CompressionType) { Compression = c }(Compression)
+	for _, compression := range []CompressionType{NoCompression, SnappyCompression, ZstdCompression} {
+		Compression = compression
+		db := ethdb.NewMemDatabase()
+		sourceBucket := dbutils.Buckets[0]
+		destBucket := dbutils.Buckets[1]
+		// several files, each of them is read ahead in several batches
+		generateTestData(t, db, sourceBucket, 5000)
+		err := Transform(
+			"logPrefix",
+			db,
+			sourceBucket,
+			destBucket,
+			"", // temp dir
+			testExtractToMapFunc,
+			testLoadFromMapFunc,
+			TransformArgs{
+				BufferSize: 300 * 1024,
+			},
+		)
+		assert.Nil(t, err, compression.String())
+		compareBuckets(t, db, sourceBucket, destBucket, nil)
+		db.Close()
+	}
+}
+
+func TestCollectorFromMixedFiles(t *testing.T) {
+	defer func(c CompressionType) { Compression = c }(Compression)
+	tmpdir, err := ioutil.TempDir("", "etl-mixed")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	// files written with different settings, e.g. before and after restart
+	collector := NewCriticalCollector(tmpdir, NewSortableBuffer(1))
+	for i, compression := range []CompressionType{NoCompression, SnappyCompression, ZstdCompression} {
+		Compression = compression
+		assert.NoError(t, collector.Collect([]byte{byte(i)}, []byte{byte(i), byte(i)}))
+	}
+	collector, err = NewCollectorFromFiles(tmpdir)
+	assert.NoError(t, err)
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	assert.NoError(t, collector.Load("logPrefix", db, dbutils.Buckets[0], IdentityLoadFunc, TransformArgs{}))
+	for i := 0; i < 3; i++ {
+		v, err := db.Get(dbutils.Buckets[0], []byte{byte(i)})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{byte(i), byte(i)}, v)
+	}
+}
+
+func TestTransformResume(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	sourceBucket := dbutils.Buckets[0]
+	destBucket := dbutils.Buckets[1]
+	generateTestData(t, db, sourceBucket, 10)
+
+	// Loading is interrupted, the files are kept
+	var manifest *Manifest
+	errInterrupted := fmt.Errorf("interrupted")
+	err := Transform("logPrefix", db, sourceBucket, destBucket, "", testExtractToMapFunc,
+		func(k []byte, value []byte, _ CurrentTableReader, next LoadNextFunc) error {
+			return errInterrupted
+		},
+		TransformArgs{
+			BufferSize: 1,
+			OnExtracted: func(m *Manifest) error {
+				manifest = m
+				return nil
+			},
+		},
+	)
+	assert.Equal(t, errInterrupted, err)
+	assert.Equal(t, 10, len(manifest.Files))
+	data, err := EncodeManifests([]*Manifest{manifest})
+	assert.NoError(t, err)
+	manifests, err := DecodeManifests(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Manifest{manifest}, manifests)
+
+	// Loading is resumed without extraction, the files are removed after it
+	err = Transform("logPrefix", db, sourceBucket, destBucket, "",
+		func(k []byte, v []byte, next ExtractNextFunc) error {
+			return fmt.Errorf("extracted again")
+		},
+		testLoadFromMapFunc,
+		TransformArgs{Resume: manifests[0]},
+	)
+	assert.NoError(t, err)
+	compareBuckets(t, db, sourceBucket, destBucket, nil)
+	for _, name := range manifest.Files {
+		_, err = os.Stat(name)
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// The files are gone, so the data is extracted again
+	assert.NoError(t, db.ClearBuckets(destBucket))
+	err = Transform("logPrefix", db, sourceBucket, destBucket, "", testExtractToMapFunc, testLoadFromMapFunc, TransformArgs{Resume: manifest})
+	assert.NoError(t, err)
+	compareBuckets(t, db, sourceBucket, destBucket, nil)
+}
+
+func TestSortEntries(t *testing.T) {
+	// enough entries to be sorted in parallel, with duplicates to check the stability
+	entries := make([]sortableBufferEntry, 3*minParallelSortLen+7)
+	for i := range entries {
+		entries[i] = sortableBufferEntry{key: []byte(fmt.Sprintf("%05d", (i*7919)%10000)), value: []byte(fmt.Sprintf("%d", i))}
+	}
+	for _, cmp := range []dbutils.CmpFunc{nil, func(k1, k2, _, _ []byte) int { return bytes.Compare(k2, k1) }} {
+		expected := append([]sortableBufferEntry{}, entries...)
+		sort.SliceStable(expected, func(i, j int) bool {
+			if cmp != nil {
+				return cmp(expected[i].key, expected[j].key, nil, nil) < 0
+			}
+			return bytes.Compare(expected[i].key, expected[j].key) < 0
+		})
+		sorted := append([]sortableBufferEntry{}, entries...)
+		sortEntries(sorted, cmp)
+		assert.Equal(t, expected, sorted)
+	}
+}
+
 func TestTransformDoubleOnExtract(t *testing.T) {
 	// test invariant when extractFunc multiplies the data 2x
 	db := ethdb.NewMemDatabase()
@@ -0,0 +1,87 @@
+package etl
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Manifest describes the temp files of the collector, which are extracted, but not loaded yet.
+// When it is persisted (e.g. in the stage data) before loading, the loading can be resumed from the files
+// after an interruption, instead of extracting everything again.
+type Manifest struct {
+	Name       string   `codec:"1"` // identifies the loading, when there are several of them
+	Files      []string `codec:"2"` // in the order of flushing, empty when the files are loaded
+	ExtractKey []byte   `codec:"3"` // progress of the extraction, the files contain everything up to this key
+	BufferType int      `codec:"4"`
+}
+
+// Manifest flushes the collected data and returns the manifest of the files, nil if the collector keeps
+// the data in RAM, so there is nothing to resume from
+func (c *Collector) Manifest(name string, extractKey []byte, bufferType int) (*Manifest, error) {
+	if !c.allFlushed {
+		if err := c.flushBuffer(nil, true); err != nil {
+			return nil, err
+		}
+	}
+	m := &Manifest{Name: name, ExtractKey: extractKey, BufferType: bufferType}
+	for _, provider := range c.dataProviders {
+		fp, ok := provider.(*fileDataProvider)
+		if !ok {
+			return nil, nil
+		}
+		m.Files = append(m.Files, fp.file.Name())
+	}
+	if len(m.Files) == 0 {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// NewCollectorFromManifest creates collector from the files of the interrupted loading.
+// The files are not removed if loading fails, Close removes them. If any of the files is gone,
+// the error wraps os.ErrNotExist.
+func NewCollectorFromManifest(m *Manifest) (*Collector, error) {
+	dataProviders := make([]dataProvider, 0, len(m.Files))
+	for _, name := range m.Files {
+		file, err := os.Open(name)
+		if err != nil {
+			for _, p := range dataProviders {
+				p.(*fileDataProvider).file.Close()
+			}
+			return nil, fmt.Errorf("collector from manifest %s - opening file: %w", m.Name, err)
+		}
+		dataProviders = append(dataProviders, &fileDataProvider{file: file})
+	}
+	return &Collector{dataProviders: dataProviders, allFlushed: true, autoClean: false}, nil
+}
+
+// RemoveFiles removes the files of the manifest, which are not going to be loaded
+func (m *Manifest) RemoveFiles() error {
+	for _, name := range m.Files {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func EncodeManifests(manifests []*Manifest) ([]byte, error) {
+	var data []byte
+	if err := codec.NewEncoderBytes(&data, &cbor).Encode(manifests); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func DecodeManifests(data []byte) ([]*Manifest, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var manifests []*Manifest
+	if err := codec.NewDecoderBytes(data, &cbor).Decode(&manifests); err != nil {
+		return nil, fmt.Errorf("decoding etl manifests: %w", err)
+	}
+	return manifests, nil
+}
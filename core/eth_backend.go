@@ -1,8 +1,12 @@
 package core
 
 import (
+	"context"
+	"errors"
+
 	"github.com/ledgerwatch/turbo-geth/common"
 	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/ethdb/remote"
 	"github.com/ledgerwatch/turbo-geth/rlp"
 )
 
@@ -28,3 +32,8 @@ func (back *EthBackend) AddLocal(signedtx []byte) ([]byte, error) {
 
 	return tx.Hash().Bytes(), back.TxPool().AddLocal(tx)
 }
+
+// Subscribe is not supported by the in-process backend, chain events are only streamed over the private API
+func (back *EthBackend) Subscribe(_ context.Context, _ func(*remote.SubscribeReply)) error {
+	return errors.New("subscriptions are not supported by the embedded backend")
+}
@@ -32,6 +32,10 @@ type IndexGenerator struct {
 	ChangeSetBufSize int
 	TempDir          string
 	quitCh           <-chan struct{}
+
+	// Resume and OnExtracted (optional) - make the index generation resumable, see etl.TransformArgs
+	Resume      *etl.Manifest
+	OnExtracted func(*etl.Manifest) error
 }
 
 func (ig *IndexGenerator) GenerateIndex(startBlock, endBlock uint64, changeSetBucket string, tmpdir string) error {
@@ -60,6 +64,8 @@ func (ig *IndexGenerator) GenerateIndex(startBlock, endBlock uint64, changeSetBu
 				blockNum, _ := dbutils.DecodeTimestamp(k)
 				return []interface{}{"block", blockNum}
 			},
+			Resume:      ig.Resume,
+			OnExtracted: ig.OnExtracted,
 		},
 	)
 	if err != nil {
@@ -152,6 +158,98 @@ func (ig *IndexGenerator) Truncate(timestampTo uint64, changeSetBucket string) e
 	return err
 }
 
+// Prune - removes change sets of blocks [timestampFrom, timestampTo) and their entries in the history index
+func (ig *IndexGenerator) Prune(timestampFrom, timestampTo uint64, changeSetBucket string) error {
+	vv, ok := changeset.Mapper[changeSetBucket]
+	if !ok {
+		return errors.New("unknown bucket type")
+	}
+
+	keys := make(map[string]struct{})
+	var changeSetKeys [][]byte
+	if err := ig.db.Walk(changeSetBucket, dbutils.EncodeTimestamp(timestampFrom), 0, func(k, v []byte) (b bool, e error) {
+		if err := common.Stopped(ig.quitCh); err != nil {
+			return false, err
+		}
+		timestamp, _ := dbutils.DecodeTimestamp(k)
+		if timestamp >= timestampTo {
+			return false, nil
+		}
+
+		changeSetKeys = append(changeSetKeys, common.CopyBytes(k))
+		err := vv.WalkerAdapter(v).Walk(func(kk []byte, _ []byte) error {
+			keys[string(dbutils.CompositeKeyWithoutIncarnation(kk))] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}); err != nil {
+		return err
+	}
+
+	historyEffects := make(map[string][]byte)
+	keySize := vv.KeySize
+	if dbutils.StorageChangeSetBucket == changeSetBucket || dbutils.PlainStorageChangeSetBucket == changeSetBucket {
+		keySize -= 8
+	}
+
+	for key := range keys {
+		if err := ig.db.Walk(vv.IndexBucket, []byte(key), 8*keySize, func(k, v []byte) (bool, error) {
+			timestamp := binary.BigEndian.Uint64(k[keySize:]) // the last timestamp in the chunk
+			if timestamp < timestampTo {
+				historyEffects[string(common.CopyBytes(k))] = nil
+				return true, nil
+			}
+			// the chunk which overlaps with pruned blocks, keep only its tail
+			blocks, vzeros, err := dbutils.WrapHistoryIndex(v).Decode()
+			if err != nil {
+				return false, err
+			}
+			if len(blocks) > 0 && blocks[0] < timestampTo {
+				index := dbutils.NewHistoryIndex()
+				for i, blockNr := range blocks {
+					if blockNr >= timestampTo {
+						index = index.Append(blockNr, vzeros[i])
+					}
+				}
+				historyEffects[string(common.CopyBytes(k))] = index
+			}
+			return false, nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	mutation := ig.db.NewBatch()
+	defer mutation.Rollback()
+
+	for key, value := range historyEffects {
+		if value == nil {
+			if err := mutation.Delete(vv.IndexBucket, []byte(key), nil); err != nil {
+				return err
+			}
+		} else {
+			if err := mutation.Put(vv.IndexBucket, []byte(key), value); err != nil {
+				return err
+			}
+		}
+		if mutation.BatchSize() >= mutation.IdealBatchSize() {
+			if err := mutation.CommitAndBegin(context.Background()); err != nil {
+				return err
+			}
+		}
+	}
+	for _, k := range changeSetKeys {
+		if err := mutation.Delete(changeSetBucket, k, nil); err != nil {
+			return err
+		}
+	}
+	_, err := mutation.Commit()
+	return err
+}
+
 func (ig *IndexGenerator) DropIndex(bucket string) error {
 	casted, ok := ig.db.(ethdb.BucketsMigrator)
 	if !ok {
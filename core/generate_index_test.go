@@ -155,6 +155,45 @@ func TestIndexGenerator_Truncate(t *testing.T) {
 	}
 }
 
+func TestIndexGenerator_Prune(t *testing.T) {
+	buckets := []string{dbutils.PlainAccountChangeSetBucket, dbutils.PlainStorageChangeSetBucket}
+	for i := range buckets {
+		csbucket := buckets[i]
+		t.Run("prune to 1500 "+csbucket, func(t *testing.T) {
+			db := ethdb.NewMemDatabase()
+			defer db.Close()
+			hashes, expected := generateTestData(t, db, csbucket, 2100)
+			indexBucket := changeset.Mapper[csbucket].IndexBucket
+			ig := NewIndexGenerator("logPrefix", db, make(chan struct{}))
+			if err := ig.GenerateIndex(0, uint64(2100), csbucket, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := ig.Prune(0, 1500, csbucket); err != nil {
+				t.Fatal(err)
+			}
+
+			var tail []uint64
+			for _, blockNum := range expected[string(hashes[0])][1] {
+				if blockNum >= 1500 {
+					tail = append(tail, blockNum)
+				}
+			}
+			checkIndex(t, db, indexBucket, hashes[0], 1500, tail)
+			checkIndex(t, db, indexBucket, hashes[0], 2000, expected[string(hashes[0])][2])
+			if _, err := db.Get(indexBucket, dbutils.IndexChunkKey(hashes[0], 999)); err != ethdb.ErrKeyNotFound {
+				t.Fatal("expected pruned chunk", err)
+			}
+			if _, err := db.Get(csbucket, dbutils.EncodeTimestamp(1499)); err != ethdb.ErrKeyNotFound {
+				t.Fatal("expected pruned change set", err)
+			}
+			if _, err := db.Get(csbucket, dbutils.EncodeTimestamp(1500)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
 func generateTestData(t *testing.T, db ethdb.Database, csBucket string, numOfBlocks int) ([][]byte, map[string][][]uint64) { //nolint
 	csInfo, ok := changeset.Mapper[string(csBucket)]
 	if !ok {
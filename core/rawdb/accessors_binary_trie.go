@@ -0,0 +1,40 @@
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+)
+
+// ReadBinaryStateRoot retrieves the root of the binary trie of the state after the block, empty hash if it is not stored.
+func ReadBinaryStateRoot(db DatabaseReader, number uint64) (common.Hash, error) {
+	data, err := db.Get(dbutils.BinaryStateRootBucket, dbutils.EncodeBlockNumber(number))
+	if err != nil && !errors.Is(err, ethdb.ErrKeyNotFound) {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(data), nil
+}
+
+// WriteBinaryStateRoot stores the root of the binary trie of the state after the block.
+func WriteBinaryStateRoot(db DatabaseWriter, number uint64, root common.Hash) error {
+	if err := db.Put(dbutils.BinaryStateRootBucket, dbutils.EncodeBlockNumber(number), root.Bytes()); err != nil {
+		return fmt.Errorf("failed to store binary state root: %w", err)
+	}
+	return nil
+}
+
+// DeleteBinaryStateRootsFrom removes the roots of the binary trie of the state after the blocks starting from `from`.
+func DeleteBinaryStateRootsFrom(db ethdb.Database, from uint64) error {
+	if err := db.Walk(dbutils.BinaryStateRootBucket, dbutils.EncodeBlockNumber(from), 0, func(k, v []byte) (bool, error) {
+		if err := db.Delete(dbutils.BinaryStateRootBucket, k, nil); err != nil {
+			return false, err
+		}
+		return true, nil
+	}); err != nil {
+		return fmt.Errorf("delete binary state roots failed: from %d, %w", from, err)
+	}
+	return nil
+}
@@ -584,6 +584,34 @@ func DeleteNewerReceipts(db ethdb.Database, number uint64) error {
 	return nil
 }
 
+// DeleteReceiptsRange removes receipts and logs of blocks [from, to)
+func DeleteReceiptsRange(db ethdb.Database, from, to uint64) error {
+	if err := db.Walk(dbutils.BlockReceiptsPrefix, dbutils.ReceiptsKey(from), 0, func(k, v []byte) (bool, error) {
+		if binary.BigEndian.Uint64(k) >= to {
+			return false, nil
+		}
+		if err := db.Delete(dbutils.BlockReceiptsPrefix, k, nil); err != nil {
+			return false, err
+		}
+		return true, nil
+	}); err != nil {
+		return fmt.Errorf("delete receipts failed: %d-%d, %w", from, to, err)
+	}
+
+	if err := db.Walk(dbutils.Log, dbutils.LogKey(from, 0), 0, func(k, v []byte) (bool, error) {
+		if binary.BigEndian.Uint64(k) >= to {
+			return false, nil
+		}
+		if err := db.Delete(dbutils.Log, k, nil); err != nil {
+			return false, err
+		}
+		return true, nil
+	}); err != nil {
+		return fmt.Errorf("delete logs failed: %d-%d, %w", from, to, err)
+	}
+	return nil
+}
+
 // ReadBlock retrieves an entire block corresponding to the hash, assembling it
 // back from the stored header and body. If either the header or body could not
 // be retrieved nil is returned.
@@ -0,0 +1,43 @@
+package rawdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+)
+
+// ReadBlockWitness retrieves the serialized witness of the canonical block, nil if the witness is not stored.
+func ReadBlockWitness(db DatabaseReader, number uint64) ([]byte, error) {
+	data, err := db.Get(dbutils.BlockWitnessBucket, dbutils.EncodeBlockNumber(number))
+	if err != nil && !errors.Is(err, ethdb.ErrKeyNotFound) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// WriteBlockWitness stores the serialized witness of the canonical block.
+func WriteBlockWitness(db DatabaseWriter, number uint64, witness []byte) error {
+	if err := db.Put(dbutils.BlockWitnessBucket, dbutils.EncodeBlockNumber(number), witness); err != nil {
+		return fmt.Errorf("failed to store block witness: %w", err)
+	}
+	return nil
+}
+
+// DeleteBlockWitnessesRange removes the witnesses of blocks in range [from, to).
+func DeleteBlockWitnessesRange(db ethdb.Database, from, to uint64) error {
+	if err := db.Walk(dbutils.BlockWitnessBucket, dbutils.EncodeBlockNumber(from), 0, func(k, v []byte) (bool, error) {
+		if binary.BigEndian.Uint64(k) >= to {
+			return false, nil
+		}
+		if err := db.Delete(dbutils.BlockWitnessBucket, k, nil); err != nil {
+			return false, err
+		}
+		return true, nil
+	}); err != nil {
+		return fmt.Errorf("delete block witnesses failed: %d-%d, %w", from, to, err)
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/core/types/accounts"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/turbo/trie"
+)
+
+// BinaryStorageTrie loads the binary trie of the storage of the account from the hashed state. Only the paths to
+// `keys` (hashes of storage keys) are resolved, the other subtries are replaced by their hashes
+// from BinaryStorageIntermediateHashBucket, so the given keys can be read or proven.
+func BinaryStorageTrie(db ethdb.Database, addrHash common.Hash, incarnation uint64, keys [][]byte) (*trie.Trie, error) {
+	resolved := make([]string, len(keys))
+	for i, k := range keys {
+		resolved[i] = string(k)
+	}
+	sort.Strings(resolved)
+	return loadBinaryTrie(db, dbutils.BinaryStorageIntermediateHashBucket, dbutils.GenerateStoragePrefix(addrHash[:], incarnation), resolved, addBinaryStorage)
+}
+
+// LoadBinaryTrie loads the binary trie of the current hashed state. Only the path to `addrHash` (if not nil) is
+// resolved, so that the account can be read or proven, the other subtries are replaced by their hashes
+// from BinaryIntermediateHashBucket.
+func LoadBinaryTrie(db ethdb.Database, addrHash []byte) (*trie.Trie, error) {
+	var resolved []string
+	if addrHash != nil {
+		resolved = []string{string(addrHash)}
+	}
+	return loadBinaryTrie(db, dbutils.BinaryIntermediateHashBucket, nil, resolved, addBinaryAccount)
+}
+
+// UpdateBinaryTrie brings the binary trie commitment in line with the hashed state, after the keys `changed`
+// (account hashes, or account hashes + incarnations + storage key hashes) have been modified there.
+// Only the paths to the changed keys are loaded, the intermediate hashes on these paths are updated.
+// Returns the root of the binary trie of the state.
+func UpdateBinaryTrie(db ethdb.Database, changed [][]byte, quit <-chan struct{}) (common.Hash, error) {
+	changedStorage := make(map[string][]string)
+	changedAccounts := make(map[string]struct{})
+	for _, k := range changed {
+		if len(k) > common.HashLength {
+			storagePrefix := string(k[:common.HashLength+common.IncarnationLength])
+			changedStorage[storagePrefix] = append(changedStorage[storagePrefix], string(k[len(storagePrefix):]))
+		}
+		changedAccounts[string(k[:common.HashLength])] = struct{}{}
+	}
+	for storagePrefix, keys := range changedStorage {
+		if err := common.Stopped(quit); err != nil {
+			return common.Hash{}, err
+		}
+		sort.Strings(keys)
+		t, err := loadBinaryTrie(db, dbutils.BinaryStorageIntermediateHashBucket, []byte(storagePrefix), keys, addBinaryStorage)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if err = putBinaryStorageRoot(db, []byte(storagePrefix), t.Hash()); err != nil {
+			return common.Hash{}, err
+		}
+		if err = updateBinaryHashes(db, dbutils.BinaryStorageIntermediateHashBucket, []byte(storagePrefix), t, keys); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	keys := make([]string, 0, len(changedAccounts))
+	for k := range changedAccounts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	t, err := loadBinaryTrie(db, dbutils.BinaryIntermediateHashBucket, nil, keys, addBinaryAccount)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err = updateBinaryHashes(db, dbutils.BinaryIntermediateHashBucket, nil, t, keys); err != nil {
+		return common.Hash{}, err
+	}
+	return t.Hash(), nil
+}
+
+// RegenerateBinaryTrie builds the binary trie commitment of the hashed state from scratch.
+// Returns the root of the binary trie of the state.
+func RegenerateBinaryTrie(db ethdb.Database, quit <-chan struct{}) (common.Hash, error) {
+	if err := db.(ethdb.BucketsMigrator).ClearBuckets(dbutils.BinaryIntermediateHashBucket, dbutils.BinaryStorageIntermediateHashBucket, dbutils.BinaryStorageRootBucket); err != nil {
+		return common.Hash{}, err
+	}
+
+	// the storage tries are built one by one, the accounts - by the subtries of the first byte of their hashes,
+	// all hashes of the branch nodes inside them are kept
+	var storagePrefix, accountPrefix []byte
+	var storageTrie, accountsTrie *trie.Trie
+	flushStorage := func() error {
+		if storageTrie == nil {
+			return nil
+		}
+		if err := putBinaryStorageRoot(db, storagePrefix, storageTrie.Hash()); err != nil {
+			return err
+		}
+		return putBinaryHashes(db, dbutils.BinaryStorageIntermediateHashBucket, storagePrefix, storageTrie)
+	}
+	flushAccounts := func() error {
+		if accountsTrie == nil {
+			return nil
+		}
+		return putBinaryHashes(db, dbutils.BinaryIntermediateHashBucket, nil, accountsTrie)
+	}
+	if err := db.Walk(dbutils.CurrentStateBucket, nil, 0, func(k, v []byte) (bool, error) {
+		if err := common.Stopped(quit); err != nil {
+			return false, err
+		}
+		if len(k) == common.HashLength {
+			return true, nil
+		}
+		if storageTrie == nil || !bytes.HasPrefix(k, storagePrefix) {
+			if err := flushStorage(); err != nil {
+				return false, err
+			}
+			storagePrefix = common.CopyBytes(k[:common.HashLength+common.IncarnationLength])
+			storageTrie = trie.NewBinary(common.Hash{})
+		}
+		storageTrie.Update(common.CopyBytes(k[len(storagePrefix):]), common.CopyBytes(v))
+		return true, nil
+	}); err != nil {
+		return common.Hash{}, err
+	}
+	if err := flushStorage(); err != nil {
+		return common.Hash{}, err
+	}
+	// accounts go after the storage, they need the roots of the storage tries
+	if err := db.Walk(dbutils.CurrentStateBucket, nil, 0, func(k, v []byte) (bool, error) {
+		if err := common.Stopped(quit); err != nil {
+			return false, err
+		}
+		if len(k) != common.HashLength {
+			return true, nil
+		}
+		if accountsTrie == nil || !bytes.HasPrefix(k, accountPrefix) {
+			if err := flushAccounts(); err != nil {
+				return false, err
+			}
+			accountPrefix = common.CopyBytes(k[:1])
+			accountsTrie = trie.NewBinary(common.Hash{})
+		}
+		return true, addBinaryAccount(db, accountsTrie, k, v)
+	}); err != nil {
+		return common.Hash{}, err
+	}
+	if err := flushAccounts(); err != nil {
+		return common.Hash{}, err
+	}
+	t, err := LoadBinaryTrie(db, nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return t.Hash(), nil
+}
+
+// loadBinaryTrie loads the binary trie of the keys of the hashed state, which start with `base` (the accounts if it is
+// empty, the storage of the account otherwise). The subtries at the prefixes, which none of the `resolved` keys
+// (without `base`, sorted) start with, are replaced by their hashes from `ihBucket`, if they have them.
+func loadBinaryTrie(db ethdb.Database, ihBucket string, base []byte, resolved []string, add func(db ethdb.Database, t *trie.Trie, key, v []byte) error) (*trie.Trie, error) {
+	tx := db.(ethdb.HasTx).Tx()
+	c := tx.Cursor(dbutils.CurrentStateBucket)
+	defer c.Close()
+	ih := tx.Cursor(ihBucket)
+	defer ih.Close()
+
+	t := trie.NewBinary(common.Hash{})
+	next := base
+	for {
+		k, v, err := c.Seek(next)
+		if err != nil {
+			return nil, err
+		}
+		if k == nil || !bytes.HasPrefix(k, base) {
+			break
+		}
+		var ok bool
+		if len(k) != len(base)+common.HashLength { // storage of the account, when the accounts are loaded
+			if next, ok = dbutils.NextSubtree(k[:len(base)+common.HashLength]); !ok {
+				break
+			}
+			continue
+		}
+		key := k[len(base):]
+		// the shortest prefix of the key, which has the hash and none of the resolved keys
+		l := 1
+		for ; l < len(key); l++ {
+			if hasKeyWithPrefix(resolved, key[:l]) {
+				continue
+			}
+			ihK, ihV, err := ih.Seek(k[:len(base)+l])
+			if err != nil {
+				return nil, err
+			}
+			if ihK == nil || !bytes.HasPrefix(ihK, k[:len(base)+l]) {
+				l = len(key) // no hashes below the prefix
+				break
+			}
+			if len(ihK) == len(base)+l {
+				(*trie.BinaryTrie)(t).AddSubTrieHash(key[:l], common.BytesToHash(ihV))
+				break
+			}
+		}
+		if l >= len(key) {
+			if err = add(db, t, key, v); err != nil {
+				return nil, err
+			}
+			l = len(key)
+		}
+		if next, ok = dbutils.NextSubtree(k[:len(base)+l]); !ok {
+			break
+		}
+	}
+	return t, nil
+}
+
+// hasKeyWithPrefix checks if any of the sorted keys starts with the prefix
+func hasKeyWithPrefix(sorted []string, prefix []byte) bool {
+	i := sort.SearchStrings(sorted, string(prefix))
+	return i < len(sorted) && bytes.HasPrefix([]byte(sorted[i]), prefix)
+}
+
+// updateBinaryHashes updates the hashes of the branch nodes at all prefixes of the `changed` keys (without `base`),
+// the trie has to be loaded with these keys resolved
+func updateBinaryHashes(db ethdb.Database, ihBucket string, base []byte, t *trie.Trie, changed []string) error {
+	updated := make(map[string]struct{})
+	for _, key := range changed {
+		for l := 1; l < len(key); l++ {
+			prefix := key[:l]
+			if _, ok := updated[prefix]; ok {
+				continue
+			}
+			updated[prefix] = struct{}{}
+			ihK := append(common.CopyBytes(base), prefix...)
+			if hash, ok := (*trie.BinaryTrie)(t).SubTrieHash([]byte(prefix)); ok {
+				if err := db.Put(ihBucket, ihK, hash[:]); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := db.Delete(ihBucket, ihK, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// putBinaryHashes saves the hashes of all branch nodes of the trie, at the prefixes of whole bytes
+func putBinaryHashes(db ethdb.Database, ihBucket string, base []byte, t *trie.Trie) error {
+	return (*trie.BinaryTrie)(t).SubTrieHashes(func(prefix []byte, hash common.Hash) error {
+		return db.Put(ihBucket, append(common.CopyBytes(base), prefix...), hash[:])
+	})
+}
+
+// addBinaryAccount adds the account to the binary trie, with the root of its binary storage trie
+func addBinaryAccount(db ethdb.Database, t *trie.Trie, addrHash, v []byte) error {
+	var acc accounts.Account
+	if err := acc.DecodeForStorage(v); err != nil {
+		return fmt.Errorf("decoding account %x: %w", addrHash, err)
+	}
+	var err error
+	if acc.Root, err = binaryStorageRoot(db, addrHash, acc.Incarnation); err != nil {
+		return err
+	}
+	t.UpdateAccount(common.CopyBytes(addrHash), &acc)
+	return nil
+}
+
+func addBinaryStorage(_ ethdb.Database, t *trie.Trie, keyHash, v []byte) error {
+	t.Update(common.CopyBytes(keyHash), common.CopyBytes(v))
+	return nil
+}
+
+func binaryStorageRoot(db ethdb.Database, addrHash []byte, incarnation uint64) (common.Hash, error) {
+	if incarnation == 0 {
+		return trie.EmptyRoot, nil
+	}
+	v, err := db.Get(dbutils.BinaryStorageRootBucket, dbutils.GenerateStoragePrefix(addrHash, incarnation))
+	if err != nil {
+		if errors.Is(err, ethdb.ErrKeyNotFound) {
+			return trie.EmptyRoot, nil
+		}
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(v), nil
+}
+
+func putBinaryStorageRoot(db ethdb.Database, storagePrefix []byte, root common.Hash) error {
+	if root == trie.EmptyRoot {
+		return db.Delete(dbutils.BinaryStorageRootBucket, storagePrefix, nil)
+	}
+	return db.Put(dbutils.BinaryStorageRootBucket, common.CopyBytes(storagePrefix), root.Bytes())
+}
@@ -0,0 +1,149 @@
+package state
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/core/types/accounts"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/turbo/trie"
+	"github.com/stretchr/testify/require"
+)
+
+func putHashedAccount(t *testing.T, db ethdb.Database, addrHash []byte, balance uint64, incarnation uint64) {
+	acc := accounts.NewAccount()
+	acc.Balance.SetUint64(balance)
+	acc.Incarnation = incarnation
+	v := make([]byte, acc.EncodingLengthForStorage())
+	acc.EncodeForStorage(v)
+	require.NoError(t, db.Put(dbutils.CurrentStateBucket, common.CopyBytes(addrHash), v))
+}
+
+// fullBinaryRoot builds the binary trie of the whole hashed state
+func fullBinaryRoot(t *testing.T, db ethdb.Database) common.Hash {
+	storageTries := make(map[string]*trie.Trie)
+	accountsTrie := trie.NewBinary(common.Hash{})
+	require.NoError(t, db.Walk(dbutils.CurrentStateBucket, nil, 0, func(k, v []byte) (bool, error) {
+		if len(k) == common.HashLength {
+			return true, nil
+		}
+		prefix := string(k[:common.HashLength+common.IncarnationLength])
+		if storageTries[prefix] == nil {
+			storageTries[prefix] = trie.NewBinary(common.Hash{})
+		}
+		storageTries[prefix].Update(common.CopyBytes(k[len(prefix):]), common.CopyBytes(v))
+		return true, nil
+	}))
+	require.NoError(t, db.Walk(dbutils.CurrentStateBucket, nil, 0, func(k, v []byte) (bool, error) {
+		if len(k) != common.HashLength {
+			return true, nil
+		}
+		var acc accounts.Account
+		require.NoError(t, acc.DecodeForStorage(v))
+		acc.Root = trie.EmptyRoot
+		if st, ok := storageTries[string(dbutils.GenerateStoragePrefix(k, acc.Incarnation))]; ok {
+			acc.Root = st.Hash()
+		}
+		accountsTrie.UpdateAccount(common.CopyBytes(k), &acc)
+		return true, nil
+	}))
+	return accountsTrie.Hash()
+}
+
+func readBinaryHashes(t *testing.T, db ethdb.Database) map[string]string {
+	hashes := make(map[string]string)
+	for _, bucket := range []string{dbutils.BinaryIntermediateHashBucket, dbutils.BinaryStorageIntermediateHashBucket, dbutils.BinaryStorageRootBucket} {
+		require.NoError(t, db.Walk(bucket, nil, 0, func(k, v []byte) (bool, error) {
+			hashes[bucket+string(k)] = string(v)
+			return true, nil
+		}))
+	}
+	return hashes
+}
+
+func TestUpdateBinaryTrie(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomHash := func() []byte {
+		h := make([]byte, common.HashLength)
+		rnd.Read(h)
+		return h
+	}
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	tx, err := db.Begin(context.Background(), ethdb.RW)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	var addrHashes, storageKeys [][]byte
+	for i := 0; i < 1000; i++ {
+		addrHash := randomHash()
+		addrHashes = append(addrHashes, addrHash)
+		if i%10 != 0 {
+			putHashedAccount(t, tx, addrHash, uint64(i), 0)
+			continue
+		}
+		putHashedAccount(t, tx, addrHash, uint64(i), 1)
+		for j := 0; j < 300; j++ {
+			k := append(dbutils.GenerateStoragePrefix(addrHash, 1), randomHash()...)
+			storageKeys = append(storageKeys, k)
+			require.NoError(t, tx.Put(dbutils.CurrentStateBucket, k, []byte{byte(j + 1)}))
+		}
+	}
+	root, err := RegenerateBinaryTrie(tx, nil)
+	require.NoError(t, err)
+	require.Equal(t, fullBinaryRoot(t, tx), root)
+
+	for cycle := 0; cycle < 3; cycle++ {
+		var changed [][]byte
+		for i := 0; i < 20; i++ {
+			addrHash := addrHashes[rnd.Intn(len(addrHashes))]
+			switch i % 3 {
+			case 0: // new balance, or the account is deleted
+				if _, err = tx.Get(dbutils.CurrentStateBucket, addrHash); err == nil && i%2 == 0 {
+					require.NoError(t, tx.Delete(dbutils.CurrentStateBucket, addrHash, nil))
+				} else {
+					putHashedAccount(t, tx, addrHash, uint64(rnd.Int63()), 0)
+				}
+				changed = append(changed, addrHash)
+			case 1: // new account
+				addrHash = randomHash()
+				addrHashes = append(addrHashes, addrHash)
+				putHashedAccount(t, tx, addrHash, 1, 0)
+				changed = append(changed, addrHash)
+			case 2: // storage is modified, deleted or added
+				k := storageKeys[rnd.Intn(len(storageKeys))]
+				if i%2 == 0 {
+					require.NoError(t, tx.Put(dbutils.CurrentStateBucket, k, []byte{byte(i)}))
+				} else {
+					require.NoError(t, tx.Delete(dbutils.CurrentStateBucket, k, nil))
+				}
+				added := append(common.CopyBytes(k[:common.HashLength+common.IncarnationLength]), randomHash()...)
+				require.NoError(t, tx.Put(dbutils.CurrentStateBucket, added, []byte{1}))
+				changed = append(changed, k, added)
+			}
+		}
+		root, err = UpdateBinaryTrie(tx, changed, nil)
+		require.NoError(t, err)
+		require.Equal(t, fullBinaryRoot(t, tx), root, "cycle %d", cycle)
+
+		// the hashes are the same as the hashes of the trie built from scratch
+		updated := readBinaryHashes(t, tx)
+		regeneratedRoot, err := RegenerateBinaryTrie(tx, nil)
+		require.NoError(t, err)
+		require.Equal(t, root, regeneratedRoot)
+		require.Equal(t, readBinaryHashes(t, tx), updated, "cycle %d", cycle)
+	}
+
+	// only the requested path is resolved, the account can be read
+	tr, err := LoadBinaryTrie(tx, addrHashes[1])
+	require.NoError(t, err)
+	require.Equal(t, root, tr.Hash())
+	acc, found := tr.GetAccount(addrHashes[1])
+	require.True(t, found)
+	require.NotNil(t, acc)
+	require.Equal(t, uint256.NewInt().SetUint64(1), &acc.Balance)
+}
@@ -0,0 +1,254 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/changeset"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/core/types/accounts"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/turbo/trie"
+)
+
+// LoadTrieAt loads the state trie as of the block `blockNr` from the hashed state and the intermediate hashes.
+// Only the nodes on the paths to `keys` (account hashes, or account hashes concatenated with storage key hashes)
+// are resolved, other subtries are replaced by their hashes. For a historical block, the hashed state is rewound
+// in memory by the plain change sets of the blocks after it, so the subtries touched since then are rebuilt from the leaves.
+// The root of the loaded trie is checked against the state root of the block.
+func LoadTrieAt(db ethdb.Database, blockNr uint64, keys [][]byte) (*trie.Trie, error) {
+	// Hashed state and intermediate hashes are only consistent up to the progress of the IntermediateHashes stage
+	hashedAt, _, err := stages.GetStageProgress(db, stages.IntermediateHashes)
+	if err != nil {
+		return nil, err
+	}
+	if blockNr > hashedAt {
+		return nil, fmt.Errorf("state of block %d is not available yet, intermediate hashes are at block %d", blockNr, hashedAt)
+	}
+
+	ts := dbutils.EncodeTimestamp(blockNr + 1)
+	accountMap := make(map[string]*accounts.Account)
+	if err = db.Walk(dbutils.PlainAccountChangeSetBucket, ts, 0, func(k, v []byte) (bool, error) {
+		timestamp, _ := dbutils.DecodeTimestamp(k)
+		if timestamp > hashedAt {
+			return false, nil
+		}
+		if changeset.Len(v) > 0 {
+			walker := func(kk, vv []byte) error {
+				addrHash, innerErr := common.HashData(kk)
+				if innerErr != nil {
+					return innerErr
+				}
+				// The first change after the block keeps the value at the block
+				if _, ok := accountMap[string(addrHash[:])]; !ok {
+					if len(vv) > 0 {
+						var a accounts.Account
+						if innerErr = a.DecodeForStorage(vv); innerErr != nil {
+							return innerErr
+						}
+						accountMap[string(addrHash[:])] = &a
+					} else {
+						accountMap[string(addrHash[:])] = nil
+					}
+				}
+				return nil
+			}
+			v = common.CopyBytes(v) // Making copy because otherwise it will be invalid after the transaction
+			if innerErr := changeset.AccountChangeSetPlainBytes(v).Walk(walker); innerErr != nil {
+				return false, innerErr
+			}
+		}
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+	storageMap := make(map[string][]byte)
+	if err = db.Walk(dbutils.PlainStorageChangeSetBucket, ts, 0, func(k, v []byte) (bool, error) {
+		timestamp, _ := dbutils.DecodeTimestamp(k)
+		if timestamp > hashedAt {
+			return false, nil
+		}
+		if changeset.Len(v) > 0 {
+			walker := func(kk, vv []byte) error {
+				addr, incarnation, key := dbutils.PlainParseCompositeStorageKey(kk)
+				addrHash, innerErr := common.HashData(addr[:])
+				if innerErr != nil {
+					return innerErr
+				}
+				keyHash, innerErr := common.HashData(key[:])
+				if innerErr != nil {
+					return innerErr
+				}
+				compositeKey := string(dbutils.GenerateCompositeStorageKey(addrHash, incarnation, keyHash))
+				if _, ok := storageMap[compositeKey]; !ok {
+					storageMap[compositeKey] = common.CopyBytes(vv)
+				}
+				return nil
+			}
+			v = common.CopyBytes(v) // Making copy because otherwise it will be invalid after the transaction
+			if innerErr := changeset.StorageChangeSetPlainBytes(v).Walk(walker); innerErr != nil {
+				return false, innerErr
+			}
+		}
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+	var unfurlList = make([]string, len(accountMap)+len(storageMap))
+	unfurl := trie.NewRetainList(0)
+	i := 0
+	for ks, acc := range accountMap {
+		unfurlList[i] = ks
+		i++
+		unfurl.AddKey([]byte(ks))
+		if acc != nil {
+			// Fill the code hashes
+			if acc.Incarnation > 0 && acc.IsEmptyCodeHash() {
+				if codeHash, err1 := db.Get(dbutils.ContractCodeBucket, dbutils.GenerateStoragePrefix([]byte(ks), acc.Incarnation)); err1 == nil {
+					copy(acc.CodeHash[:], codeHash)
+				} else if !errors.Is(err1, ethdb.ErrKeyNotFound) {
+					return nil, err1
+				}
+			}
+		}
+	}
+	for ks := range storageMap {
+		unfurlList[i] = ks
+		i++
+		var sk [64]byte
+		copy(sk[:], []byte(ks)[:common.HashLength])
+		copy(sk[common.HashLength:], []byte(ks)[common.HashLength+common.IncarnationLength:])
+		unfurl.AddKey(sk[:])
+	}
+	rl := trie.NewRetainList(0)
+	for _, key := range keys {
+		rl.AddKey(key)
+		unfurl.AddKey(key)
+		if len(key) <= common.HashLength {
+			continue
+		}
+		// Storage items are streamed from the hashed state with the incarnation of the account
+		incarnation, err1 := incarnationAt(db, key[:common.HashLength], accountMap)
+		if err1 != nil {
+			return nil, err1
+		}
+		if incarnation == 0 {
+			continue
+		}
+		storageKey := dbutils.GenerateCompositeStorageKey(common.BytesToHash(key[:common.HashLength]), incarnation, common.BytesToHash(key[common.HashLength:]))
+		rl.AddKey(storageKey)
+		unfurl.AddKey(storageKey)
+	}
+	sort.Strings(unfurlList)
+	loader := trie.NewFlatDbSubTrieLoader()
+	if err = loader.Reset(db, unfurl, unfurl, nil /* hashCollector */, [][]byte{nil}, []int{0}, false); err != nil {
+		return nil, err
+	}
+	r := &historyReceiver{defaultReceiver: trie.NewDefaultReceiver(), unfurlList: unfurlList, accountMap: accountMap, storageMap: storageMap}
+	r.defaultReceiver.Reset(rl, nil /* hashCollector */, false)
+	loader.SetStreamReceiver(r)
+	subTries, err := loader.LoadSubTries()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := rawdb.ReadCanonicalHash(db, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	header := rawdb.ReadHeader(db, hash, blockNr)
+	if header == nil {
+		return nil, fmt.Errorf("header for block %d not found", blockNr)
+	}
+	tr := trie.New(header.Root)
+	// Hooking checks the root of the loaded trie against the state root of the block
+	if err = tr.HookSubTries(subTries, [][]byte{nil}); err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// incarnationAt returns the incarnation of the account as of the block, to which the change sets in `accountMap` rewind
+// the hashed state, 0 if the account does not exist
+func incarnationAt(db rawdb.DatabaseReader, addrHash []byte, accountMap map[string]*accounts.Account) (uint64, error) {
+	if acc, ok := accountMap[string(addrHash)]; ok {
+		if acc == nil {
+			return 0, nil
+		}
+		return acc.Incarnation, nil
+	}
+	var acc accounts.Account
+	if _, err := rawdb.ReadAccount(db, common.BytesToHash(addrHash), &acc); err != nil {
+		if errors.Is(err, ethdb.ErrKeyNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return acc.Incarnation, nil
+}
+
+// historyReceiver injects the values of the rewound keys into the stream of the hashed state
+type historyReceiver struct {
+	defaultReceiver *trie.DefaultReceiver
+	accountMap      map[string]*accounts.Account
+	storageMap      map[string][]byte
+	unfurlList      []string
+	currentIdx      int
+}
+
+func (r *historyReceiver) Root() common.Hash { panic("don't call me") }
+func (r *historyReceiver) Receive(
+	itemType trie.StreamItem,
+	accountKey []byte,
+	storageKey []byte,
+	accountValue *accounts.Account,
+	storageValue []byte,
+	hash []byte,
+	cutoff int,
+) error {
+	for r.currentIdx < len(r.unfurlList) {
+		ks := r.unfurlList[r.currentIdx]
+		k := []byte(ks)
+		var c int
+		switch itemType {
+		case trie.StorageStreamItem, trie.SHashStreamItem:
+			c = bytes.Compare(k, storageKey)
+		case trie.AccountStreamItem, trie.AHashStreamItem:
+			c = bytes.Compare(k, accountKey)
+		case trie.CutoffStreamItem:
+			c = -1
+		}
+		if c > 0 {
+			return r.defaultReceiver.Receive(itemType, accountKey, storageKey, accountValue, storageValue, hash, cutoff)
+		}
+		if len(k) > common.HashLength {
+			v := r.storageMap[ks]
+			if c <= 0 && len(v) > 0 {
+				if err := r.defaultReceiver.Receive(trie.StorageStreamItem, nil, k, nil, v, nil, 0); err != nil {
+					return err
+				}
+			}
+		} else {
+			v := r.accountMap[ks]
+			if c <= 0 && v != nil {
+				if err := r.defaultReceiver.Receive(trie.AccountStreamItem, k, nil, v, nil, nil, 0); err != nil {
+					return err
+				}
+			}
+		}
+		r.currentIdx++
+		if c == 0 {
+			return nil
+		}
+	}
+	// We ran out of modifications, simply pass through
+	return r.defaultReceiver.Receive(itemType, accountKey, storageKey, accountValue, storageValue, hash, cutoff)
+}
+
+func (r *historyReceiver) Result() trie.SubTries {
+	return r.defaultReceiver.Result()
+}
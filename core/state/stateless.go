@@ -31,8 +31,9 @@ import (
 )
 
 var (
-	_ StateReader = (*Stateless)(nil)
-	_ StateWriter = (*Stateless)(nil)
+	_ StateReader          = (*Stateless)(nil)
+	_ StateWriter          = (*Stateless)(nil)
+	_ WriterWithChangeSets = (*Stateless)(nil)
 )
 
 // Stateless is the inter-block cache for stateless client prototype, iteration 2
@@ -258,6 +259,18 @@ func (s *Stateless) CreateContract(address common.Address) error {
 	return nil
 }
 
+// WriteChangeSets is a part of the WriterWithChangeSets interface
+// Stateless does not keep change sets, so this implementation does nothing
+func (s *Stateless) WriteChangeSets() error {
+	return nil
+}
+
+// WriteHistory is a part of the WriterWithChangeSets interface
+// Stateless does not keep history, so this implementation does nothing
+func (s *Stateless) WriteHistory() error {
+	return nil
+}
+
 // CheckRoot finalises the execution of a block and computes the resulting state root
 func (s *Stateless) CheckRoot(expected common.Hash) error {
 	// The following map is to prevent repeated clearouts of the storage
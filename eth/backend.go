@@ -32,6 +32,7 @@ import (
 	"sync/atomic"
 
 	"github.com/ledgerwatch/turbo-geth/common/etl"
+	"github.com/ledgerwatch/turbo-geth/turbo/changefeed"
 	"github.com/ledgerwatch/turbo-geth/turbo/torrent"
 
 	ethereum "github.com/ledgerwatch/turbo-geth"
@@ -81,6 +82,7 @@ type Ethereum struct {
 	chainDb    *ethdb.ObjectDatabase // Block chain database
 	chainKV    ethdb.KV              // Same as chainDb, but different interface
 	privateAPI *grpc.Server
+	changeFeed *changefeed.Client
 
 	eventMux       *event.TypeMux
 	engine         consensus.Engine
@@ -178,7 +180,7 @@ func New(stack *node.Node, config *Config) (*Ethereum, error) {
 			return nil, err
 		}
 		chainDb.SetKV(snapshotKV)
-		err = torrent.PostProcessing(chainDb, config.SnapshotMode)
+		err = torrent.PostProcessing(chainDb, config.SnapshotMode, dbPath)
 		if err != nil {
 			return nil, err
 		}
@@ -228,7 +230,8 @@ func New(stack *node.Node, config *Config) (*Ethereum, error) {
 		return nil, err
 	}
 	if !reflect.DeepEqual(sm, config.StorageMode) {
-		return nil, errors.New("mode is " + config.StorageMode.ToString() + " original mode is " + sm.ToString())
+		return nil, errors.New("mode is " + config.StorageMode.ToString() + " prune " + config.StorageMode.Prune.ToString() +
+			", original mode is " + sm.ToString() + " prune " + sm.Prune.ToString())
 	}
 
 	vmConfig, cacheConfig := BlockchainRuntimeConfig(config)
@@ -266,7 +269,19 @@ func New(stack *node.Node, config *Config) (*Ethereum, error) {
 
 	eth.txPool = core.NewTxPool(config.TxPool, chainConfig, chainDb, txCacher)
 
+	var events *remotedbserver.Events
 	if stack.Config().PrivateApiAddr != "" {
+		events = remotedbserver.NewEvents()
+		var auth *remotedbserver.Auth
+		if stack.Config().PrivateApiAuthFile != "" {
+			auth, err = remotedbserver.LoadAuth(stack.Config().PrivateApiAuthFile)
+			if err != nil {
+				return nil, fmt.Errorf("private api auth: %w", err)
+			}
+			if !stack.Config().TLSConnection {
+				log.Warn("Private API authentication is enabled without TLS, tokens are sent in plain text")
+			}
+		}
 		if stack.Config().TLSConnection {
 			// load peer cert/key, ca cert
 			var creds credentials.TransportCredentials
@@ -298,12 +313,12 @@ func New(stack *node.Node, config *Config) (*Ethereum, error) {
 			if err != nil {
 				return nil, err
 			}
-			eth.privateAPI, err = remotedbserver.StartGrpc(chainDb.KV(), eth, stack.Config().PrivateApiAddr, &creds)
+			eth.privateAPI, err = remotedbserver.StartGrpc(chainDb.KV(), eth, events, stack.Config().PrivateApiAddr, &creds, auth)
 			if err != nil {
 				return nil, err
 			}
 		} else {
-			eth.privateAPI, err = remotedbserver.StartGrpc(chainDb.KV(), eth, stack.Config().PrivateApiAddr, nil)
+			eth.privateAPI, err = remotedbserver.StartGrpc(chainDb.KV(), eth, events, stack.Config().PrivateApiAddr, nil, auth)
 			if err != nil {
 				return nil, err
 			}
@@ -320,6 +335,16 @@ func New(stack *node.Node, config *Config) (*Ethereum, error) {
 	eth.miner = miner.New(eth, &config.Miner, chainConfig, eth.EventMux(), eth.engine, eth.isLocalBlock)
 	eth.protocolManager.SetTmpDir(tmpdir)
 	eth.protocolManager.SetBatchSize(int(config.BatchSize))
+	if config.ExecutionCache {
+		eth.protocolManager.SetExecutionCacheDir(path.Join(stack.Config().DataDir, "execution_cache"))
+	}
+	if config.ChangeFeedAddr != "" {
+		eth.changeFeed = changefeed.NewClient(config.ChangeFeedAddr)
+		eth.protocolManager.SetChangeFeed(eth.changeFeed)
+	}
+	if events != nil {
+		eth.protocolManager.SetNotifier(events)
+	}
 
 	if config.SyncMode != downloader.StagedSync {
 		if err = eth.StartTxPool(); err != nil {
@@ -744,6 +769,9 @@ func (s *Ethereum) Stop() error {
 	if s.privateAPI != nil {
 		s.privateAPI.GracefulStop()
 	}
+	if s.changeFeed != nil {
+		s.changeFeed.Close()
+	}
 
 	// Then stop everything else.
 	if err := s.StopTxPool(); err != nil {
@@ -129,6 +129,8 @@ type Config struct {
 
 	StorageMode     ethdb.StorageMode
 	BatchSize       datasize.ByteSize // Batch size for execution stage
+	ExecutionCache  bool              // Whether to persist warm state caches of execution stage between restarts
+	ChangeFeedAddr  string            // Address of the sink for state diffs, empty string disables the ChangeFeed stage
 	SnapshotMode    torrent.SnapshotMode
 	SnapshotSeeding bool
 
@@ -40,6 +40,7 @@ import (
 	"github.com/ledgerwatch/turbo-geth/log"
 	"github.com/ledgerwatch/turbo-geth/metrics"
 	"github.com/ledgerwatch/turbo-geth/params"
+	"github.com/ledgerwatch/turbo-geth/turbo/changefeed"
 )
 
 var (
@@ -166,6 +167,8 @@ type Downloader struct {
 	storageMode ethdb.StorageMode
 	tmpdir      string
 	batchSize   int
+	cacheDir    string
+	changeFeed  changefeed.Sink
 
 	headersState    *stagedsync.StageState
 	headersUnwinder stagedsync.Unwinder
@@ -175,6 +178,9 @@ type Downloader struct {
 
 	stagedSyncState *stagedsync.State
 	stagedSync      *stagedsync.StagedSync
+
+	notifier        stagedsync.ChainEventNotifier // Receives new headers and logs after each committed sync cycle
+	headersNotified bool                          // Whether the notifier received the headers of any sync cycle yet
 }
 
 // LightChain encapsulates functions required to synchronise a light chain.
@@ -291,6 +297,21 @@ func (d *Downloader) SetBatchSize(batchSize int) {
 	d.batchSize = batchSize
 }
 
+// SetExecutionCacheDir sets the directory where the execution stage saves its warm state caches, empty string disables it
+func (d *Downloader) SetExecutionCacheDir(cacheDir string) {
+	d.cacheDir = cacheDir
+}
+
+// SetChangeFeed sets the sink of the ChangeFeed stage, nil disables the stage
+func (d *Downloader) SetChangeFeed(changeFeed changefeed.Sink) {
+	d.changeFeed = changeFeed
+}
+
+// SetNotifier sets the receiver of the chain events produced by staged sync
+func (d *Downloader) SetNotifier(notifier stagedsync.ChainEventNotifier) {
+	d.notifier = notifier
+}
+
 func (d *Downloader) SetChainConfig(chainConfig *params.ChainConfig) {
 	d.chainConfig = chainConfig
 }
@@ -560,11 +581,13 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, blockNumb
 			d.storageMode,
 			d.tmpdir,
 			d.batchSize,
+			d.cacheDir,
 			d.quitCh,
 			fetchers,
 			txPool,
 			poolStart,
 			nil,
+			d.changeFeed,
 		)
 		if err != nil {
 			return err
@@ -609,24 +632,25 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, blockNumb
 			return errCommit
 		})
 
+		finishAtBefore, _, err := stages.GetStageProgress(d.stateDB, stages.Finish)
+		if err != nil {
+			return err
+		}
 		err = d.stagedSyncState.Run(d.stateDB, writeDB)
 		if err != nil {
 			return err
 		}
 		if canRunCycleInOneTransaction {
-			if hasTx, ok := tx.(ethdb.HasTx); ok && hasTx.Tx() == nil {
-				return nil
-			}
-
-			commitStart := time.Now()
-			_, errTx := tx.Commit()
-			if errTx == nil {
+			if hasTx, ok := tx.(ethdb.HasTx); !ok || hasTx.Tx() != nil {
+				commitStart := time.Now()
+				if _, errTx := tx.Commit(); errTx != nil {
+					return errTx
+				}
 				log.Info("Commit cycle", "in", time.Since(commitStart))
 			}
-			return errTx
 		}
 
-		return nil
+		return d.notifyNewHeaders(finishAtBefore)
 	}
 
 	fetchers = append(fetchers, func() error { return d.fetchBodies(origin + 1) })   // Bodies are retrieved during normal and fast sync
@@ -641,6 +665,28 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, blockNumb
 	return d.spawnSync(fetchers)
 }
 
+// notifyNewHeaders sends the blocks finished by the last sync cycle to the notifier, if any.
+// The blocks replaced by a reorg are sent again, starting from the fork point. The first cycle
+// sends only the current head, the subscribers don't need the whole chain synced on start.
+func (d *Downloader) notifyNewHeaders(finishAtBefore uint64) error {
+	if d.notifier == nil {
+		return nil
+	}
+	finishAt, _, err := stages.GetStageProgress(d.stateDB, stages.Finish)
+	if err != nil {
+		return err
+	}
+	notifyFrom := finishAtBefore
+	if unwoundTo, ok := d.stagedSyncState.UnwoundTo(); ok && unwoundTo < notifyFrom {
+		notifyFrom = unwoundTo
+	}
+	if !d.headersNotified && finishAt > 0 && notifyFrom < finishAt-1 {
+		notifyFrom = finishAt - 1
+	}
+	d.headersNotified = true
+	return stagedsync.NotifyNewHeaders(notifyFrom, finishAt, d.notifier, d.stateDB)
+}
+
 // spawnSync runs d.process and all given fetcher functions to completion in
 // separate goroutines, returning the first error that appears.
 func (d *Downloader) spawnSync(fetchers []func() error) error {
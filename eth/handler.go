@@ -42,6 +42,7 @@ import (
 	"github.com/ledgerwatch/turbo-geth/p2p/enode"
 	"github.com/ledgerwatch/turbo-geth/params"
 	"github.com/ledgerwatch/turbo-geth/rlp"
+	"github.com/ledgerwatch/turbo-geth/turbo/changefeed"
 )
 
 const (
@@ -105,7 +106,10 @@ type ProtocolManager struct {
 	mode          downloader.SyncMode // Sync mode passed from the command line
 	tmpdir        string
 	batchSize     int
+	cacheDir      string
+	changeFeed    changefeed.Sink
 	currentHeight uint64 // Atomic variable to contain chain height
+	notifier      stagedsync.ChainEventNotifier
 }
 
 // NewProtocolManager returns a new Ethereum sub protocol manager. The Ethereum sub protocol manages peers capable
@@ -180,6 +184,27 @@ func (pm *ProtocolManager) SetBatchSize(batchSize int) {
 	}
 }
 
+func (pm *ProtocolManager) SetExecutionCacheDir(cacheDir string) {
+	pm.cacheDir = cacheDir
+	if pm.downloader != nil {
+		pm.downloader.SetExecutionCacheDir(cacheDir)
+	}
+}
+
+func (pm *ProtocolManager) SetChangeFeed(changeFeed changefeed.Sink) {
+	pm.changeFeed = changeFeed
+	if pm.downloader != nil {
+		pm.downloader.SetChangeFeed(changeFeed)
+	}
+}
+
+func (pm *ProtocolManager) SetNotifier(notifier stagedsync.ChainEventNotifier) {
+	pm.notifier = notifier
+	if pm.downloader != nil {
+		pm.downloader.SetNotifier(notifier)
+	}
+}
+
 func initPm(manager *ProtocolManager, engine consensus.Engine, chainConfig *params.ChainConfig, blockchain *core.BlockChain, chaindb *ethdb.ObjectDatabase) {
 	sm, err := ethdb.GetStorageModeFromDB(chaindb)
 	if err != nil {
@@ -192,6 +217,9 @@ func initPm(manager *ProtocolManager, engine consensus.Engine, chainConfig *para
 	manager.downloader = downloader.New(manager.checkpointNumber, chaindb, manager.eventMux, chainConfig, blockchain, nil, manager.removePeer, sm)
 	manager.downloader.SetTmpDir(manager.tmpdir)
 	manager.downloader.SetBatchSize(manager.batchSize)
+	manager.downloader.SetExecutionCacheDir(manager.cacheDir)
+	manager.downloader.SetChangeFeed(manager.changeFeed)
+	manager.downloader.SetNotifier(manager.notifier)
 	manager.downloader.SetStagedSync(manager.stagedSync)
 
 	// Construct the fetcher (short sync)
@@ -0,0 +1,209 @@
+package stagedsync
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+)
+
+const (
+	executionCacheManifestFile = "manifest.json"
+	executionCacheMinBlocks    = 100
+)
+
+// cachedState is implemented by state readers and writers which can use the warm caches
+// (the same set of caches as StateAccessBuilder receives)
+type cachedState interface {
+	SetAccountCache(*fastcache.Cache)
+	SetStorageCache(*fastcache.Cache)
+	SetCodeCache(*fastcache.Cache)
+	SetCodeSizeCache(*fastcache.Cache)
+}
+
+// executionCaches - warm state caches of the execution stage, persisted to `dir` at commit boundaries.
+// Layout of the directory: one fastcache directory per cache + manifest.json,
+// manifest is written last, so the checkpoint without manifest is never used.
+type executionCaches struct {
+	dir           string
+	accountCache  *fastcache.Cache
+	storageCache  *fastcache.Cache
+	codeCache     *fastcache.Cache
+	codeSizeCache *fastcache.Cache
+}
+
+type executionCacheManifest struct {
+	Block     uint64      `json:"block"`     // caches contain state after execution of this block
+	BlockHash common.Hash `json:"blockHash"` // canonical hash of the block, detects unwind and re-execution of other blocks
+	Checksum  uint32      `json:"checksum"`  // checksum of the block and of all cache files
+}
+
+var executionCacheSizes = []struct {
+	name     string
+	maxBytes int
+}{
+	{"account", 2 * 1024 * 1024 * 1024}, // 2 Gb
+	{"storage", 2 * 1024 * 1024 * 1024}, // 2 Gb
+	{"code", 512 * 1024 * 1024},         // 512 Mb
+	{"codesize", 32 * 1024 * 1024},      // 32 Mb (the minimum)
+}
+
+func (c *executionCaches) all() []*fastcache.Cache {
+	return []*fastcache.Cache{c.accountCache, c.storageCache, c.codeCache, c.codeSizeCache}
+}
+
+func newExecutionCaches(dir string) *executionCaches {
+	return &executionCaches{
+		dir:           dir,
+		accountCache:  fastcache.New(executionCacheSizes[0].maxBytes),
+		storageCache:  fastcache.New(executionCacheSizes[1].maxBytes),
+		codeCache:     fastcache.New(executionCacheSizes[2].maxBytes),
+		codeSizeCache: fastcache.New(executionCacheSizes[3].maxBytes),
+	}
+}
+
+// loadExecutionCaches returns caches saved at `block`. If there are no saved caches, or they are stale or corrupted,
+// then empty caches are returned and the execution starts cold.
+func loadExecutionCaches(logPrefix string, dir string, db ethdb.Getter, block uint64) *executionCaches {
+	if err := checkExecutionCaches(dir, db, block); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warn(fmt.Sprintf("[%s] Saved state caches discarded", logPrefix), "dir", dir, "err", err)
+		}
+		return newExecutionCaches(dir)
+	}
+	var caches [4]*fastcache.Cache
+	for i, c := range executionCacheSizes {
+		var err error
+		if caches[i], err = fastcache.LoadFromFile(filepath.Join(dir, c.name)); err != nil {
+			log.Warn(fmt.Sprintf("[%s] Saved state caches discarded", logPrefix), "dir", dir, "err", err)
+			return newExecutionCaches(dir)
+		}
+	}
+	log.Info(fmt.Sprintf("[%s] Loaded saved state caches", logPrefix), "block", block)
+	return &executionCaches{
+		dir:           dir,
+		accountCache:  caches[0],
+		storageCache:  caches[1],
+		codeCache:     caches[2],
+		codeSizeCache: caches[3],
+	}
+}
+
+func checkExecutionCaches(dir string, db ethdb.Getter, block uint64) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, executionCacheManifestFile))
+	if err != nil {
+		return err
+	}
+	var m executionCacheManifest
+	if err = json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("parsing manifest: %w", err)
+	}
+	if m.Block != block {
+		return fmt.Errorf("caches are saved at block %d, but stage is at block %d", m.Block, block)
+	}
+	hash, err := rawdb.ReadCanonicalHash(db, block)
+	if err != nil {
+		return err
+	}
+	if m.BlockHash != hash {
+		return fmt.Errorf("caches are saved at block %x, but canonical block is %x", m.BlockHash, hash)
+	}
+	checksum, err := executionCachesChecksum(dir, m.Block, m.BlockHash)
+	if err != nil {
+		return err
+	}
+	if checksum != m.Checksum {
+		return fmt.Errorf("checksum mismatch: %x != %x", checksum, m.Checksum)
+	}
+	return nil
+}
+
+// save writes caches as a checkpoint of the state at `block`, the state must be committed before
+func (c *executionCaches) save(db ethdb.Getter, block uint64) error {
+	// invalidate the previous checkpoint first, partially written one must not be loaded after crash
+	manifestPath := filepath.Join(c.dir, executionCacheManifestFile)
+	if err := os.Remove(manifestPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i, cache := range c.all() {
+		if err := cache.SaveToFileConcurrent(filepath.Join(c.dir, executionCacheSizes[i].name), runtime.GOMAXPROCS(-1)); err != nil {
+			return err
+		}
+	}
+	hash, err := rawdb.ReadCanonicalHash(db, block)
+	if err != nil {
+		return err
+	}
+	checksum, err := executionCachesChecksum(c.dir, block, hash)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(executionCacheManifest{Block: block, BlockHash: hash, Checksum: checksum})
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(manifestPath+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(manifestPath+".tmp", manifestPath)
+}
+
+// attach sets the caches to the state reader or writer, if it supports caching
+func (c *executionCaches) attach(s interface{}) {
+	if cs, ok := s.(cachedState); ok {
+		cs.SetAccountCache(c.accountCache)
+		cs.SetStorageCache(c.storageCache)
+		cs.SetCodeCache(c.codeCache)
+		cs.SetCodeSizeCache(c.codeSizeCache)
+	}
+}
+
+func executionCachesChecksum(dir string, block uint64, blockHash common.Hash) (uint32, error) {
+	h := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], block)
+	h.Write(buf[:])
+	h.Write(blockHash[:])
+	for _, c := range executionCacheSizes {
+		if err := checksumDir(h, filepath.Join(dir, c.name)); err != nil {
+			return 0, err
+		}
+	}
+	return h.Sum32(), nil
+}
+
+func checksumDir(h hash.Hash, dir string) error {
+	files, err := ioutil.ReadDir(dir) // sorted by name
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if fi.IsDir() {
+			continue
+		}
+		h.Write([]byte(fi.Name()))
+		f, err := os.Open(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package stagedsync
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+)
+
+// ExtraStage is a third-party stage, which is added to the list of stages without forking the binary
+// (see `turbo/plugins` to load them from Go plugins or to drive out-of-process stages).
+// The progress of the stage is kept by staged sync, in the same way as for the built-in stages.
+type ExtraStage struct {
+	// ID of the stage. It is recommended to prefix it with reverse domain `com.example.my-stage` to avoid conflicts.
+	ID stages.SyncStage
+	// Description is a string that is shown in the logs.
+	Description string
+	// After is the ID of the stage after which the stage runs. On unwind, the stage is unwound right before that stage.
+	After stages.SyncStage
+	// DependsOn (optional) lists the stages which output is read by this stage, see `StageBuilder.DependsOn`.
+	DependsOn []stages.SyncStage
+	// Buckets of the stage, they are created at the start of the node. The list only declares the buckets to create,
+	// writes of the stage are not restricted to them - it should not modify the buckets of other stages.
+	Buckets dbutils.BucketsCfg
+	// Forward moves the stage forward. `db` is the transaction staged sync runs in. Should end with `s.Done()`
+	// or `s.DoneAndUpdate(...)`.
+	Forward func(s *StageState, db ethdb.Database, quitCh <-chan struct{}) error
+	// Unwind removes the data of the stage above `u.UnwindPoint`. Should end with `u.Done(db)`.
+	Unwind func(u *UnwindState, s *StageState, db ethdb.Database, quitCh <-chan struct{}) error
+}
+
+func (e ExtraStage) builder() StageBuilder {
+	return StageBuilder{
+		ID:        e.ID,
+		DependsOn: e.DependsOn,
+		Build: func(world StageParameters) *Stage {
+			return &Stage{
+				ID:          e.ID,
+				Description: e.Description,
+				ExecFunc: func(s *StageState, _ Unwinder) error {
+					return e.Forward(s, world.TX, world.QuitCh)
+				},
+				UnwindFunc: func(u *UnwindState, s *StageState) error {
+					return e.Unwind(u, s, world.TX, world.QuitCh)
+				},
+			}
+		},
+	}
+}
+
+// WithExtraStages inserts the extra stages into the list of stage builders and into the unwind order.
+func WithExtraStages(builders StageBuilders, unwindOrder UnwindOrder, extra []ExtraStage) (StageBuilders, UnwindOrder, error) {
+	resultBuilders := append(StageBuilders{}, builders...)
+	resultOrder := append(UnwindOrder{}, unwindOrder...)
+	for _, e := range extra {
+		if len(e.ID) == 0 || e.Forward == nil || e.Unwind == nil {
+			return nil, nil, fmt.Errorf("stage %q: ID, Forward and Unwind are required", e.ID)
+		}
+		after := -1
+		for i, b := range resultBuilders {
+			if bytes.Equal(b.ID, e.ID) {
+				return nil, nil, fmt.Errorf("stage %q already exists", e.ID)
+			}
+			if bytes.Equal(b.ID, e.After) {
+				after = i
+			}
+		}
+		if after == -1 {
+			return nil, nil, fmt.Errorf("stage %q: stage %q to insert after not found", e.ID, e.After)
+		}
+
+		pos := after + 1
+		resultBuilders = append(resultBuilders[:pos], append(StageBuilders{e.builder()}, resultBuilders[pos:]...)...)
+
+		// indexes of the stages after the inserted one are shifted,
+		// the new stage is unwound right before the stage it goes after (unwind goes from the end of the list)
+		orderPos := len(resultOrder)
+		for i, idx := range resultOrder {
+			if idx >= pos {
+				resultOrder[i]++
+			}
+			if idx == after {
+				orderPos = i + 1
+			}
+		}
+		resultOrder = append(resultOrder[:orderPos], append(UnwindOrder{pos}, resultOrder[orderPos:]...)...)
+	}
+	return resultBuilders, resultOrder, nil
+}
+
+// ExtraBuckets returns the buckets required by the extra stages.
+func ExtraBuckets(extra []ExtraStage) dbutils.BucketsCfg {
+	buckets := dbutils.BucketsCfg{}
+	for _, e := range extra {
+		for name, cfg := range e.Buckets {
+			buckets[name] = cfg
+		}
+	}
+	return buckets
+}
@@ -0,0 +1,42 @@
+package stagedsync
+
+import (
+	"testing"
+
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithExtraStages(t *testing.T) {
+	forward := func(s *StageState, db ethdb.Database, quitCh <-chan struct{}) error { return nil }
+	unwind := func(u *UnwindState, s *StageState, db ethdb.Database, quitCh <-chan struct{}) error { return nil }
+	builders := StageBuilders{{ID: stages.Headers}, {ID: stages.Bodies}, {ID: stages.Execution}, {ID: stages.TxPool}}
+	order := UnwindOrder{0, 3, 1, 2}
+
+	extra := []ExtraStage{
+		{ID: stages.SyncStage("com.example.a"), After: stages.Execution, Forward: forward, Unwind: unwind},
+		{ID: stages.SyncStage("com.example.b"), After: stages.Bodies, Forward: forward, Unwind: unwind},
+	}
+	resultBuilders, resultOrder, err := WithExtraStages(builders, order, extra)
+	require.NoError(t, err)
+
+	ids := make([]string, len(resultBuilders))
+	for i, b := range resultBuilders {
+		ids[i] = string(b.ID)
+	}
+	assert.Equal(t, []string{"Headers", "Bodies", "com.example.b", "Execution", "com.example.a", "TxPool"}, ids)
+	// every stage is unwound right before the stage it goes after
+	assert.Equal(t, UnwindOrder{0, 5, 1, 2, 3, 4}, resultOrder)
+	// original lists are not modified
+	assert.Equal(t, 4, len(builders))
+	assert.Equal(t, UnwindOrder{0, 3, 1, 2}, order)
+
+	_, _, err = WithExtraStages(builders, order, []ExtraStage{{ID: stages.Bodies, After: stages.Headers, Forward: forward, Unwind: unwind}})
+	assert.Error(t, err)
+	_, _, err = WithExtraStages(builders, order, []ExtraStage{{ID: stages.SyncStage("com.example.c"), After: stages.Senders, Forward: forward, Unwind: unwind}})
+	assert.Error(t, err)
+	_, _, err = WithExtraStages(builders, order, []ExtraStage{{ID: stages.SyncStage("com.example.c"), After: stages.Headers}})
+	assert.Error(t, err)
+}
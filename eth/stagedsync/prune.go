@@ -0,0 +1,108 @@
+package stagedsync
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+)
+
+// PruneState contains the information about pruning of the stage.
+type PruneState struct {
+	state *State
+	// Stage is the ID of the stage
+	Stage stages.SyncStage
+	// PrunedTo - data of all blocks below this one is already removed.
+	PrunedTo uint64
+	// ForwardProgress is the block number reached by the stage, data is kept relative to it.
+	ForwardProgress uint64
+}
+
+// LogPrefix returns the prefix for log messages of the stage.
+func (p *PruneState) LogPrefix() string {
+	return p.state.stageLogPrefix(p.Stage)
+}
+
+// Range returns the blocks [from, to) which data the stage must remove to keep only
+// `distance` recent blocks. ok is false if there is nothing to prune.
+func (p *PruneState) Range(distance uint64) (from, to uint64, ok bool) {
+	to, ok = ethdb.PruneTo(distance, p.ForwardProgress)
+	if !ok {
+		return 0, 0, false
+	}
+	return p.PrunedTo, to, p.PrunedTo < to
+}
+
+// Done saves the pruning progress of the stage. Can be called multiple times during pruning.
+func (p *PruneState) Done(db ethdb.Putter, prunedTo uint64) error {
+	p.PrunedTo = prunedTo
+	return stages.SaveStagePruneProgress(db, p.Stage, prunedTo)
+}
+
+// pruneBatch - amount of blocks pruned at once, to limit memory used for the keys changed in these blocks
+const pruneBatch = 10_000
+
+// pruneBitmaps removes blocks below `to` from the sharded bitmaps of the given keys,
+// shard key is [key] + [4 bytes of the biggest block in the shard], so only the first shards of each key are visited
+func pruneBitmaps(tx ethdb.Tx, bucket string, keys map[string]struct{}, to uint64, quit <-chan struct{}) error {
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	c := tx.Cursor(bucket)
+	defer c.Close()
+	cForDelete := tx.Cursor(bucket) // use dedicated cursor for delete operation, the same way as bitmapdb.TruncateRange does
+	defer cForDelete.Close()
+
+	for _, key := range sorted {
+		if err := common.Stopped(quit); err != nil {
+			return err
+		}
+		for k, v, err := c.Seek([]byte(key)); k != nil; k, v, err = c.Next() {
+			if err != nil {
+				return err
+			}
+			if len(k) != len(key)+4 || !bytes.HasPrefix(k, []byte(key)) {
+				break
+			}
+			if uint64(binary.BigEndian.Uint32(k[len(k)-4:])) < to {
+				if err = cForDelete.Delete(k, nil); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// the first shard which keeps blocks from `to`, next shards have only bigger blocks
+			bm := roaring.New()
+			if _, err = bm.FromBuffer(v); err != nil {
+				return err
+			}
+			if bm.IsEmpty() || uint64(bm.Minimum()) >= to {
+				break
+			}
+			bm.RemoveRange(0, to)
+			if bm.GetCardinality() == 0 { // don't store empty bitmaps
+				if err = cForDelete.Delete(k, nil); err != nil {
+					return err
+				}
+				break
+			}
+			bm.RunOptimize()
+			newV := bytes.NewBuffer(make([]byte, 0, bm.GetSerializedSizeInBytes()))
+			if _, err = bm.WriteTo(newV); err != nil {
+				return err
+			}
+			if err = c.Put(common.CopyBytes(k), newV.Bytes()); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
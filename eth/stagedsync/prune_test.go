@@ -0,0 +1,100 @@
+package stagedsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/ethdb/bitmapdb"
+	"github.com/stretchr/testify/require"
+)
+
+func putShard(t *testing.T, tx ethdb.Putter, bucket string, key []byte, shard uint32, blocks ...uint32) {
+	k := make([]byte, len(key)+4)
+	copy(k, key)
+	binary.BigEndian.PutUint32(k[len(key):], shard)
+	buf := bytes.NewBuffer(nil)
+	_, err := roaring.BitmapOf(blocks...).WriteTo(buf)
+	require.NoError(t, err)
+	require.NoError(t, tx.Put(bucket, k, buf.Bytes()))
+}
+
+func TestPruneBitmaps(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	tx, err := db.Begin(context.Background(), ethdb.RW)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	kv := tx.(ethdb.HasTx).Tx()
+
+	addr1, addr2 := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	putShard(t, tx, dbutils.CallFromIndex, addr1[:], 10, 1, 5, 10)
+	putShard(t, tx, dbutils.CallFromIndex, addr1[:], 20, 11, 15, 20)
+	putShard(t, tx, dbutils.CallFromIndex, addr1[:], ^uint32(0), 25, 30)
+	putShard(t, tx, dbutils.CallFromIndex, addr2[:], ^uint32(0), 1, 30)
+
+	require.NoError(t, pruneBitmaps(kv, dbutils.CallFromIndex, map[string]struct{}{string(addr1[:]): {}}, 15, nil))
+
+	m, err := bitmapdb.Get(tx, dbutils.CallFromIndex, addr1[:], 0, 10_000_000)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{15, 20, 25, 30}, m.ToArray())
+	c := kv.Cursor(dbutils.CallFromIndex)
+	defer c.Close()
+	n, err := c.Count()
+	require.NoError(t, err)
+	require.Equal(t, 3, int(n))
+
+	// keys which are not given are not touched
+	m, err = bitmapdb.Get(tx, dbutils.CallFromIndex, addr2[:], 0, 10_000_000)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, 30}, m.ToArray())
+}
+
+func TestPruneCallTraces(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	tx, err := db.Begin(context.Background(), ethdb.RW)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	kv := tx.(ethdb.HasTx).Tx()
+
+	addr1, addr2, addr3 := common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+	putShard(t, tx, dbutils.CallFromIndex, addr1[:], ^uint32(0), 1, 3)
+	putShard(t, tx, dbutils.CallToIndex, addr2[:], ^uint32(0), 1, 2)
+	putShard(t, tx, dbutils.CallToIndex, addr3[:], ^uint32(0), 3)
+	sets := map[uint64][]byte{
+		1: encodeCallTraceSet(map[common.Address]struct{}{addr1: {}}, map[common.Address]struct{}{addr2: {}}),
+		2: encodeCallTraceSet(nil, map[common.Address]struct{}{addr2: {}}),
+		3: encodeCallTraceSet(map[common.Address]struct{}{addr1: {}}, map[common.Address]struct{}{addr3: {}}),
+	}
+	for blockNum, set := range sets {
+		require.NoError(t, tx.Put(dbutils.CallTraceSet, dbutils.EncodeBlockNumber(blockNum), set))
+	}
+
+	require.NoError(t, pruneCallTraces(kv, 0, 3, nil))
+
+	m, err := bitmapdb.Get(tx, dbutils.CallFromIndex, addr1[:], 0, 10_000_000)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{3}, m.ToArray())
+	m, err = bitmapdb.Get(tx, dbutils.CallToIndex, addr2[:], 0, 10_000_000)
+	require.NoError(t, err)
+	require.True(t, m.IsEmpty())
+	m, err = bitmapdb.Get(tx, dbutils.CallToIndex, addr3[:], 0, 10_000_000)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{3}, m.ToArray())
+
+	for blockNum := uint64(1); blockNum <= 3; blockNum++ {
+		v, err := tx.Get(dbutils.CallTraceSet, dbutils.EncodeBlockNumber(blockNum))
+		if blockNum < 3 {
+			require.Error(t, err, blockNum)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, sets[blockNum], v)
+	}
+}
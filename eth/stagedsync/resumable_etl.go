@@ -0,0 +1,166 @@
+package stagedsync
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/common/etl"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+)
+
+// resumableETL keeps the manifests of the ETL files of the stage in its stage data, so that when the stage
+// is interrupted while loading, the next run loads the extracted files instead of extracting everything again.
+// Loadings are identified by names: the ones which are done before the interruption are skipped, and the stage
+// goes to the block the interrupted run was going to, because that is what the files are extracted for.
+// The manifests are only useful if they are committed before the loading, so the stage running in the
+// external transaction is not resumable, and nil is used instead - it does not persist anything.
+type resumableETL struct {
+	s         *StageState
+	db        ethdb.Database // if it is the transaction of the stage, it is committed after saving the manifests
+	to        uint64
+	manifests []*etl.Manifest
+}
+
+func newResumableETL(logPrefix string, s *StageState, db ethdb.Database, to uint64) *resumableETL {
+	r := &resumableETL{s: s, db: db, to: to}
+	manifests, err := etl.DecodeManifests(s.StageData)
+	if err != nil {
+		log.Warn(fmt.Sprintf("[%s] Can't resume ETL of the interrupted run", logPrefix), "err", err)
+		return r
+	}
+	if len(manifests) > 0 && len(manifests[0].ExtractKey) == 8 {
+		r.to = binary.BigEndian.Uint64(manifests[0].ExtractKey)
+		r.manifests = manifests
+		log.Info(fmt.Sprintf("[%s] Resuming ETL of the interrupted run", logPrefix), "to", r.to)
+	}
+	return r
+}
+
+func (r *resumableETL) manifest(name string) *etl.Manifest {
+	if r == nil {
+		return nil
+	}
+	for _, m := range r.manifests {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// loaded returns true if the loading is done before the interruption
+func (r *resumableETL) loaded(name string) bool {
+	m := r.manifest(name)
+	return m != nil && len(m.Files) == 0
+}
+
+// collector returns the collector with the files of the interrupted run, nil if there are none
+func (r *resumableETL) collector(logPrefix, name string) (*etl.Collector, error) {
+	m := r.manifest(name)
+	if m == nil || len(m.Files) == 0 {
+		return nil, nil
+	}
+	c, err := etl.NewCollectorFromManifest(m)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Warn(fmt.Sprintf("[%s] ETL files of the interrupted run are deleted, extracting again", logPrefix), "name", name, "err", err)
+		return nil, m.RemoveFiles()
+	}
+	return c, err
+}
+
+// extracted saves the manifest of the collector, so that its loading can be resumed
+func (r *resumableETL) extracted(name string, c *etl.Collector, bufferType int) error {
+	if r == nil {
+		return nil
+	}
+	m, err := c.Manifest(name, dbutils.EncodeBlockNumber(r.to), bufferType)
+	if err != nil || m == nil {
+		return err // kept in RAM, nothing to resume from
+	}
+	return r.save(m)
+}
+
+// done marks the loading as done, so it is not repeated if the stage is interrupted later
+func (r *resumableETL) done(name string) error {
+	if r == nil {
+		return nil
+	}
+	return r.save(&etl.Manifest{Name: name, ExtractKey: dbutils.EncodeBlockNumber(r.to)})
+}
+
+func (r *resumableETL) save(m *etl.Manifest) error {
+	replaced := false
+	for i := range r.manifests {
+		if r.manifests[i].Name == m.Name {
+			r.manifests[i] = m
+			replaced = true
+		}
+	}
+	if !replaced {
+		r.manifests = append(r.manifests, m)
+	}
+	data, err := etl.EncodeManifests(r.manifests)
+	if err != nil {
+		return err
+	}
+	if err = r.s.UpdateWithStageData(r.db, r.s.BlockNumber, data); err != nil {
+		return err
+	}
+	if hasTx, ok := r.db.(ethdb.HasTx); ok && hasTx.Tx() != nil {
+		return r.db.(ethdb.DbWithPendingMutations).CommitAndBegin(context.Background())
+	}
+	return nil
+}
+
+// args sets up the arguments of etl.Transform, to resume the interrupted loading, or to save the manifest before loading
+func (r *resumableETL) args(name string, args etl.TransformArgs) etl.TransformArgs {
+	if r == nil {
+		return args
+	}
+	if m := r.manifest(name); m != nil {
+		args.Resume = m
+	}
+	args.OnExtracted = func(m *etl.Manifest) error {
+		m.Name = name
+		m.ExtractKey = dbutils.EncodeBlockNumber(r.to)
+		return r.save(m)
+	}
+	return args
+}
+
+// transform is etl.Transform, which skips the loading done before the interruption, and loads the files of the
+// interrupted one without extracting them again
+func (r *resumableETL) transform(logPrefix, name string, db ethdb.Database, fromBucket, toBucket, tmpdir string, extractFunc etl.ExtractFunc, loadFunc etl.LoadFunc, args etl.TransformArgs) error {
+	if r.loaded(name) {
+		log.Info(fmt.Sprintf("[%s] Skipping ETL loaded before the interruption", logPrefix), "name", name)
+		return nil
+	}
+	if err := etl.Transform(logPrefix, db, fromBucket, toBucket, tmpdir, extractFunc, loadFunc, r.args(name, args)); err != nil {
+		return err
+	}
+	return r.done(name)
+}
+
+// discardInterruptedETL removes the files of the interrupted run of the stage, which can't be loaded after unwinding
+func discardInterruptedETL(logPrefix string, s *StageState, db ethdb.Putter) error {
+	manifests, err := etl.DecodeManifests(s.StageData)
+	if err != nil {
+		log.Warn(fmt.Sprintf("[%s] Can't discard ETL of the interrupted run", logPrefix), "err", err)
+		return nil
+	}
+	for _, m := range manifests {
+		if err = m.RemoveFiles(); err != nil {
+			return err
+		}
+	}
+	if len(manifests) > 0 {
+		log.Info(fmt.Sprintf("[%s] Discarded ETL files of the interrupted run", logPrefix))
+		return s.UpdateWithStageData(db, s.BlockNumber, nil)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package stagedsync
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/common/etl"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumableETL(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	tmpdir, err := ioutil.TempDir("", "resumable-etl")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, db.Put(dbutils.PlainStateBucket, []byte(fmt.Sprintf("key-%d", i)), []byte(fmt.Sprintf("value-%d", i))))
+	}
+
+	errInterrupted := errors.New("interrupted")
+	failingLoad := func(k []byte, value []byte, _ etl.CurrentTableReader, next etl.LoadNextFunc) error {
+		return errInterrupted
+	}
+	failingExtract := func(k []byte, v []byte, next etl.ExtractNextFunc) error {
+		return errors.New("extracted again")
+	}
+	identityExtract := func(k []byte, v []byte, next etl.ExtractNextFunc) error {
+		return next(k, k, v)
+	}
+	args := etl.TransformArgs{BufferSize: 1}
+	interrupt := func() {
+		require.NoError(t, stages.SaveStageProgress(db, stages.HashState, 5, nil))
+		r := newResumableETL("", &StageState{Stage: stages.HashState, BlockNumber: 5}, db, 10)
+		require.NoError(t, r.transform("", "state", db, dbutils.PlainStateBucket, dbutils.CurrentStateBucket, tmpdir, identityExtract, etl.IdentityLoadFunc, args))
+		require.Equal(t, errInterrupted, r.transform("", "codes", db, dbutils.PlainStateBucket, dbutils.ContractCodeBucket, tmpdir, identityExtract, failingLoad, args))
+	}
+	restart := func(to uint64) (*StageState, *resumableETL) {
+		progress, stageData, err := stages.GetStageProgress(db, stages.HashState)
+		require.NoError(t, err)
+		s := &StageState{Stage: stages.HashState, BlockNumber: progress, StageData: stageData}
+		return s, newResumableETL("", s, db, to)
+	}
+
+	interrupt()
+	s, r := restart(20)
+	require.Equal(t, 5, int(s.BlockNumber))
+	require.Equal(t, 10, int(r.to), "goes to the block of the interrupted run")
+	// The loaded one is skipped, the interrupted one is loaded from the files
+	require.NoError(t, r.transform("", "state", db, dbutils.PlainStateBucket, dbutils.CurrentStateBucket, tmpdir, failingExtract, etl.IdentityLoadFunc, args))
+	require.NoError(t, r.transform("", "codes", db, dbutils.PlainStateBucket, dbutils.ContractCodeBucket, tmpdir, failingExtract, etl.IdentityLoadFunc, args))
+	for i := 0; i < 10; i++ {
+		v, err := db.Get(dbutils.ContractCodeBucket, []byte(fmt.Sprintf("key-%d", i)))
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("value-%d", i), string(v))
+	}
+	requireNoFiles(t, tmpdir)
+	require.NoError(t, s.Update(db, r.to))
+	s, r = restart(20)
+	require.Equal(t, 10, int(s.BlockNumber))
+	require.Equal(t, 20, int(r.to), "nothing to resume")
+
+	// Unwinding discards the files
+	interrupt()
+	s, _ = restart(20)
+	require.NoError(t, discardInterruptedETL("", s, db))
+	requireNoFiles(t, tmpdir)
+	s, r = restart(20)
+	require.Empty(t, s.StageData)
+	require.Equal(t, 20, int(r.to))
+}
+
+func requireNoFiles(t *testing.T, dir string) {
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
@@ -15,6 +15,11 @@ type ExecFunc func(state *StageState, unwinder Unwinder) error
 // * stageState - represents the state of this stage at the beginning of unwind.
 type UnwindFunc func(unwindState *UnwindState, state *StageState) error
 
+// PruneFunc is the pruning logic of the stage, it is called after forward progress of all stages.
+// * pruneState - contains information about already pruned blocks.
+// * stageState - represents the state of this stage after the forward progress.
+type PruneFunc func(pruneState *PruneState, state *StageState) error
+
 // Stage is a single sync stage in staged sync.
 type Stage struct {
 	// ID of the sync stage. Should not be empty and should be unique. It is recommended to prefix it with reverse domain to avoid clashes (`com.example.my-stage`).
@@ -29,6 +34,10 @@ type Stage struct {
 	ExecFunc ExecFunc
 	// UnwindFunc is called when the stage should be unwound. The unwind logic should be there. MUST NOT be nil!
 	UnwindFunc UnwindFunc
+	// PruneFunc is called after forward progress to remove the stage data of old blocks. Optional, nil means that the stage keeps all its data.
+	PruneFunc PruneFunc
+	// DependsOn (optional) lists the stages which output is read by this stage, see `StageBuilder.DependsOn`.
+	DependsOn []stages.SyncStage
 }
 
 // StageState is the state of the stage.
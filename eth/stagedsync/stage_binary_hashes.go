@@ -0,0 +1,134 @@
+package stagedsync
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/etl"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/core/state"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+)
+
+// SpawnBinaryHashesStage maintains the binary trie commitment of the hashed state, alongside the hexary one.
+// There is nothing to check the root against, so the root is only stored for the block the stage has reached.
+func SpawnBinaryHashesStage(s *StageState, db ethdb.Database, tmpdir string, quit <-chan struct{}) error {
+	to, _, err := stages.GetStageProgress(db, stages.HashState)
+	if err != nil {
+		return err
+	}
+	if s.BlockNumber == to {
+		s.Done()
+		return nil
+	}
+
+	var tx ethdb.DbWithPendingMutations
+	var useExternalTx bool
+	if hasTx, ok := db.(ethdb.HasTx); ok && hasTx.Tx() != nil {
+		tx = db.(ethdb.DbWithPendingMutations)
+		useExternalTx = true
+	} else {
+		var err error
+		tx, err = db.Begin(context.Background(), ethdb.RW)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	logPrefix := s.state.LogPrefix()
+	log.Info(fmt.Sprintf("[%s] Generating binary trie hashes", logPrefix), "from", s.BlockNumber, "to", to)
+	var root common.Hash
+	if s.BlockNumber == 0 {
+		if root, err = state.RegenerateBinaryTrie(tx, quit); err != nil {
+			return fmt.Errorf("%s: %w", logPrefix, err)
+		}
+	} else {
+		p := NewHashPromoter(tx, quit)
+		p.TempDir = tmpdir
+		var changed [][]byte
+		collect := func(k []byte, _ []byte, _ etl.CurrentTableReader, _ etl.LoadNextFunc) error {
+			changed = append(changed, common.CopyBytes(k))
+			return nil
+		}
+		if err = p.Promote(logPrefix, s, s.BlockNumber, to, false /* storage */, collect); err != nil {
+			return err
+		}
+		if err = p.Promote(logPrefix, s, s.BlockNumber, to, true /* storage */, collect); err != nil {
+			return err
+		}
+		if root, err = state.UpdateBinaryTrie(tx, changed, quit); err != nil {
+			return fmt.Errorf("%s: %w", logPrefix, err)
+		}
+	}
+	if err = rawdb.WriteBinaryStateRoot(tx, to, root); err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("[%s] Binary trie root", logPrefix), "block", to, "root", root.Hex())
+
+	if err = s.DoneAndUpdate(tx, to); err != nil {
+		return err
+	}
+	if !useExternalTx {
+		if _, err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnwindBinaryHashesStage brings the binary trie commitment in line with the unwound hashed state,
+// so it has to run after HashState is unwound, but before the change sets are removed by Execution
+func UnwindBinaryHashesStage(u *UnwindState, s *StageState, db ethdb.Database, tmpdir string, quit <-chan struct{}) error {
+	var tx ethdb.DbWithPendingMutations
+	var useExternalTx bool
+	if hasTx, ok := db.(ethdb.HasTx); ok && hasTx.Tx() != nil {
+		tx = db.(ethdb.DbWithPendingMutations)
+		useExternalTx = true
+	} else {
+		var err error
+		tx, err = db.Begin(context.Background(), ethdb.RW)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	logPrefix := s.state.LogPrefix()
+	p := NewHashPromoter(tx, quit)
+	p.TempDir = tmpdir
+	var changed [][]byte
+	collect := func(k []byte, _ []byte, _ etl.CurrentTableReader, _ etl.LoadNextFunc) error {
+		changed = append(changed, common.CopyBytes(k))
+		return nil
+	}
+	if err := p.Unwind(logPrefix, s, u, false /* storage */, collect); err != nil {
+		return err
+	}
+	if err := p.Unwind(logPrefix, s, u, true /* storage */, collect); err != nil {
+		return err
+	}
+	root, err := state.UpdateBinaryTrie(tx, changed, quit)
+	if err != nil {
+		return fmt.Errorf("%s: %w", logPrefix, err)
+	}
+	if err = rawdb.DeleteBinaryStateRootsFrom(tx, u.UnwindPoint+1); err != nil {
+		return fmt.Errorf("%s: %w", logPrefix, err)
+	}
+	if err = rawdb.WriteBinaryStateRoot(tx, u.UnwindPoint, root); err != nil {
+		return err
+	}
+
+	if err = u.Done(tx); err != nil {
+		return fmt.Errorf("%s: reset: %w", logPrefix, err)
+	}
+	if !useExternalTx {
+		if _, err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
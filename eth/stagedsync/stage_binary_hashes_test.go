@@ -0,0 +1,197 @@
+package stagedsync
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/consensus/ethash"
+	"github.com/ledgerwatch/turbo-geth/core"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/core/state"
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/core/types/accounts"
+	"github.com/ledgerwatch/turbo-geth/core/vm"
+	"github.com/ledgerwatch/turbo-geth/crypto"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/params"
+	"github.com/ledgerwatch/turbo-geth/turbo/trie"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryHashes(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	tmpdir, err := ioutil.TempDir("", "binaryhashes")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	gspec, _, chain := generateContractChain(t, db)
+	tx, err := db.Begin(context.Background(), ethdb.RW)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	executeTestChain(t, tx, gspec, chain, tmpdir)
+
+	// Accounts sharing the prefixes with the accounts of the chain, so that there are branch nodes at these prefixes
+	var prefixes [][]byte
+	require.NoError(t, tx.Walk(dbutils.CurrentStateBucket, nil, 0, func(k, v []byte) (bool, error) {
+		if len(k) == common.HashLength {
+			prefixes = append(prefixes, common.CopyBytes(k[:2]))
+		}
+		return true, nil
+	}))
+	for i := 0; i < 50; i++ {
+		addrHash := crypto.Keccak256([]byte{byte(i)})
+		copy(addrHash, prefixes[i%len(prefixes)])
+		acc := accounts.NewAccount()
+		acc.Balance.SetUint64(uint64(i + 1))
+		v := make([]byte, acc.EncodingLengthForStorage())
+		acc.EncodeForStorage(v)
+		require.NoError(t, tx.Put(dbutils.CurrentStateBucket, addrHash, v))
+	}
+
+	require.NoError(t, SpawnBinaryHashesStage(&StageState{Stage: stages.BinaryHashes}, tx, tmpdir, nil))
+	requireBinaryRoot(t, tx, 5)
+
+	// Unwinding of HashState followed by unwinding of the binary hashes
+	require.NoError(t, UnwindHashStateStage(&UnwindState{Stage: stages.HashState, UnwindPoint: 3}, &StageState{Stage: stages.HashState, BlockNumber: 5}, tx, tmpdir, nil))
+	require.NoError(t, UnwindBinaryHashesStage(&UnwindState{Stage: stages.BinaryHashes, UnwindPoint: 3}, &StageState{Stage: stages.BinaryHashes, BlockNumber: 5}, tx, tmpdir, nil))
+	requireBinaryRoot(t, tx, 3)
+	root, err := rawdb.ReadBinaryStateRoot(tx, 5)
+	require.NoError(t, err)
+	require.Equal(t, common.Hash{}, root)
+
+	// Incremental promotion
+	require.NoError(t, SpawnHashStateStage(&StageState{Stage: stages.HashState, BlockNumber: 3}, tx, tmpdir, nil))
+	require.NoError(t, SpawnBinaryHashesStage(&StageState{Stage: stages.BinaryHashes, BlockNumber: 3}, tx, tmpdir, nil))
+	requireBinaryRoot(t, tx, 5)
+}
+
+// requireBinaryRoot checks the stored binary root against the binary trie built from the whole hashed state
+func requireBinaryRoot(t *testing.T, db ethdb.Database, blockNum uint64) {
+	expected := trie.NewBinary(common.Hash{})
+	require.NoError(t, db.Walk(dbutils.CurrentStateBucket, nil, 0, func(k, v []byte) (bool, error) {
+		if len(k) != common.HashLength {
+			return true, nil
+		}
+		var acc accounts.Account
+		if err := acc.DecodeForStorage(v); err != nil {
+			return false, err
+		}
+		storagePrefix := dbutils.GenerateStoragePrefix(k, acc.Incarnation)
+		storageTrie := trie.NewBinary(common.Hash{})
+		if err := db.Walk(dbutils.CurrentStateBucket, storagePrefix, 8*len(storagePrefix), func(sk, sv []byte) (bool, error) {
+			storageTrie.Update(common.CopyBytes(sk[len(storagePrefix):]), common.CopyBytes(sv))
+			return true, nil
+		}); err != nil {
+			return false, err
+		}
+		acc.Root = storageTrie.Hash()
+		expected.UpdateAccount(common.CopyBytes(k), &acc)
+		return true, nil
+	}))
+
+	root, err := rawdb.ReadBinaryStateRoot(db, blockNum)
+	require.NoError(t, err)
+	require.Equal(t, expected.Hash(), root, "block %d", blockNum)
+
+	var hashes int
+	require.NoError(t, db.Walk(dbutils.BinaryIntermediateHashBucket, nil, 0, func(k, v []byte) (bool, error) {
+		hashes++
+		// The trie with the subtrie resolved gives the same root
+		tr, err := state.LoadBinaryTrie(db, append(common.CopyBytes(k), make([]byte, common.HashLength-len(k))...))
+		if err != nil {
+			return false, err
+		}
+		require.Equal(t, root, tr.Hash())
+		return true, nil
+	}))
+	require.NotZero(t, hashes)
+
+	require.NoError(t, db.Walk(dbutils.BinaryStorageIntermediateHashBucket, nil, 0, func(k, v []byte) (bool, error) {
+		storagePrefix := k[:common.HashLength+common.IncarnationLength]
+		key := append(common.CopyBytes(k[len(storagePrefix):]), make([]byte, common.HashLength-len(k)+len(storagePrefix))...)
+		tr, err := state.BinaryStorageTrie(db, common.BytesToHash(storagePrefix[:common.HashLength]), binary.BigEndian.Uint64(storagePrefix[common.HashLength:]), [][]byte{key})
+		if err != nil {
+			return false, err
+		}
+		storageRoot, err := db.Get(dbutils.BinaryStorageRootBucket, storagePrefix)
+		if err != nil {
+			return false, err
+		}
+		require.Equal(t, common.BytesToHash(storageRoot), tr.Hash())
+		return true, nil
+	}))
+}
+
+// generateContractChain generates 5 blocks with transfers and a contract, which stores the first word of the call data
+// into the slot 0, the slot is set to zero again in the block 5
+func generateContractChain(t *testing.T, db *ethdb.ObjectDatabase) (*core.Genesis, *types.Block, []*types.Block) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	bank := crypto.PubkeyToAddress(key.PublicKey)
+	receiver := common.HexToAddress("0x1234567890")
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{bank: {Balance: big.NewInt(1000000000000000000)}},
+	}
+	genesis := gspec.MustCommit(db)
+	signer := types.HomesteadSigner{}
+
+	runtime := []byte{byte(vm.PUSH1), 0, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+	deploy := append([]byte{
+		byte(vm.PUSH1), byte(len(runtime)), byte(vm.PUSH1), 12, byte(vm.PUSH1), 0, byte(vm.CODECOPY),
+		byte(vm.PUSH1), byte(len(runtime)), byte(vm.PUSH1), 0, byte(vm.RETURN),
+	}, runtime...)
+	contract := crypto.CreateAddress(bank, 0)
+
+	chain, _, err := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 5, func(i int, block *core.BlockGen) {
+		var txs []*types.Transaction
+		switch i {
+		case 0:
+			txs = append(txs, types.NewContractCreation(block.TxNonce(bank), new(uint256.Int), 100000, new(uint256.Int), deploy))
+		case 1, 2, 4:
+			txs = append(txs, types.NewTransaction(block.TxNonce(bank), contract, new(uint256.Int), 100000, new(uint256.Int), common.LeftPadBytes([]byte{byte(i % 4)}, 32)))
+		}
+		if i != 3 {
+			txs = append(txs, types.NewTransaction(block.TxNonce(bank)+uint64(len(txs)), receiver, uint256.NewInt().SetUint64(1000), params.TxGas, new(uint256.Int), nil))
+		}
+		for _, tx := range txs {
+			signedTx, err1 := types.SignTx(tx, signer, key)
+			require.NoError(t, err1)
+			block.AddTx(signedTx)
+		}
+	}, false /* intermediateHashes */)
+	require.NoError(t, err)
+	return gspec, genesis, chain
+}
+
+// executeTestChain writes the blocks with their senders, executes them and hashes the state
+func executeTestChain(t *testing.T, tx ethdb.DbWithPendingMutations, gspec *core.Genesis, chain []*types.Block, tmpdir string) *core.TinyChainContext {
+	signer := types.HomesteadSigner{}
+	for _, block := range chain {
+		require.NoError(t, rawdb.WriteBlock(context.Background(), tx, block))
+		require.NoError(t, rawdb.WriteCanonicalHash(tx, block.Hash(), block.NumberU64()))
+		senders := make([]common.Address, len(block.Transactions()))
+		for i, txn := range block.Transactions() {
+			var err error
+			senders[i], err = types.Sender(signer, txn)
+			require.NoError(t, err)
+		}
+		rawdb.WriteSenders(context.Background(), tx, block.Hash(), block.NumberU64(), senders)
+	}
+	require.NoError(t, stages.SaveStageProgress(tx, stages.Senders, uint64(len(chain)), nil))
+
+	cc := &core.TinyChainContext{}
+	cc.SetDB(tx)
+	cc.SetEngine(ethash.NewFaker())
+	require.NoError(t, SpawnExecuteBlocksStage(&StageState{Stage: stages.Execution}, tx, gspec.Config, cc, &vm.Config{}, nil, ExecuteBlockStageParams{}))
+	require.NoError(t, SpawnHashStateStage(&StageState{Stage: stages.HashState}, tx, tmpdir, nil))
+	return cc
+}
@@ -0,0 +1,307 @@
+package stagedsync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/core"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/core/state"
+	"github.com/ledgerwatch/turbo-geth/core/types/accounts"
+	"github.com/ledgerwatch/turbo-geth/core/vm"
+	"github.com/ledgerwatch/turbo-geth/crypto"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+	"github.com/ledgerwatch/turbo-geth/params"
+	"github.com/ledgerwatch/turbo-geth/turbo/trie"
+)
+
+// SpawnBlockWitnessStage builds the witness of every block, which is enough to re-execute the block with `state.Stateless`.
+// Each block is re-executed on top of the history to record the keys and the codes it touches, then the trie of the state
+// before the block is loaded with only these keys resolved (see `state.LoadTrieAt`). So the stage requires history indices
+// and intermediate hashes. When `distance` is set, the witnesses are built only for the blocks which are not going to be pruned.
+func SpawnBlockWitnessStage(s *StageState, db ethdb.Database, chainConfig *params.ChainConfig, chainContext core.ChainContext, distance uint64, quitCh <-chan struct{}) error {
+	var tx ethdb.DbWithPendingMutations
+	var useExternalTx bool
+	if hasTx, ok := db.(ethdb.HasTx); ok && hasTx.Tx() != nil {
+		tx = db.(ethdb.DbWithPendingMutations)
+		useExternalTx = true
+	} else {
+		var err error
+		tx, err = db.Begin(context.Background(), ethdb.RW)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	to, _, err := stages.GetStageProgress(tx, stages.IntermediateHashes)
+	if err != nil {
+		return err
+	}
+	for _, stage := range []stages.SyncStage{stages.AccountHistoryIndex, stages.StorageHistoryIndex} {
+		indexedTo, _, err1 := stages.GetStageProgress(tx, stage)
+		if err1 != nil {
+			return err1
+		}
+		to = min(to, indexedTo)
+	}
+	if to <= s.BlockNumber {
+		s.Done()
+		return nil
+	}
+	from := s.BlockNumber + 1
+	if pruneTo, ok := ethdb.PruneTo(distance, to); ok && pruneTo > from {
+		from = pruneTo
+	}
+
+	logPrefix := s.state.LogPrefix()
+	log.Info(fmt.Sprintf("[%s] Building block witnesses", logPrefix), "from", from, "to", to)
+	logEvery := time.NewTicker(logInterval)
+	defer logEvery.Stop()
+
+	for blockNum := from; blockNum <= to; blockNum++ {
+		if err = common.Stopped(quitCh); err != nil {
+			return err
+		}
+		witness, err := BuildBlockWitness(tx, blockNum, chainConfig, chainContext)
+		if err != nil {
+			return fmt.Errorf("%s: block %d: %w", logPrefix, blockNum, err)
+		}
+		var buf bytes.Buffer
+		if _, err = witness.WriteTo(&buf); err != nil {
+			return fmt.Errorf("%s: block %d: %w", logPrefix, blockNum, err)
+		}
+		if err = rawdb.WriteBlockWitness(tx, blockNum, buf.Bytes()); err != nil {
+			return fmt.Errorf("%s: %w", logPrefix, err)
+		}
+
+		select {
+		default:
+		case <-logEvery.C:
+			log.Info(fmt.Sprintf("[%s] Progress", logPrefix), "number", blockNum)
+		}
+	}
+
+	if err = s.DoneAndUpdate(tx, to); err != nil {
+		return err
+	}
+	if !useExternalTx {
+		if _, err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnwindBlockWitnessStage removes the witnesses of the blocks above the unwind point
+func UnwindBlockWitnessStage(u *UnwindState, s *StageState, db ethdb.Database) error {
+	if err := rawdb.DeleteBlockWitnessesRange(db, u.UnwindPoint+1, s.BlockNumber+1); err != nil {
+		return fmt.Errorf("%s: %w", s.state.LogPrefix(), err)
+	}
+	return u.Done(db)
+}
+
+// PruneBlockWitnesses removes the witnesses of the blocks older than `distance` blocks
+func PruneBlockWitnesses(p *PruneState, db ethdb.Database, distance uint64) error {
+	from, to, ok := p.Range(distance)
+	if !ok {
+		return nil
+	}
+	logPrefix := p.LogPrefix()
+	log.Info(fmt.Sprintf("[%s] Prune", logPrefix), "from", from, "to", to)
+	if err := rawdb.DeleteBlockWitnessesRange(db, from, to); err != nil {
+		return fmt.Errorf("%s: %w", logPrefix, err)
+	}
+	return p.Done(db, to)
+}
+
+// BuildBlockWitness builds the witness of the canonical block `blockNum`. The state as of the previous block
+// must be available: history indices and intermediate hashes must be built at least up to the block.
+func BuildBlockWitness(db ethdb.Database, blockNum uint64, chainConfig *params.ChainConfig, chainContext core.ChainContext) (*trie.Witness, error) {
+	hasTx, ok := db.(ethdb.HasTx)
+	if !ok || hasTx.Tx() == nil {
+		return nil, fmt.Errorf("block witness must be built in a transaction, got %T", db)
+	}
+	blockHash, err := rawdb.ReadCanonicalHash(db, blockNum)
+	if err != nil {
+		return nil, fmt.Errorf("getting canonical hash: %w", err)
+	}
+	block := rawdb.ReadBlock(db, blockHash, blockNum)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", blockHash)
+	}
+	senders := rawdb.ReadSenders(db, blockHash, blockNum)
+	block.Body().SendersToTxs(senders)
+
+	recorder := newWitnessRecorder(state.NewPlainDBState(hasTx.Tx(), blockNum-1))
+	vmConfig := &vm.Config{NoReceipts: true}
+	if _, err = core.ExecuteBlockEphemerally(chainConfig, vmConfig, chainContext, chainContext.Engine(), block, recorder, recorder); err != nil {
+		return nil, err
+	}
+
+	keys := recorder.keys()
+	t, err := state.LoadTrieAt(db, blockNum-1, keys)
+	if err != nil {
+		return nil, err
+	}
+	rl := trie.NewRetainList(0)
+	for _, key := range keys {
+		rl.AddKey(key)
+	}
+	for addrHash, code := range recorder.codes {
+		if err = t.UpdateAccountCode(addrHash[:], code); err != nil {
+			return nil, err
+		}
+		rl.AddCodeTouch(crypto.Keccak256Hash(code))
+	}
+	for addrHash, codeSize := range recorder.codeSizes {
+		if _, ok := recorder.codes[addrHash]; ok {
+			continue
+		}
+		if err = t.UpdateAccountCodeSize(addrHash[:], codeSize); err != nil {
+			return nil, err
+		}
+	}
+	return t.ExtractWitness(false /* trace */, rl)
+}
+
+// witnessRecorder reads the state through the underlying reader and records the accounts, the storage items and
+// the codes, which are read or written during the execution of a block. Writes are not applied anywhere.
+type witnessRecorder struct {
+	reader    state.StateReader
+	accounts  map[common.Hash]struct{}
+	storage   map[string]struct{} // account hash + storage key hash
+	codes     map[common.Hash][]byte
+	codeSizes map[common.Hash]int
+}
+
+func newWitnessRecorder(reader state.StateReader) *witnessRecorder {
+	return &witnessRecorder{
+		reader:    reader,
+		accounts:  make(map[common.Hash]struct{}),
+		storage:   make(map[string]struct{}),
+		codes:     make(map[common.Hash][]byte),
+		codeSizes: make(map[common.Hash]int),
+	}
+}
+
+// keys returns the touched keys in the trie encoding: account hashes and account hashes concatenated with storage key hashes
+func (r *witnessRecorder) keys() [][]byte {
+	keys := make([][]byte, 0, len(r.accounts)+len(r.storage))
+	for addrHash := range r.accounts {
+		keys = append(keys, common.CopyBytes(addrHash[:]))
+	}
+	for key := range r.storage {
+		keys = append(keys, []byte(key))
+	}
+	return keys
+}
+
+func (r *witnessRecorder) touchAccount(address common.Address) (common.Hash, error) {
+	addrHash, err := common.HashData(address[:])
+	if err != nil {
+		return common.Hash{}, err
+	}
+	r.accounts[addrHash] = struct{}{}
+	return addrHash, nil
+}
+
+func (r *witnessRecorder) touchStorage(address common.Address, key *common.Hash) error {
+	addrHash, err := r.touchAccount(address)
+	if err != nil {
+		return err
+	}
+	keyHash, err := common.HashData(key[:])
+	if err != nil {
+		return err
+	}
+	r.storage[string(append(addrHash[:], keyHash[:]...))] = struct{}{}
+	return nil
+}
+
+func (r *witnessRecorder) ReadAccountData(address common.Address) (*accounts.Account, error) {
+	if _, err := r.touchAccount(address); err != nil {
+		return nil, err
+	}
+	return r.reader.ReadAccountData(address)
+}
+
+func (r *witnessRecorder) ReadAccountStorage(address common.Address, incarnation uint64, key *common.Hash) ([]byte, error) {
+	if err := r.touchStorage(address, key); err != nil {
+		return nil, err
+	}
+	return r.reader.ReadAccountStorage(address, incarnation, key)
+}
+
+func (r *witnessRecorder) ReadAccountCode(address common.Address, codeHash common.Hash) ([]byte, error) {
+	addrHash, err := r.touchAccount(address)
+	if err != nil {
+		return nil, err
+	}
+	code, err := r.reader.ReadAccountCode(address, codeHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) > 0 {
+		r.codes[addrHash] = code
+	}
+	return code, nil
+}
+
+func (r *witnessRecorder) ReadAccountCodeSize(address common.Address, codeHash common.Hash) (int, error) {
+	addrHash, err := r.touchAccount(address)
+	if err != nil {
+		return 0, err
+	}
+	codeSize, err := r.reader.ReadAccountCodeSize(address, codeHash)
+	if err != nil {
+		return 0, err
+	}
+	r.codeSizes[addrHash] = codeSize
+	return codeSize, nil
+}
+
+func (r *witnessRecorder) ReadAccountIncarnation(address common.Address) (uint64, error) {
+	if _, err := r.touchAccount(address); err != nil {
+		return 0, err
+	}
+	return r.reader.ReadAccountIncarnation(address)
+}
+
+func (r *witnessRecorder) UpdateAccountData(_ context.Context, address common.Address, original, account *accounts.Account) error {
+	_, err := r.touchAccount(address)
+	return err
+}
+
+func (r *witnessRecorder) UpdateAccountCode(address common.Address, incarnation uint64, codeHash common.Hash, code []byte) error {
+	_, err := r.touchAccount(address)
+	return err
+}
+
+func (r *witnessRecorder) DeleteAccount(_ context.Context, address common.Address, original *accounts.Account) error {
+	_, err := r.touchAccount(address)
+	return err
+}
+
+func (r *witnessRecorder) WriteAccountStorage(_ context.Context, address common.Address, incarnation uint64, key *common.Hash, original, value *uint256.Int) error {
+	return r.touchStorage(address, key)
+}
+
+func (r *witnessRecorder) CreateContract(address common.Address) error {
+	_, err := r.touchAccount(address)
+	return err
+}
+
+func (r *witnessRecorder) WriteChangeSets() error {
+	return nil
+}
+
+func (r *witnessRecorder) WriteHistory() error {
+	return nil
+}
@@ -0,0 +1,129 @@
+package stagedsync
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/consensus/ethash"
+	"github.com/ledgerwatch/turbo-geth/core"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/core/state"
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/core/vm"
+	"github.com/ledgerwatch/turbo-geth/crypto"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/params"
+	"github.com/ledgerwatch/turbo-geth/turbo/trie"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockWitness(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	tmpdir, err := ioutil.TempDir("", "blockwitness")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	bank := crypto.PubkeyToAddress(key.PublicKey)
+	receiver := common.HexToAddress("0x1234567890")
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{bank: {Balance: big.NewInt(1000000000000000000)}},
+	}
+	genesis := gspec.MustCommit(db)
+	signer := types.HomesteadSigner{}
+
+	// Stores the first word of the call data into the slot 0
+	runtime := []byte{byte(vm.PUSH1), 0, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+	deploy := append([]byte{
+		byte(vm.PUSH1), byte(len(runtime)), byte(vm.PUSH1), 12, byte(vm.PUSH1), 0, byte(vm.CODECOPY),
+		byte(vm.PUSH1), byte(len(runtime)), byte(vm.PUSH1), 0, byte(vm.RETURN),
+	}, runtime...)
+	contract := crypto.CreateAddress(bank, 0)
+
+	chain, _, err := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 5, func(i int, block *core.BlockGen) {
+		var txs []*types.Transaction
+		switch i {
+		case 0:
+			txs = append(txs, types.NewContractCreation(block.TxNonce(bank), new(uint256.Int), 100000, new(uint256.Int), deploy))
+		case 1, 2, 4:
+			txs = append(txs, types.NewTransaction(block.TxNonce(bank), contract, new(uint256.Int), 100000, new(uint256.Int), common.LeftPadBytes([]byte{byte(i % 4)}, 32)))
+		}
+		if i != 3 {
+			txs = append(txs, types.NewTransaction(block.TxNonce(bank)+uint64(len(txs)), receiver, uint256.NewInt().SetUint64(1000), params.TxGas, new(uint256.Int), nil))
+		}
+		for _, tx := range txs {
+			signedTx, err1 := types.SignTx(tx, signer, key)
+			require.NoError(t, err1)
+			block.AddTx(signedTx)
+		}
+	}, false /* intermediateHashes */)
+	require.NoError(t, err)
+
+	tx, err := db.Begin(context.Background(), ethdb.RW)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	for _, block := range chain {
+		require.NoError(t, rawdb.WriteBlock(context.Background(), tx, block))
+		require.NoError(t, rawdb.WriteCanonicalHash(tx, block.Hash(), block.NumberU64()))
+		senders := make([]common.Address, len(block.Transactions()))
+		for i, txn := range block.Transactions() {
+			senders[i], err = types.Sender(signer, txn)
+			require.NoError(t, err)
+		}
+		rawdb.WriteSenders(context.Background(), tx, block.Hash(), block.NumberU64(), senders)
+	}
+	require.NoError(t, stages.SaveStageProgress(tx, stages.Senders, 5, nil))
+
+	cc := &core.TinyChainContext{}
+	cc.SetDB(tx)
+	cc.SetEngine(ethash.NewFaker())
+	require.NoError(t, SpawnExecuteBlocksStage(&StageState{Stage: stages.Execution}, tx, gspec.Config, cc, &vm.Config{}, nil, ExecuteBlockStageParams{}))
+	require.NoError(t, SpawnHashStateStage(&StageState{Stage: stages.HashState}, tx, tmpdir, nil))
+	require.NoError(t, SpawnIntermediateHashesStage(&StageState{Stage: stages.IntermediateHashes}, tx, tmpdir, nil))
+	require.NoError(t, SpawnAccountHistoryIndex(&StageState{Stage: stages.AccountHistoryIndex}, tx, tmpdir, nil))
+	require.NoError(t, SpawnStorageHistoryIndex(&StageState{Stage: stages.StorageHistoryIndex}, tx, tmpdir, nil))
+
+	require.NoError(t, SpawnBlockWitnessStage(&StageState{Stage: stages.BlockWitness}, tx, gspec.Config, cc, 0, nil))
+	progress, _, err := stages.GetStageProgress(tx, stages.BlockWitness)
+	require.NoError(t, err)
+	require.Equal(t, 5, int(progress))
+
+	// Every block is re-executed from its witness only, and gives the same state root
+	preRoot := genesis.Root()
+	for _, block := range chain {
+		data, err1 := rawdb.ReadBlockWitness(tx, block.NumberU64())
+		require.NoError(t, err1)
+		require.NotNil(t, data, "block %d", block.NumberU64())
+		witness, err1 := trie.NewWitnessFromReader(bytes.NewReader(data), false)
+		require.NoError(t, err1)
+		stateless, err1 := state.NewStateless(preRoot, witness, block.NumberU64()-1, false, false)
+		require.NoError(t, err1, "block %d", block.NumberU64())
+		_, err1 = core.ExecuteBlockEphemerally(gspec.Config, &vm.Config{}, cc, cc.Engine(), block, stateless, stateless)
+		require.NoError(t, err1, "block %d", block.NumberU64())
+		require.NoError(t, stateless.CheckRoot(block.Root()), "block %d", block.NumberU64())
+		preRoot = block.Root()
+	}
+
+	require.NoError(t, UnwindBlockWitnessStage(&UnwindState{Stage: stages.BlockWitness, UnwindPoint: 3}, &StageState{Stage: stages.BlockWitness, BlockNumber: 5}, tx))
+	for blockNum := uint64(1); blockNum <= 5; blockNum++ {
+		data, err1 := rawdb.ReadBlockWitness(tx, blockNum)
+		require.NoError(t, err1)
+		require.Equal(t, blockNum <= 3, data != nil, "block %d", blockNum)
+	}
+}
+
+func TestBuildBlockWitnessWithoutTx(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	_, err := BuildBlockWitness(db, 1, params.TestChainConfig, &core.TinyChainContext{})
+	require.Error(t, err)
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math/big"
 	"runtime"
+	"sort"
 	"time"
 
 	"github.com/RoaringBitmap/roaring"
@@ -50,11 +51,11 @@ func SpawnCallTraces(s *StageState, db ethdb.Database, chainConfig *params.Chain
 		useExternalTx = true
 	} else {
 		var err error
-		tx, err = db.Begin(context.Background(), ethdb.RW)
+		tx, err = db.Begin(context.Background(), ethdb.RO)
 		if err != nil {
 			return err
 		}
-		defer tx.Rollback()
+		defer func() { tx.Rollback() }()
 	}
 
 	endBlock, err := s.ExecutionAt(tx)
@@ -70,7 +71,16 @@ func SpawnCallTraces(s *StageState, db ethdb.Database, chainConfig *params.Chain
 		return nil
 	}
 
-	if err := promoteCallTraces(logPrefix, tx, s.BlockNumber+1, endBlock, chainConfig, chainContext, tmpdir, quit, params); err != nil {
+	collectorFrom, collectorTo, collectorSet, err := extractCallTraces(logPrefix, tx, s.BlockNumber+1, endBlock, chainConfig, chainContext, tmpdir, quit, params)
+	if err != nil {
+		return err
+	}
+	if !useExternalTx {
+		if tx, err = beginLoad(db, tx); err != nil {
+			return err
+		}
+	}
+	if err := loadCallTraces(logPrefix, tx, collectorFrom, collectorTo, collectorSet, quit); err != nil {
 		return err
 	}
 
@@ -86,7 +96,8 @@ func SpawnCallTraces(s *StageState, db ethdb.Database, chainConfig *params.Chain
 	return nil
 }
 
-func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock uint64, chainConfig *params.ChainConfig, chainContext core.ChainContext, tmpdir string, quit <-chan struct{}, params CallTracesStageParams) error {
+// extractCallTraces executes the blocks to collect the call traces, it only reads tx
+func extractCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock uint64, chainConfig *params.ChainConfig, chainContext core.ChainContext, tmpdir string, quit <-chan struct{}, params CallTracesStageParams) (*etl.Collector, *etl.Collector, *etl.Collector, error) {
 	logEvery := time.NewTicker(logInterval)
 	defer logEvery.Stop()
 
@@ -94,6 +105,7 @@ func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock
 	tos := map[string]*roaring.Bitmap{}
 	collectorFrom := etl.NewCollector(tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize))
 	collectorTo := etl.NewCollector(tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize))
+	collectorSet := etl.NewCollector(tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize))
 
 	accountChangesCursor := tx.(ethdb.HasTx).Tx().Cursor(dbutils.PlainAccountChangeSetBucket)
 	defer accountChangesCursor.Close()
@@ -124,16 +136,16 @@ func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock
 	if params.PresetChanges {
 		accountCsKey, accountCsVal, errAcc = accountChangesCursor.Seek(dbutils.EncodeTimestamp(startBlock))
 		if errAcc != nil {
-			return fmt.Errorf("%s: seeking in account changeset cursor: %v", logPrefix, errAcc)
+			return nil, nil, nil, fmt.Errorf("%s: seeking in account changeset cursor: %v", logPrefix, errAcc)
 		}
 		storageCsKey, storageCsVal, errSt = storageChangesCursor.Seek(dbutils.EncodeTimestamp(startBlock))
 		if errSt != nil {
-			return fmt.Errorf("%s: seeking in storage changeset cursor: %v", logPrefix, errSt)
+			return nil, nil, nil, fmt.Errorf("%s: seeking in storage changeset cursor: %v", logPrefix, errSt)
 		}
 	}
 	for blockNum := startBlock; blockNum <= endBlock; blockNum++ {
 		if err := common.Stopped(quit); err != nil {
-			return err
+			return nil, nil, nil, err
 		}
 
 		select {
@@ -141,11 +153,11 @@ func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock
 		case <-logEvery.C:
 			sz, err := tx.(ethdb.HasTx).Tx().BucketSize(dbutils.CallFromIndex)
 			if err != nil {
-				return err
+				return nil, nil, nil, err
 			}
 			sz2, err := tx.(ethdb.HasTx).Tx().BucketSize(dbutils.CallToIndex)
 			if err != nil {
-				return err
+				return nil, nil, nil, err
 			}
 			var m runtime.MemStats
 			runtime.ReadMemStats(&m)
@@ -160,7 +172,7 @@ func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock
 		case <-checkFlushEvery.C:
 			if needFlush(froms, callIndicesMemLimit) {
 				if err := flushBitmaps(collectorFrom, froms); err != nil {
-					return err
+					return nil, nil, nil, err
 				}
 
 				froms = map[string]*roaring.Bitmap{}
@@ -168,7 +180,7 @@ func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock
 
 			if needFlush(tos, callIndicesMemLimit) {
 				if err := flushBitmaps(collectorTo, tos); err != nil {
-					return err
+					return nil, nil, nil, err
 				}
 
 				tos = map[string]*roaring.Bitmap{}
@@ -176,7 +188,7 @@ func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock
 		}
 		blockHash, err := rawdb.ReadCanonicalHash(tx, blockNum)
 		if err != nil {
-			return fmt.Errorf("%s: getting canonical blockhadh for block %d: %v", logPrefix, blockNum, err)
+			return nil, nil, nil, fmt.Errorf("%s: getting canonical blockhadh for block %d: %v", logPrefix, blockNum, err)
 		}
 		block := rawdb.ReadBlock(tx, blockHash, blockNum)
 		if block == nil {
@@ -213,7 +225,7 @@ func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock
 				cs := changeset.AccountChangeSetPlainBytes(accountCsVal)
 				accountCsKey, accountCsVal, errAcc = accountChangesCursor.Next()
 				if errAcc != nil {
-					return fmt.Errorf("%s: seeking in account changeset cursor: %v", logPrefix, errAcc)
+					return nil, nil, nil, fmt.Errorf("%s: seeking in account changeset cursor: %v", logPrefix, errAcc)
 				}
 				if errAcc = cs.Walk(func(k, v []byte) error {
 					if len(v) == 0 {
@@ -223,7 +235,7 @@ func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock
 					}
 					return nil
 				}); errAcc != nil {
-					return fmt.Errorf("%s: walking in account changeset: %v", logPrefix, errAcc)
+					return nil, nil, nil, fmt.Errorf("%s: walking in account changeset: %v", logPrefix, errAcc)
 				}
 			}
 		}
@@ -233,7 +245,7 @@ func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock
 				cs := changeset.StorageChangeSetPlainBytes(storageCsVal)
 				storageCsKey, storageCsVal, errSt = storageChangesCursor.Next()
 				if errSt != nil {
-					return fmt.Errorf("%s: seeking in storage changeset cursor: %v", logPrefix, errSt)
+					return nil, nil, nil, fmt.Errorf("%s: seeking in storage changeset cursor: %v", logPrefix, errSt)
 				}
 				if errSt = cs.Walk(func(k, v []byte) error {
 					if len(v) == 0 {
@@ -243,7 +255,7 @@ func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock
 					}
 					return nil
 				}); errSt != nil {
-					return fmt.Errorf("%s: walking in storage changeset: %v", logPrefix, errSt)
+					return nil, nil, nil, fmt.Errorf("%s: walking in storage changeset: %v", logPrefix, errSt)
 				}
 			}
 		}
@@ -251,7 +263,12 @@ func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock
 		tracer := NewCallTracer()
 		vmConfig := &vm.Config{Debug: true, NoReceipts: true, ReadOnly: false, Tracer: tracer}
 		if _, err = core.ExecuteBlockEphemerally(chainConfig, vmConfig, chainContext, engine, block, stateReader, stateWriter); err != nil {
-			return err
+			return nil, nil, nil, err
+		}
+		if set := encodeCallTraceSet(tracer.froms, tracer.tos); len(set) > 0 {
+			if err := collectorSet.Collect(dbutils.EncodeBlockNumber(blockNum), set); err != nil {
+				return nil, nil, nil, err
+			}
 		}
 		for addr := range tracer.froms {
 			m, ok := froms[string(addr[:])]
@@ -274,12 +291,16 @@ func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock
 	}
 
 	if err := flushBitmaps(collectorFrom, froms); err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 	if err := flushBitmaps(collectorTo, tos); err != nil {
-		return err
+		return nil, nil, nil, err
 	}
+	return collectorFrom, collectorTo, collectorSet, nil
+}
 
+// loadCallTraces loads the collected call traces into the indices
+func loadCallTraces(logPrefix string, tx ethdb.Database, collectorFrom, collectorTo, collectorSet *etl.Collector, quit <-chan struct{}) error {
 	var currentBitmap = roaring.New()
 	var buf = bytes.NewBuffer(nil)
 	var loaderFunc = func(k []byte, v []byte, table etl.CurrentTableReader, next etl.LoadNextFunc) error {
@@ -335,6 +356,10 @@ func promoteCallTraces(logPrefix string, tx ethdb.Database, startBlock, endBlock
 	if err := collectorTo.Load(logPrefix, tx, dbutils.CallToIndex, loaderFunc, etl.TransformArgs{Quit: quit}); err != nil {
 		return err
 	}
+
+	if err := collectorSet.Load(logPrefix, tx, dbutils.CallTraceSet, etl.IdentityLoadFunc, etl.TransformArgs{Quit: quit}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -371,6 +396,85 @@ func UnwindCallTraces(u *UnwindState, s *StageState, db ethdb.Database, chainCon
 	return nil
 }
 
+// PruneCallTraces removes blocks from the call traces index, except `distance` recent ones
+func PruneCallTraces(p *PruneState, db ethdb.Database, distance uint64, quitCh <-chan struct{}) error {
+	from, to, ok := p.Range(distance)
+	if !ok {
+		return nil
+	}
+
+	var tx ethdb.DbWithPendingMutations
+	var useExternalTx bool
+	if hasTx, ok := db.(ethdb.HasTx); ok && hasTx.Tx() != nil {
+		tx = db.(ethdb.DbWithPendingMutations)
+		useExternalTx = true
+	} else {
+		var err error
+		tx, err = db.Begin(context.Background(), ethdb.RW)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	logPrefix := p.LogPrefix()
+	log.Info(fmt.Sprintf("[%s] Prune", logPrefix), "from", from, "to", to)
+
+	for batchFrom := from; batchFrom < to; batchFrom += pruneBatch {
+		batchTo := min(batchFrom+pruneBatch, to)
+		if err := pruneCallTraces(tx.(ethdb.HasTx).Tx(), batchFrom, batchTo, quitCh); err != nil {
+			return fmt.Errorf("%s: %w", logPrefix, err)
+		}
+		if err := p.Done(tx, batchTo); err != nil {
+			return fmt.Errorf("%s: %w", logPrefix, err)
+		}
+	}
+
+	if !useExternalTx {
+		if _, err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneCallTraces removes blocks [from, to) from the bitmaps of addresses which are recorded in the call trace sets of these blocks
+func pruneCallTraces(tx ethdb.Tx, from, to uint64, quitCh <-chan struct{}) error {
+	froms := map[string]struct{}{}
+	tos := map[string]struct{}{}
+	c := tx.Cursor(dbutils.CallTraceSet)
+	defer c.Close()
+	for k, v, err := c.Seek(dbutils.EncodeBlockNumber(from)); k != nil; k, v, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if err = common.Stopped(quitCh); err != nil {
+			return err
+		}
+		if binary.BigEndian.Uint64(k) >= to {
+			break
+		}
+		if err = walkCallTraceSet(v, func(addr []byte, flags byte) {
+			if flags&callFromFlag != 0 {
+				froms[string(addr)] = struct{}{}
+			}
+			if flags&callToFlag != 0 {
+				tos[string(addr)] = struct{}{}
+			}
+		}); err != nil {
+			return fmt.Errorf("block %d: %w", binary.BigEndian.Uint64(k), err)
+		}
+	}
+
+	if err := pruneBitmaps(tx, dbutils.CallFromIndex, froms, to, quitCh); err != nil {
+		return err
+	}
+	if err := pruneBitmaps(tx, dbutils.CallToIndex, tos, to, quitCh); err != nil {
+		return err
+	}
+	return deleteCallTraceSets(tx, from, to)
+}
+
 func unwindCallTraces(logPrefix string, db rawdb.DatabaseReader, from, to uint64, chainConfig *params.ChainConfig, chainContext core.ChainContext, quitCh <-chan struct{}) error {
 	froms := map[string]struct{}{}
 	tos := map[string]struct{}{}
@@ -420,6 +524,67 @@ func unwindCallTraces(logPrefix string, db rawdb.DatabaseReader, from, to uint64
 	if err := truncateBitmaps(db.(ethdb.HasTx).Tx(), dbutils.CallToIndex, tos, to+1, from+1); err != nil {
 		return err
 	}
+	if err := deleteCallTraceSets(db.(ethdb.HasTx).Tx(), to+1, from+1); err != nil {
+		return err
+	}
+	return nil
+}
+
+const (
+	callFromFlag byte = 1
+	callToFlag   byte = 2
+)
+
+// encodeCallTraceSet encodes addresses seen in the call traces of a block, see dbutils.CallTraceSet
+func encodeCallTraceSet(froms, tos map[common.Address]struct{}) []byte {
+	flags := make(map[common.Address]byte, len(froms)+len(tos))
+	for addr := range froms {
+		flags[addr] |= callFromFlag
+	}
+	for addr := range tos {
+		flags[addr] |= callToFlag
+	}
+	addrs := make([]common.Address, 0, len(flags))
+	for addr := range flags {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+	v := make([]byte, 0, len(addrs)*(common.AddressLength+1))
+	for _, addr := range addrs {
+		v = append(v, addr[:]...)
+		v = append(v, flags[addr])
+	}
+	return v
+}
+
+func walkCallTraceSet(v []byte, f func(addr []byte, flags byte)) error {
+	const entrySize = common.AddressLength + 1
+	if len(v)%entrySize != 0 {
+		return fmt.Errorf("wrong size of call trace set: %d", len(v))
+	}
+	for i := 0; i < len(v); i += entrySize {
+		f(v[i:i+common.AddressLength], v[i+common.AddressLength])
+	}
+	return nil
+}
+
+// deleteCallTraceSets removes the call trace sets of blocks [from, to)
+func deleteCallTraceSets(tx ethdb.Tx, from, to uint64) error {
+	c := tx.Cursor(dbutils.CallTraceSet)
+	defer c.Close()
+	cForDelete := tx.Cursor(dbutils.CallTraceSet) // use dedicated cursor for delete operation, the same way as bitmapdb.TruncateRange does
+	defer cForDelete.Close()
+	for k, _, err := c.Seek(dbutils.EncodeBlockNumber(from)); k != nil; k, _, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if binary.BigEndian.Uint64(k) >= to {
+			break
+		}
+		if err = cForDelete.Delete(k, nil); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
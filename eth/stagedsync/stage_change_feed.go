@@ -0,0 +1,205 @@
+package stagedsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/changeset"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/common/hexutil"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/core/state"
+	"github.com/ledgerwatch/turbo-geth/core/types/accounts"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+	"github.com/ledgerwatch/turbo-geth/turbo/changefeed"
+)
+
+// SpawnChangeFeed sends diffs of the state of every block to the sink.
+// New values are read from the history (as of the next block), so the stage requires history indices
+// and goes after them.
+func SpawnChangeFeed(s *StageState, db ethdb.Database, sink changefeed.Sink, quitCh <-chan struct{}) error {
+	var tx ethdb.DbWithPendingMutations
+	var useExternalTx bool
+	if hasTx, ok := db.(ethdb.HasTx); ok && hasTx.Tx() != nil {
+		tx = db.(ethdb.DbWithPendingMutations)
+		useExternalTx = true
+	} else {
+		var err error
+		tx, err = db.Begin(context.Background(), ethdb.RW)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	accountsIndexedTo, _, err := stages.GetStageProgress(tx, stages.AccountHistoryIndex)
+	if err != nil {
+		return err
+	}
+	storageIndexedTo, _, err := stages.GetStageProgress(tx, stages.StorageHistoryIndex)
+	if err != nil {
+		return err
+	}
+	to := min(accountsIndexedTo, storageIndexedTo)
+	if to <= s.BlockNumber {
+		s.Done()
+		return nil
+	}
+
+	logPrefix := s.state.LogPrefix()
+	log.Info(fmt.Sprintf("[%s] Sending state diffs", logPrefix), "from", s.BlockNumber+1, "to", to)
+	logEvery := time.NewTicker(logInterval)
+	defer logEvery.Stop()
+
+	for blockNum := s.BlockNumber + 1; blockNum <= to; blockNum++ {
+		if err = common.Stopped(quitCh); err != nil {
+			return err
+		}
+		hash, err := rawdb.ReadCanonicalHash(tx, blockNum)
+		if err != nil {
+			return err
+		}
+		ev, err := blockDiff(tx.(ethdb.HasTx).Tx(), blockNum, hash)
+		if err != nil {
+			return fmt.Errorf("%s: block %d: %w", logPrefix, blockNum, err)
+		}
+		if err = sink.Send(ev); err != nil {
+			return fmt.Errorf("%s: %w", logPrefix, err)
+		}
+		if err = s.Update(tx, blockNum); err != nil {
+			return err
+		}
+
+		select {
+		default:
+		case <-logEvery.C:
+			log.Info(fmt.Sprintf("[%s] Progress", logPrefix), "number", blockNum)
+		}
+	}
+
+	if err = s.DoneAndUpdate(tx, to); err != nil {
+		return err
+	}
+	if !useExternalTx {
+		if _, err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnwindChangeFeed notifies the sink that all blocks above the unwind point are reverted
+func UnwindChangeFeed(u *UnwindState, s *StageState, db ethdb.Database, sink changefeed.Sink) error {
+	hash, err := rawdb.ReadCanonicalHash(db, u.UnwindPoint)
+	if err != nil {
+		return err
+	}
+	if err = sink.Send(&changefeed.Event{Type: changefeed.EventRevert, Number: u.UnwindPoint, Hash: hash}); err != nil {
+		return fmt.Errorf("%s: %w", s.state.LogPrefix(), err)
+	}
+	return u.Done(db)
+}
+
+func blockDiff(tx ethdb.Tx, blockNum uint64, hash common.Hash) (*changefeed.Event, error) {
+	ev := &changefeed.Event{Type: changefeed.EventBlock, Number: blockNum, Hash: hash}
+
+	accountChanges, err := tx.GetOne(dbutils.PlainAccountChangeSetBucket, dbutils.EncodeTimestamp(blockNum))
+	if err != nil {
+		return nil, err
+	}
+	if err = changeset.AccountChangeSetPlainBytes(accountChanges).Walk(func(k, _ []byte) error {
+		diff, code, err := accountDiff(tx, k, blockNum)
+		if err != nil {
+			return err
+		}
+		ev.Accounts = append(ev.Accounts, diff)
+		if code != nil {
+			ev.Codes = append(ev.Codes, code)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	storageChanges, err := tx.GetOne(dbutils.PlainStorageChangeSetBucket, dbutils.EncodeTimestamp(blockNum))
+	if err != nil {
+		return nil, err
+	}
+	if err = changeset.StorageChangeSetPlainBytes(storageChanges).Walk(func(k, _ []byte) error {
+		v, err := getAfterBlock(tx, true, k, blockNum)
+		if err != nil {
+			return err
+		}
+		addr, incarnation, key := dbutils.PlainParseCompositeStorageKey(k)
+		ev.Storage = append(ev.Storage, &changefeed.StorageDiff{
+			Address:     addr,
+			Incarnation: hexutil.Uint64(incarnation),
+			Key:         key,
+			Value:       v,
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// accountDiff returns new value of the account and its code, if the code was deployed by the block
+func accountDiff(tx ethdb.Tx, addr []byte, blockNum uint64) (*changefeed.AccountDiff, *changefeed.CodeDiff, error) {
+	diff := &changefeed.AccountDiff{Address: common.BytesToAddress(addr)}
+	enc, err := getAfterBlock(tx, false, addr, blockNum)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(enc) == 0 {
+		diff.Deleted = true
+		return diff, nil, nil
+	}
+	var acc accounts.Account
+	if err = acc.DecodeForStorage(enc); err != nil {
+		return nil, nil, err
+	}
+	diff.Nonce = hexutil.Uint64(acc.Nonce)
+	diff.Balance = (*hexutil.Big)(acc.Balance.ToBig())
+	diff.Incarnation = hexutil.Uint64(acc.Incarnation)
+	diff.CodeHash = acc.CodeHash
+	if acc.IsEmptyCodeHash() {
+		return diff, nil, nil
+	}
+
+	prevEnc, err := getAfterBlock(tx, false, addr, blockNum-1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(prevEnc) > 0 {
+		var prev accounts.Account
+		if err = prev.DecodeForStorage(prevEnc); err != nil {
+			return nil, nil, err
+		}
+		if prev.CodeHash == acc.CodeHash {
+			return diff, nil, nil
+		}
+	}
+	code, err := tx.GetOne(dbutils.CodeBucket, acc.CodeHash[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return diff, &changefeed.CodeDiff{Address: diff.Address, CodeHash: acc.CodeHash, Code: code}, nil
+}
+
+// getAfterBlock returns value of the key after execution of the block, nil if the key doesn't exist
+func getAfterBlock(tx ethdb.Tx, storage bool, key []byte, blockNum uint64) ([]byte, error) {
+	v, err := state.GetAsOf(tx, storage, key, blockNum+1)
+	if err != nil {
+		if errors.Is(err, ethdb.ErrKeyNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return v, nil
+}
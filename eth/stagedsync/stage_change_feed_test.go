@@ -0,0 +1,97 @@
+package stagedsync
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/turbo/changefeed"
+	"github.com/stretchr/testify/require"
+)
+
+type testSink struct {
+	events []*changefeed.Event
+}
+
+func (s *testSink) Send(events ...*changefeed.Event) error {
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *testSink) Close() error { return nil }
+
+func testBlockHash(blockNum uint64) common.Hash {
+	return common.BigToHash(new(big.Int).SetUint64(1000 + blockNum))
+}
+
+func TestChangeFeed(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	tx, err := db.Begin(context.Background(), ethdb.RW)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	tmpdir, err := ioutil.TempDir("", "changefeed")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	generateBlocks(t, 1, 20, plainWriterGen(tx), changeCodeWithIncarnations)
+	for i := uint64(0); i <= 20; i++ {
+		require.NoError(t, rawdb.WriteCanonicalHash(tx, testBlockHash(i), i))
+	}
+	require.NoError(t, stages.SaveStageProgress(tx, stages.Execution, 20, nil))
+	require.NoError(t, SpawnAccountHistoryIndex(&StageState{Stage: stages.AccountHistoryIndex}, tx, tmpdir, nil))
+	require.NoError(t, SpawnStorageHistoryIndex(&StageState{Stage: stages.StorageHistoryIndex}, tx, tmpdir, nil))
+
+	sink := &testSink{}
+	require.NoError(t, SpawnChangeFeed(&StageState{Stage: stages.ChangeFeed, BlockNumber: 10}, tx, sink, nil))
+	require.Equal(t, 10, len(sink.events))
+	progress, _, err := stages.GetStageProgress(tx, stages.ChangeFeed)
+	require.NoError(t, err)
+	require.Equal(t, 20, int(progress))
+
+	contract, eoa := common.HexToAddress("0x12345678900"), common.HexToAddress("0x12345678901")
+	for i, ev := range sink.events {
+		blockNum := uint64(11 + i)
+		require.Equal(t, changefeed.EventBlock, ev.Type)
+		require.Equal(t, blockNum, ev.Number)
+		require.Equal(t, testBlockHash(blockNum), ev.Hash)
+
+		require.Equal(t, 2, len(ev.Accounts), fmt.Sprintf("block %d", blockNum))
+		for _, acc := range ev.Accounts {
+			require.False(t, acc.Deleted)
+			require.Equal(t, blockNum, acc.Balance.ToInt().Uint64())
+		}
+		require.Equal(t, contract, ev.Accounts[0].Address)
+		require.Equal(t, eoa, ev.Accounts[1].Address)
+
+		// contract is re-created with new code every 10 blocks
+		if blockNum%10 == 0 {
+			require.Equal(t, 1, len(ev.Codes))
+			require.Equal(t, contract, ev.Codes[0].Address)
+			require.Equal(t, []byte(fmt.Sprintf("acc-code-%d", blockNum)), []byte(ev.Codes[0].Code))
+		} else {
+			require.Equal(t, 0, len(ev.Codes))
+		}
+
+		require.Equal(t, 1, len(ev.Storage))
+		require.Equal(t, contract, ev.Storage[0].Address)
+		require.Equal(t, common.BigToHash(new(big.Int).SetUint64(blockNum)), ev.Storage[0].Key)
+		require.Equal(t, []byte{1}, []byte(ev.Storage[0].Value))
+	}
+
+	u := &UnwindState{Stage: stages.ChangeFeed, UnwindPoint: 15}
+	require.NoError(t, UnwindChangeFeed(u, &StageState{Stage: stages.ChangeFeed, BlockNumber: 20}, tx, sink))
+	revert := sink.events[len(sink.events)-1]
+	require.Equal(t, changefeed.EventRevert, revert.Type)
+	require.Equal(t, 15, int(revert.Number))
+	progress, _, err = stages.GetStageProgress(tx, stages.ChangeFeed)
+	require.NoError(t, err)
+	require.Equal(t, 15, int(progress))
+}
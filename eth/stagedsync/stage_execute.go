@@ -34,6 +34,10 @@ type StateReaderBuilder func(ethdb.Getter) state.StateReader
 
 type StateWriterBuilder func(db ethdb.Database, changeSetsDB ethdb.Database, blockNumber uint64) state.WriterWithChangeSets
 
+// ExecuteBlocksFunc executes the blocks in the block execution stage, `SpawnExecuteBlocksStage` by default.
+// The unwinder unwinds the execution together with the stages which depend on it.
+type ExecuteBlocksFunc func(s *StageState, u Unwinder, stateDB ethdb.Database, chainConfig *params.ChainConfig, chainContext *core.TinyChainContext, vmConfig *vm.Config, quit <-chan struct{}, params ExecuteBlockStageParams) error
+
 type ExecuteBlockStageParams struct {
 	ToBlock       uint64 // not setting this params means no limit
 	WriteReceipts bool
@@ -41,6 +45,10 @@ type ExecuteBlockStageParams struct {
 	ChangeSetHook ChangeSetHook
 	ReaderBuilder StateReaderBuilder
 	WriterBuilder StateWriterBuilder
+	// CacheDir (optional) - directory to save warm state caches at every commit, they are loaded on the next start of the stage
+	CacheDir string
+	// BlockHook (optional) - is called after every executed block, before it can be committed, e.g. to wait for other shards
+	BlockHook func(blockNum uint64) error
 }
 
 func SpawnExecuteBlocksStage(s *StageState, stateDB ethdb.Database, chainConfig *params.ChainConfig, chainContext *core.TinyChainContext, vmConfig *vm.Config, quit <-chan struct{}, params ExecuteBlockStageParams) error {
@@ -76,6 +84,12 @@ func SpawnExecuteBlocksStage(s *StageState, stateDB ethdb.Database, chainConfig
 	batch := tx.NewBatch()
 	defer batch.Rollback()
 
+	var caches *executionCaches
+	// caching is not worth it for small runs of blocks, e.g. at the tip of the chain
+	if params.CacheDir != "" && to-s.BlockNumber > executionCacheMinBlocks {
+		caches = loadExecutionCaches(logPrefix, params.CacheDir, tx, s.BlockNumber)
+	}
+
 	engine := chainContext.Engine()
 	chainContext.SetDB(tx)
 
@@ -117,6 +131,10 @@ func SpawnExecuteBlocksStage(s *StageState, stateDB ethdb.Database, chainConfig
 		} else {
 			stateWriter = state.NewPlainStateWriter(batch, tx, blockNum)
 		}
+		if caches != nil {
+			caches.attach(stateReader)
+			caches.attach(stateWriter)
+		}
 
 		// where the magic happens
 		receipts, err := core.ExecuteBlockEphemerally(chainConfig, vmConfig, chainContext, engine, block, stateReader, stateWriter)
@@ -130,6 +148,12 @@ func SpawnExecuteBlocksStage(s *StageState, stateDB ethdb.Database, chainConfig
 			}
 		}
 
+		if params.BlockHook != nil {
+			if err = params.BlockHook(blockNum); err != nil {
+				return fmt.Errorf("%s: block %d: %w", logPrefix, blockNum, err)
+			}
+		}
+
 		if batch.BatchSize() >= params.BatchSize {
 			if err = s.Update(batch, blockNum); err != nil {
 				return err
@@ -143,6 +167,11 @@ func SpawnExecuteBlocksStage(s *StageState, stateDB ethdb.Database, chainConfig
 				}
 				chainContext.SetDB(tx)
 			}
+			if caches != nil {
+				if err = caches.save(tx, blockNum); err != nil {
+					return fmt.Errorf("%s: saving state caches: %w", logPrefix, err)
+				}
+			}
 		}
 
 		if params.ChangeSetHook != nil {
@@ -169,6 +198,11 @@ func SpawnExecuteBlocksStage(s *StageState, stateDB ethdb.Database, chainConfig
 			return err
 		}
 	}
+	if caches != nil {
+		if err := caches.save(stateDB, stageProgress); err != nil {
+			return fmt.Errorf("%s: saving state caches: %w", logPrefix, err)
+		}
+	}
 
 	log.Info(fmt.Sprintf("[%s] Completed on", logPrefix), "block", stageProgress)
 	s.Done()
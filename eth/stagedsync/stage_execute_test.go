@@ -3,10 +3,15 @@ package stagedsync
 import (
 	"context"
 	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/ledgerwatch/turbo-geth/common"
 	"github.com/ledgerwatch/turbo-geth/common/dbutils"
 	"github.com/ledgerwatch/turbo-geth/core"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
 	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
 	"github.com/ledgerwatch/turbo-geth/ethdb"
 )
@@ -129,3 +134,38 @@ func TestUnwindExecutionStagePlainWithCodeChanges(t *testing.T) {
 
 	compareCurrentState(t, db1, db2, dbutils.PlainStateBucket, dbutils.PlainContractCodeBucket)
 }
+
+func TestExecutionCachesCheckpoint(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	dir, err := ioutil.TempDir("", "execution_cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, rawdb.WriteCanonicalHash(db, common.HexToHash("0x01"), 10))
+	require.NoError(t, rawdb.WriteCanonicalHash(db, common.HexToHash("0x02"), 11))
+
+	caches := loadExecutionCaches("test", dir, db, 10)
+	caches.accountCache.Set([]byte("acc"), []byte("value"))
+	require.NoError(t, caches.save(db, 10))
+
+	// warm caches are loaded at the same block
+	caches = loadExecutionCaches("test", dir, db, 10)
+	require.Equal(t, []byte("value"), caches.accountCache.Get(nil, []byte("acc")))
+
+	// stage progress doesn't match
+	caches = loadExecutionCaches("test", dir, db, 11)
+	require.False(t, caches.accountCache.Has([]byte("acc")))
+
+	// block was unwound and another one executed
+	require.NoError(t, rawdb.WriteCanonicalHash(db, common.HexToHash("0x03"), 10))
+	caches = loadExecutionCaches("test", dir, db, 10)
+	require.False(t, caches.accountCache.Has([]byte("acc")))
+
+	// corrupted cache file
+	require.NoError(t, rawdb.WriteCanonicalHash(db, common.HexToHash("0x01"), 10))
+	require.True(t, loadExecutionCaches("test", dir, db, 10).accountCache.Has([]byte("acc")))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "account", "metadata.bin"), []byte("garbage"), 0644))
+	caches = loadExecutionCaches("test", dir, db, 10)
+	require.False(t, caches.accountCache.Has([]byte("acc")))
+}
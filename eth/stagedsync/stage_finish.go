@@ -0,0 +1,58 @@
+package stagedsync
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/migrations"
+)
+
+// ChainEventNotifier receives the chain events produced by a sync cycle,
+// after the cycle has been committed to the database.
+type ChainEventNotifier interface {
+	OnNewHeader(*types.Header)
+	OnNewLogs(types.Logs)
+	// HasLogsSubscriptions returns false if OnNewLogs is not needed, not to read the receipts then
+	HasLogsSubscriptions() bool
+}
+
+// NotifyNewHeaders sends the canonical headers (from, to] and the logs of
+// their blocks to the notifier. It is expected to be called after the
+// Finish stage has been committed, so the data is visible to the RPC API.
+func NotifyNewHeaders(from, to uint64, notifier ChainEventNotifier, db ethdb.Database) error {
+	if notifier == nil {
+		return nil
+	}
+	for i := from + 1; i <= to; i++ {
+		hash, err := rawdb.ReadCanonicalHash(db, i)
+		if err != nil {
+			return err
+		}
+		header := rawdb.ReadHeader(db, hash, i)
+		if header == nil {
+			return fmt.Errorf("could not find canonical header for block %d", i)
+		}
+		notifier.OnNewHeader(header)
+
+		if !notifier.HasLogsSubscriptions() {
+			continue
+		}
+		var logs types.Logs
+		for _, receipt := range rawdb.ReadReceipts(db, hash, i) {
+			logs = append(logs, receipt.Logs...)
+		}
+		if len(logs) > 0 {
+			notifier.OnNewLogs(logs)
+		}
+	}
+	return nil
+}
+
+// dropStaleMigrationBackups frees the space of the migration backups once the stages moved,
+// they can't be restored after that
+func dropStaleMigrationBackups(db ethdb.Database, tmpdir string) error {
+	return migrations.DropStaleBackups(db, path.Join(tmpdir, "migrations_backup"), nil)
+}
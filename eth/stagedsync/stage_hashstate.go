@@ -32,13 +32,19 @@ func SpawnHashStateStage(s *StageState, db ethdb.Database, tmpdir string, quit <
 	}
 
 	logPrefix := s.state.LogPrefix()
+	var r *resumableETL
+	if hasTx, ok := db.(ethdb.HasTx); !ok || hasTx.Tx() == nil {
+		// in the transaction nothing survives the interruption, so there is nothing to resume
+		r = newResumableETL(logPrefix, s, db, to)
+		to = r.to
+	}
 	log.Info(fmt.Sprintf("[%s] Promoting plain state", logPrefix), "from", s.BlockNumber, "to", to)
 	if s.BlockNumber == 0 { // Initial hashing of the state is performed at the previous stage
-		if err := promoteHashedStateCleanly(logPrefix, db, tmpdir, quit); err != nil {
+		if err := promoteHashedStateCleanly(logPrefix, db, r, tmpdir, quit); err != nil {
 			return err
 		}
 	} else {
-		if err := promoteHashedStateIncrementally(logPrefix, s, s.BlockNumber, to, db, tmpdir, quit); err != nil {
+		if err := promoteHashedStateIncrementally(logPrefix, s, s.BlockNumber, to, db, r, tmpdir, quit); err != nil {
 			return err
 		}
 	}
@@ -74,14 +80,15 @@ func unwindHashStateStageImpl(logPrefix string, u *UnwindState, s *StageState, s
 	return nil
 }
 
-func promoteHashedStateCleanly(logPrefix string, db ethdb.Database, tmpdir string, quit <-chan struct{}) error {
-	err := etl.Transform(
+func promoteHashedStateCleanly(logPrefix string, db ethdb.Database, r *resumableETL, tmpdir string, quit <-chan struct{}) error {
+	err := r.transform(
 		logPrefix,
+		"state",
 		db,
 		dbutils.PlainStateBucket,
 		dbutils.CurrentStateBucket,
 		tmpdir,
-		keyTransformExtractFunc(transformPlainStateKey),
+		keyTransformExtractFunc(TransformPlainStateKey),
 		etl.IdentityLoadFunc,
 		etl.TransformArgs{
 			Quit: quit,
@@ -91,13 +98,14 @@ func promoteHashedStateCleanly(logPrefix string, db ethdb.Database, tmpdir strin
 		return err
 	}
 
-	return etl.Transform(
+	return r.transform(
 		logPrefix,
+		"codes",
 		db,
 		dbutils.PlainContractCodeBucket,
 		dbutils.ContractCodeBucket,
 		tmpdir,
-		keyTransformExtractFunc(transformContractCodeKey),
+		keyTransformExtractFunc(TransformContractCodeKey),
 		etl.IdentityLoadFunc,
 		etl.TransformArgs{
 			Quit: quit,
@@ -115,7 +123,8 @@ func keyTransformExtractFunc(transformKey func([]byte) ([]byte, error)) etl.Extr
 	}
 }
 
-func transformPlainStateKey(key []byte) ([]byte, error) {
+// TransformPlainStateKey converts the key of PlainStateBucket to the key of CurrentStateBucket
+func TransformPlainStateKey(key []byte) ([]byte, error) {
 	switch len(key) {
 	case common.AddressLength:
 		// account
@@ -140,7 +149,8 @@ func transformPlainStateKey(key []byte) ([]byte, error) {
 	}
 }
 
-func transformContractCodeKey(key []byte) ([]byte, error) {
+// TransformContractCodeKey converts the key of PlainContractCodeBucket to the key of ContractCodeBucket
+func TransformContractCodeKey(key []byte) ([]byte, error) {
 	if len(key) != common.AddressLength+common.IncarnationLength {
 		return nil, fmt.Errorf("could not convert code key from plain to hashed, unexpected len: %d", len(key))
 	}
@@ -183,6 +193,7 @@ type Promoter struct {
 	ChangeSetBufSize uint64
 	TempDir          string
 	quitCh           chan struct{}
+	resume           *resumableETL
 }
 
 func getExtractFunc(db ethdb.Getter, changeSetBucket string) etl.ExtractFunc {
@@ -194,7 +205,7 @@ func getExtractFunc(db ethdb.Getter, changeSetBucket string) etl.ExtractFunc {
 			if err != nil && !errors.Is(err, ethdb.ErrKeyNotFound) {
 				return err
 			}
-			newK, err := transformPlainStateKey(k)
+			newK, err := TransformPlainStateKey(k)
 			if err != nil {
 				return err
 			}
@@ -230,7 +241,7 @@ func getExtractCode(db ethdb.Getter, changeSetBucket string) etl.ExtractFunc {
 			if codeHash == nil {
 				return nil
 			}
-			newK, err := transformContractCodeKey(plainKey)
+			newK, err := TransformContractCodeKey(plainKey)
 			if err != nil {
 				return err
 			}
@@ -243,7 +254,7 @@ func getUnwindExtractStorage(changeSetBucket string) etl.ExtractFunc {
 	walkerAdapter := changeset.Mapper[changeSetBucket].WalkerAdapter
 	return func(_, changesetBytes []byte, next etl.ExtractNextFunc) error {
 		return walkerAdapter(changesetBytes).Walk(func(k, v []byte) error {
-			newK, err := transformPlainStateKey(k)
+			newK, err := TransformPlainStateKey(k)
 			if err != nil {
 				return err
 			}
@@ -256,7 +267,7 @@ func getUnwindExtractAccounts(db ethdb.Getter, changeSetBucket string) etl.Extra
 	walkerAdapter := changeset.Mapper[changeSetBucket].WalkerAdapter
 	return func(_, changesetBytes []byte, next etl.ExtractNextFunc) error {
 		return walkerAdapter(changesetBytes).Walk(func(k, v []byte) error {
-			newK, err := transformPlainStateKey(k)
+			newK, err := TransformPlainStateKey(k)
 			if err != nil {
 				return err
 			}
@@ -307,7 +318,7 @@ func getCodeUnwindExtractFunc(db ethdb.Getter) etl.ExtractFunc {
 			if err != nil && !errors.Is(err, ethdb.ErrKeyNotFound) {
 				return fmt.Errorf("getCodeUnwindExtractFunc: %w, key=%x", err, plainKey)
 			}
-			newK, err := transformContractCodeKey(plainKey)
+			newK, err := TransformContractCodeKey(plainKey)
 			if err != nil {
 				return err
 			}
@@ -332,16 +343,20 @@ func (p *Promoter) Promote(logPrefix string, s *StageState, from, to uint64, sto
 
 	var loadBucket string
 	var extract etl.ExtractFunc
+	var name string
 	if codes {
 		loadBucket = dbutils.ContractCodeBucket
 		extract = getExtractCode(p.db, changeSetBucket)
+		name = "codes"
 	} else {
 		loadBucket = dbutils.CurrentStateBucket
 		extract = getExtractFunc(p.db, changeSetBucket)
+		name = changeSetBucket
 	}
 
-	return etl.Transform(
+	return p.resume.transform(
 		logPrefix,
+		name,
 		p.db,
 		changeSetBucket,
 		loadBucket,
@@ -351,6 +366,7 @@ func (p *Promoter) Promote(logPrefix string, s *StageState, from, to uint64, sto
 		etl.TransformArgs{
 			BufferType:      etl.SortableOldestAppearedBuffer,
 			ExtractStartKey: startkey,
+			ExtractEndKey:   dbutils.EncodeTimestamp(to),
 			Quit:            p.quitCh,
 		},
 	)
@@ -409,9 +425,10 @@ func (p *Promoter) Unwind(logPrefix string, s *StageState, u *UnwindState, stora
 	)
 }
 
-func promoteHashedStateIncrementally(logPrefix string, s *StageState, from, to uint64, db ethdb.Database, tmpdir string, quit <-chan struct{}) error {
+func promoteHashedStateIncrementally(logPrefix string, s *StageState, from, to uint64, db ethdb.Database, r *resumableETL, tmpdir string, quit <-chan struct{}) error {
 	prom := NewPromoter(db, quit)
 	prom.TempDir = tmpdir
+	prom.resume = r
 	if err := prom.Promote(logPrefix, s, from, to, false /* storage */, true /* codes */); err != nil {
 		return err
 	}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 
 	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/common/etl"
 	"github.com/ledgerwatch/turbo-geth/core"
 	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
 )
 
 func SpawnAccountHistoryIndex(s *StageState, db ethdb.Database, tmpdir string, quitCh <-chan struct{}) error {
@@ -26,6 +28,7 @@ func SpawnAccountHistoryIndex(s *StageState, db ethdb.Database, tmpdir string, q
 
 	ig := core.NewIndexGenerator(logPrefix, db, quitCh)
 	ig.TempDir = tmpdir
+	endBlock = resumableIndex(logPrefix, s, db, ig, endBlock)
 
 	if err := ig.GenerateIndex(blockNum, endBlock, dbutils.PlainAccountChangeSetBucket, tmpdir); err != nil {
 		return fmt.Errorf("%s: fail to generate index: %w", logPrefix, err)
@@ -51,6 +54,7 @@ func SpawnStorageHistoryIndex(s *StageState, db ethdb.Database, tmpdir string, q
 	}
 	ig := core.NewIndexGenerator(logPrefix, db, quitCh)
 	ig.TempDir = tmpdir
+	endBlock = resumableIndex(logPrefix, s, db, ig, endBlock)
 	if err := ig.GenerateIndex(blockNum, endBlock, dbutils.PlainStorageChangeSetBucket, tmpdir); err != nil {
 		return fmt.Errorf("%s: fail to generate index: %w", logPrefix, err)
 	}
@@ -58,6 +62,19 @@ func SpawnStorageHistoryIndex(s *StageState, db ethdb.Database, tmpdir string, q
 	return s.DoneAndUpdate(db, endBlock)
 }
 
+// resumableIndex makes the index generation resume the loading of the interrupted run, and returns the block
+// which the index is generated up to then
+func resumableIndex(logPrefix string, s *StageState, db ethdb.Database, ig *core.IndexGenerator, endBlock uint64) uint64 {
+	if hasTx, ok := db.(ethdb.HasTx); ok && hasTx.Tx() != nil {
+		// in the transaction nothing survives the interruption, so there is nothing to resume
+		return endBlock
+	}
+	r := newResumableETL(logPrefix, s, db, endBlock)
+	args := r.args("index", etl.TransformArgs{})
+	ig.Resume, ig.OnExtracted = args.Resume, args.OnExtracted
+	return r.to
+}
+
 func UnwindAccountHistoryIndex(u *UnwindState, s *StageState, db ethdb.Database, quitCh <-chan struct{}) error {
 	logPrefix := s.state.LogPrefix()
 	ig := core.NewIndexGenerator(logPrefix, db, quitCh)
@@ -81,3 +98,33 @@ func UnwindStorageHistoryIndex(u *UnwindState, s *StageState, db ethdb.Database,
 	}
 	return nil
 }
+
+// PruneAccountHistoryIndex removes account change sets and history index of all blocks except `distance` recent ones
+func PruneAccountHistoryIndex(p *PruneState, db ethdb.Database, distance uint64, quitCh <-chan struct{}) error {
+	return pruneHistoryIndex(p, db, distance, dbutils.PlainAccountChangeSetBucket, quitCh)
+}
+
+// PruneStorageHistoryIndex removes storage change sets and history index of all blocks except `distance` recent ones
+func PruneStorageHistoryIndex(p *PruneState, db ethdb.Database, distance uint64, quitCh <-chan struct{}) error {
+	return pruneHistoryIndex(p, db, distance, dbutils.PlainStorageChangeSetBucket, quitCh)
+}
+
+func pruneHistoryIndex(p *PruneState, db ethdb.Database, distance uint64, changeSetBucket string, quitCh <-chan struct{}) error {
+	from, to, ok := p.Range(distance)
+	if !ok {
+		return nil
+	}
+	logPrefix := p.LogPrefix()
+	log.Info(fmt.Sprintf("[%s] Prune", logPrefix), "from", from, "to", to)
+	ig := core.NewIndexGenerator(logPrefix, db, quitCh)
+	for batchFrom := from; batchFrom < to; batchFrom += pruneBatch {
+		batchTo := min(batchFrom+pruneBatch, to)
+		if err := ig.Prune(batchFrom, batchTo, changeSetBucket); err != nil {
+			return fmt.Errorf("%s: fail to prune index: %w", logPrefix, err)
+		}
+		if err := p.Done(db, batchTo); err != nil {
+			return fmt.Errorf("%s: %w", logPrefix, err)
+		}
+	}
+	return nil
+}
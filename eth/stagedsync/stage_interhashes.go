@@ -150,7 +150,7 @@ func (p *HashPromoter) Promote(logPrefix string, s *StageState, from, to uint64,
 	walkerAdapter := changeset.Mapper[changeSetBucket].WalkerAdapter
 	extract := func(_, changesetBytes []byte, next etl.ExtractNextFunc) error {
 		return walkerAdapter(changesetBytes).Walk(func(k, v []byte) error {
-			newK, err := transformPlainStateKey(k)
+			newK, err := TransformPlainStateKey(k)
 			if err != nil {
 				return err
 			}
@@ -195,7 +195,7 @@ func (p *HashPromoter) Unwind(logPrefix string, s *StageState, u *UnwindState, s
 	walkerAdapter := changeset.Mapper[changeSetBucket].WalkerAdapter
 	extract := func(_, changesetBytes []byte, next etl.ExtractNextFunc) error {
 		return walkerAdapter(changesetBytes).Walk(func(k, v []byte) error {
-			newK, err := transformPlainStateKey(k)
+			newK, err := TransformPlainStateKey(k)
 			if err != nil {
 				return err
 			}
@@ -394,26 +394,29 @@ func unwindIntermediateHashesStageImpl(logPrefix string, u *UnwindState, s *Stag
 	return nil
 }
 
+// ResetHashState clears the hashed state and resets the HashState stage, together with the stages which are
+// derived from the hashed state: IntermediateHashes, BinaryHashes and BlockWitness
 func ResetHashState(db ethdb.Database) error {
 	if err := db.(ethdb.BucketsMigrator).ClearBuckets(
 		dbutils.CurrentStateBucket,
 		dbutils.ContractCodeBucket,
 		dbutils.IntermediateTrieHashBucket,
+		dbutils.BinaryIntermediateHashBucket,
+		dbutils.BinaryStorageIntermediateHashBucket,
+		dbutils.BinaryStorageRootBucket,
+		dbutils.BinaryStateRootBucket,
+		dbutils.BlockWitnessBucket,
 	); err != nil {
 		return err
 	}
 	batch := db.NewBatch()
-	if err := stages.SaveStageProgress(batch, stages.IntermediateHashes, 0, nil); err != nil {
-		return err
-	}
-	if err := stages.SaveStageUnwind(batch, stages.IntermediateHashes, 0, nil); err != nil {
-		return err
-	}
-	if err := stages.SaveStageProgress(batch, stages.HashState, 0, nil); err != nil {
-		return err
-	}
-	if err := stages.SaveStageUnwind(batch, stages.HashState, 0, nil); err != nil {
-		return err
+	for _, stage := range []stages.SyncStage{stages.HashState, stages.IntermediateHashes, stages.BinaryHashes, stages.BlockWitness} {
+		if err := stages.SaveStageProgress(batch, stage, 0, nil); err != nil {
+			return err
+		}
+		if err := stages.SaveStageUnwind(batch, stage, 0, nil); err != nil {
+			return err
+		}
 	}
 	if _, err := batch.Commit(); err != nil {
 		return err
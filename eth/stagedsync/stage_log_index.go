@@ -17,6 +17,7 @@ import (
 	"github.com/ledgerwatch/turbo-geth/common"
 	"github.com/ledgerwatch/turbo-geth/common/dbutils"
 	"github.com/ledgerwatch/turbo-geth/common/etl"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
 	"github.com/ledgerwatch/turbo-geth/core/types"
 	"github.com/ledgerwatch/turbo-geth/ethdb"
 	"github.com/ledgerwatch/turbo-geth/ethdb/bitmapdb"
@@ -36,11 +37,11 @@ func SpawnLogIndex(s *StageState, db ethdb.Database, tmpdir string, quit <-chan
 		useExternalTx = true
 	} else {
 		var err error
-		tx, err = db.Begin(context.Background(), ethdb.RW)
+		tx, err = db.Begin(context.Background(), ethdb.RO)
 		if err != nil {
 			return err
 		}
-		defer tx.Rollback()
+		defer func() { tx.Rollback() }()
 	}
 
 	endBlock, err := s.ExecutionAt(tx)
@@ -53,12 +54,31 @@ func SpawnLogIndex(s *StageState, db ethdb.Database, tmpdir string, quit <-chan
 		return nil
 	}
 
+	var r *resumableETL
+	if !useExternalTx {
+		// the manifests are saved in own transaction, not to write in the read one, and are marked done
+		// with the transaction of the loading
+		r = newResumableETL(logPrefix, s, db, endBlock)
+		endBlock = r.to
+	}
+
 	start := s.BlockNumber
 	if start > 0 {
 		start++
 	}
 
-	if err := promoteLogIndex(logPrefix, tx, start, tmpdir, quit); err != nil {
+	collectorTopics, collectorAddrs, err := collectLogIndex(logPrefix, tx, r, start, tmpdir, quit)
+	if err != nil {
+		return err
+	}
+	if !useExternalTx {
+		if tx, err = beginLoad(db, tx); err != nil {
+			closeCollectors(logPrefix, collectorTopics, collectorAddrs)
+			return err
+		}
+		r.db = tx
+	}
+	if err := loadLogIndex(logPrefix, tx, r, collectorTopics, collectorAddrs, quit); err != nil {
 		return err
 	}
 
@@ -74,7 +94,131 @@ func SpawnLogIndex(s *StageState, db ethdb.Database, tmpdir string, quit <-chan
 	return nil
 }
 
-func promoteLogIndex(logPrefix string, db ethdb.Database, start uint64, tmpdir string, quit <-chan struct{}) error {
+func promoteLogIndex(logPrefix string, db ethdb.Database, r *resumableETL, start uint64, tmpdir string, quit <-chan struct{}) error {
+	collectorTopics, collectorAddrs, err := collectLogIndex(logPrefix, db, r, start, tmpdir, quit)
+	if err != nil {
+		return err
+	}
+	return loadLogIndex(logPrefix, db, r, collectorTopics, collectorAddrs, quit)
+}
+
+// collectLogIndex returns the collectors of the indices, with the files of the interrupted run or extracted from db.
+// It only reads db, the manifests of the extracted files are saved by r
+func collectLogIndex(logPrefix string, db ethdb.Database, r *resumableETL, start uint64, tmpdir string, quit <-chan struct{}) (collectorTopics, collectorAddrs *etl.Collector, err error) {
+	collectorTopics, err = r.collector(logPrefix, "topics")
+	if err != nil {
+		return nil, nil, err
+	}
+	collectorAddrs, err = r.collector(logPrefix, "addresses")
+	if err != nil {
+		return nil, nil, err
+	}
+	if (collectorTopics == nil && !r.loaded("topics")) || (collectorAddrs == nil && !r.loaded("addresses")) {
+		// nothing to resume, or some files are deleted, then both indices are extracted again, but the loading
+		// done before the interruption is not repeated: its collector is discarded and it keeps the done marker
+		closeCollectors(logPrefix, collectorTopics, collectorAddrs)
+		if r == nil {
+			collectorTopics = etl.NewCollector(tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize))
+			collectorAddrs = etl.NewCollector(tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize))
+		} else {
+			// the files are kept if loading fails, to resume it
+			collectorTopics = etl.NewCriticalCollector(tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize))
+			collectorAddrs = etl.NewCriticalCollector(tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize))
+		}
+		if err = extractLogIndex(logPrefix, db, start, collectorTopics, collectorAddrs, quit); err != nil {
+			closeCollectors(logPrefix, collectorTopics, collectorAddrs)
+			return nil, nil, err
+		}
+		if r.loaded("topics") {
+			collectorTopics.Close(logPrefix)
+		} else if err = r.extracted("topics", collectorTopics, etl.SortableSliceBuffer); err != nil {
+			return nil, nil, err
+		}
+		if r.loaded("addresses") {
+			collectorAddrs.Close(logPrefix)
+		} else if err = r.extracted("addresses", collectorAddrs, etl.SortableSliceBuffer); err != nil {
+			return nil, nil, err
+		}
+	}
+	return collectorTopics, collectorAddrs, nil
+}
+
+// loadLogIndex loads the collected indices into db
+func loadLogIndex(logPrefix string, db ethdb.Database, r *resumableETL, collectorTopics, collectorAddrs *etl.Collector, quit <-chan struct{}) error {
+
+	var currentBitmap = roaring.New()
+	var buf = bytes.NewBuffer(nil)
+
+	var loaderFunc = func(k []byte, v []byte, table etl.CurrentTableReader, next etl.LoadNextFunc) error {
+		lastChunkKey := make([]byte, len(k)+4)
+		copy(lastChunkKey, k)
+		binary.BigEndian.PutUint32(lastChunkKey[len(k):], ^uint32(0))
+		lastChunkBytes, err := table.Get(lastChunkKey)
+		if err != nil && !errors.Is(err, ethdb.ErrKeyNotFound) {
+			return fmt.Errorf("%s: find last chunk failed: %w", logPrefix, err)
+		}
+
+		lastChunk := roaring.New()
+		if len(lastChunkBytes) > 0 {
+			_, err = lastChunk.FromBuffer(lastChunkBytes)
+			if err != nil {
+				return fmt.Errorf("%s: couldn't read last log index chunk: %w, len(lastChunkBytes)=%d", logPrefix, err, len(lastChunkBytes))
+			}
+		}
+
+		if _, err := currentBitmap.FromBuffer(v); err != nil {
+			return err
+		}
+		currentBitmap.Or(lastChunk) // merge last existing chunk from db - next loop will overwrite it
+		nextChunk := bitmapdb.ChunkIterator(currentBitmap, bitmapdb.ChunkLimit)
+		for chunk := nextChunk(); chunk != nil; chunk = nextChunk() {
+			buf.Reset()
+			if _, err := chunk.WriteTo(buf); err != nil {
+				return err
+			}
+			chunkKey := make([]byte, len(k)+4)
+			copy(chunkKey, k)
+			if currentBitmap.GetCardinality() == 0 {
+				binary.BigEndian.PutUint32(chunkKey[len(k):], ^uint32(0))
+				if err := next(k, chunkKey, common.CopyBytes(buf.Bytes())); err != nil {
+					return err
+				}
+				break
+			}
+			binary.BigEndian.PutUint32(chunkKey[len(k):], chunk.Maximum())
+			if err := next(k, chunkKey, common.CopyBytes(buf.Bytes())); err != nil {
+				return err
+			}
+		}
+
+		currentBitmap.Clear()
+		return nil
+	}
+
+	if !r.loaded("topics") {
+		if err := collectorTopics.Load(logPrefix, db, dbutils.LogTopicIndex, loaderFunc, etl.TransformArgs{Quit: quit}); err != nil {
+			return err
+		}
+		collectorTopics.Close(logPrefix)
+		if err := r.done("topics"); err != nil {
+			return err
+		}
+	}
+
+	if !r.loaded("addresses") {
+		if err := collectorAddrs.Load(logPrefix, db, dbutils.LogAddressIndex, loaderFunc, etl.TransformArgs{Quit: quit}); err != nil {
+			return err
+		}
+		collectorAddrs.Close(logPrefix)
+		if err := r.done("addresses"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractLogIndex(logPrefix string, db ethdb.Database, start uint64, collectorTopics, collectorAddrs *etl.Collector, quit <-chan struct{}) error {
 	logEvery := time.NewTicker(30 * time.Second)
 	defer logEvery.Stop()
 
@@ -86,9 +230,6 @@ func promoteLogIndex(logPrefix string, db ethdb.Database, start uint64, tmpdir s
 	checkFlushEvery := time.NewTicker(logIndicesCheckSizeEvery)
 	defer checkFlushEvery.Stop()
 
-	collectorTopics := etl.NewCollector(tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize))
-	collectorAddrs := etl.NewCollector(tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize))
-
 	reader := bytes.NewReader(nil)
 
 	for k, v, err := logs.Seek(dbutils.LogKey(start, 0)); k != nil; k, v, err = logs.Next() {
@@ -156,64 +297,6 @@ func promoteLogIndex(logPrefix string, db ethdb.Database, start uint64, tmpdir s
 	if err := flushBitmaps(collectorAddrs, addresses); err != nil {
 		return err
 	}
-
-	var currentBitmap = roaring.New()
-	var buf = bytes.NewBuffer(nil)
-
-	var loaderFunc = func(k []byte, v []byte, table etl.CurrentTableReader, next etl.LoadNextFunc) error {
-		lastChunkKey := make([]byte, len(k)+4)
-		copy(lastChunkKey, k)
-		binary.BigEndian.PutUint32(lastChunkKey[len(k):], ^uint32(0))
-		lastChunkBytes, err := table.Get(lastChunkKey)
-		if err != nil && !errors.Is(err, ethdb.ErrKeyNotFound) {
-			return fmt.Errorf("%s: find last chunk failed: %w", logPrefix, err)
-		}
-
-		lastChunk := roaring.New()
-		if len(lastChunkBytes) > 0 {
-			_, err = lastChunk.FromBuffer(lastChunkBytes)
-			if err != nil {
-				return fmt.Errorf("%s: couldn't read last log index chunk: %w, len(lastChunkBytes)=%d", logPrefix, err, len(lastChunkBytes))
-			}
-		}
-
-		if _, err := currentBitmap.FromBuffer(v); err != nil {
-			return err
-		}
-		currentBitmap.Or(lastChunk) // merge last existing chunk from db - next loop will overwrite it
-		nextChunk := bitmapdb.ChunkIterator(currentBitmap, bitmapdb.ChunkLimit)
-		for chunk := nextChunk(); chunk != nil; chunk = nextChunk() {
-			buf.Reset()
-			if _, err := chunk.WriteTo(buf); err != nil {
-				return err
-			}
-			chunkKey := make([]byte, len(k)+4)
-			copy(chunkKey, k)
-			if currentBitmap.GetCardinality() == 0 {
-				binary.BigEndian.PutUint32(chunkKey[len(k):], ^uint32(0))
-				if err := next(k, chunkKey, common.CopyBytes(buf.Bytes())); err != nil {
-					return err
-				}
-				break
-			}
-			binary.BigEndian.PutUint32(chunkKey[len(k):], chunk.Maximum())
-			if err := next(k, chunkKey, common.CopyBytes(buf.Bytes())); err != nil {
-				return err
-			}
-		}
-
-		currentBitmap.Clear()
-		return nil
-	}
-
-	if err := collectorTopics.Load(logPrefix, db, dbutils.LogTopicIndex, loaderFunc, etl.TransformArgs{Quit: quit}); err != nil {
-		return err
-	}
-
-	if err := collectorAddrs.Load(logPrefix, db, dbutils.LogAddressIndex, loaderFunc, etl.TransformArgs{Quit: quit}); err != nil {
-		return err
-	}
-
 	return nil
 }
 
@@ -284,6 +367,84 @@ func unwindLogIndex(logPrefix string, db ethdb.DbWithPendingMutations, from, to
 	return nil
 }
 
+// PruneLogIndex removes receipts, logs and logs index of all blocks except `distance` recent ones
+func PruneLogIndex(p *PruneState, db ethdb.Database, distance uint64, quitCh <-chan struct{}) error {
+	from, to, ok := p.Range(distance)
+	if !ok {
+		return nil
+	}
+
+	var tx ethdb.DbWithPendingMutations
+	var useExternalTx bool
+	if hasTx, ok := db.(ethdb.HasTx); ok && hasTx.Tx() != nil {
+		tx = db.(ethdb.DbWithPendingMutations)
+		useExternalTx = true
+	} else {
+		var err error
+		tx, err = db.Begin(context.Background(), ethdb.RW)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	logPrefix := p.LogPrefix()
+	log.Info(fmt.Sprintf("[%s] Prune", logPrefix), "from", from, "to", to)
+	for batchFrom := from; batchFrom < to; batchFrom += pruneBatch {
+		batchTo := min(batchFrom+pruneBatch, to)
+		if err := pruneLogIndex(logPrefix, tx, batchFrom, batchTo, quitCh); err != nil {
+			return err
+		}
+		if err := p.Done(tx, batchTo); err != nil {
+			return fmt.Errorf("%s: %w", logPrefix, err)
+		}
+	}
+
+	if !useExternalTx {
+		if _, err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pruneLogIndex(logPrefix string, tx ethdb.DbWithPendingMutations, from, to uint64, quitCh <-chan struct{}) error {
+	topics := map[string]struct{}{}
+	addrs := map[string]struct{}{}
+	if err := tx.Walk(dbutils.Log, dbutils.EncodeBlockNumber(from), 0, func(k, v []byte) (bool, error) {
+		if err := common.Stopped(quitCh); err != nil {
+			return false, err
+		}
+		if binary.BigEndian.Uint64(k) >= to {
+			return false, nil
+		}
+		var logs types.Logs
+		if err := cbor.Unmarshal(&logs, bytes.NewReader(v)); err != nil {
+			return false, fmt.Errorf("%s: receipt unmarshal failed: %w, block=%d", logPrefix, err, binary.BigEndian.Uint64(k))
+		}
+		for _, l := range logs {
+			for _, topic := range l.Topics {
+				topics[string(topic.Bytes())] = struct{}{}
+			}
+			addrs[string(l.Address.Bytes())] = struct{}{}
+		}
+		return true, nil
+	}); err != nil {
+		return err
+	}
+
+	if err := pruneBitmaps(tx.(ethdb.HasTx).Tx(), dbutils.LogTopicIndex, topics, to, quitCh); err != nil {
+		return fmt.Errorf("%s: %w", logPrefix, err)
+	}
+	if err := pruneBitmaps(tx.(ethdb.HasTx).Tx(), dbutils.LogAddressIndex, addrs, to, quitCh); err != nil {
+		return fmt.Errorf("%s: %w", logPrefix, err)
+	}
+	if err := rawdb.DeleteReceiptsRange(tx, from, to); err != nil {
+		return fmt.Errorf("%s: %w", logPrefix, err)
+	}
+	return nil
+}
+
 func needFlush(bitmaps map[string]*roaring.Bitmap, memLimit datasize.ByteSize) bool {
 	sz := uint64(0)
 	for _, m := range bitmaps {
@@ -310,6 +471,14 @@ func flushBitmaps(c *etl.Collector, inMem map[string]*roaring.Bitmap) error {
 	return nil
 }
 
+func closeCollectors(logPrefix string, collectors ...*etl.Collector) {
+	for _, c := range collectors {
+		if c != nil {
+			c.Close(logPrefix)
+		}
+	}
+}
+
 func truncateBitmaps(tx ethdb.Tx, bucket string, inMem map[string]struct{}, from, to uint64) error {
 	keys := make([]string, 0, len(inMem))
 	for k := range inMem {
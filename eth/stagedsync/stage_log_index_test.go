@@ -2,12 +2,20 @@ package stagedsync
 
 import (
 	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/c2h5oh/datasize"
 	"github.com/ledgerwatch/turbo-geth/common"
 	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/common/etl"
 	"github.com/ledgerwatch/turbo-geth/core/rawdb"
 	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
 	"github.com/ledgerwatch/turbo-geth/ethdb/bitmapdb"
 
 	"github.com/ledgerwatch/turbo-geth/ethdb"
@@ -51,7 +59,7 @@ func TestLogIndex(t *testing.T) {
 	err = rawdb.AppendReceipts(tx, 2, receipts2)
 	require.NoError(err)
 
-	err = promoteLogIndex("logPrefix", tx, 0, "", nil)
+	err = promoteLogIndex("logPrefix", tx, nil, 0, "", nil)
 	require.NoError(err)
 
 	// Check indices GetCardinality (in how many blocks they meet)
@@ -111,3 +119,123 @@ func TestLogIndex(t *testing.T) {
 	require.NoError(err)
 	require.Equal(0, int(m.GetCardinality()))
 }
+
+func TestPruneLogIndex(t *testing.T) {
+	require := require.New(t)
+
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	tx, err := db.Begin(context.Background(), ethdb.RW)
+	require.NoError(err)
+	defer tx.Rollback()
+
+	addr1, addr2 := common.HexToAddress("0x0"), common.HexToAddress("0x376c47978271565f56DEB45495afa69E59c16Ab2")
+	topic1, topic2 := common.HexToHash("0x0"), common.HexToHash("0x1234")
+	receipts1 := types.Receipts{{
+		Logs: []*types.Log{{Address: addr1, Topics: []common.Hash{topic1, topic2}}},
+	}}
+	receipts2 := types.Receipts{{
+		Logs: []*types.Log{{Address: addr2, Topics: []common.Hash{topic2}}},
+	}}
+	require.NoError(rawdb.AppendReceipts(tx, 1, receipts1))
+	require.NoError(rawdb.AppendReceipts(tx, 2, receipts2))
+	require.NoError(promoteLogIndex("logPrefix", tx, nil, 0, "", nil))
+
+	err = pruneLogIndex("logPrefix", tx, 0, 2, nil)
+	require.NoError(err)
+
+	m, err := bitmapdb.Get(tx, dbutils.LogAddressIndex, addr1[:], 0, 10_000_000)
+	require.NoError(err)
+	require.Equal(0, int(m.GetCardinality()))
+
+	m, err = bitmapdb.Get(tx, dbutils.LogAddressIndex, addr2[:], 0, 10_000_000)
+	require.NoError(err)
+	require.Equal(1, int(m.GetCardinality()))
+
+	m, err = bitmapdb.Get(tx, dbutils.LogTopicIndex, topic2[:], 0, 10_000_000)
+	require.NoError(err)
+	require.Equal(1, int(m.GetCardinality()))
+
+	require.Nil(rawdb.ReadRawReceipts(tx, common.Hash{}, 1))
+	require.NotNil(rawdb.ReadRawReceipts(tx, common.Hash{}, 2))
+}
+
+// TestLogIndexExtractsWithoutWriter - the stage running concurrently with other stages extracts the indices in the
+// read transaction, it doesn't wait for the write transaction of the other stage until it loads them
+func TestLogIndexExtractsWithoutWriter(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	receipts := types.Receipts{{
+		Logs: []*types.Log{{Address: common.HexToAddress("0x1"), Topics: []common.Hash{common.HexToHash("0x1")}}},
+	}}
+	tx, err := db.Begin(context.Background(), ethdb.RW)
+	require.NoError(t, err)
+	require.NoError(t, rawdb.AppendReceipts(tx, 1, receipts))
+	require.NoError(t, stages.SaveStageProgress(tx, stages.Execution, 1, nil))
+	_, err = tx.Commit()
+	require.NoError(t, err)
+	state := NewState([]*Stage{{ID: stages.LogIndex}})
+	s, err := state.StageState(stages.LogIndex, db)
+	require.NoError(t, err)
+
+	writer, err := db.Begin(context.Background(), ethdb.RW)
+	require.NoError(t, err)
+	defer writer.Rollback()
+
+	// the stage is interrupted while extracting, it can't get there if it waits for the writer
+	quit := make(chan struct{})
+	close(quit)
+	done := make(chan error, 1)
+	go func() { done <- SpawnLogIndex(s, db, "", quit) }()
+	select {
+	case err = <-done:
+		require.True(t, errors.Is(err, common.ErrStopped), err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("the stage waits for the write transaction before extracting")
+	}
+}
+
+func TestLogIndexResume(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	tmpdir, err := ioutil.TempDir("", "logindex-resume")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	addr, topic := common.HexToAddress("0x1"), common.HexToHash("0x1")
+	receipts := types.Receipts{{
+		Logs: []*types.Log{{Address: addr, Topics: []common.Hash{topic}}},
+	}}
+	tx, err := db.Begin(context.Background(), ethdb.RW)
+	require.NoError(t, err)
+	require.NoError(t, rawdb.AppendReceipts(tx, 1, receipts))
+	require.NoError(t, stages.SaveStageProgress(tx, stages.Execution, 1, nil))
+	_, err = tx.Commit()
+	require.NoError(t, err)
+
+	// the indices are extracted into files, to be resumable
+	defer func(size datasize.ByteSize) { etl.BufferOptimalSize = size }(etl.BufferOptimalSize)
+	etl.BufferOptimalSize = 1
+
+	// the run is interrupted after loading the topics, and the files of the addresses are deleted meanwhile
+	r := newResumableETL("", &StageState{Stage: stages.LogIndex}, db, 1)
+	require.NoError(t, r.done("topics"))
+	require.NoError(t, r.save(&etl.Manifest{Name: "addresses", ExtractKey: dbutils.EncodeBlockNumber(1), Files: []string{filepath.Join(tmpdir, "deleted")}}))
+
+	state := NewState([]*Stage{{ID: stages.LogIndex}})
+	s, err := state.StageState(stages.LogIndex, db)
+	require.NoError(t, err)
+	require.NoError(t, SpawnLogIndex(s, db, tmpdir, nil))
+
+	// the addresses are extracted and loaded again, the topics are not loaded twice
+	m, err := bitmapdb.Get(db, dbutils.LogAddressIndex, addr[:], 0, 10_000_000)
+	require.NoError(t, err)
+	require.Equal(t, 1, int(m.GetCardinality()))
+	m, err = bitmapdb.Get(db, dbutils.LogTopicIndex, topic[:], 0, 10_000_000)
+	require.NoError(t, err)
+	require.Equal(t, 0, int(m.GetCardinality()))
+	progress, stageData, err := stages.GetStageProgress(db, stages.LogIndex)
+	require.NoError(t, err)
+	require.Equal(t, 1, int(progress))
+	require.Empty(t, stageData)
+	requireNoFiles(t, tmpdir)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/ledgerwatch/turbo-geth/core/rawdb"
 	"github.com/ledgerwatch/turbo-geth/core/types"
 	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
 	"github.com/ledgerwatch/turbo-geth/rlp"
 )
 
@@ -109,3 +110,37 @@ func UnwindTxLookup(u *UnwindState, s *StageState, db ethdb.Database, tmpdir str
 	}
 	return u.Done(db)
 }
+
+// PruneTxLookup removes lookup entries of transactions of all blocks except `distance` recent ones
+func PruneTxLookup(p *PruneState, db ethdb.Database, distance uint64, tmpdir string, quitCh <-chan struct{}) error {
+	from, to, ok := p.Range(distance)
+	if !ok {
+		return nil
+	}
+	logPrefix := p.LogPrefix()
+	log.Info(fmt.Sprintf("[%s] Prune", logPrefix), "from", from, "to", to)
+
+	collector := etl.NewCollector(tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize))
+	for blockNum := from; blockNum < to; blockNum++ {
+		if err := common.Stopped(quitCh); err != nil {
+			return err
+		}
+		blockHash, err := rawdb.ReadCanonicalHash(db, blockNum)
+		if err != nil {
+			return fmt.Errorf("%s: getting canonical hash for block %d: %w", logPrefix, blockNum, err)
+		}
+		body := rawdb.ReadBody(db, blockHash, blockNum)
+		if body == nil {
+			continue
+		}
+		for _, tx := range body.Transactions {
+			if err := collector.Collect(tx.Hash().Bytes(), nil); err != nil {
+				return err
+			}
+		}
+	}
+	if err := collector.Load(logPrefix, db, dbutils.TxLookupPrefix, etl.IdentityLoadFunc, etl.TransformArgs{Quit: quitCh}); err != nil {
+		return err
+	}
+	return p.Done(db, to)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/ledgerwatch/turbo-geth/ethdb"
 	"github.com/ledgerwatch/turbo-geth/log"
 	"github.com/ledgerwatch/turbo-geth/params"
+	"github.com/ledgerwatch/turbo-geth/turbo/changefeed"
 )
 
 // StageParameters contains the stage that stages receives at runtime when initializes.
@@ -26,7 +27,8 @@ type StageParameters struct {
 	// It can be used for both reading and writing.
 	TX          ethdb.Database
 	pid         string
-	batchSize   int // Batch size for the execution stage
+	batchSize   int    // Batch size for the execution stage
+	cacheDir    string // Directory for warm state caches of the execution stage, empty string means not to save them
 	storageMode ethdb.StorageMode
 	tmpdir      string
 	// QuitCh is a channel that is closed. This channel is useful to listen to when
@@ -36,9 +38,12 @@ type StageParameters struct {
 	txPool             *core.TxPool
 	poolStart          func() error
 	changeSetHook      ChangeSetHook
+	changeFeed         changefeed.Sink
 	prefetchedBlocks   *PrefetchedBlocks
 	stateReaderBuilder StateReaderBuilder
 	stateWriterBuilder StateWriterBuilder
+	executeBlocks      ExecuteBlocksFunc
+	partialState       bool
 }
 
 // StageBuilder represent an object to create a single stage for staged sync
@@ -47,6 +52,10 @@ type StageBuilder struct {
 	ID stages.SyncStage
 	// Build is a factory function that initializes the sync stage based on the `StageParameters` provided.
 	Build func(StageParameters) *Stage
+	// DependsOn (optional) lists the stages which output is read by this stage. Stages with known dependencies
+	// can run concurrently with the neighbour stages they don't depend on. nil means that dependencies are unknown
+	// and the stage runs only after all previous stages.
+	DependsOn []stages.SyncStage
 }
 
 // StageBuilders represents an ordered list of builders to build different stages. It also contains helper methods to change the list of stages.
@@ -80,6 +89,9 @@ func (bb StageBuilders) Build(world StageParameters) []*Stage {
 	stages := make([]*Stage, len(bb))
 	for i, builder := range bb {
 		stages[i] = builder.Build(world)
+		if stages[i].DependsOn == nil {
+			stages[i].DependsOn = builder.DependsOn
+		}
 	}
 	return stages
 }
@@ -166,7 +178,7 @@ func DefaultStages() StageBuilders {
 					ID:          stages.Execution,
 					Description: "Execute blocks w/o hash checks",
 					ExecFunc: func(s *StageState, u Unwinder) error {
-						return SpawnExecuteBlocksStage(s, world.TX,
+						return world.executeBlocks(s, u, world.TX,
 							world.chainConfig, world.chainContext, world.vmConfig,
 							world.QuitCh,
 							ExecuteBlockStageParams{
@@ -175,6 +187,7 @@ func DefaultStages() StageBuilders {
 								ChangeSetHook: world.changeSetHook,
 								ReaderBuilder: world.stateReaderBuilder,
 								WriterBuilder: world.stateWriterBuilder,
+								CacheDir:      world.cacheDir,
 							})
 					},
 					UnwindFunc: func(u *UnwindState, s *StageState) error {
@@ -187,8 +200,10 @@ func DefaultStages() StageBuilders {
 			ID: stages.HashState,
 			Build: func(world StageParameters) *Stage {
 				return &Stage{
-					ID:          stages.HashState,
-					Description: "Hash the key in the state",
+					ID:                  stages.HashState,
+					Description:         "Hash the key in the state",
+					Disabled:            world.partialState,
+					DisabledDescription: partialStateDescription,
 					ExecFunc: func(s *StageState, u Unwinder) error {
 						return SpawnHashStateStage(s, world.TX, world.tmpdir, world.QuitCh)
 					},
@@ -202,8 +217,10 @@ func DefaultStages() StageBuilders {
 			ID: stages.IntermediateHashes,
 			Build: func(world StageParameters) *Stage {
 				return &Stage{
-					ID:          stages.IntermediateHashes,
-					Description: "Generate intermediate hashes and computing state root",
+					ID:                  stages.IntermediateHashes,
+					Description:         "Generate intermediate hashes and computing state root",
+					Disabled:            world.partialState,
+					DisabledDescription: partialStateDescription,
 					ExecFunc: func(s *StageState, u Unwinder) error {
 						return SpawnIntermediateHashesStage(s, world.TX, world.tmpdir, world.QuitCh)
 					},
@@ -214,7 +231,8 @@ func DefaultStages() StageBuilders {
 			},
 		},
 		{
-			ID: stages.AccountHistoryIndex,
+			ID:        stages.AccountHistoryIndex,
+			DependsOn: []stages.SyncStage{stages.Execution},
 			Build: func(world StageParameters) *Stage {
 				return &Stage{
 					ID:                  stages.AccountHistoryIndex,
@@ -227,11 +245,15 @@ func DefaultStages() StageBuilders {
 					UnwindFunc: func(u *UnwindState, s *StageState) error {
 						return UnwindAccountHistoryIndex(u, s, world.TX, world.QuitCh)
 					},
+					PruneFunc: func(p *PruneState, s *StageState) error {
+						return PruneAccountHistoryIndex(p, world.TX, world.storageMode.Prune.History, world.QuitCh)
+					},
 				}
 			},
 		},
 		{
-			ID: stages.StorageHistoryIndex,
+			ID:        stages.StorageHistoryIndex,
+			DependsOn: []stages.SyncStage{stages.Execution},
 			Build: func(world StageParameters) *Stage {
 				return &Stage{
 					ID:                  stages.StorageHistoryIndex,
@@ -244,11 +266,15 @@ func DefaultStages() StageBuilders {
 					UnwindFunc: func(u *UnwindState, s *StageState) error {
 						return UnwindStorageHistoryIndex(u, s, world.TX, world.QuitCh)
 					},
+					PruneFunc: func(p *PruneState, s *StageState) error {
+						return PruneStorageHistoryIndex(p, world.TX, world.storageMode.Prune.History, world.QuitCh)
+					},
 				}
 			},
 		},
 		{
-			ID: stages.LogIndex,
+			ID:        stages.LogIndex,
+			DependsOn: []stages.SyncStage{stages.Execution},
 			Build: func(world StageParameters) *Stage {
 				return &Stage{
 					ID:                  stages.LogIndex,
@@ -261,17 +287,21 @@ func DefaultStages() StageBuilders {
 					UnwindFunc: func(u *UnwindState, s *StageState) error {
 						return UnwindLogIndex(u, s, world.TX, world.QuitCh)
 					},
+					PruneFunc: func(p *PruneState, s *StageState) error {
+						return PruneLogIndex(p, world.TX, world.storageMode.Prune.Receipts, world.QuitCh)
+					},
 				}
 			},
 		},
 		{
-			ID: stages.CallTraces,
+			ID:        stages.CallTraces,
+			DependsOn: []stages.SyncStage{stages.Execution},
 			Build: func(world StageParameters) *Stage {
 				return &Stage{
 					ID:                  stages.CallTraces,
 					Description:         "Generate call traces index",
-					Disabled:            !world.storageMode.CallTraces,
-					DisabledDescription: "Work In Progress",
+					Disabled:            !world.storageMode.CallTraces || world.partialState,
+					DisabledDescription: disabledDescription(world, "Work In Progress"),
 					ExecFunc: func(s *StageState, u Unwinder) error {
 						return SpawnCallTraces(s, world.TX, world.chainConfig, world.chainContext, world.tmpdir, world.QuitCh,
 							CallTracesStageParams{})
@@ -279,11 +309,15 @@ func DefaultStages() StageBuilders {
 					UnwindFunc: func(u *UnwindState, s *StageState) error {
 						return UnwindCallTraces(u, s, world.TX, world.chainConfig, world.chainContext, world.QuitCh)
 					},
+					PruneFunc: func(p *PruneState, s *StageState) error {
+						return PruneCallTraces(p, world.TX, world.storageMode.Prune.CallTraces, world.QuitCh)
+					},
 				}
 			},
 		},
 		{
-			ID: stages.TxLookup,
+			ID:        stages.TxLookup,
+			DependsOn: []stages.SyncStage{stages.Execution},
 			Build: func(world StageParameters) *Stage {
 				return &Stage{
 					ID:                  stages.TxLookup,
@@ -296,6 +330,9 @@ func DefaultStages() StageBuilders {
 					UnwindFunc: func(u *UnwindState, s *StageState) error {
 						return UnwindTxLookup(u, s, world.TX, world.tmpdir, world.QuitCh)
 					},
+					PruneFunc: func(p *PruneState, s *StageState) error {
+						return PruneTxLookup(p, world.TX, world.storageMode.Prune.TxIndex, world.tmpdir, world.QuitCh)
+					},
 				}
 			},
 		},
@@ -314,6 +351,63 @@ func DefaultStages() StageBuilders {
 				}
 			},
 		},
+		{
+			ID:        stages.ChangeFeed,
+			DependsOn: []stages.SyncStage{stages.AccountHistoryIndex, stages.StorageHistoryIndex},
+			Build: func(world StageParameters) *Stage {
+				return &Stage{
+					ID:                  stages.ChangeFeed,
+					Description:         "Send state diffs to the change feed",
+					Disabled:            world.changeFeed == nil || !world.storageMode.History,
+					DisabledDescription: "Enable by --changefeed.addr, requires `h` in --storage-mode",
+					ExecFunc: func(s *StageState, u Unwinder) error {
+						return SpawnChangeFeed(s, world.TX, world.changeFeed, world.QuitCh)
+					},
+					UnwindFunc: func(u *UnwindState, s *StageState) error {
+						return UnwindChangeFeed(u, s, world.TX, world.changeFeed)
+					},
+				}
+			},
+		},
+		{
+			ID:        stages.BlockWitness,
+			DependsOn: []stages.SyncStage{stages.IntermediateHashes, stages.AccountHistoryIndex, stages.StorageHistoryIndex},
+			Build: func(world StageParameters) *Stage {
+				return &Stage{
+					ID:                  stages.BlockWitness,
+					Description:         "Build block witnesses",
+					Disabled:            !world.storageMode.Witnesses || !world.storageMode.History || world.partialState,
+					DisabledDescription: disabledDescription(world, "Enable by adding `w` to --storage-mode, requires `h` in --storage-mode"),
+					ExecFunc: func(s *StageState, u Unwinder) error {
+						return SpawnBlockWitnessStage(s, world.TX, world.chainConfig, world.chainContext, world.storageMode.Prune.Witnesses, world.QuitCh)
+					},
+					UnwindFunc: func(u *UnwindState, s *StageState) error {
+						return UnwindBlockWitnessStage(u, s, world.TX)
+					},
+					PruneFunc: func(p *PruneState, s *StageState) error {
+						return PruneBlockWitnesses(p, world.TX, world.storageMode.Prune.Witnesses)
+					},
+				}
+			},
+		},
+		{
+			ID:        stages.BinaryHashes,
+			DependsOn: []stages.SyncStage{stages.HashState},
+			Build: func(world StageParameters) *Stage {
+				return &Stage{
+					ID:                  stages.BinaryHashes,
+					Description:         "Generate binary trie hashes",
+					Disabled:            !world.storageMode.BinaryTrie || world.partialState,
+					DisabledDescription: disabledDescription(world, "Enable by adding `b` to --storage-mode"),
+					ExecFunc: func(s *StageState, u Unwinder) error {
+						return SpawnBinaryHashesStage(s, world.TX, world.tmpdir, world.QuitCh)
+					},
+					UnwindFunc: func(u *UnwindState, s *StageState) error {
+						return UnwindBinaryHashesStage(u, s, world.TX, world.tmpdir, world.QuitCh)
+					},
+				}
+			},
+		},
 		{
 			ID: stages.Finish,
 			Build: func(world StageParameters) *Stage {
@@ -328,7 +422,10 @@ func DefaultStages() StageBuilders {
 						}
 						logPrefix := s.state.LogPrefix()
 						log.Info(fmt.Sprintf("[%s] Update current block for the RPC API", logPrefix), "to", executionAt)
-						return s.DoneAndUpdate(world.TX, executionAt)
+						if err = s.DoneAndUpdate(world.TX, executionAt); err != nil {
+							return err
+						}
+						return dropStaleMigrationBackups(world.TX, world.tmpdir)
 					},
 					UnwindFunc: func(u *UnwindState, s *StageState) error {
 						var executionAt uint64
@@ -336,7 +433,10 @@ func DefaultStages() StageBuilders {
 						if executionAt, err = s.ExecutionAt(world.TX); err != nil {
 							return err
 						}
-						return s.DoneAndUpdate(world.TX, executionAt)
+						if err = s.DoneAndUpdate(world.TX, executionAt); err != nil {
+							return err
+						}
+						return dropStaleMigrationBackups(world.TX, world.tmpdir)
 					},
 				}
 			},
@@ -351,6 +451,16 @@ func DefaultStages() StageBuilders {
 // is fully unwound (stages 9...3).
 type UnwindOrder []int
 
+const partialStateDescription = "The node keeps only a part of the state, see `OptionalParameters.PartialState`"
+
+// disabledDescription explains why the stage which needs the whole state is disabled
+func disabledDescription(world StageParameters, description string) string {
+	if world.partialState {
+		return partialStateDescription
+	}
+	return description
+}
+
 // DefaultUnwindOrder contains the default unwind order for `DefaultStages()`.
 // Just adding stages that don't do unwinding, don't require altering the default order.
 func DefaultUnwindOrder() UnwindOrder {
@@ -360,8 +470,11 @@ func DefaultUnwindOrder() UnwindOrder {
 		// also tx pool is before senders because senders unwind is inside cycle transaction
 		12,
 		3, 4,
+		// Unwinding of binary trie hashes needs to happen after unwinding HashState, but before unwinding execution
+		15,
 		// Unwinding of IHashes needs to happen after unwinding HashState
 		6, 5,
 		7, 8, 9, 10, 11,
+		13, 14,
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/ledgerwatch/turbo-geth/core/vm"
 	"github.com/ledgerwatch/turbo-geth/ethdb"
 	"github.com/ledgerwatch/turbo-geth/params"
+	"github.com/ledgerwatch/turbo-geth/turbo/changefeed"
 )
 
 const prof = false // whether to profile
@@ -27,6 +28,14 @@ type OptionalParameters struct {
 	// StateReaderBuilder is a function that returns state writer for the block execution stage.
 	// It can be used to update bloom or other types of filters between block execution.
 	StateWriterBuilder StateWriterBuilder
+
+	// ExecuteBlocks replaces `SpawnExecuteBlocksStage` in the block execution stage.
+	// It is used to execute the blocks as a shard of the sharded execution, see `turbo/shards`.
+	ExecuteBlocks ExecuteBlocksFunc
+
+	// PartialState means that the node keeps only a part of the state, e.g. a shard of the sharded execution.
+	// The stages which need the whole state (hashing of the state, trie, call traces, witnesses) are disabled then.
+	PartialState bool
 }
 
 func New(stages StageBuilders, unwindOrder UnwindOrder, params OptionalParameters) *StagedSync {
@@ -49,11 +58,13 @@ func (stagedSync *StagedSync) Prepare(
 	storageMode ethdb.StorageMode,
 	tmpdir string,
 	batchSize int,
+	cacheDir string,
 	quitCh <-chan struct{},
 	headersFetchers []func() error,
 	txPool *core.TxPool,
 	poolStart func() error,
 	changeSetHook ChangeSetHook,
+	changeFeed changefeed.Sink,
 ) (*State, error) {
 	var readerBuilder StateReaderBuilder
 	if stagedSync.params.StateReaderBuilder != nil {
@@ -71,6 +82,13 @@ func (stagedSync *StagedSync) Prepare(
 		}
 	}
 
+	executeBlocks := stagedSync.params.ExecuteBlocks
+	if executeBlocks == nil {
+		executeBlocks = func(s *StageState, _ Unwinder, stateDB ethdb.Database, chainConfig *params.ChainConfig, chainContext *core.TinyChainContext, vmConfig *vm.Config, quit <-chan struct{}, params ExecuteBlockStageParams) error {
+			return SpawnExecuteBlocksStage(s, stateDB, chainConfig, chainContext, vmConfig, quit, params)
+		}
+	}
+
 	stages := stagedSync.stageBuilders.Build(
 		StageParameters{
 			d:                  d,
@@ -87,10 +105,14 @@ func (stagedSync *StagedSync) Prepare(
 			txPool:             txPool,
 			poolStart:          poolStart,
 			changeSetHook:      changeSetHook,
+			changeFeed:         changeFeed,
 			batchSize:          batchSize,
+			cacheDir:           cacheDir,
 			prefetchedBlocks:   stagedSync.PrefetchedBlocks,
 			stateReaderBuilder: readerBuilder,
 			stateWriterBuilder: writerBuilder,
+			executeBlocks:      executeBlocks,
+			partialState:       stagedSync.params.PartialState,
 		},
 	)
 	state := NewState(stages)
@@ -46,9 +46,16 @@ var (
 	CallTraces          SyncStage = []byte("CallTraces")          // Generating call traces index
 	TxLookup            SyncStage = []byte("TxLookup")            // Generating transactions lookup index
 	TxPool              SyncStage = []byte("TxPool")              // Starts Backend
+	ChangeFeed          SyncStage = []byte("ChangeFeed")          // Sends state diffs of blocks to the external sink
+	BlockWitness        SyncStage = []byte("BlockWitness")        // Builds witnesses of blocks for stateless execution
+	BinaryHashes        SyncStage = []byte("BinaryHashes")        // Maintains the binary trie commitment of the state
 	Finish              SyncStage = []byte("Finish")              // Nominal stage after all other stages
 )
 
+// StateSnapshot is not a stage, its progress is the block of the state snapshot which the node started from.
+// History of the state before that block is not available, so the chain can't be unwound below it.
+var StateSnapshot SyncStage = []byte("StateSnapshot")
+
 var AllStages = []SyncStage{
 	Headers,
 	BlockHashes,
@@ -63,6 +70,9 @@ var AllStages = []SyncStage{
 	CallTraces,
 	TxLookup,
 	TxPool,
+	ChangeFeed,
+	BlockWitness,
+	BinaryHashes,
 	Finish,
 }
 
@@ -96,6 +106,21 @@ func SaveStageUnwind(db ethdb.Putter, stage SyncStage, invalidation uint64, stag
 	return db.Put(dbutils.SyncStageUnwind, []byte(stage), marshalData(invalidation, stageData))
 }
 
+// GetStagePruneProgress retrieves the block before which given sync stage removed its data
+func GetStagePruneProgress(db ethdb.Getter, stage SyncStage) (uint64, error) {
+	v, err := db.Get(dbutils.SyncStagePrune, stage)
+	if err != nil && !errors.Is(err, ethdb.ErrKeyNotFound) {
+		return 0, err
+	}
+	progress, _, err := unmarshalData(v)
+	return progress, err
+}
+
+// SaveStagePruneProgress saves the block before which given sync stage removed its data
+func SaveStagePruneProgress(db ethdb.Putter, stage SyncStage, progress uint64) error {
+	return db.Put(dbutils.SyncStagePrune, stage, marshalData(progress, nil))
+}
+
 func marshalData(blockNumber uint64, stageData []byte) []byte {
 	return append(encodeBigEndian(blockNumber), stageData...)
 }
@@ -2,8 +2,12 @@ package stagedsync
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ledgerwatch/turbo-geth/common"
@@ -12,11 +16,17 @@ import (
 	"github.com/ledgerwatch/turbo-geth/log"
 )
 
+// ErrUnwindBelowSnapshot - the state before the block of the state snapshot, which the node started from, is not available
+var ErrUnwindBelowSnapshot = errors.New("can't unwind below the block of the state snapshot")
+
 type State struct {
 	unwindStack  *PersistentUnwindStack
 	stages       []*Stage
 	unwindOrder  []*Stage
 	currentStage uint
+	// unwoundTo - the lowest unwind point of the last Run, if unwound is set
+	unwoundTo uint64
+	unwound   bool
 
 	beforeStageRun    map[string]func() error
 	onBeforeUnwind    func(stages.SyncStage) error
@@ -74,6 +84,13 @@ func (s *State) GetLocalHeight(db ethdb.Getter) (uint64, error) {
 }
 
 func (s *State) UnwindTo(blockNumber uint64, db ethdb.Database) error {
+	snapshotAt, _, err := stages.GetStageProgress(db, stages.StateSnapshot)
+	if err != nil {
+		return err
+	}
+	if blockNumber < snapshotAt {
+		return fmt.Errorf("%w: unwind to %d, state snapshot is at %d", ErrUnwindBelowSnapshot, blockNumber, snapshotAt)
+	}
 	log.Info("UnwindTo", "block", blockNumber)
 	for _, stage := range s.unwindOrder {
 		if stage.Disabled {
@@ -86,6 +103,12 @@ func (s *State) UnwindTo(blockNumber uint64, db ethdb.Database) error {
 	return nil
 }
 
+// UnwoundTo returns the lowest block the stages were unwound to by the last Run, ok is false if there was no unwind.
+// Blocks above it may have been replaced by the sync cycle.
+func (s *State) UnwoundTo() (blockNumber uint64, ok bool) {
+	return s.unwoundTo, s.unwound
+}
+
 func (s *State) IsDone() bool {
 	return s.currentStage >= uint(len(s.stages)) && s.unwindStack.Empty()
 }
@@ -101,6 +124,15 @@ func (s *State) LogPrefix() string {
 	return fmt.Sprintf("%d/%d %s", s.currentStage+1, s.Len(), s.stages[s.currentStage].ID)
 }
 
+func (s *State) stageLogPrefix(id stages.SyncStage) string {
+	for i, stage := range s.stages {
+		if bytes.Equal(stage.ID, id) {
+			return fmt.Sprintf("%d/%d %s", i+1, s.Len(), id)
+		}
+	}
+	return string(id)
+}
+
 func (s *State) SetCurrentStage(id stages.SyncStage) error {
 	for i, stage := range s.stages {
 		if bytes.Equal(stage.ID, id) {
@@ -147,11 +179,27 @@ func (s *State) StageState(stage stages.SyncStage, db ethdb.Getter) (*StageState
 	return &StageState{s, stage, blockNum, stageData}, nil
 }
 
+func (s *State) PruneState(stage stages.SyncStage, db ethdb.Getter) (*PruneState, error) {
+	blockNum, _, err := stages.GetStageProgress(db, stage)
+	if err != nil {
+		return nil, err
+	}
+	prunedTo, err := stages.GetStagePruneProgress(db, stage)
+	if err != nil {
+		return nil, err
+	}
+	return &PruneState{s, stage, prunedTo, blockNum}, nil
+}
+
 func (s *State) Run(db ethdb.GetterPutter, tx ethdb.GetterPutter) error {
 	var timings []interface{}
+	s.unwoundTo, s.unwound = 0, false
 	for !s.IsDone() {
 		if !s.unwindStack.Empty() {
 			for unwind := s.unwindStack.Pop(); unwind != nil; unwind = s.unwindStack.Pop() {
+				if !s.unwound || unwind.UnwindPoint < s.unwoundTo {
+					s.unwoundTo, s.unwound = unwind.UnwindPoint, true
+				}
 				if err := s.SetCurrentStage(unwind.Stage); err != nil {
 					return err
 				}
@@ -196,6 +244,15 @@ func (s *State) Run(db ethdb.GetterPutter, tx ethdb.GetterPutter) error {
 			continue
 		}
 
+		if group, end := s.parallelGroup(tx); len(group) > 1 {
+			groupTimings, err := s.runParallel(group, end, db)
+			if err != nil {
+				return err
+			}
+			timings = append(timings, groupTimings...)
+			continue
+		}
+
 		t := time.Now()
 		if err := s.runStage(stage, db, tx); err != nil {
 			return err
@@ -203,6 +260,17 @@ func (s *State) Run(db ethdb.GetterPutter, tx ethdb.GetterPutter) error {
 		timings = append(timings, string(stage.ID), time.Since(t))
 	}
 
+	for _, stage := range s.stages {
+		if stage.Disabled || stage.PruneFunc == nil {
+			continue
+		}
+		t := time.Now()
+		if err := s.PruneStage(stage, db, tx); err != nil {
+			return err
+		}
+		timings = append(timings, "Prune "+string(stage.ID), time.Since(t))
+	}
+
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 	log.Info("Memory", "alloc", common.StorageSize(m.Alloc), "sys", common.StorageSize(m.Sys))
@@ -232,6 +300,150 @@ func (s *State) runStage(stage *Stage, db ethdb.Getter, tx ethdb.Getter) error {
 	return nil
 }
 
+// parallelGroup returns the enabled stages, starting from the current one, which don't depend on each other
+// and can run concurrently, and the index of the first stage after the group.
+// Stages run concurrently only if they don't share the external transaction: a transaction can't be used
+// from several goroutines, and its changes are not visible to other transactions.
+func (s *State) parallelGroup(tx ethdb.Getter) ([]uint, uint) {
+	if hasTx, ok := tx.(ethdb.HasTx); ok && hasTx.Tx() != nil {
+		return nil, s.currentStage
+	}
+	var group []uint
+	end := s.currentStage
+	for ; end < uint(len(s.stages)); end++ {
+		stage := s.stages[end]
+		if stage.DependsOn == nil {
+			break
+		}
+		if _, ok := s.beforeStageRun[string(stage.ID)]; ok && end > s.currentStage {
+			break
+		}
+		if s.dependsOnAny(stage, group) {
+			break
+		}
+		if !stage.Disabled {
+			group = append(group, end)
+		}
+	}
+	return group, end
+}
+
+func (s *State) dependsOnAny(stage *Stage, group []uint) bool {
+	for _, i := range group {
+		for _, dep := range stage.DependsOn {
+			if bytes.Equal(dep, s.stages[i].ID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runParallel runs the group of stages concurrently, each stage in its own transactions. The stages must not
+// hold the write transaction while extracting their data (see `beginLoad`), otherwise they just wait for each other.
+// Stages which didn't call `Done()` run again, and unwinds requested by the stages are
+// picked up from the database after all of them finish.
+func (s *State) runParallel(group []uint, end uint, db ethdb.Getter) ([]interface{}, error) {
+	views := make([]*State, len(group))
+	durations := make([]time.Duration, len(group))
+	errs := make([]error, len(group))
+	ids := make([]string, len(group))
+	for k, i := range group {
+		ids[k] = string(s.stages[i].ID)
+	}
+	for i := s.currentStage; i < end; i++ {
+		if stage := s.stages[i]; stage.Disabled {
+			log.Info(fmt.Sprintf("[%s] disabled. %s", s.stageLogPrefix(stage.ID), stage.DisabledDescription))
+		}
+	}
+	log.Info("Running stages concurrently", "stages", strings.Join(ids, ", "))
+
+	var wg sync.WaitGroup
+	for k, i := range group {
+		views[k] = s.stageView(i)
+		wg.Add(1)
+		go func(k int, i uint) {
+			defer wg.Done()
+			t := time.Now()
+			errs[k] = views[k].runStage(s.stages[i], db, nil)
+			durations[k] = time.Since(t)
+		}(k, i)
+	}
+	wg.Wait()
+
+	var timings []interface{}
+	next := end
+	unwind := false
+	for k, i := range group {
+		if errs[k] != nil {
+			return nil, errs[k]
+		}
+		timings = append(timings, ids[k], durations[k])
+		if views[k].currentStage == i && i < next {
+			next = i
+		}
+		if !views[k].unwindStack.Empty() {
+			unwind = true
+		}
+	}
+	s.currentStage = next
+	if unwind {
+		if err := s.LoadUnwindInfo(db); err != nil {
+			return nil, err
+		}
+	}
+	return timings, nil
+}
+
+// stageView returns a copy of the state positioned at the stage `i`, to run that stage concurrently with others.
+// Unwinds requested by the stage are saved to the database and to the own unwind stack of the copy.
+func (s *State) stageView(i uint) *State {
+	return &State{
+		stages:            s.stages,
+		unwindOrder:       s.unwindOrder,
+		currentStage:      i,
+		unwindStack:       NewPersistentUnwindStack(),
+		beforeStageRun:    s.beforeStageRun,
+		onBeforeUnwind:    s.onBeforeUnwind,
+		beforeStageUnwind: s.beforeStageUnwind,
+	}
+}
+
+// beginLoad rolls back the read transaction, in which the stage extracts its data, and begins the write transaction
+// to load it. Only the loading of the stages running concurrently is serialized then, by the single writer of the database.
+func beginLoad(db ethdb.Database, readTx ethdb.DbWithPendingMutations) (ethdb.DbWithPendingMutations, error) {
+	readTx.Rollback()
+	return db.Begin(context.Background(), ethdb.RW)
+}
+
+// PruneStage removes old data of the stage, it runs in the same transaction as the forward progress of the stages
+func (s *State) PruneStage(stage *Stage, db ethdb.Getter, tx ethdb.Getter) error {
+	if hasTx, ok := tx.(ethdb.HasTx); ok && hasTx.Tx() != nil {
+		db = tx
+	}
+	if stage.PruneFunc == nil {
+		return nil
+	}
+	pruneState, err := s.PruneState(stage.ID, db)
+	if err != nil {
+		return err
+	}
+	stageState, err := s.StageState(stage.ID, db)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if err = stage.PruneFunc(pruneState, stageState); err != nil {
+		return err
+	}
+
+	if time.Since(start) > 30*time.Second {
+		log.Info(fmt.Sprintf("[%s] Prune DONE", pruneState.LogPrefix()), "in", time.Since(start))
+	}
+	return nil
+}
+
 func (s *State) UnwindStage(unwind *UnwindState, db ethdb.GetterPutter, tx ethdb.GetterPutter) error {
 	if hasTx, ok := tx.(ethdb.HasTx); ok && hasTx.Tx() != nil {
 		db = tx
@@ -251,6 +463,13 @@ func (s *State) UnwindStage(unwind *UnwindState, db ethdb.GetterPutter, tx ethdb
 		return err
 	}
 
+	if len(stageState.StageData) > 0 {
+		// the files extracted by the interrupted run of the stage are not valid after unwinding
+		if err = discardInterruptedETL(s.LogPrefix(), stageState, db); err != nil {
+			return err
+		}
+	}
+
 	if stageState.BlockNumber <= unwind.UnwindPoint {
 		if err = unwind.Skip(db); err != nil {
 			return err
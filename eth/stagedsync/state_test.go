@@ -2,7 +2,9 @@ package stagedsync
 
 import (
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
 	"github.com/ledgerwatch/turbo-geth/ethdb"
@@ -392,6 +394,31 @@ func TestStateUnwind(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 500, int(stageState.BlockNumber))
 
+	unwoundTo, ok := state.UnwoundTo()
+	assert.True(t, ok)
+	assert.Equal(t, 500, int(unwoundTo))
+
+	// the next run without unwinds resets it
+	err = state.Run(db, db)
+	assert.NoError(t, err)
+	_, ok = state.UnwoundTo()
+	assert.False(t, ok)
+}
+
+func TestStateUnwindBelowSnapshot(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	s := []*Stage{{ID: stages.Headers}, {ID: stages.Execution}}
+	state := NewState(s)
+	state.unwindOrder = []*Stage{s[1], s[0]}
+	assert.NoError(t, stages.SaveStageProgress(db, stages.StateSnapshot, 1000, nil))
+
+	err := state.UnwindTo(999, db)
+	assert.True(t, errors.Is(err, ErrUnwindBelowSnapshot), err)
+	assert.True(t, state.unwindStack.Empty())
+
+	assert.NoError(t, state.UnwindTo(1000, db))
+	assert.False(t, state.unwindStack.Empty())
 }
 
 func TestStateUnwindEmptyUnwinder(t *testing.T) {
@@ -535,6 +562,238 @@ func TestStateSyncDoTwice(t *testing.T) {
 	assert.Equal(t, 600, int(stageState.BlockNumber))
 }
 
+func TestStatePrune(t *testing.T) {
+	flow := make([]string, 0)
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+
+	prune := func(p *PruneState, s *StageState) error {
+		flow = append(flow, "prune "+string(p.Stage))
+		from, to, ok := p.Range(100)
+		if !ok {
+			return nil
+		}
+		assert.Equal(t, s.BlockNumber, p.ForwardProgress)
+		assert.Equal(t, p.PrunedTo, from)
+		return p.Done(db, to)
+	}
+	s := []*Stage{
+		{
+			ID:          stages.Headers,
+			Description: "Downloading headers",
+			ExecFunc: func(s *StageState, u Unwinder) error {
+				flow = append(flow, string(stages.Headers))
+				return s.DoneAndUpdate(db, s.BlockNumber+150)
+			},
+		},
+		{
+			ID:          stages.Bodies,
+			Description: "Downloading block bodiess",
+			ExecFunc: func(s *StageState, u Unwinder) error {
+				flow = append(flow, string(stages.Bodies))
+				return s.DoneAndUpdate(db, s.BlockNumber+150)
+			},
+			PruneFunc: prune,
+			Disabled:  true,
+		},
+		{
+			ID:          stages.Senders,
+			Description: "Recovering senders from tx signatures",
+			ExecFunc: func(s *StageState, u Unwinder) error {
+				flow = append(flow, string(stages.Senders))
+				return s.DoneAndUpdate(db, s.BlockNumber+150)
+			},
+			PruneFunc: prune,
+		},
+	}
+
+	state := NewState(s)
+	err := state.Run(db, db)
+	assert.NoError(t, err)
+
+	state = NewState(s)
+	err = state.Run(db, db)
+	assert.NoError(t, err)
+
+	expectedFlow := []string{
+		string(stages.Headers), string(stages.Senders), "prune " + string(stages.Senders),
+		string(stages.Headers), string(stages.Senders), "prune " + string(stages.Senders),
+	}
+	assert.Equal(t, expectedFlow, flow)
+
+	pruneState, err := state.PruneState(stages.Senders, db)
+	assert.NoError(t, err)
+	assert.Equal(t, 300, int(pruneState.ForwardProgress))
+	assert.Equal(t, 200, int(pruneState.PrunedTo))
+
+	pruneState, err = state.PruneState(stages.Bodies, db)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, int(pruneState.PrunedTo))
+}
+
+func TestStateParallelStages(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	var mu sync.Mutex
+	flow := make([]stages.SyncStage, 0)
+	record := func(id stages.SyncStage) {
+		mu.Lock()
+		defer mu.Unlock()
+		flow = append(flow, id)
+	}
+
+	// Bodies and Senders both wait for each other, it succeeds only if they run concurrently
+	bodiesStarted, sendersStarted := make(chan struct{}), make(chan struct{})
+	rendezvous := func(started chan struct{}, other chan struct{}) error {
+		close(started)
+		select {
+		case <-other:
+			return nil
+		case <-time.After(10 * time.Second):
+			return errors.New("stages are not run concurrently")
+		}
+	}
+	sendersRuns := 0
+	s := []*Stage{
+		{
+			ID: stages.Headers,
+			ExecFunc: func(s *StageState, u Unwinder) error {
+				record(stages.Headers)
+				s.Done()
+				return nil
+			},
+		},
+		{
+			ID:        stages.Bodies,
+			DependsOn: []stages.SyncStage{stages.Headers},
+			ExecFunc: func(s *StageState, u Unwinder) error {
+				if err := rendezvous(bodiesStarted, sendersStarted); err != nil {
+					return err
+				}
+				record(stages.Bodies)
+				return s.DoneAndUpdate(db, 100)
+			},
+		},
+		{
+			ID:        stages.BlockHashes,
+			DependsOn: []stages.SyncStage{stages.Headers},
+			Disabled:  true,
+		},
+		{
+			ID:        stages.Senders,
+			DependsOn: []stages.SyncStage{stages.Headers},
+			ExecFunc: func(s *StageState, u Unwinder) error {
+				sendersRuns++
+				if sendersRuns == 1 {
+					if err := rendezvous(sendersStarted, bodiesStarted); err != nil {
+						return err
+					}
+					// not done, the stage must be called again
+					return s.Update(db, 50)
+				}
+				assert.Equal(t, 50, int(s.BlockNumber))
+				assert.Equal(t, "4/5 Senders", s.state.LogPrefix())
+				record(stages.Senders)
+				return s.DoneAndUpdate(db, 100)
+			},
+		},
+		{
+			ID:        stages.Execution,
+			DependsOn: []stages.SyncStage{stages.Senders},
+			ExecFunc: func(s *StageState, u Unwinder) error {
+				record(stages.Execution)
+				s.Done()
+				return nil
+			},
+		},
+	}
+	state := NewState(s)
+	err := state.Run(db, db)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sendersRuns)
+
+	expectedFlow := []stages.SyncStage{
+		stages.Headers, stages.Bodies, stages.Senders, stages.Execution,
+	}
+	assert.Equal(t, expectedFlow, flow)
+}
+
+func TestStateParallelStagesUnwind(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	var mu sync.Mutex
+	flow := make([]stages.SyncStage, 0)
+	record := func(id stages.SyncStage) {
+		mu.Lock()
+		defer mu.Unlock()
+		flow = append(flow, id)
+	}
+	unwound := false
+	s := []*Stage{
+		{
+			ID: stages.Headers,
+			ExecFunc: func(s *StageState, u Unwinder) error {
+				record(stages.Headers)
+				return s.DoneAndUpdate(db, 2000)
+			},
+			UnwindFunc: func(u *UnwindState, s *StageState) error {
+				record(unwindOf(stages.Headers))
+				return u.Done(db)
+			},
+		},
+		{
+			ID:        stages.Bodies,
+			DependsOn: []stages.SyncStage{stages.Headers},
+			ExecFunc: func(s *StageState, u Unwinder) error {
+				record(stages.Bodies)
+				return s.DoneAndUpdate(db, 2000)
+			},
+			UnwindFunc: func(u *UnwindState, s *StageState) error {
+				record(unwindOf(stages.Bodies))
+				return u.Done(db)
+			},
+		},
+		{
+			ID:        stages.Senders,
+			DependsOn: []stages.SyncStage{stages.Headers},
+			ExecFunc: func(s *StageState, u Unwinder) error {
+				if !unwound {
+					unwound = true
+					if err := s.Update(db, 1700); err != nil {
+						return err
+					}
+					return u.UnwindTo(1500, db)
+				}
+				record(stages.Senders)
+				return s.DoneAndUpdate(db, 2000)
+			},
+			UnwindFunc: func(u *UnwindState, s *StageState) error {
+				record(unwindOf(stages.Senders))
+				return u.Done(db)
+			},
+		},
+	}
+	state := NewState(s)
+	state.unwindOrder = []*Stage{s[0], s[1], s[2]}
+	err := state.Run(db, db)
+	assert.NoError(t, err)
+
+	// Bodies and Senders run concurrently, so Bodies is recorded before the unwind
+	expectedFlow := []stages.SyncStage{
+		stages.Headers, stages.Bodies,
+		unwindOf(stages.Senders), unwindOf(stages.Bodies), unwindOf(stages.Headers),
+		stages.Headers,
+	}
+	assert.Equal(t, expectedFlow, flow[:len(expectedFlow)])
+	assert.ElementsMatch(t, []stages.SyncStage{stages.Bodies, stages.Senders}, flow[len(expectedFlow):])
+
+	for _, id := range []stages.SyncStage{stages.Headers, stages.Bodies, stages.Senders} {
+		stageState, err := state.StageState(id, db)
+		assert.NoError(t, err)
+		assert.Equal(t, 2000, int(stageState.BlockNumber), string(id))
+	}
+}
+
 func TestStateSyncInterruptRestart(t *testing.T) {
 	flow := make([]stages.SyncStage, 0)
 	expectedErr := errors.New("interrupt")
@@ -0,0 +1,395 @@
+// Package backup makes a consistent copy of the database while it is being written by the running node, and restores it.
+//
+// All buckets are copied in one read transaction of the source, so the copy is the snapshot of the moment the
+// backup started. Reading can be throttled, to leave IO for the node - but keep in mind that the long read
+// transaction prevents the source from reusing the freed pages, so it grows until the backup is done.
+// The backup and the restored database can use another backend (LMDB or MDBX) than the source.
+//
+// Every bucket which exists in the source is copied, also the ones this binary doesn't know (of plugins and
+// remote stages, they are copied as plain buckets) and the deprecated ones.
+// The backup is complete when its manifest is written: number of entries and checksum of every bucket.
+// Restore verifies the checksums while copying, and fails if any of them doesn't match.
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+)
+
+const (
+	ManifestFile    = "backup.json"
+	ManifestVersion = 1
+
+	LMDB = "lmdb"
+	MDBX = "mdbx"
+
+	DefaultCommitEvery = 256 * datasize.MB
+)
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrNotEmpty         = errors.New("destination database is not empty")
+)
+
+// Manifest describes the complete backup, it is written to ManifestFile in the directory of the backup
+type Manifest struct {
+	Version int              `json:"version"`
+	Created time.Time        `json:"created"`
+	Source  string           `json:"source"`  // path of the copied database
+	Backend string           `json:"backend"` // of the backup
+	Buckets []BucketManifest `json:"buckets"`
+}
+
+type BucketManifest struct {
+	Name     string `json:"name"`
+	Count    uint64 `json:"count"`
+	Size     uint64 `json:"size"`     // of all keys and values
+	Checksum string `json:"checksum"` // sha256 of the keys and values with their lengths, in the order of the bucket
+}
+
+type Options struct {
+	Rate        datasize.ByteSize // of reading, per second, 0 - no limit
+	CommitEvery datasize.ByteSize // of writing, DefaultCommitEvery if 0
+}
+
+// Open opens the database with the backend, the source of the backup is opened read-only, so it can be done
+// while the node is running. The buckets are configured in addition to the known ones, see WithBuckets.
+func Open(path string, backend string, readOnly bool, buckets []string) (ethdb.KV, error) {
+	switch backend {
+	case LMDB, "":
+		opts := ethdb.NewLMDB().Path(path).WithBucketsConfig(WithBuckets(buckets))
+		if readOnly {
+			opts = opts.ReadOnly()
+		}
+		return opts.Open()
+	case MDBX:
+		opts := ethdb.NewMDBX().Path(path).WithBucketsConfig(WithBuckets(buckets))
+		if readOnly {
+			opts = opts.ReadOnly()
+		}
+		return opts.Open()
+	default:
+		return nil, fmt.Errorf("unknown database backend: %s, supported: %s, %s", backend, LMDB, MDBX)
+	}
+}
+
+// OpenExisting opens the existing database read-only, with all buckets it has - including the buckets of plugins
+// and remote stages, which are not known to this binary, and the deprecated buckets which still hold data
+func OpenExisting(path string, backend string) (ethdb.KV, []string, error) {
+	kv, err := Open(path, backend, true, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var buckets []string
+	err = kv.View(context.Background(), func(tx ethdb.Tx) error {
+		buckets, err = existingBuckets(tx)
+		return err
+	})
+	kv.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	if kv, err = Open(path, backend, true, buckets); err != nil {
+		return nil, nil, err
+	}
+	return kv, buckets, nil
+}
+
+// WithBuckets configures the buckets which are not known as plain (not DupSort) buckets, and opens
+// the deprecated ones among them as regular buckets
+func WithBuckets(buckets []string) ethdb.BucketConfigsFunc {
+	return func(defaultBuckets dbutils.BucketsCfg) dbutils.BucketsCfg {
+		cfg := make(dbutils.BucketsCfg, len(defaultBuckets)+len(buckets))
+		for name, item := range defaultBuckets {
+			cfg[name] = item
+		}
+		for _, name := range buckets {
+			item := cfg[name]
+			item.IsDeprecated = false
+			cfg[name] = item
+		}
+		return cfg
+	}
+}
+
+// Backup copies all buckets which exist in src into empty dst in one read transaction, it fails with ErrNotEmpty
+// if dst has any entries. Both databases must be configured with all buckets of src, see OpenExisting.
+func Backup(ctx context.Context, src, dst ethdb.KV, opts Options) (*Manifest, error) {
+	if err := checkEmpty(ctx, dst); err != nil {
+		return nil, err
+	}
+	m := &Manifest{Version: ManifestVersion, Created: time.Now().UTC()}
+	throttle := newThrottle(opts.Rate)
+	if err := src.View(ctx, func(tx ethdb.Tx) error {
+		buckets, err := existingBuckets(tx)
+		if err != nil {
+			return err
+		}
+		if err = checkConfigured(src, buckets); err != nil {
+			return fmt.Errorf("source: %w", err)
+		}
+		if err = checkConfigured(dst, buckets); err != nil {
+			return fmt.Errorf("destination: %w", err)
+		}
+		for _, name := range buckets {
+			bm, err := copyBucket(ctx, tx, dst, name, opts, throttle)
+			if err != nil {
+				return err
+			}
+			m.Buckets = append(m.Buckets, bm)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Restore copies the buckets of the backup into empty dst, and verifies them against the manifest,
+// it fails with ErrNotEmpty if dst has any entries. Both databases must be configured with
+// the buckets of the manifest, see Manifest.BucketNames.
+func Restore(ctx context.Context, backup, dst ethdb.KV, m *Manifest, opts Options) error {
+	if m.Version != ManifestVersion {
+		return fmt.Errorf("unsupported version of backup manifest: %d", m.Version)
+	}
+	if err := checkConfigured(backup, m.BucketNames()); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	if err := checkConfigured(dst, m.BucketNames()); err != nil {
+		return fmt.Errorf("destination: %w", err)
+	}
+	if err := checkEmpty(ctx, dst); err != nil {
+		return err
+	}
+	throttle := newThrottle(opts.Rate)
+	return backup.View(ctx, func(tx ethdb.Tx) error {
+		for _, expected := range m.Buckets {
+			bm, err := copyBucket(ctx, tx, dst, expected.Name, opts, throttle)
+			if err != nil {
+				return err
+			}
+			if bm != expected {
+				return fmt.Errorf("%w: bucket %s has %d entries, checksum %s, manifest: %d entries, checksum %s",
+					ErrChecksumMismatch, bm.Name, bm.Count, bm.Checksum, expected.Count, expected.Checksum)
+			}
+		}
+		return nil
+	})
+}
+
+// checkEmpty returns ErrNotEmpty if any bucket of the database has entries - appending to them would either fail
+// in the middle of the copy or mix the copied entries with the existing ones
+func checkEmpty(ctx context.Context, kv ethdb.KV) error {
+	return kv.View(ctx, func(tx ethdb.Tx) error {
+		buckets, err := existingBuckets(tx)
+		if err != nil {
+			return err
+		}
+		configured := kv.AllBuckets()
+		for _, name := range buckets {
+			if cfg, ok := configured[name]; !ok || cfg.IsDeprecated {
+				return fmt.Errorf("%w: has bucket %s", ErrNotEmpty, name)
+			}
+			k, _, err := tx.Cursor(name).First()
+			if err != nil {
+				return err
+			}
+			if k != nil {
+				return fmt.Errorf("%w: bucket %s has entries", ErrNotEmpty, name)
+			}
+		}
+		return nil
+	})
+}
+
+// existingBuckets returns the buckets which exist in the database, sorted by name
+func existingBuckets(tx ethdb.Tx) ([]string, error) {
+	migrator, ok := tx.(ethdb.BucketMigrator)
+	if !ok {
+		return nil, fmt.Errorf("%T can't list the buckets", tx)
+	}
+	names, err := migrator.ExistingBuckets()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// checkConfigured returns an error if any of the buckets is not opened by the database - its cursors
+// would read and write another bucket
+func checkConfigured(kv ethdb.KV, buckets []string) error {
+	configured := kv.AllBuckets()
+	for _, name := range buckets {
+		if cfg, ok := configured[name]; !ok || cfg.IsDeprecated {
+			return fmt.Errorf("bucket %s is not configured", name)
+		}
+	}
+	return nil
+}
+
+// BucketNames returns the names of the backed up buckets
+func (m *Manifest) BucketNames() []string {
+	names := make([]string, len(m.Buckets))
+	for i, b := range m.Buckets {
+		names[i] = b.Name
+	}
+	return names
+}
+
+// copyBucket appends all entries of the bucket into dst, which commits every opts.CommitEvery bytes
+func copyBucket(ctx context.Context, tx ethdb.Tx, dst ethdb.KV, name string, opts Options, throttle *throttle) (BucketManifest, error) {
+	commitEvery := uint64(opts.CommitEvery)
+	if commitEvery == 0 {
+		commitEvery = uint64(DefaultCommitEvery)
+	}
+	logEvery := time.NewTicker(30 * time.Second)
+	defer logEvery.Stop()
+
+	dstTx, err := dst.Begin(ctx, nil, ethdb.RW)
+	if err != nil {
+		return BucketManifest{}, err
+	}
+	defer func() {
+		dstTx.Rollback()
+	}()
+	c := dstTx.Cursor(name)
+	sum := newChecksum()
+	var count, size, uncommitted uint64
+	srcC := tx.Cursor(name)
+	for k, v, err := srcC.First(); k != nil; k, v, err = srcC.Next() {
+		if err != nil {
+			return BucketManifest{}, err
+		}
+		sum.add(k, v)
+		if err = c.Append(k, v); err != nil {
+			return BucketManifest{}, fmt.Errorf("bucket %s: %w", name, err)
+		}
+		count++
+		size += uint64(len(k) + len(v))
+		uncommitted += uint64(len(k) + len(v))
+		if err = throttle.wait(ctx, len(k)+len(v)); err != nil {
+			return BucketManifest{}, err
+		}
+
+		select {
+		default:
+		case <-ctx.Done():
+			return BucketManifest{}, ctx.Err()
+		case <-logEvery.C:
+			log.Info("Progress", "bucket", name, "entries", count, "size", datasize.ByteSize(size).HR())
+		}
+		if uncommitted >= commitEvery {
+			if err = dstTx.Commit(ctx); err != nil {
+				return BucketManifest{}, err
+			}
+			if dstTx, err = dst.Begin(ctx, nil, ethdb.RW); err != nil {
+				return BucketManifest{}, err
+			}
+			c = dstTx.Cursor(name)
+			uncommitted = 0
+		}
+	}
+	if err = dstTx.Commit(ctx); err != nil {
+		return BucketManifest{}, err
+	}
+	log.Info("Copied bucket", "bucket", name, "entries", count, "size", datasize.ByteSize(size).HR())
+	return BucketManifest{Name: name, Count: count, Size: size, Checksum: sum.hex()}, nil
+}
+
+type checksum struct {
+	h   hash.Hash
+	buf [binary.MaxVarintLen64]byte
+}
+
+func newChecksum() *checksum {
+	return &checksum{h: sha256.New()}
+}
+
+func (s *checksum) add(k, v []byte) {
+	s.h.Write(s.buf[:binary.PutUvarint(s.buf[:], uint64(len(k)))]) //nolint:errcheck
+	s.h.Write(k)                                                   //nolint:errcheck
+	s.h.Write(s.buf[:binary.PutUvarint(s.buf[:], uint64(len(v)))]) //nolint:errcheck
+	s.h.Write(v)                                                   //nolint:errcheck
+}
+
+func (s *checksum) hex() string {
+	return hex.EncodeToString(s.h.Sum(nil))
+}
+
+// throttle limits the average rate of reading, nil means no limit
+type throttle struct {
+	rate  float64 // bytes per second
+	start time.Time
+	bytes uint64
+}
+
+func newThrottle(rate datasize.ByteSize) *throttle {
+	if rate == 0 {
+		return nil
+	}
+	return &throttle{rate: float64(rate), start: time.Now()}
+}
+
+// wait sleeps while more than n bytes are read than the rate allows
+func (t *throttle) wait(ctx context.Context, n int) error {
+	if t == nil {
+		return nil
+	}
+	t.bytes += uint64(n)
+	ahead := time.Duration(float64(t.bytes)/t.rate*float64(time.Second)) - time.Since(t.start)
+	if ahead < 10*time.Millisecond {
+		return nil
+	}
+	timer := time.NewTimer(ahead)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// WriteManifest writes the manifest to the directory of the backup, the backup isn't complete without it
+func WriteManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, ManifestFile+".tmp")
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, ManifestFile))
+}
+
+// ReadManifest reads the manifest from the directory of the backup, the error wraps os.ErrNotExist if the backup
+// is incomplete
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("reading backup manifest: %w", err)
+	}
+	m := &Manifest{}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("decoding backup manifest: %w", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,29 @@
+//+build mdbx
+
+package backup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupToMdbx(t *testing.T) {
+	ctx := context.Background()
+	src := ethdb.NewLMDB().InMem().MustOpen()
+	defer src.Close()
+	fill(t, src)
+
+	dst := ethdb.NewMDBX().InMem().MustOpen()
+	defer dst.Close()
+	m, err := Backup(ctx, src, dst, Options{CommitEvery: 1000})
+	require.NoError(t, err)
+	requireEqual(t, src, dst)
+
+	restored := ethdb.NewLMDB().InMem().MustOpen()
+	defer restored.Close()
+	require.NoError(t, Restore(ctx, dst, restored, m, Options{}))
+	requireEqual(t, src, restored)
+}
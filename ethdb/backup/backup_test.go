@@ -0,0 +1,194 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/stretchr/testify/require"
+)
+
+// fill puts into the plain bucket, into DupSort bucket with auto conversion of the keys, and into DupSort bucket
+func fill(t *testing.T, kv ethdb.KV) {
+	require.NoError(t, kv.Update(context.Background(), func(tx ethdb.Tx) error {
+		for i := 0; i < 100; i++ {
+			addr := common.BytesToAddress([]byte{byte(i)}).Bytes()
+			if err := tx.Cursor(dbutils.PlainStateBucket).Put(addr, []byte{byte(i), 1}); err != nil {
+				return err
+			}
+			for j := 0; j < 3; j++ {
+				k := dbutils.PlainGenerateCompositeStorageKey(common.BytesToAddress(addr), 1, common.BytesToHash([]byte{byte(j)}))
+				if err := tx.Cursor(dbutils.PlainStateBucket).Put(k, []byte{byte(j + 1)}); err != nil {
+					return err
+				}
+				if err := tx.Cursor(dbutils.IntermediateTrieHashBucket).Put(addr, append([]byte{byte(j)}, common.BytesToHash([]byte{byte(i)}).Bytes()...)); err != nil {
+					return err
+				}
+			}
+			if err := tx.Cursor(dbutils.HeaderPrefix).Put(dbutils.EncodeBlockNumber(uint64(i)), addr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+func requireEqual(t *testing.T, expected, actual ethdb.KV) {
+	type entry struct{ k, v []byte }
+	read := func(kv ethdb.KV, bucket string) (entries []entry) {
+		require.NoError(t, kv.View(context.Background(), func(tx ethdb.Tx) error {
+			c := tx.Cursor(bucket)
+			for k, v, err := c.First(); k != nil; k, v, err = c.Next() {
+				if err != nil {
+					return err
+				}
+				entries = append(entries, entry{common.CopyBytes(k), common.CopyBytes(v)})
+			}
+			return nil
+		}))
+		return entries
+	}
+	for _, bucket := range []string{dbutils.PlainStateBucket, dbutils.IntermediateTrieHashBucket, dbutils.HeaderPrefix} {
+		entries := read(expected, bucket)
+		require.NotEmpty(t, entries, bucket)
+		require.Equal(t, entries, read(actual, bucket), bucket)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	src := ethdb.NewLMDB().InMem().MustOpen()
+	defer src.Close()
+	fill(t, src)
+
+	dst := ethdb.NewLMDB().InMem().MustOpen()
+	defer dst.Close()
+	opts := Options{CommitEvery: 1000}
+	m, err := Backup(ctx, src, dst, opts)
+	require.NoError(t, err)
+	requireEqual(t, src, dst)
+
+	counts := map[string]uint64{}
+	for _, bm := range m.Buckets {
+		counts[bm.Name] = bm.Count
+	}
+	require.Equal(t, uint64(400), counts[dbutils.PlainStateBucket])
+	require.Equal(t, uint64(300), counts[dbutils.IntermediateTrieHashBucket])
+	require.Equal(t, uint64(100), counts[dbutils.HeaderPrefix])
+	require.Equal(t, uint64(0), counts[dbutils.BlockBodyPrefix])
+
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	_, err = ReadManifest(dir)
+	require.True(t, errors.Is(err, os.ErrNotExist))
+	require.NoError(t, WriteManifest(dir, m))
+	read, err := ReadManifest(dir)
+	require.NoError(t, err)
+	require.Equal(t, m.Buckets, read.Buckets)
+
+	restored := ethdb.NewLMDB().InMem().MustOpen()
+	defer restored.Close()
+	require.NoError(t, Restore(ctx, dst, restored, read, opts))
+	requireEqual(t, src, restored)
+
+	// the destination is checked before anything is copied
+	_, err = Backup(ctx, src, restored, opts)
+	require.True(t, errors.Is(err, ErrNotEmpty), err)
+	err = Restore(ctx, dst, restored, read, opts)
+	require.True(t, errors.Is(err, ErrNotEmpty), err)
+	requireEqual(t, src, restored)
+
+	// the backup is modified after the manifest is written
+	require.NoError(t, dst.Update(ctx, func(tx ethdb.Tx) error {
+		return tx.Cursor(dbutils.HeaderPrefix).Put(dbutils.EncodeBlockNumber(5), []byte{1})
+	}))
+	corrupted := ethdb.NewLMDB().InMem().MustOpen()
+	defer corrupted.Close()
+	err = Restore(ctx, dst, corrupted, read, opts)
+	require.True(t, errors.Is(err, ErrChecksumMismatch), err)
+	require.Contains(t, err.Error(), dbutils.HeaderPrefix)
+}
+
+func TestThrottle(t *testing.T) {
+	require.NoError(t, newThrottle(0).wait(context.Background(), 1<<30))
+
+	th := newThrottle(1 << 20) // 1MB per second
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, th.wait(context.Background(), 10<<10))
+	}
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(90*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.True(t, errors.Is(th.wait(ctx, 1<<20), context.Canceled))
+}
+
+func TestBackupAllBuckets(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the bucket of a plugin, which this binary doesn't know, and the deprecated bucket which still has data
+	const pluginBucket = "plugin_bucket"
+	kv, err := Open(filepath.Join(dir, "src"), LMDB, false, []string{pluginBucket, dbutils.SyncStageProgressOld1})
+	require.NoError(t, err)
+	fill(t, kv)
+	require.NoError(t, kv.Update(ctx, func(tx ethdb.Tx) error {
+		if err := tx.Cursor(pluginBucket).Put([]byte{1}, []byte{2}); err != nil {
+			return err
+		}
+		return tx.Cursor(dbutils.SyncStageProgressOld1).Put([]byte{3}, []byte{4})
+	}))
+	kv.Close()
+
+	src, buckets, err := OpenExisting(filepath.Join(dir, "src"), LMDB)
+	require.NoError(t, err)
+	defer src.Close()
+	require.Contains(t, buckets, pluginBucket)
+	require.Contains(t, buckets, dbutils.SyncStageProgressOld1)
+
+	// the destination must be able to open all buckets
+	notConfigured := ethdb.NewLMDB().InMem().MustOpen()
+	defer notConfigured.Close()
+	_, err = Backup(ctx, src, notConfigured, Options{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not configured")
+
+	dst, err := Open(filepath.Join(dir, "dst"), LMDB, false, buckets)
+	require.NoError(t, err)
+	defer dst.Close()
+	m, err := Backup(ctx, src, dst, Options{})
+	require.NoError(t, err)
+	require.Equal(t, buckets, m.BucketNames())
+	counts := map[string]uint64{}
+	for _, bm := range m.Buckets {
+		counts[bm.Name] = bm.Count
+	}
+	require.Equal(t, uint64(1), counts[pluginBucket])
+	require.Equal(t, uint64(1), counts[dbutils.SyncStageProgressOld1])
+
+	restored, err := Open(filepath.Join(dir, "restored"), LMDB, false, m.BucketNames())
+	require.NoError(t, err)
+	defer restored.Close()
+	require.NoError(t, Restore(ctx, dst, restored, m, Options{}))
+	requireEqual(t, src, restored)
+	require.NoError(t, restored.View(ctx, func(tx ethdb.Tx) error {
+		v, err := tx.GetOne(pluginBucket, []byte{1})
+		require.NoError(t, err)
+		require.Equal(t, []byte{2}, v)
+		v, err = tx.GetOne(dbutils.SyncStageProgressOld1, []byte{3})
+		require.NoError(t, err)
+		require.Equal(t, []byte{4}, v)
+		return nil
+	}))
+}
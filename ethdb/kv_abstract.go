@@ -6,6 +6,7 @@ import (
 
 	"github.com/ledgerwatch/turbo-geth/common"
 	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/ethdb/remote"
 )
 
 var (
@@ -148,6 +149,14 @@ type Cursor interface {
 	Close()
 }
 
+// RangeCursor - RemoteKV cursors also can bound and filter the keys returned by First/Seek/Next,
+// during streaming the server skips filtered out keys without sending them to the client
+type RangeCursor interface {
+	Cursor
+	EndKey(k []byte) Cursor                   // EndKey - returns only keys less than k
+	Filter(prefix []byte, offset uint) Cursor // Filter - returns only keys which have prefix at offset
+}
+
 type CursorDupSort interface {
 	Cursor
 
@@ -192,6 +201,7 @@ type Backend interface {
 	AddLocal([]byte) ([]byte, error)
 	Etherbase() (common.Address, error)
 	NetVersion() (uint64, error)
+	Subscribe(ctx context.Context, cb func(*remote.SubscribeReply)) error
 }
 
 type DbProvider uint8
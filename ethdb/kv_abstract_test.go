@@ -89,6 +89,9 @@ func TestManagedTx(t *testing.T) {
 		t.Run("multiple cursors "+msg, func(t *testing.T) {
 			testMultiCursor(t, db, bucket1, bucket2)
 		})
+		t.Run("range "+msg, func(t *testing.T) {
+			testRange(t, db, bucket2)
+		})
 	}
 }
 
@@ -188,6 +191,95 @@ func testPrefixFilter(t *testing.T, db ethdb.KV, bucket1 string) {
 	}
 
 }
+func testRange(t *testing.T, db ethdb.KV, bucket string) {
+	assert := assert.New(t)
+
+	if err := db.View(context.Background(), func(tx ethdb.Tx) error {
+		c := tx.Cursor(bucket).Prefetch(2)
+		var keys [][]byte
+		for k, _, err := c.First(); k != nil; k, _, err = c.Next() {
+			if err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		}
+		assert.Equal(13, len(keys))
+
+		// relative moves after read-ahead must start from the last returned pair
+		k, _, err := c.Seek(keys[0])
+		assert.NoError(err)
+		assert.Equal(keys[0], k)
+		for i := 1; i < 5; i++ {
+			k, _, err = c.Next()
+			assert.NoError(err)
+			assert.Equal(keys[i], k)
+		}
+		k, _, err = c.Current()
+		assert.NoError(err)
+		assert.Equal(keys[4], k)
+		k, _, err = c.Prev()
+		assert.NoError(err)
+		assert.Equal(keys[3], k)
+
+		// the first range stops after the limit, the second one - at the first key out of the prefix
+		c = tx.Cursor(bucket).Prefix([]byte{0}).Prefetch(3)
+		var prefixed [][]byte
+		for k, _, err := c.First(); k != nil; k, _, err = c.Next() {
+			if err != nil {
+				return err
+			}
+			prefixed = append(prefixed, k)
+		}
+		assert.Equal(keys[:4], prefixed)
+		k, _, err = c.Next()
+		assert.NoError(err)
+		assert.Nil(k)
+		k, _, err = c.Prev()
+		assert.NoError(err)
+		assert.Equal(keys[2], k)
+
+		// the range stops before the end key, also on Seek
+		c = tx.Cursor(bucket).(ethdb.RangeCursor).EndKey([]byte{5}).Prefetch(3)
+		var bounded [][]byte
+		for k, _, err := c.First(); k != nil; k, _, err = c.Next() {
+			if err != nil {
+				return err
+			}
+			bounded = append(bounded, k)
+		}
+		assert.Equal(keys[:8], bounded)
+		k, _, err = c.Prev()
+		assert.NoError(err)
+		assert.Equal(keys[6], k)
+		k, _, err = c.Seek([]byte{5})
+		assert.NoError(err)
+		assert.Nil(k)
+
+		// the keys out of the filter are skipped by the server while streaming, and by the client before it
+		for _, prefetch := range []uint{0, 2} {
+			c = tx.Cursor(bucket).(ethdb.RangeCursor).Filter([]byte{0}, 1).Prefetch(prefetch)
+			var filtered [][]byte
+			for k, _, err := c.First(); k != nil; k, _, err = c.Next() {
+				if err != nil {
+					return err
+				}
+				filtered = append(filtered, k)
+			}
+			assert.Equal(keys[1:4], filtered, "prefetch %d", prefetch)
+		}
+		c = tx.Cursor(bucket).(ethdb.RangeCursor).Filter([]byte{2}, 5).Prefetch(2)
+		k, _, err = c.Seek(nil)
+		assert.NoError(err)
+		assert.Equal(keys[2], k)
+		k, _, err = c.Next()
+		assert.NoError(err)
+		assert.Nil(k)
+		return nil
+	}); err != nil {
+		assert.NoError(err)
+	}
+}
+
 func testCtxCancel(t *testing.T, db ethdb.KV, bucket1 string) {
 	assert := assert.New(t)
 	cancelableCtx, cancel := context.WithTimeout(context.Background(), time.Microsecond)
@@ -453,3 +545,35 @@ func testMultiCursor(t *testing.T, db ethdb.KV, bucket1, bucket2 string) {
 //		})
 //	}
 //}
+
+func TestAutoDupSortSeekFirst(t *testing.T) {
+	testAutoDupSortSeekFirst(t, func(cfg ethdb.BucketConfigsFunc) ethdb.KV {
+		return ethdb.NewLMDB().InMem().WithBucketsConfig(cfg).MustOpen()
+	})
+}
+
+func testAutoDupSortSeekFirst(t *testing.T, open func(ethdb.BucketConfigsFunc) ethdb.KV) {
+	bucket := dbutils.Buckets[0]
+	db := open(func(defaultBuckets dbutils.BucketsCfg) dbutils.BucketsCfg {
+		return map[string]dbutils.BucketConfigItem{
+			bucket: {
+				Flags:                     dbutils.DupSort,
+				AutoDupSortKeysConversion: true,
+				DupToLen:                  4,
+				DupFromLen:                6,
+			},
+		}
+	})
+	defer db.Close()
+	require.NoError(t, db.Update(context.Background(), func(tx ethdb.Tx) error {
+		return tx.Cursor(bucket).Put([]byte{0, 0, 0, 1, 0, 2}, []byte{3})
+	}))
+	require.NoError(t, db.View(context.Background(), func(tx ethdb.Tx) error {
+		// the first key of the bucket has length of DupFromLen, like the ones found by non-empty seek
+		k, v, err := tx.Cursor(bucket).Seek(nil)
+		require.NoError(t, err)
+		require.Equal(t, []byte{0, 0, 0, 1, 0, 2}, k)
+		require.Equal(t, []byte{3}, v)
+		return nil
+	}))
+}
@@ -467,10 +467,10 @@ func (tx *lmdbTx) CreateBucket(name string) error {
 	if !tx.db.opts.readOnly {
 		nativeFlags |= lmdb.Create
 	}
-	switch flags {
-	case dbutils.DupSort:
+	if flags&dbutils.DupSort != 0 {
 		nativeFlags |= lmdb.DupSort
-	case dbutils.DupFixed:
+	}
+	if flags&dbutils.DupFixed != 0 {
 		nativeFlags |= lmdb.DupFixed
 	}
 	dbi, err := tx.tx.OpenDBI(name, nativeFlags)
@@ -902,6 +902,13 @@ func (c *LmdbCursor) seekDupSort(seek []byte) (k, v []byte, err error) {
 			}
 			return []byte{}, nil, err
 		}
+		// the first key is stored split, rejoin it as the non-empty seek below does
+		if len(k) == to {
+			k2 := make([]byte, 0, len(k)+from-to)
+			k2 = append(append(k2, k...), v[:from-to]...)
+			v = v[from-to:]
+			k = k2
+		}
 		if c.prefix != nil && !bytes.HasPrefix(k, c.prefix) {
 			k, v = nil, nil
 		}
@@ -891,6 +891,13 @@ func (c *MdbxCursor) seekDupSort(seek []byte) (k, v []byte, err error) {
 			}
 			return []byte{}, nil, err
 		}
+		// the first key is stored split, rejoin it as the non-empty seek below does
+		if len(k) == to {
+			k2 := make([]byte, 0, len(k)+from-to)
+			k2 = append(append(k2, k...), v[:from-to]...)
+			v = v[from-to:]
+			k = k2
+		}
 		if c.prefix != nil && !bytes.HasPrefix(k, c.prefix) {
 			k, v = nil, nil
 		}
@@ -0,0 +1,15 @@
+//+build mdbx
+
+package ethdb_test
+
+import (
+	"testing"
+
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+)
+
+func TestMdbxAutoDupSortSeekFirst(t *testing.T) {
+	testAutoDupSortSeekFirst(t, func(cfg ethdb.BucketConfigsFunc) ethdb.KV {
+		return ethdb.NewMDBX().InMem().WithBucketsConfig(cfg).MustOpen()
+	})
+}
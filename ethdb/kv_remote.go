@@ -38,6 +38,7 @@ type remoteOpts struct {
 	DialAddress string
 	inMemConn   *bufconn.Listener // for tests
 	bucketsCfg  BucketConfigsFunc
+	token       string
 }
 
 type RemoteKV struct {
@@ -64,12 +65,30 @@ type remoteCursor struct {
 	prefetch    uint32
 	ctx         context.Context
 	prefix      []byte
+	endK        []byte // see EndKey
+	filter      []byte // see Filter
+	filterAt    uint32
 	stream      remote.KV_TxClient
 	tx          *remoteTx
 	bucketName  string
 	bucketCfg   dbutils.BucketConfigItem
+
+	// read-ahead of sequential .Next() calls by Op_RANGE, see Next()
+	nextCalls  int      // amount of consecutive .Next() calls
+	rangeKs    [][]byte // pairs received from server and not yet returned to user
+	rangeVs    [][]byte
+	rangeAhead bool // server-side cursor is ahead of the pair returned to user
+	rangeEnd   bool // server has no more pairs in the range
+	lastK      []byte
+	lastV      []byte
 }
 
+// RangeReadAhead - amount of pairs requested by one Op_RANGE if user didn't set .Prefetch()
+const RangeReadAhead = 256
+
+// rangeAfterNextCalls - sequential .Next() calls after which cursor switches to Op_RANGE read-ahead
+const rangeAfterNextCalls = 3
+
 type remoteCursorDupSort struct {
 	*remoteCursor
 }
@@ -92,6 +111,26 @@ func (opts remoteOpts) WithBucketsConfig(f BucketConfigsFunc) remoteOpts {
 	return opts
 }
 
+// WithToken - token to authenticate on the server, see --private.api.auth
+func (opts remoteOpts) WithToken(token string) remoteOpts {
+	opts.token = token
+	return opts
+}
+
+// tokenCredentials - sends token in "authorization" metadata of every request
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+func (c tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + c.token}, nil
+}
+
+func (c tokenCredentials) RequireTransportSecurity() bool {
+	return c.secure
+}
+
 func (opts remoteOpts) InMem(listener *bufconn.Listener) remoteOpts {
 	opts.inMemConn = listener
 	return opts
@@ -150,6 +189,13 @@ func (opts remoteOpts) Open(certFile, keyFile, caCert string) (KV, Backend, erro
 		dialOpts = append(dialOpts, grpc.WithTransportCredentials(creds))
 	}
 
+	if opts.token != "" {
+		if certFile == "" {
+			log.Warn("Private API token is sent without TLS")
+		}
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(tokenCredentials{token: opts.token, secure: certFile != ""}))
+	}
+
 	if opts.inMemConn != nil {
 		dialOpts = append(dialOpts, grpc.WithContextDialer(func(ctx context.Context, url string) (net.Conn, error) {
 			return opts.inMemConn.Dial()
@@ -273,6 +319,16 @@ func (c *remoteCursor) Prefetch(v uint) Cursor {
 	return c
 }
 
+func (c *remoteCursor) EndKey(k []byte) Cursor {
+	c.endK = k
+	return c
+}
+
+func (c *remoteCursor) Filter(prefix []byte, offset uint) Cursor {
+	c.filter, c.filterAt = prefix, uint32(offset)
+	return c
+}
+
 func (tx *remoteTx) BucketSize(name string) (uint64, error) {
 	sizeReply, err := tx.db.remoteDB.BucketSize(tx.ctx, &remote.BucketSizeRequest{BucketName: name})
 	if err != nil {
@@ -301,6 +357,7 @@ func (c *remoteCursor) SeekExact(key []byte) (val []byte, err error) {
 	if err := c.initCursor(); err != nil {
 		return nil, err
 	}
+	c.resetRange()
 	return c.seekExact(key)
 }
 
@@ -308,6 +365,9 @@ func (c *remoteCursor) Prev() ([]byte, []byte, error) {
 	if err := c.initCursor(); err != nil {
 		return []byte{}, nil, err
 	}
+	if err := c.syncRange(); err != nil {
+		return []byte{}, nil, err
+	}
 	return c.prev()
 }
 
@@ -515,10 +575,93 @@ func (c *remoteCursor) nextMultiple() ([]byte, []byte, error) {
 	return pair.K, pair.V, nil
 }
 
+// rangeRequest - asks server to send next pairs of the range, starting after server-side cursor position
+func (c *remoteCursor) rangeRequest() error {
+	limit := c.prefetch
+	if limit <= 1 {
+		limit = RangeReadAhead
+	}
+	if err := c.stream.Send(&remote.Cursor{Cursor: c.id, Op: remote.Op_RANGE, Prefix: c.prefix, EndK: c.endK,
+		FilterPrefix: c.filter, FilterOffset: c.filterAt, Limit: limit}); err != nil {
+		return err
+	}
+	var received uint32
+	for {
+		page, err := c.stream.Recv()
+		if err != nil {
+			return err
+		}
+		if len(page.Ks) == 0 { // terminator
+			break
+		}
+		c.rangeKs = append(c.rangeKs, page.Ks...)
+		c.rangeVs = append(c.rangeVs, page.Vs...)
+		received += uint32(len(page.Ks))
+	}
+	c.rangeAhead = true
+	c.rangeEnd = received < limit
+	return nil
+}
+
+// resetRange - drops read-ahead pairs, must be called before any operation which sets absolute position of cursor
+func (c *remoteCursor) resetRange() {
+	c.nextCalls = 0
+	c.rangeKs, c.rangeVs = nil, nil
+	c.rangeAhead, c.rangeEnd = false, false
+	c.lastK, c.lastV = nil, nil
+}
+
+// syncRange - moves server-side cursor back to the last pair returned to user,
+// must be called before any operation which moves cursor relatively to it's current position
+func (c *remoteCursor) syncRange() error {
+	if !c.rangeAhead {
+		c.nextCalls = 0
+		return nil
+	}
+	lastK, lastV := c.lastK, c.lastV
+	c.resetRange()
+	if lastK == nil {
+		return nil
+	}
+	var err error
+	if c.bucketCfg.Flags&dbutils.DupSort != 0 && !c.bucketCfg.AutoDupSortKeysConversion {
+		_, _, err = c.getBothRange(lastK, lastV)
+	} else {
+		_, _, err = c.setRange(lastK)
+	}
+	return err
+}
+
+// withBounds - applies Prefix and EndKey to the pair the cursor moved to, and moves it forward while the key
+// doesn't pass the Filter - the same way as the server does for Op_RANGE
+func (c *remoteCursor) withBounds(k, v []byte, err error) ([]byte, []byte, error) {
+	for {
+		if err != nil {
+			return []byte{}, nil, err
+		}
+		if k == nil {
+			return nil, nil, nil
+		}
+		if c.prefix != nil && !bytes.HasPrefix(k, c.prefix) {
+			return nil, nil, nil
+		}
+		if c.endK != nil && bytes.Compare(k, c.endK) >= 0 {
+			return nil, nil, nil
+		}
+		if c.filter == nil || (uint32(len(k)) >= c.filterAt && bytes.HasPrefix(k[c.filterAt:], c.filter)) {
+			return k, v, nil
+		}
+		k, v, err = c.next()
+	}
+}
+
 func (c *remoteCursor) Current() ([]byte, []byte, error) {
 	if err := c.initCursor(); err != nil {
 		return []byte{}, nil, err
 	}
+	if err := c.syncRange(); err != nil {
+		return []byte{}, nil, err
+	}
 	return c.getCurrent()
 }
 
@@ -528,28 +671,54 @@ func (c *remoteCursor) Seek(seek []byte) ([]byte, []byte, error) {
 	if err := c.initCursor(); err != nil {
 		return []byte{}, nil, err
 	}
-	return c.setRange(seek)
+	c.resetRange()
+	return c.withBounds(c.setRange(seek))
 }
 
 func (c *remoteCursor) First() ([]byte, []byte, error) {
 	if err := c.initCursor(); err != nil {
 		return []byte{}, nil, err
 	}
-	return c.first()
+	c.resetRange()
+	if c.prefix != nil {
+		return c.withBounds(c.setRange(c.prefix))
+	}
+	return c.withBounds(c.first())
 }
 
-// Next - returns next data element from server, request streaming (if configured by user)
+// Next - returns next data element from server. If user set .Prefetch() or cursor is used
+// for sequential reading - pairs are requested by Op_RANGE in batches, without round-trip per pair
 func (c *remoteCursor) Next() ([]byte, []byte, error) {
 	if err := c.initCursor(); err != nil {
 		return []byte{}, nil, err
 	}
-	return c.next()
+	if !c.rangeAhead {
+		c.nextCalls++
+		if c.prefetch <= 1 && c.nextCalls < rangeAfterNextCalls {
+			return c.withBounds(c.next())
+		}
+	}
+	if len(c.rangeKs) == 0 {
+		if c.rangeEnd {
+			return nil, nil, nil
+		}
+		if err := c.rangeRequest(); err != nil {
+			return []byte{}, nil, err
+		}
+		if len(c.rangeKs) == 0 {
+			return nil, nil, nil
+		}
+	}
+	c.lastK, c.lastV = c.rangeKs[0], c.rangeVs[0]
+	c.rangeKs, c.rangeVs = c.rangeKs[1:], c.rangeVs[1:]
+	return c.lastK, c.lastV, nil
 }
 
 func (c *remoteCursor) Last() ([]byte, []byte, error) {
 	if err := c.initCursor(); err != nil {
 		return []byte{}, nil, err
 	}
+	c.resetRange()
 	return c.last()
 }
 
@@ -620,6 +789,7 @@ func (c *remoteCursorDupSort) SeekBothExact(key, value []byte) ([]byte, []byte,
 	if err := c.initCursor(); err != nil {
 		return []byte{}, nil, err
 	}
+	c.resetRange()
 	return c.seekBothExact(key, value)
 }
 
@@ -627,6 +797,7 @@ func (c *remoteCursorDupSort) SeekBothRange(key, value []byte) ([]byte, []byte,
 	if err := c.initCursor(); err != nil {
 		return []byte{}, nil, err
 	}
+	c.resetRange()
 	return c.getBothRange(key, value)
 }
 
@@ -640,36 +811,54 @@ func (c *remoteCursorDupSort) FirstDup() ([]byte, error) {
 	if err := c.initCursor(); err != nil {
 		return nil, err
 	}
+	if err := c.syncRange(); err != nil {
+		return nil, err
+	}
 	return c.firstDup()
 }
 func (c *remoteCursorDupSort) NextDup() ([]byte, []byte, error) {
 	if err := c.initCursor(); err != nil {
 		return []byte{}, nil, err
 	}
+	if err := c.syncRange(); err != nil {
+		return []byte{}, nil, err
+	}
 	return c.nextDup()
 }
 func (c *remoteCursorDupSort) NextNoDup() ([]byte, []byte, error) {
 	if err := c.initCursor(); err != nil {
 		return []byte{}, nil, err
 	}
+	if err := c.syncRange(); err != nil {
+		return []byte{}, nil, err
+	}
 	return c.nextNoDup()
 }
 func (c *remoteCursorDupSort) PrevDup() ([]byte, []byte, error) {
 	if err := c.initCursor(); err != nil {
 		return []byte{}, nil, err
 	}
+	if err := c.syncRange(); err != nil {
+		return []byte{}, nil, err
+	}
 	return c.prevDup()
 }
 func (c *remoteCursorDupSort) PrevNoDup() ([]byte, []byte, error) {
 	if err := c.initCursor(); err != nil {
 		return []byte{}, nil, err
 	}
+	if err := c.syncRange(); err != nil {
+		return []byte{}, nil, err
+	}
 	return c.prevNoDup()
 }
 func (c *remoteCursorDupSort) LastDup(k []byte) ([]byte, error) {
 	if err := c.initCursor(); err != nil {
 		return nil, err
 	}
+	if err := c.syncRange(); err != nil {
+		return nil, err
+	}
 	return c.lastDup(k)
 }
 
@@ -681,6 +870,9 @@ func (c *remoteCursorDupFixed) GetMulti() ([]byte, error) {
 	if err := c.initCursor(); err != nil {
 		return nil, err
 	}
+	if err := c.syncRange(); err != nil {
+		return nil, err
+	}
 	return c.multiple()
 }
 
@@ -688,6 +880,9 @@ func (c *remoteCursorDupFixed) NextMulti() ([]byte, []byte, error) {
 	if err := c.initCursor(); err != nil {
 		return []byte{}, nil, err
 	}
+	if err := c.syncRange(); err != nil {
+		return []byte{}, nil, err
+	}
 	return c.nextMultiple()
 }
 
@@ -720,3 +915,21 @@ func (back *RemoteBackend) NetVersion() (uint64, error) {
 
 	return res.Id, nil
 }
+
+// Subscribe streams the chain events of the remote node into onNewEvent, it blocks until the stream is closed
+func (back *RemoteBackend) Subscribe(ctx context.Context, onNewEvent func(*remote.SubscribeReply)) error {
+	subscription, err := back.remoteEthBackend.Subscribe(ctx, &remote.SubscribeRequest{})
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := subscription.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		onNewEvent(event)
+	}
+}
@@ -1,12 +1,12 @@
 package ethdb
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"sync"
 
-	"github.com/ledgerwatch/turbo-geth/common"
 	"github.com/ledgerwatch/turbo-geth/common/dbutils"
 	"github.com/ledgerwatch/turbo-geth/log"
 )
@@ -17,24 +17,10 @@ var (
 	_ Tx             = &snapshotTX{}
 	_ Tx             = &lazyTx{}
 	_ Cursor         = &snapshotCursor{}
+	_ CursorDupSort  = &snapshotCursorDupSort{}
+	_ CursorDupFixed = &snapshotCursorDupFixed{}
 )
 
-func (s *snapshotCursor) Reserve(k []byte, n int) ([]byte, error) {
-	return s.dbCursor.Reserve(k, n)
-}
-
-func (s *snapshotCursor) PutCurrent(key, value []byte) error {
-	return s.dbCursor.PutCurrent(key, value)
-}
-
-func (s *snapshotTX) CursorDupSort(bucket string) CursorDupSort {
-	return s.dbTX.CursorDupSort(bucket)
-}
-
-func (s *snapshotTX) CursorDupFixed(bucket string) CursorDupFixed {
-	return s.dbTX.CursorDupFixed(bucket)
-}
-
 func (s *snapshotTX) Comparator(bucket string) dbutils.CmpFunc {
 	return s.dbTX.Comparator(bucket)
 }
@@ -48,39 +34,60 @@ func (s *snapshotTX) DCmp(bucket string, a, b []byte) int {
 }
 
 func (v *lazyTx) CursorDupSort(bucket string) CursorDupSort {
-	panic("implement me")
+	if _, ok := v.forBuckets[bucket]; !ok {
+		return nil
+	}
+
+	tx, err := v.getTx()
+	if err != nil {
+		log.Error("Fail to create tx", "err", err)
+	}
+	if tx != nil {
+		return tx.CursorDupSort(bucket)
+	}
+	return nil
 }
 
 func (v *lazyTx) CursorDupFixed(bucket string) CursorDupFixed {
-	panic("implement me")
+	if _, ok := v.forBuckets[bucket]; !ok {
+		return nil
+	}
+
+	tx, err := v.getTx()
+	if err != nil {
+		log.Error("Fail to create tx", "err", err)
+	}
+	if tx != nil {
+		return tx.CursorDupFixed(bucket)
+	}
+	return nil
 }
 
 func (v *lazyTx) Comparator(bucket string) dbutils.CmpFunc {
-	panic("implement me")
+	tx, err := v.getTx()
+	if err != nil {
+		log.Error("Fail to create tx", "err", err)
+		return nil
+	}
+	return tx.Comparator(bucket)
 }
 
 func (v *lazyTx) Cmp(bucket string, a, b []byte) int {
-	panic("implement me")
+	tx, err := v.getTx()
+	if err != nil {
+		log.Error("Fail to create tx", "err", err)
+		return bytes.Compare(a, b)
+	}
+	return tx.Cmp(bucket, a, b)
 }
 
 func (v *lazyTx) DCmp(bucket string, a, b []byte) int {
-	panic("implement me")
-}
-
-func (s *snapshotCursor) Prev() ([]byte, []byte, error) {
-	return s.dbCursor.Prev()
-}
-
-func (s *snapshotCursor) Current() ([]byte, []byte, error) {
-	return s.dbCursor.Current()
-}
-
-func (s *snapshotCursor) DeleteCurrent() error {
-	return s.dbCursor.DeleteCurrent()
-}
-
-func (s *snapshotCursor) Count() (uint64, error) {
-	return s.dbCursor.Count()
+	tx, err := v.getTx()
+	if err != nil {
+		log.Error("Fail to create tx", "err", err)
+		return bytes.Compare(a, b)
+	}
+	return tx.DCmp(bucket, a, b)
 }
 
 func (s *SnapshotKV) AllBuckets() dbutils.BucketsCfg {
@@ -174,19 +181,22 @@ func (s *SnapshotKV) View(ctx context.Context, f func(tx Tx) error) error {
 		return err
 	}
 
-	t := &snapshotTX{
-		dbTX: dbTx,
-		snTX: newVirtualTx(func() (Tx, error) {
-			return s.snapshotDB.Begin(ctx, nil, RO)
-		}, s.forBuckets),
-		forBuckets: s.forBuckets,
-	}
+	t := s.newTx(ctx, dbTx)
 	defer t.Rollback()
 	return f(t)
 }
 
+// Update - deletions of the snapshot entries are kept in the main database, so updates also go through the snapshot
 func (s *SnapshotKV) Update(ctx context.Context, f func(tx Tx) error) error {
-	return s.db.Update(ctx, f)
+	tx, err := s.Begin(ctx, nil, RW)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err = f(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 func (s *SnapshotKV) Close() {
@@ -202,14 +212,22 @@ func (s *SnapshotKV) Begin(ctx context.Context, parentTx Tx, flags TxFlags) (Tx,
 		return nil, err
 	}
 
-	t := &snapshotTX{
+	return s.newTx(ctx, dbTx), nil
+}
+
+func (s *SnapshotKV) newTx(ctx context.Context, dbTx Tx) *snapshotTX {
+	buckets := s.db.AllBuckets()
+	deletesBucket, ok := buckets[dbutils.SnapshotDeletesBucket]
+	return &snapshotTX{
 		dbTX: dbTx,
+		// snapshot is read-only, all writes go to the main database
 		snTX: newVirtualTx(func() (Tx, error) {
-			return s.snapshotDB.Begin(ctx, parentTx, flags)
+			return s.snapshotDB.Begin(ctx, nil, RO)
 		}, s.forBuckets),
 		forBuckets: s.forBuckets,
+		buckets:    buckets,
+		hasDeletes: ok && deletesBucket.DBI != NonExistingDBI,
 	}
-	return t, nil
 }
 
 func newVirtualTx(construct func() (Tx, error), forBucket map[string]struct{}) *lazyTx {
@@ -303,6 +321,8 @@ type snapshotTX struct {
 	dbTX       Tx
 	snTX       Tx
 	forBuckets map[string]struct{}
+	buckets    dbutils.BucketsCfg
+	hasDeletes bool
 }
 
 func (s *snapshotTX) Commit(ctx context.Context) error {
@@ -319,15 +339,42 @@ func (s *snapshotTX) Cursor(bucket string) Cursor {
 	if _, ok := s.forBuckets[bucket]; !ok {
 		return s.dbTX.Cursor(bucket)
 	}
+	if s.isDupSort(bucket) {
+		if s.buckets[bucket].Flags&dbutils.DupFixed != 0 {
+			return s.CursorDupFixed(bucket)
+		}
+		return s.CursorDupSort(bucket)
+	}
 	snCursor := s.snTX.Cursor(bucket)
 	//check snapshot bucket
 	if snCursor == nil {
 		return s.dbTX.Cursor(bucket)
 	}
-	return &snapshotCursor{
-		snCursor: snCursor,
-		dbCursor: s.dbTX.Cursor(bucket),
+	return s.newCursor(bucket, s.dbTX.Cursor(bucket), nil, snCursor, nil)
+}
+
+func (s *snapshotTX) CursorDupSort(bucket string) CursorDupSort {
+	if _, ok := s.forBuckets[bucket]; !ok {
+		return s.dbTX.CursorDupSort(bucket)
+	}
+	snCursor := s.snTX.CursorDupSort(bucket)
+	if snCursor == nil {
+		return s.dbTX.CursorDupSort(bucket)
 	}
+	dbCursor := s.dbTX.CursorDupSort(bucket)
+	return &snapshotCursorDupSort{s.newCursor(bucket, dbCursor, dbCursor, snCursor, snCursor)}
+}
+
+func (s *snapshotTX) CursorDupFixed(bucket string) CursorDupFixed {
+	if _, ok := s.forBuckets[bucket]; !ok {
+		return s.dbTX.CursorDupFixed(bucket)
+	}
+	snCursor := s.snTX.CursorDupFixed(bucket)
+	if snCursor == nil {
+		return s.dbTX.CursorDupFixed(bucket)
+	}
+	dbCursor := s.dbTX.CursorDupFixed(bucket)
+	return &snapshotCursorDupFixed{&snapshotCursorDupSort{s.newCursor(bucket, dbCursor, dbCursor, snCursor, snCursor)}, dbCursor}
 }
 
 func (s *snapshotTX) GetOne(bucket string, key []byte) (val []byte, err error) {
@@ -335,6 +382,11 @@ func (s *snapshotTX) GetOne(bucket string, key []byte) (val []byte, err error) {
 	if !ok {
 		return s.dbTX.GetOne(bucket, key)
 	}
+	if s.isDupSort(bucket) {
+		c := s.Cursor(bucket)
+		defer c.Close()
+		return c.SeekExact(key)
+	}
 	v, err := s.dbTX.GetOne(bucket, key)
 	switch {
 	case err == nil && v != nil:
@@ -342,6 +394,13 @@ func (s *snapshotTX) GetOne(bucket string, key []byte) (val []byte, err error) {
 	case err != nil && !errors.Is(err, ErrKeyNotFound):
 		return nil, err
 	}
+	deleted, err := s.isDeleted(bucket, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if deleted {
+		return nil, nil
+	}
 	return s.snTX.GetOne(bucket, key)
 }
 
@@ -350,6 +409,10 @@ func (s *snapshotTX) HasOne(bucket string, key []byte) (bool, error) {
 	if !ok {
 		return s.dbTX.HasOne(bucket, key)
 	}
+	if s.isDupSort(bucket) {
+		v, err := s.GetOne(bucket, key)
+		return v != nil, err
+	}
 	v, err := s.dbTX.HasOne(bucket, key)
 	switch {
 	case err == nil && v:
@@ -357,6 +420,13 @@ func (s *snapshotTX) HasOne(bucket string, key []byte) (bool, error) {
 	case err != nil && !errors.Is(err, ErrKeyNotFound):
 		return false, err
 	}
+	deleted, err := s.isDeleted(bucket, key, nil)
+	if err != nil {
+		return false, err
+	}
+	if deleted {
+		return false, nil
+	}
 	return s.snTX.HasOne(bucket, key)
 }
 
@@ -372,154 +442,668 @@ func (s *snapshotTX) BucketSize(name string) (uint64, error) {
 	return dbSize + snSize, nil
 }
 
-type snapshotCursor struct {
-	snCursor Cursor
-	dbCursor Cursor
+// isDupSort - the bucket keeps multiple values per key and cursors walk over pairs key/value.
+// For buckets with AutoDupSortKeysConversion cursors see unique keys, and they are merged by keys only.
+func (s *snapshotTX) isDupSort(bucket string) bool {
+	cfg := s.buckets[bucket]
+	return cfg.Flags&dbutils.DupSort != 0 && !cfg.AutoDupSortKeysConversion
+}
 
-	lastDBKey   []byte
-	lastSNDBKey []byte
-	lastDBVal   []byte
-	lastSNDBVal []byte
-	keyCmp      int
+// Snapshot is read-only, so deletions of its entries are kept in the main database, in dbutils.SnapshotDeletesBucket,
+// and mask the entries of the snapshot. The value is a part of the deleted entry only for DupSort buckets.
+func snapshotDeletedKey(bucket string, k, v []byte) []byte {
+	key := make([]byte, 0, len(bucket)+3+len(k)+len(v))
+	key = append(key, bucket...)
+	key = append(key, 0, byte(len(k)>>8), byte(len(k)))
+	key = append(key, k...)
+	return append(key, v...)
+}
+
+func (s *snapshotTX) isDeleted(bucket string, k, v []byte) (bool, error) {
+	if !s.hasDeletes {
+		return false, nil
+	}
+	return s.dbTX.HasOne(dbutils.SnapshotDeletesBucket, snapshotDeletedKey(bucket, k, v))
+}
+
+func (s *snapshotTX) markDeleted(bucket string, k, v []byte) error {
+	if !s.hasDeletes {
+		return fmt.Errorf("can't delete %x from snapshot bucket %s: bucket %s not found", k, bucket, dbutils.SnapshotDeletesBucket)
+	}
+	c := s.dbTX.Cursor(dbutils.SnapshotDeletesBucket)
+	defer c.Close()
+	return c.Put(snapshotDeletedKey(bucket, k, v), []byte{1})
+}
+
+// inSnapshot checks if the entry exists in the snapshot, it doesn't move cursors of the bucket
+func (s *snapshotTX) inSnapshot(bucket string, k, v []byte) (bool, error) {
+	if !s.isDupSort(bucket) {
+		return s.snTX.HasOne(bucket, k)
+	}
+	c := s.snTX.CursorDupSort(bucket)
+	if c == nil {
+		return false, nil
+	}
+	defer c.Close()
+	sk, _, err := c.SeekBothExact(k, v)
+	return sk != nil, err
+}
+
+func (s *snapshotTX) newCursor(bucket string, dbCursor Cursor, dbDupCursor CursorDupSort, snCursor Cursor, snDupCursor CursorDupSort) *snapshotCursor {
+	return &snapshotCursor{
+		tx:      s,
+		bucket:  bucket,
+		dupSort: dbDupCursor != nil,
+		cmp:     s.dbTX.Comparator(bucket),
+		db:      snapshotCursorSide{c: dbCursor, dup: dbDupCursor},
+		sn:      snapshotCursorSide{c: snCursor, dup: snDupCursor},
+	}
+}
+
+// snapshotCursorSide - cursor of the main database or of the snapshot, positioned at the entry k/v.
+// The entry is either the current entry of snapshotCursor or the first entry after it.
+type snapshotCursorSide struct {
+	c    Cursor
+	dup  CursorDupSort
+	k, v []byte
+	cur  bool
+}
+
+// snapshotCursor merges entries of the main database and of the snapshot.
+// If an entry exists in both, the one from the main database is returned.
+// Entries of the snapshot deleted in the main database are skipped.
+type snapshotCursor struct {
+	tx      *snapshotTX
+	bucket  string
+	dupSort bool
+	cmp     dbutils.CmpFunc
+	db, sn  snapshotCursorSide
+	k, v    []byte
+
+	prefix    []byte
+	matchBits uint // of the prefix, all bits of the prefix if 0
 }
 
 func (s *snapshotCursor) Close() {
-	defer s.dbCursor.Close()
-	defer s.snCursor.Close()
+	defer s.db.c.Close()
+	defer s.sn.c.Close()
 }
 
+// Prefix - the entries are merged first and filtered by the prefix after, so the sides of the cursor
+// stay positioned at the entries around the current one
 func (s *snapshotCursor) Prefix(v []byte) Cursor {
-	panic("implement me")
+	s.prefix = v
+	return s
 }
 
+// MatchBits - only the first u bits of the prefix must match
 func (s *snapshotCursor) MatchBits(u uint) Cursor {
-	panic("implement me")
+	s.matchBits = u
+	return s
 }
 
 func (s *snapshotCursor) Prefetch(v uint) Cursor {
-	panic("implement me")
+	s.db.c.Prefetch(v)
+	s.sn.c.Prefetch(v)
+	return s
 }
 
-func (s *snapshotCursor) First() ([]byte, []byte, error) {
-	var err error
-	s.lastSNDBKey, s.lastSNDBVal, err = s.snCursor.First()
-	if err != nil {
-		return nil, nil, err
+func (s *snapshotCursor) hasPrefix(k []byte) bool {
+	if s.matchBits == 0 {
+		return bytes.HasPrefix(k, s.prefix)
 	}
-	s.lastDBKey, s.lastDBVal, err = s.dbCursor.First()
-	if err != nil {
-		return nil, nil, err
+	bytesLen, bits := int(s.matchBits/8), s.matchBits%8
+	if bits != 0 {
+		bytesLen++
 	}
-	cmp, br := common.KeyCmp(s.lastDBKey, s.lastSNDBKey)
-	if br {
-		return nil, nil, nil
+	if len(k) < bytesLen || len(s.prefix) < bytesLen {
+		return false
+	}
+	if !bytes.Equal(k[:bytesLen-1], s.prefix[:bytesLen-1]) {
+		return false
+	}
+	mask := byte(0xff)
+	if bits != 0 {
+		mask <<= 8 - bits
 	}
+	return k[bytesLen-1]&mask == s.prefix[bytesLen-1]&mask
+}
 
-	s.keyCmp = cmp
-	if cmp <= 0 {
-		return s.lastDBKey, s.lastDBVal, nil
+// filter returns nil instead of the entries without the prefix
+func (s *snapshotCursor) filter(k, v []byte, err error) ([]byte, []byte, error) {
+	if err != nil || k == nil || s.prefix == nil || s.hasPrefix(k) {
+		return k, v, err
 	}
+	return nil, nil, nil
+}
 
-	return s.lastSNDBKey, s.lastSNDBVal, nil
+// compare - entries of DupSort buckets are compared by keys and values, other entries - only by keys
+func (s *snapshotCursor) compare(k1, v1, k2, v2 []byte) int {
+	if !s.dupSort {
+		return s.cmp(k1, k2, nil, nil)
+	}
+	return s.cmp(k1, k2, v1, v2)
 }
 
-func (s *snapshotCursor) Seek(seek []byte) ([]byte, []byte, error) {
-	var err error
-	s.lastSNDBKey, s.lastSNDBVal, err = s.snCursor.Seek(seek)
-	if err != nil {
-		return nil, nil, err
+func (s *snapshotCursor) sameKey(k1, k2 []byte) bool {
+	return k1 != nil && k2 != nil && s.cmp(k1, k2, nil, nil) == 0
+}
+
+func (s *snapshotCursor) deletedValue(v []byte) []byte {
+	if s.dupSort {
+		return v
+	}
+	return nil
+}
+
+// resolve chooses the current entry from the positions of both sides: the smallest one when moving forward,
+// the biggest one when moving backward. Deleted entries of the snapshot are skipped.
+func (s *snapshotCursor) resolve(forward bool) ([]byte, []byte, error) {
+	for {
+		s.db.cur, s.sn.cur = false, false
+		switch {
+		case s.db.k == nil && s.sn.k == nil:
+			s.k, s.v = nil, nil
+			return nil, nil, nil
+		case s.sn.k == nil:
+			s.db.cur = true
+		case s.db.k == nil:
+			s.sn.cur = true
+		default:
+			cmp := s.compare(s.db.k, s.db.v, s.sn.k, s.sn.v)
+			if !forward {
+				cmp = -cmp
+			}
+			s.db.cur, s.sn.cur = cmp <= 0, cmp >= 0
+		}
+		if s.db.cur {
+			s.k, s.v = s.db.k, s.db.v
+			return s.k, s.v, nil
+		}
+
+		deleted, err := s.tx.isDeleted(s.bucket, s.sn.k, s.deletedValue(s.sn.v))
+		if err != nil {
+			return []byte{}, nil, err
+		}
+		if !deleted {
+			s.k, s.v = s.sn.k, s.sn.v
+			return s.k, s.v, nil
+		}
+		if forward {
+			s.sn.k, s.sn.v, err = s.sn.c.Next()
+		} else {
+			s.sn.k, s.sn.v, err = s.sn.c.Prev()
+		}
+		if err != nil {
+			return []byte{}, nil, err
+		}
 	}
-	s.lastDBKey, s.lastDBVal, err = s.dbCursor.Seek(seek)
+}
+
+// resolveBackward chooses the current entry and moves the other side forward, right after the current entry
+func (s *snapshotCursor) resolveBackward() ([]byte, []byte, error) {
+	k, v, err := s.resolve(false)
 	if err != nil {
-		return nil, nil, err
+		return []byte{}, nil, err
 	}
-	cmp, br := common.KeyCmp(s.lastDBKey, s.lastSNDBKey)
-	if br {
+	if k == nil {
+		// nothing before, next step forward goes to the first entry
+		for _, side := range []*snapshotCursorSide{&s.db, &s.sn} {
+			if side.k, side.v, err = side.c.First(); err != nil {
+				return []byte{}, nil, err
+			}
+		}
 		return nil, nil, nil
 	}
-
-	s.keyCmp = cmp
-	if cmp <= 0 {
-		return s.lastDBKey, s.lastDBVal, nil
+	for _, side := range []*snapshotCursorSide{&s.db, &s.sn} {
+		if side.cur {
+			continue
+		}
+		if side.k, side.v, err = s.seekAfter(side, k, v); err != nil {
+			return []byte{}, nil, err
+		}
 	}
+	return k, v, nil
+}
 
-	return s.lastSNDBKey, s.lastSNDBVal, nil
+// notFound is used when the cursor moved to the entry which doesn't match the request (e.g. to the next key in NextDup),
+// the entry stays pending and is returned by the next call of Next
+func (s *snapshotCursor) notFound(k, v []byte) ([]byte, []byte, error) {
+	s.db.cur, s.sn.cur = false, false
+	s.k, s.v = k, v
+	return nil, nil, nil
 }
 
-func (s *snapshotCursor) Next() ([]byte, []byte, error) {
-	var err error
-	if s.keyCmp >= 0 {
-		s.lastSNDBKey, s.lastSNDBVal, err = s.snCursor.Next()
+// seekAfter positions the side at the first entry after k/v
+func (s *snapshotCursor) seekAfter(side *snapshotCursorSide, k, v []byte) ([]byte, []byte, error) {
+	if !s.dupSort {
+		sk, sv, err := side.c.Seek(k)
+		if err != nil || !s.sameKey(sk, k) {
+			return sk, sv, err
+		}
+		return side.c.Next()
 	}
+	sk, sv, err := side.dup.SeekBothRange(k, v)
 	if err != nil {
-		return nil, nil, err
+		return []byte{}, nil, err
 	}
-	if s.keyCmp <= 0 {
-		s.lastDBKey, s.lastDBVal, err = s.dbCursor.Next()
+	if sk != nil {
+		if s.compare(sk, sv, k, v) == 0 {
+			return side.c.Next()
+		}
+		return sk, sv, nil
 	}
+	return s.seekNextKey(side, k)
+}
+
+// seekBefore positions the side at the last entry before k/v
+func (s *snapshotCursor) seekBefore(side *snapshotCursorSide, k, v []byte) ([]byte, []byte, error) {
+	if s.dupSort {
+		sk, _, err := side.dup.SeekBothRange(k, v)
+		if err != nil {
+			return []byte{}, nil, err
+		}
+		if sk != nil {
+			return side.c.Prev()
+		}
+	}
+	sk, _, err := side.c.Seek(k)
 	if err != nil {
+		return []byte{}, nil, err
+	}
+	if sk == nil {
+		return side.c.Last()
+	}
+	if s.dupSort && s.sameKey(sk, k) {
+		// all values of the key are smaller than v
+		return s.seekLastDup(side, k)
+	}
+	return side.c.Prev()
+}
+
+// seekNextKey positions the side at the first entry of the next key after k
+func (s *snapshotCursor) seekNextKey(side *snapshotCursorSide, k []byte) ([]byte, []byte, error) {
+	sk, sv, err := side.c.Seek(k)
+	if err != nil || !s.sameKey(sk, k) {
+		return sk, sv, err
+	}
+	return side.dup.NextNoDup()
+}
+
+// seekLastDup positions the side at the last entry of the key k, the side must be positioned at the key already
+func (s *snapshotCursor) seekLastDup(side *snapshotCursorSide, k []byte) ([]byte, []byte, error) {
+	v, err := side.dup.LastDup(k)
+	if err != nil || v == nil {
 		return nil, nil, err
 	}
+	return k, v, nil
+}
 
-	cmp, br := common.KeyCmp(s.lastDBKey, s.lastSNDBKey)
-	if br {
-		return nil, nil, nil
+func (s *snapshotCursor) First() ([]byte, []byte, error) {
+	if s.prefix != nil {
+		return s.Seek(s.prefix)
 	}
+	var err error
+	for _, side := range []*snapshotCursorSide{&s.db, &s.sn} {
+		if side.k, side.v, err = side.c.First(); err != nil {
+			return []byte{}, nil, err
+		}
+	}
+	return s.resolve(true)
+}
 
-	s.keyCmp = cmp
-	if cmp <= 0 {
-		return s.lastDBKey, s.lastDBVal, nil
+func (s *snapshotCursor) Seek(seek []byte) ([]byte, []byte, error) {
+	var err error
+	for _, side := range []*snapshotCursorSide{&s.db, &s.sn} {
+		if side.k, side.v, err = side.c.Seek(seek); err != nil {
+			return []byte{}, nil, err
+		}
 	}
+	return s.filter(s.resolve(true))
+}
 
-	return s.lastSNDBKey, s.lastSNDBVal, nil
+func (s *snapshotCursor) Next() ([]byte, []byte, error) {
+	var err error
+	for _, side := range []*snapshotCursorSide{&s.db, &s.sn} {
+		if !side.cur {
+			continue
+		}
+		if side.k, side.v, err = side.c.Next(); err != nil {
+			return []byte{}, nil, err
+		}
+	}
+	return s.filter(s.resolve(true))
 }
 
+func (s *snapshotCursor) Prev() ([]byte, []byte, error) {
+	if s.k == nil {
+		return s.Last()
+	}
+	var err error
+	k, v := s.k, s.v
+	for _, side := range []*snapshotCursorSide{&s.db, &s.sn} {
+		if side.k, side.v, err = s.seekBefore(side, k, v); err != nil {
+			return []byte{}, nil, err
+		}
+	}
+	return s.filter(s.resolveBackward())
+}
+
+func (s *snapshotCursor) Last() ([]byte, []byte, error) {
+	if s.prefix != nil {
+		return []byte{}, nil, fmt.Errorf(".Last doesn't support c.prefix yet")
+	}
+	var err error
+	for _, side := range []*snapshotCursorSide{&s.db, &s.sn} {
+		if side.k, side.v, err = side.c.Last(); err != nil {
+			return []byte{}, nil, err
+		}
+	}
+	return s.resolveBackward()
+}
+
+func (s *snapshotCursor) Current() ([]byte, []byte, error) {
+	return s.k, s.v, nil
+}
+
+func (s *snapshotCursor) SeekExact(key []byte) ([]byte, error) {
+	k, v, err := s.Seek(key)
+	if err != nil {
+		return nil, err
+	}
+	if !s.sameKey(k, key) {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// Walk calls walker for the merged entries from the first one (with the prefix), until it returns false
 func (s *snapshotCursor) Walk(walker func(k []byte, v []byte) (bool, error)) error {
-	panic("implement me")
+	for k, v, err := s.First(); k != nil; k, v, err = s.Next() {
+		if err != nil {
+			return err
+		}
+		ok, err := walker(k, v)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+	return nil
 }
 
 func (s *snapshotCursor) Put(key []byte, value []byte) error {
-	return s.dbCursor.Put(key, value)
+	return s.db.c.Put(key, value)
+}
+
+func (s *snapshotCursor) Append(key []byte, value []byte) error {
+	return s.db.c.Append(key, value)
 }
 
+func (s *snapshotCursor) Reserve(k []byte, n int) ([]byte, error) {
+	return s.db.c.Reserve(k, n)
+}
+
+func (s *snapshotCursor) PutCurrent(key, value []byte) error {
+	if s.db.cur {
+		return s.db.c.PutCurrent(key, value)
+	}
+	// the current entry is only in the snapshot
+	return s.db.c.Put(key, value)
+}
+
+// Delete - the entry of the snapshot is marked as deleted. Position of the cursor is undefined after the call.
 func (s *snapshotCursor) Delete(k, v []byte) error {
-	return s.dbCursor.Delete(k, v)
+	inSnapshot, err := s.tx.inSnapshot(s.bucket, k, s.deletedValue(v))
+	if err != nil {
+		return err
+	}
+	if inSnapshot {
+		if err = s.tx.markDeleted(s.bucket, k, s.deletedValue(v)); err != nil {
+			return err
+		}
+	}
+	return s.db.c.Delete(k, v)
 }
 
-func (s *snapshotCursor) Append(key []byte, value []byte) error {
-	return s.dbCursor.Append(key, value)
+func (s *snapshotCursor) DeleteCurrent() error {
+	if s.sn.cur {
+		if err := s.tx.markDeleted(s.bucket, s.k, s.deletedValue(s.v)); err != nil {
+			return err
+		}
+	}
+	if s.db.cur {
+		return s.db.c.DeleteCurrent()
+	}
+	return nil
 }
 
-func (s *snapshotCursor) SeekExact(key []byte) ([]byte, error) {
-	v, err := s.dbCursor.SeekExact(key)
+func (s *snapshotCursor) Count() (uint64, error) {
+	return s.db.c.Count()
+}
+
+// snapshotCursorDupSort - merges duplicates of the same key from the main database and from the snapshot
+type snapshotCursorDupSort struct {
+	*snapshotCursor
+}
+
+func (s *snapshotCursorDupSort) SeekBothExact(key, value []byte) ([]byte, []byte, error) {
+	k, v, err := s.SeekBothRange(key, value)
+	if err != nil || k == nil {
+		return k, v, err
+	}
+	if s.compare(k, v, key, value) != 0 {
+		return s.notFound(k, v)
+	}
+	return k, v, nil
+}
+
+func (s *snapshotCursorDupSort) SeekBothRange(key, value []byte) ([]byte, []byte, error) {
+	var err error
+	for _, side := range []*snapshotCursorSide{&s.db, &s.sn} {
+		if side.k, side.v, err = side.dup.SeekBothRange(key, value); err != nil {
+			return []byte{}, nil, err
+		}
+		if side.k == nil {
+			if side.k, side.v, err = s.seekNextKey(side, key); err != nil {
+				return []byte{}, nil, err
+			}
+		}
+	}
+	k, v, err := s.resolve(true)
+	if err != nil || k == nil {
+		return k, v, err
+	}
+	if !s.sameKey(k, key) {
+		return s.notFound(k, v)
+	}
+	return k, v, nil
+}
+
+func (s *snapshotCursorDupSort) FirstDup() ([]byte, error) {
+	if s.k == nil {
+		return nil, nil
+	}
+	key, prevV := s.k, s.v
+	var err error
+	for _, side := range []*snapshotCursorSide{&s.db, &s.sn} {
+		if side.k, side.v, err = side.c.Seek(key); err != nil {
+			return nil, err
+		}
+	}
+	k, v, err := s.resolve(true)
 	if err != nil {
 		return nil, err
 	}
-	if v == nil {
-		return s.snCursor.SeekExact(key)
+	if !s.sameKey(k, key) {
+		_, _, err = s.notFound(key, prevV)
+		return nil, err
 	}
-	return v, err
+	return v, nil
 }
 
-func (s *snapshotCursor) Last() ([]byte, []byte, error) {
+func (s *snapshotCursorDupSort) NextDup() ([]byte, []byte, error) {
+	if s.k == nil {
+		return nil, nil, nil
+	}
+	key, prevV := s.k, s.v
+	k, v, err := s.Next()
+	if err != nil || k == nil {
+		return k, v, err
+	}
+	if !s.sameKey(k, key) {
+		return s.notFound(key, prevV)
+	}
+	return k, v, nil
+}
+
+func (s *snapshotCursorDupSort) NextNoDup() ([]byte, []byte, error) {
+	if s.k == nil {
+		return s.First()
+	}
+	key := s.k
 	var err error
-	s.lastSNDBKey, s.lastSNDBVal, err = s.snCursor.Last()
+	for _, side := range []*snapshotCursorSide{&s.db, &s.sn} {
+		if !s.sameKey(side.k, key) {
+			continue
+		}
+		if side.k, side.v, err = side.dup.NextNoDup(); err != nil {
+			return []byte{}, nil, err
+		}
+	}
+	return s.resolve(true)
+}
+
+func (s *snapshotCursorDupSort) LastDup(_ []byte) ([]byte, error) {
+	if s.k == nil {
+		return nil, nil
+	}
+	key := s.k
+	for _, side := range []*snapshotCursorSide{&s.db, &s.sn} {
+		sk, _, err := side.c.Seek(key)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case sk == nil:
+			side.k, side.v, err = side.c.Last()
+		case s.sameKey(sk, key):
+			side.k, side.v, err = s.seekLastDup(side, key)
+		default:
+			side.k, side.v, err = side.c.Prev()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	k, v, err := s.resolveBackward()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if !s.sameKey(k, key) {
+		return nil, nil
+	}
+	return v, nil
+}
+
+func (s *snapshotCursorDupSort) CountDuplicates() (uint64, error) {
+	if s.k == nil {
+		return 0, nil
+	}
+	c := s.tx.CursorDupSort(s.bucket)
+	defer c.Close()
+	var count uint64
+	for k, _, err := c.Seek(s.k); k != nil; k, _, err = c.NextDup() {
+		if err != nil {
+			return 0, err
+		}
+		if !s.sameKey(k, s.k) {
+			break
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (s *snapshotCursorDupSort) DeleteCurrentDuplicates() error {
+	if s.k == nil {
+		return nil
+	}
+	key := s.k
+	c := s.tx.snTX.CursorDupSort(s.bucket)
+	defer c.Close()
+	k, v, err := c.Seek(key)
+	for ; err == nil && s.sameKey(k, key); k, v, err = c.NextDup() {
+		if err = s.tx.markDeleted(s.bucket, key, v); err != nil {
+			return err
+		}
 	}
-	s.lastDBKey, s.lastDBVal, err = s.dbCursor.Last()
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
-	cmp, br := common.KeyCmp(s.lastDBKey, s.lastSNDBKey)
-	if br {
-		return nil, nil, nil
+
+	if k, _, err = s.db.c.Seek(key); err != nil {
+		return err
+	}
+	if s.sameKey(k, key) {
+		if err = s.db.dup.DeleteCurrentDuplicates(); err != nil {
+			return err
+		}
 	}
 
-	s.keyCmp = cmp
-	if cmp >= 0 {
-		return s.lastDBKey, s.lastDBVal, nil
+	// the next step forward goes to the next key
+	for _, side := range []*snapshotCursorSide{&s.db, &s.sn} {
+		if side.k, side.v, err = s.seekNextKey(side, key); err != nil {
+			return err
+		}
 	}
+	s.db.cur, s.sn.cur = false, false
+	return nil
+}
+
+func (s *snapshotCursorDupSort) AppendDup(key, value []byte) error {
+	return s.db.dup.AppendDup(key, value)
+}
+
+// snapshotCursorDupFixed - pages of values are assembled from the merged values of the key
+type snapshotCursorDupFixed struct {
+	*snapshotCursorDupSort
+	dbCursor CursorDupFixed
+}
+
+// GetMulti - returns the current value and all next values of the current key,
+// the cursor is positioned at the last value of the key
+func (s *snapshotCursorDupFixed) GetMulti() ([]byte, error) {
+	if s.k == nil {
+		return nil, nil
+	}
+	page := append([]byte{}, s.v...)
+	for {
+		k, v, err := s.NextDup()
+		if err != nil {
+			return nil, err
+		}
+		if k == nil {
+			return page, nil
+		}
+		page = append(page, v...)
+	}
+}
+
+func (s *snapshotCursorDupFixed) NextMulti() ([]byte, []byte, error) {
+	k, _, err := s.Next()
+	if err != nil || k == nil {
+		return k, nil, err
+	}
+	page, err := s.GetMulti()
+	if err != nil {
+		return []byte{}, nil, err
+	}
+	return k, page, nil
+}
 
-	return s.lastSNDBKey, s.lastSNDBVal, nil
+func (s *snapshotCursorDupFixed) PutMulti(key []byte, page []byte, stride int) error {
+	return s.dbCursor.PutMulti(key, page, stride)
 }
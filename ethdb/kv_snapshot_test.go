@@ -3,9 +3,12 @@ package ethdb
 import (
 	"bytes"
 	"context"
+	"errors"
+	"testing"
+
 	"github.com/ledgerwatch/turbo-geth/common"
 	"github.com/ledgerwatch/turbo-geth/common/dbutils"
-	"testing"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSnapshotGet(t *testing.T) {
@@ -276,3 +279,249 @@ func TestSnapshotWritableTxAndGet(t *testing.T) {
 	t.Log(c.Next())
 	t.Log(c.Next())
 }
+
+func TestSnapshotDupSort(t *testing.T) {
+	const bucket, fixedBucket = "dupsort_test", "dupfixed_test"
+	withDupSort := func(defaultBuckets dbutils.BucketsCfg) dbutils.BucketsCfg {
+		defaultBuckets[bucket] = dbutils.BucketConfigItem{Flags: dbutils.DupSort}
+		defaultBuckets[fixedBucket] = dbutils.BucketConfigItem{Flags: dbutils.DupSort | dbutils.DupFixed}
+		return defaultBuckets
+	}
+	fill := func(db KV, entries [][2]string) {
+		err := db.Update(context.Background(), func(tx Tx) error {
+			for _, name := range []string{bucket, fixedBucket} {
+				c := tx.Cursor(name)
+				for _, e := range entries {
+					if err := c.Put([]byte(e[0]), []byte(e[1])); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	sn := NewLMDB().WithBucketsConfig(withDupSort).InMem().MustOpen()
+	fill(sn, [][2]string{{"k1", "a"}, {"k1", "c"}, {"k1", "e"}, {"k2", "a"}, {"k3", "b"}})
+	mainDB := NewLMDB().WithBucketsConfig(withDupSort).InMem().MustOpen()
+	fill(mainDB, [][2]string{{"k1", "b"}, {"k1", "c"}, {"k1", "d"}, {"k3", "a"}})
+	kv := NewSnapshotKV().For(bucket).For(fixedBucket).SnapshotDB(sn).DB(mainDB).MustOpen()
+	defer kv.Close()
+
+	all := func(c Cursor) []string {
+		var res []string
+		for k, v, err := c.First(); k != nil; k, v, err = c.Next() {
+			require.NoError(t, err)
+			res = append(res, string(k)+":"+string(v))
+		}
+		return res
+	}
+
+	err := kv.Update(context.Background(), func(tx Tx) error {
+		c := tx.CursorDupSort(bucket)
+		defer c.Close()
+		require.Equal(t, []string{"k1:a", "k1:b", "k1:c", "k1:d", "k1:e", "k2:a", "k3:a", "k3:b"}, all(c))
+
+		// deletion of the snapshot entries
+		require.NoError(t, c.Delete([]byte("k1"), []byte("e")))
+		k, v, err := c.SeekBothExact([]byte("k2"), []byte("a"))
+		require.NoError(t, err)
+		require.Equal(t, "k2:a", string(k)+":"+string(v))
+		require.NoError(t, c.DeleteCurrent())
+		require.Equal(t, []string{"k1:a", "k1:b", "k1:c", "k1:d", "k3:a", "k3:b"}, all(c))
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = kv.View(context.Background(), func(tx Tx) error {
+		c := tx.CursorDupSort(bucket)
+		defer c.Close()
+		require.Equal(t, []string{"k1:a", "k1:b", "k1:c", "k1:d", "k3:a", "k3:b"}, all(c))
+
+		k, v, err := c.SeekBothRange([]byte("k1"), []byte("bb"))
+		require.NoError(t, err)
+		require.Equal(t, "k1:c", string(k)+":"+string(v))
+		k, v, err = c.NextDup()
+		require.NoError(t, err)
+		require.Equal(t, "k1:d", string(k)+":"+string(v))
+		k, _, err = c.NextDup()
+		require.NoError(t, err)
+		require.Nil(t, k)
+		k, v, err = c.Next()
+		require.NoError(t, err)
+		require.Equal(t, "k3:a", string(k)+":"+string(v))
+
+		k, _, err = c.SeekBothExact([]byte("k1"), []byte("e"))
+		require.NoError(t, err)
+		require.Nil(t, k)
+
+		_, _, err = c.Seek([]byte("k1"))
+		require.NoError(t, err)
+		count, err := c.CountDuplicates()
+		require.NoError(t, err)
+		require.Equal(t, uint64(4), count)
+		v, err = c.LastDup([]byte("k1"))
+		require.NoError(t, err)
+		require.Equal(t, "d", string(v))
+		v, err = c.FirstDup()
+		require.NoError(t, err)
+		require.Equal(t, "a", string(v))
+		k, v, err = c.NextNoDup()
+		require.NoError(t, err)
+		require.Equal(t, "k3:a", string(k)+":"+string(v))
+
+		var backward []string
+		for k, v, err := c.Last(); k != nil; k, v, err = c.Prev() {
+			require.NoError(t, err)
+			backward = append(backward, string(k)+":"+string(v))
+		}
+		require.Equal(t, []string{"k3:b", "k3:a", "k1:d", "k1:c", "k1:b", "k1:a"}, backward)
+
+		v, err = tx.GetOne(bucket, []byte("k3"))
+		require.NoError(t, err)
+		require.Equal(t, "a", string(v))
+
+		fc := tx.CursorDupFixed(fixedBucket)
+		defer fc.Close()
+		_, _, err = fc.First()
+		require.NoError(t, err)
+		page, err := fc.GetMulti()
+		require.NoError(t, err)
+		require.Equal(t, "abcde", string(page))
+		k, page, err = fc.NextMulti()
+		require.NoError(t, err)
+		require.Equal(t, "k2", string(k))
+		require.Equal(t, "a", string(page))
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = kv.Update(context.Background(), func(tx Tx) error {
+		c := tx.CursorDupSort(bucket)
+		defer c.Close()
+		_, _, err := c.Seek([]byte("k3"))
+		require.NoError(t, err)
+		require.NoError(t, c.DeleteCurrentDuplicates())
+		require.Equal(t, []string{"k1:a", "k1:b", "k1:c", "k1:d"}, all(c))
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestSnapshotDelete(t *testing.T) {
+	sn := NewLMDB().InMem().MustOpen()
+	account := common.FromHex("0x1000000000000000000000000000000000000001")
+	storageKey := dbutils.PlainGenerateCompositeStorageKey(common.BytesToAddress(account), 1, common.Hash{1})
+	err := sn.Update(context.Background(), func(tx Tx) error {
+		c := tx.Cursor(dbutils.HeaderPrefix)
+		for i := uint64(1); i <= 3; i++ {
+			if err := c.Put(dbutils.HeaderKey(i, common.Hash{}), []byte{byte(i)}); err != nil {
+				return err
+			}
+		}
+		c = tx.Cursor(dbutils.PlainStateBucket)
+		if err := c.Put(account, []byte{1}); err != nil {
+			return err
+		}
+		return c.Put(storageKey, []byte{2})
+	})
+	require.NoError(t, err)
+
+	mainDB := NewLMDB().InMem().MustOpen()
+	kv := NewSnapshotKV().For(dbutils.HeaderPrefix).For(dbutils.PlainStateBucket).SnapshotDB(sn).DB(mainDB).MustOpen()
+	db := NewObjectDatabase(kv)
+	defer db.Close()
+
+	require.NoError(t, db.Delete(dbutils.HeaderPrefix, dbutils.HeaderKey(2, common.Hash{}), nil))
+	require.NoError(t, db.Delete(dbutils.PlainStateBucket, storageKey, nil))
+
+	_, err = db.Get(dbutils.HeaderPrefix, dbutils.HeaderKey(2, common.Hash{}))
+	require.True(t, errors.Is(err, ErrKeyNotFound))
+	has, err := db.Has(dbutils.HeaderPrefix, dbutils.HeaderKey(2, common.Hash{}))
+	require.NoError(t, err)
+	require.False(t, has)
+
+	var headers []byte
+	require.NoError(t, db.Walk(dbutils.HeaderPrefix, nil, 0, func(k, v []byte) (bool, error) {
+		headers = append(headers, v...)
+		return true, nil
+	}))
+	require.Equal(t, []byte{1, 3}, headers)
+
+	var state [][]byte
+	require.NoError(t, db.Walk(dbutils.PlainStateBucket, nil, 0, func(k, v []byte) (bool, error) {
+		state = append(state, common.CopyBytes(k))
+		return true, nil
+	}))
+	require.Equal(t, [][]byte{account}, state)
+
+	// the deleted entry is visible again after it is written to the main database
+	require.NoError(t, db.Put(dbutils.HeaderPrefix, dbutils.HeaderKey(2, common.Hash{}), []byte{22}))
+	v, err := db.Get(dbutils.HeaderPrefix, dbutils.HeaderKey(2, common.Hash{}))
+	require.NoError(t, err)
+	require.Equal(t, []byte{22}, v)
+}
+
+func TestSnapshotCursorPrefix(t *testing.T) {
+	sn := NewLMDB().InMem().MustOpen()
+	require.NoError(t, sn.Update(context.Background(), func(tx Tx) error {
+		c := tx.Cursor(dbutils.HeaderPrefix)
+		for _, k := range [][]byte{dbutils.HeaderKey(1, common.Hash{1}), dbutils.HeaderKey(2, common.Hash{1}), dbutils.HeaderKey(2, common.Hash{3}), dbutils.HeaderKey(4, common.Hash{1})} {
+			if err := c.Put(k, []byte{1}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	mainDB := NewLMDB().InMem().MustOpen()
+	kv := NewSnapshotKV().For(dbutils.HeaderPrefix).SnapshotDB(sn).DB(mainDB).MustOpen()
+	defer kv.Close()
+	require.NoError(t, kv.Update(context.Background(), func(tx Tx) error {
+		c := tx.Cursor(dbutils.HeaderPrefix)
+		for _, k := range [][]byte{dbutils.HeaderKey(2, common.Hash{2}), dbutils.HeaderKey(3, common.Hash{1})} {
+			if err := c.Put(k, []byte{2}); err != nil {
+				return err
+			}
+		}
+		return c.Delete(dbutils.HeaderKey(2, common.Hash{3}), nil)
+	}))
+
+	walk := func(c Cursor) (keys [][]byte) {
+		require.NoError(t, c.(*snapshotCursor).Walk(func(k, v []byte) (bool, error) {
+			keys = append(keys, common.CopyBytes(k))
+			return true, nil
+		}))
+		return keys
+	}
+	require.NoError(t, kv.View(context.Background(), func(tx Tx) error {
+		c := tx.Cursor(dbutils.HeaderPrefix).Prefetch(10).Prefix(dbutils.EncodeBlockNumber(2))
+		defer c.Close()
+		// entries of both sides with the prefix, the deleted entry of the snapshot is skipped
+		require.Equal(t, [][]byte{dbutils.HeaderKey(2, common.Hash{1}), dbutils.HeaderKey(2, common.Hash{2})}, walk(c))
+		k, _, err := c.Seek(dbutils.HeaderKey(2, common.Hash{2}))
+		require.NoError(t, err)
+		require.Equal(t, dbutils.HeaderKey(2, common.Hash{2}), k)
+		k, _, err = c.Next()
+		require.NoError(t, err)
+		require.Nil(t, k)
+		_, _, err = c.Last()
+		require.Error(t, err)
+
+		// blocks 2 and 3 differ in the last bit only
+		c = tx.Cursor(dbutils.HeaderPrefix).Prefix(dbutils.EncodeBlockNumber(2)).(*snapshotCursor).MatchBits(63)
+		defer c.Close()
+		require.Equal(t, [][]byte{dbutils.HeaderKey(2, common.Hash{1}), dbutils.HeaderKey(2, common.Hash{2}), dbutils.HeaderKey(3, common.Hash{1})}, walk(c))
+
+		// the walk stops when the walker returns false
+		c = tx.Cursor(dbutils.HeaderPrefix)
+		defer c.Close()
+		var n int
+		require.NoError(t, c.(*snapshotCursor).Walk(func(k, v []byte) (bool, error) {
+			n++
+			return n < 2, nil
+		}))
+		require.Equal(t, 2, n)
+		require.Len(t, walk(c), 5)
+		return nil
+	}))
+}
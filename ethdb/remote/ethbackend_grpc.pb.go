@@ -20,6 +20,7 @@ type ETHBACKENDClient interface {
 	Add(ctx context.Context, in *TxRequest, opts ...grpc.CallOption) (*AddReply, error)
 	Etherbase(ctx context.Context, in *EtherbaseRequest, opts ...grpc.CallOption) (*EtherbaseReply, error)
 	NetVersion(ctx context.Context, in *NetVersionRequest, opts ...grpc.CallOption) (*NetVersionReply, error)
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (ETHBACKEND_SubscribeClient, error)
 }
 
 type eTHBACKENDClient struct {
@@ -57,6 +58,38 @@ func (c *eTHBACKENDClient) NetVersion(ctx context.Context, in *NetVersionRequest
 	return out, nil
 }
 
+func (c *eTHBACKENDClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (ETHBACKEND_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_ETHBACKEND_serviceDesc.Streams[0], "/remote.ETHBACKEND/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eTHBACKENDSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ETHBACKEND_SubscribeClient interface {
+	Recv() (*SubscribeReply, error)
+	grpc.ClientStream
+}
+
+type eTHBACKENDSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *eTHBACKENDSubscribeClient) Recv() (*SubscribeReply, error) {
+	m := new(SubscribeReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // ETHBACKENDServer is the server API for ETHBACKEND service.
 // All implementations must embed UnimplementedETHBACKENDServer
 // for forward compatibility
@@ -64,6 +97,7 @@ type ETHBACKENDServer interface {
 	Add(context.Context, *TxRequest) (*AddReply, error)
 	Etherbase(context.Context, *EtherbaseRequest) (*EtherbaseReply, error)
 	NetVersion(context.Context, *NetVersionRequest) (*NetVersionReply, error)
+	Subscribe(*SubscribeRequest, ETHBACKEND_SubscribeServer) error
 	mustEmbedUnimplementedETHBACKENDServer()
 }
 
@@ -80,6 +114,9 @@ func (UnimplementedETHBACKENDServer) Etherbase(context.Context, *EtherbaseReques
 func (UnimplementedETHBACKENDServer) NetVersion(context.Context, *NetVersionRequest) (*NetVersionReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method NetVersion not implemented")
 }
+func (UnimplementedETHBACKENDServer) Subscribe(*SubscribeRequest, ETHBACKEND_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
 func (UnimplementedETHBACKENDServer) mustEmbedUnimplementedETHBACKENDServer() {}
 
 // UnsafeETHBACKENDServer may be embedded to opt out of forward compatibility for this service.
@@ -147,6 +184,27 @@ func _ETHBACKEND_NetVersion_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ETHBACKEND_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ETHBACKENDServer).Subscribe(m, &eTHBACKENDSubscribeServer{stream})
+}
+
+type ETHBACKEND_SubscribeServer interface {
+	Send(*SubscribeReply) error
+	grpc.ServerStream
+}
+
+type eTHBACKENDSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *eTHBACKENDSubscribeServer) Send(m *SubscribeReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _ETHBACKEND_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "remote.ETHBACKEND",
 	HandlerType: (*ETHBACKENDServer)(nil),
@@ -164,6 +222,12 @@ var _ETHBACKEND_serviceDesc = grpc.ServiceDesc{
 			Handler:    _ETHBACKEND_NetVersion_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _ETHBACKEND_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "remote/ethbackend.proto",
 }
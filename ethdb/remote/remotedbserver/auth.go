@@ -0,0 +1,214 @@
+package remotedbserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/ledgerwatch/turbo-geth/ethdb/remote"
+	"github.com/ledgerwatch/turbo-geth/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ClientPolicy - identity and permissions of one client of the private API.
+// Client is identified by CommonName of its verified TLS certificate (requires --tls.cacert)
+// or by token sent in "authorization: Bearer <token>" metadata.
+type ClientPolicy struct {
+	Name    string   `json:"name"`
+	CertCN  string   `json:"certCN"`
+	Token   string   `json:"token"`
+	Buckets []string `json:"buckets"` // buckets allowed to read, empty list - all buckets
+	SendTxs bool     `json:"sendTxs"` // allows to add transactions to the pool by ETHBACKEND.Add
+
+	buckets map[string]struct{}
+}
+
+// CanReadBucket - nil policy means that authentication is disabled and everything readable
+func (p *ClientPolicy) CanReadBucket(name string) bool {
+	if p == nil || p.buckets == nil {
+		return true
+	}
+	_, ok := p.buckets[name]
+	return ok
+}
+
+func (p *ClientPolicy) CanSendTxs() bool {
+	return p == nil || p.SendTxs
+}
+
+func (p *ClientPolicy) String() string {
+	if p == nil {
+		return "anonymous"
+	}
+	return p.Name
+}
+
+// Auth - authenticates clients of the private API, example of config file:
+//
+//	{"clients": [
+//	  {"name": "rpcdaemon-1", "certCN": "rpc1.example.com", "buckets": ["PLAIN-CST2", "h"]},
+//	  {"name": "rpcdaemon-2", "token": "secret", "sendTxs": true}
+//	]}
+type Auth struct {
+	byCN    map[string]*ClientPolicy
+	byToken []*ClientPolicy
+}
+
+type authConfig struct {
+	Clients []*ClientPolicy `json:"clients"`
+}
+
+func LoadAuth(path string) (*Auth, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg authConfig
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return NewAuth(cfg.Clients)
+}
+
+func NewAuth(clients []*ClientPolicy) (*Auth, error) {
+	a := &Auth{byCN: map[string]*ClientPolicy{}}
+	names := map[string]struct{}{}
+	for _, c := range clients {
+		if c.Name == "" {
+			return nil, fmt.Errorf("client without name")
+		}
+		if _, ok := names[c.Name]; ok {
+			return nil, fmt.Errorf("duplicated client name: %s", c.Name)
+		}
+		names[c.Name] = struct{}{}
+		if c.CertCN == "" && c.Token == "" {
+			return nil, fmt.Errorf("client %s has neither certCN nor token", c.Name)
+		}
+		if c.CertCN != "" {
+			if _, ok := a.byCN[c.CertCN]; ok {
+				return nil, fmt.Errorf("duplicated certCN: %s", c.CertCN)
+			}
+			a.byCN[c.CertCN] = c
+		}
+		if c.Token != "" {
+			a.byToken = append(a.byToken, c)
+		}
+		if len(c.Buckets) > 0 {
+			c.buckets = make(map[string]struct{}, len(c.Buckets))
+			for _, b := range c.Buckets {
+				c.buckets[b] = struct{}{}
+			}
+		}
+	}
+	return a, nil
+}
+
+type clientKey struct{}
+
+// ClientFromContext - returns policy of the authenticated client, nil if authentication is disabled
+func ClientFromContext(ctx context.Context) *ClientPolicy {
+	p, _ := ctx.Value(clientKey{}).(*ClientPolicy)
+	return p
+}
+
+func (a *Auth) authenticate(ctx context.Context) (*ClientPolicy, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, h := range md.Get("authorization") {
+			token := strings.TrimPrefix(h, "Bearer ")
+			for _, c := range a.byToken {
+				if subtle.ConstantTimeCompare([]byte(c.Token), []byte(token)) == 1 {
+					return c, nil
+				}
+			}
+			return nil, fmt.Errorf("unknown token")
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no peer info")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, fmt.Errorf("neither token nor verified client certificate provided")
+	}
+	cn := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+	if c, ok := a.byCN[cn]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown certificate CN: %s", cn)
+}
+
+func (a *Auth) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		client, err := a.authenticate(ss.Context())
+		if err != nil {
+			return unauthenticated(ss.Context(), info.FullMethod, err)
+		}
+		wrapped := grpc_middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = context.WithValue(ss.Context(), clientKey{}, client)
+		return handler(srv, wrapped)
+	}
+}
+
+func (a *Auth) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		client, err := a.authenticate(ctx)
+		if err != nil {
+			return nil, unauthenticated(ctx, info.FullMethod, err)
+		}
+		return handler(context.WithValue(ctx, clientKey{}, client), req)
+	}
+}
+
+func unauthenticated(ctx context.Context, method string, reason error) error {
+	log.Warn("Private API: authentication failed", "peer", peerAddr(ctx), "method", method, "reason", reason)
+	return status.Error(codes.Unauthenticated, "authentication failed")
+}
+
+// denied - logs and returns error for the operation which client is not allowed to do
+func denied(ctx context.Context, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	log.Warn("Private API: access denied", "client", ClientFromContext(ctx), "peer", peerAddr(ctx), "reason", msg)
+	return status.Error(codes.PermissionDenied, msg)
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// readOps - the only operations allowed by KV.Tx, server never opens write transactions
+var readOps = map[remote.Op]struct{}{
+	remote.Op_FIRST:           {},
+	remote.Op_FIRST_DUP:       {},
+	remote.Op_SEEK:            {},
+	remote.Op_SEEK_BOTH:       {},
+	remote.Op_CURRENT:         {},
+	remote.Op_GET_MULTIPLE:    {},
+	remote.Op_LAST:            {},
+	remote.Op_LAST_DUP:        {},
+	remote.Op_NEXT:            {},
+	remote.Op_NEXT_DUP:        {},
+	remote.Op_NEXT_MULTIPLE:   {},
+	remote.Op_NEXT_NO_DUP:     {},
+	remote.Op_PREV:            {},
+	remote.Op_PREV_DUP:        {},
+	remote.Op_PREV_NO_DUP:     {},
+	remote.Op_SEEK_EXACT:      {},
+	remote.Op_SEEK_BOTH_EXACT: {},
+	remote.Op_RANGE:           {},
+	remote.Op_OPEN:            {},
+	remote.Op_CLOSE:           {},
+}
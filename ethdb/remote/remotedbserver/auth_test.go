@@ -0,0 +1,239 @@
+package remotedbserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/ethdb/remote"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var (
+	plainBucket = dbutils.Buckets[0]
+	dupBucket   = dbutils.Buckets[1]
+)
+
+func bucketsConfig(dbutils.BucketsCfg) dbutils.BucketsCfg {
+	return dbutils.BucketsCfg{
+		plainBucket: {},
+		dupBucket:   {Flags: dbutils.DupSort},
+	}
+}
+
+// startServer serves KV and DB over in-memory connection, with auth if it's not nil
+func startServer(t *testing.T, auth *Auth) (*bufconn.Listener, func()) {
+	kv := ethdb.NewLMDB().InMem().WithBucketsConfig(bucketsConfig).MustOpen()
+	require.NoError(t, kv.Update(context.Background(), func(tx ethdb.Tx) error {
+		for _, kv := range [][2]string{{"a", "1"}, {"b", "2"}} {
+			if err := tx.Cursor(plainBucket).Put([]byte(kv[0]), []byte(kv[1])); err != nil {
+				return err
+			}
+		}
+		for _, kv := range [][2]string{{"a", "1"}, {"a", "2"}, {"a", "3"}, {"b", "1"}, {"b", "2"}} {
+			if err := tx.Cursor(dupBucket).Put([]byte(kv[0]), []byte(kv[1])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	var opts []grpc.ServerOption
+	if auth != nil {
+		opts = append(opts, grpc.StreamInterceptor(auth.StreamServerInterceptor()), grpc.UnaryInterceptor(auth.UnaryServerInterceptor()))
+	}
+	conn := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer(opts...)
+	remote.RegisterKVServer(grpcServer, NewKvServer(kv))
+	remote.RegisterDBServer(grpcServer, NewDBServer(kv))
+	go grpcServer.Serve(conn) //nolint:errcheck
+	return conn, func() {
+		grpcServer.Stop()
+		conn.Close()
+		kv.Close()
+	}
+}
+
+func openRemote(t *testing.T, conn *bufconn.Listener, token string) ethdb.KV {
+	opts := ethdb.NewRemote().InMem(conn).WithBucketsConfig(bucketsConfig)
+	if token != "" {
+		opts = opts.WithToken(token)
+	}
+	kv, _, err := opts.Open("", "", "")
+	require.NoError(t, err)
+	return kv
+}
+
+func readFirst(kv ethdb.KV, bucket string) (k []byte, err error) {
+	err = kv.View(context.Background(), func(tx ethdb.Tx) error {
+		k, _, err = tx.Cursor(bucket).First()
+		return err
+	})
+	return k, err
+}
+
+func TestNewAuth(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		clients []*ClientPolicy
+		err     string
+	}{
+		{"no name", []*ClientPolicy{{Token: "t"}}, "client without name"},
+		{"duplicated name", []*ClientPolicy{{Name: "a", Token: "t1"}, {Name: "a", Token: "t2"}}, "duplicated client name: a"},
+		{"no credentials", []*ClientPolicy{{Name: "a"}}, "client a has neither certCN nor token"},
+		{"duplicated CN", []*ClientPolicy{{Name: "a", CertCN: "cn"}, {Name: "b", CertCN: "cn"}}, "duplicated certCN: cn"},
+	} {
+		_, err := NewAuth(tc.clients)
+		require.EqualError(t, err, tc.err, tc.name)
+	}
+
+	auth, err := NewAuth([]*ClientPolicy{
+		{Name: "a", CertCN: "cn", Buckets: []string{plainBucket}},
+		{Name: "b", Token: "t", SendTxs: true},
+	})
+	require.NoError(t, err)
+	a := auth.byCN["cn"]
+	require.True(t, a.CanReadBucket(plainBucket))
+	require.False(t, a.CanReadBucket(dupBucket))
+	require.False(t, a.CanSendTxs())
+	b := auth.byToken[0]
+	require.True(t, b.CanReadBucket(dupBucket))
+	require.True(t, b.CanSendTxs())
+
+	var anonymous *ClientPolicy
+	require.True(t, anonymous.CanReadBucket(dupBucket))
+	require.True(t, anonymous.CanSendTxs())
+}
+
+func TestLoadAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "auth.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"clients": [
+		{"name": "rpcdaemon-1", "certCN": "rpc1.example.com", "buckets": ["PLAIN-CST2", "h"]},
+		{"name": "rpcdaemon-2", "token": "secret", "sendTxs": true}
+	]}`), 0600))
+	auth, err := LoadAuth(path)
+	require.NoError(t, err)
+	require.Equal(t, "rpcdaemon-1", auth.byCN["rpc1.example.com"].Name)
+	require.Equal(t, []string{"PLAIN-CST2", "h"}, auth.byCN["rpc1.example.com"].Buckets)
+	require.Equal(t, "rpcdaemon-2", auth.byToken[0].Name)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"clients": [`), 0600))
+	_, err = LoadAuth(path)
+	require.Error(t, err)
+}
+
+func TestAuthenticate(t *testing.T) {
+	auth, err := NewAuth([]*ClientPolicy{
+		{Name: "by-cn", CertCN: "rpc1.example.com"},
+		{Name: "by-token", Token: "secret"},
+	})
+	require.NoError(t, err)
+
+	withToken := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+	withCN := func(cn string) context.Context {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		state := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+	}
+
+	client, err := auth.authenticate(withToken("secret"))
+	require.NoError(t, err)
+	require.Equal(t, "by-token", client.Name)
+	_, err = auth.authenticate(withToken("wrong"))
+	require.EqualError(t, err, "unknown token")
+
+	client, err = auth.authenticate(withCN("rpc1.example.com"))
+	require.NoError(t, err)
+	require.Equal(t, "by-cn", client.Name)
+	_, err = auth.authenticate(withCN("rpc2.example.com"))
+	require.EqualError(t, err, "unknown certificate CN: rpc2.example.com")
+
+	_, err = auth.authenticate(peer.NewContext(context.Background(), &peer.Peer{}))
+	require.EqualError(t, err, "neither token nor verified client certificate provided")
+	_, err = auth.authenticate(context.Background())
+	require.EqualError(t, err, "no peer info")
+}
+
+func TestInterceptors(t *testing.T) {
+	auth, err := NewAuth([]*ClientPolicy{
+		{Name: "all", Token: "all-secret"},
+		{Name: "plain-only", Token: "plain-secret", Buckets: []string{plainBucket}},
+	})
+	require.NoError(t, err)
+	conn, stop := startServer(t, auth)
+	defer stop()
+
+	all := openRemote(t, conn, "all-secret")
+	defer all.Close()
+	k, err := readFirst(all, dupBucket)
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), k)
+
+	plainOnly := openRemote(t, conn, "plain-secret")
+	defer plainOnly.Close()
+	k, err = readFirst(plainOnly, plainBucket)
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), k)
+	_, err = readFirst(plainOnly, dupBucket)
+	require.Equal(t, codes.PermissionDenied, status.Code(err), err)
+
+	// unary calls go through the same policy
+	require.NoError(t, plainOnly.View(context.Background(), func(tx ethdb.Tx) error {
+		_, err = tx.BucketSize(dupBucket)
+		require.Equal(t, codes.PermissionDenied, status.Code(err), err)
+		_, err = tx.BucketSize(plainBucket)
+		return err
+	}))
+
+	for _, token := range []string{"wrong", ""} {
+		unknown := openRemote(t, conn, token)
+		_, err = readFirst(unknown, plainBucket)
+		require.Equal(t, codes.Unauthenticated, status.Code(err), err)
+		unknown.Close()
+	}
+}
+
+// TestReadOps - all read operations of the remote cursors are allowed, also when authentication is disabled
+func TestReadOps(t *testing.T) {
+	conn, stop := startServer(t, nil)
+	defer stop()
+	kv := openRemote(t, conn, "")
+	defer kv.Close()
+
+	require.NoError(t, kv.View(context.Background(), func(tx ethdb.Tx) error {
+		c := tx.CursorDupSort(dupBucket)
+		prev := c.(interface {
+			PrevDup() ([]byte, []byte, error)
+			PrevNoDup() ([]byte, []byte, error)
+		})
+		k, v, err := c.Last()
+		require.NoError(t, err)
+		require.Equal(t, "b2", string(k)+string(v))
+		k, v, err = prev.PrevDup()
+		require.NoError(t, err)
+		require.Equal(t, "b1", string(k)+string(v))
+		k, v, err = prev.PrevNoDup()
+		require.NoError(t, err)
+		require.Equal(t, "a3", string(k)+string(v))
+		return nil
+	}))
+}
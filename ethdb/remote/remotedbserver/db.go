@@ -26,6 +26,9 @@ func (s *DBServer) Size(ctx context.Context, in *remote.SizeRequest) (*remote.Si
 }
 
 func (s *DBServer) BucketSize(ctx context.Context, in *remote.BucketSizeRequest) (*remote.BucketSizeReply, error) {
+	if !ClientFromContext(ctx).CanReadBucket(in.BucketName) {
+		return nil, denied(ctx, "bucket %s is not allowed", in.BucketName)
+	}
 	out := &remote.BucketSizeReply{}
 	if err := s.kv.View(ctx, func(tx ethdb.Tx) error {
 		sz, err := tx.BucketSize(in.BucketName)
@@ -2,6 +2,9 @@ package remotedbserver
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"sync"
 
 	"github.com/ledgerwatch/turbo-geth/common"
 	"github.com/ledgerwatch/turbo-geth/core"
@@ -13,16 +16,20 @@ import (
 type EthBackendServer struct {
 	remote.UnimplementedETHBACKENDServer // must be embedded to have forward compatible implementations.
 
-	eth core.Backend
+	eth    core.Backend
+	events *Events
 }
 
-func NewEthBackendServer(eth core.Backend) *EthBackendServer {
-	return &EthBackendServer{eth: eth}
+func NewEthBackendServer(eth core.Backend, events *Events) *EthBackendServer {
+	return &EthBackendServer{eth: eth, events: events}
 }
 
-func (s *EthBackendServer) Add(_ context.Context, in *remote.TxRequest) (*remote.AddReply, error) {
+func (s *EthBackendServer) Add(ctx context.Context, in *remote.TxRequest) (*remote.AddReply, error) {
 	signedTx := new(types.Transaction)
 	out := &remote.AddReply{Hash: common.Hash{}.Bytes()}
+	if !ClientFromContext(ctx).CanSendTxs() {
+		return out, denied(ctx, "sending transactions is not allowed")
+	}
 
 	if err := rlp.DecodeBytes(in.Signedtx, signedTx); err != nil {
 		return out, err
@@ -55,3 +62,74 @@ func (s *EthBackendServer) NetVersion(_ context.Context, _ *remote.NetVersionReq
 	}
 	return &remote.NetVersionReply{Id: id}, nil
 }
+
+// txChanSize is the size of channel listening to NewTxsEvent.
+const txChanSize = 4096
+
+// eventsChanSize is the size of the queue of the chain events of one subscriber. The subscriber which doesn't
+// receive them fast enough is disconnected, not to delay the sync, which produces them.
+const eventsChanSize = 4096
+
+var errSlowSubscriber = errors.New("subscriber is too slow to receive chain events")
+
+func (s *EthBackendServer) Subscribe(_ *remote.SubscribeRequest, subscribeServer remote.ETHBACKEND_SubscribeServer) error {
+	// chain events are produced by staged sync, and are only queued by it, because the stream is not safe for
+	// concurrent sends, and the sync must not wait for the client - everything is sent by the loop below
+	eventsCh := make(chan *remote.SubscribeReply, eventsChanSize)
+	slow := make(chan struct{})
+	var slowOnce sync.Once
+	enqueue := func(event remote.Event, data []byte) error {
+		select {
+		case eventsCh <- &remote.SubscribeReply{Type: event, Data: data}:
+			return nil
+		default:
+			slowOnce.Do(func() { close(slow) })
+			return errSlowSubscriber
+		}
+	}
+
+	if s.events != nil {
+		headersID := s.events.AddHeaderSubscription(func(h *types.Header) error {
+			payload, err := rlp.EncodeToBytes(h)
+			if err != nil {
+				return err
+			}
+			return enqueue(remote.Event_HEADER, payload)
+		})
+		defer s.events.Unsubscribe(headersID)
+
+		logsID := s.events.AddLogsSubscription(func(logs types.Logs) error {
+			payload, err := json.Marshal(logs)
+			if err != nil {
+				return err
+			}
+			return enqueue(remote.Event_LOGS, payload)
+		})
+		defer s.events.Unsubscribe(logsID)
+	}
+
+	txsCh := make(chan core.NewTxsEvent, txChanSize)
+	txsSub := s.eth.TxPool().SubscribeNewTxsEvent(txsCh)
+	defer txsSub.Unsubscribe()
+
+	for {
+		select {
+		case reply := <-eventsCh:
+			if err := subscribeServer.Send(reply); err != nil {
+				return err
+			}
+		case e := <-txsCh:
+			for _, tx := range e.Txs {
+				if err := subscribeServer.Send(&remote.SubscribeReply{Type: remote.Event_PENDING_TX, Data: tx.Hash().Bytes()}); err != nil {
+					return err
+				}
+			}
+		case <-slow:
+			return errSlowSubscriber
+		case err := <-txsSub.Err():
+			return err
+		case <-subscribeServer.Context().Done():
+			return subscribeServer.Context().Err()
+		}
+	}
+}
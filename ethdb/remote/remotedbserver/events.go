@@ -0,0 +1,90 @@
+package remotedbserver
+
+import (
+	"sync"
+
+	"github.com/ledgerwatch/turbo-geth/core/types"
+)
+
+type HeaderSubscription func(*types.Header) error
+type LogsSubscription func(types.Logs) error
+
+// Events manages the subscriptions of the remote clients to the chain events
+type Events struct {
+	id                  int
+	headerSubscriptions map[int]HeaderSubscription
+	logsSubscriptions   map[int]LogsSubscription
+	lock                sync.Mutex
+}
+
+func NewEvents() *Events {
+	return &Events{
+		headerSubscriptions: map[int]HeaderSubscription{},
+		logsSubscriptions:   map[int]LogsSubscription{},
+	}
+}
+
+// AddHeaderSubscription registers a callback for new canonical headers, it returns the subscription id
+func (e *Events) AddHeaderSubscription(s HeaderSubscription) int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.id++
+	e.headerSubscriptions[e.id] = s
+	return e.id
+}
+
+// AddLogsSubscription registers a callback for logs of new canonical blocks, it returns the subscription id
+func (e *Events) AddLogsSubscription(s LogsSubscription) int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.id++
+	e.logsSubscriptions[e.id] = s
+	return e.id
+}
+
+// Unsubscribe removes the subscription with the given id
+func (e *Events) Unsubscribe(id int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	delete(e.headerSubscriptions, id)
+	delete(e.logsSubscriptions, id)
+}
+
+// HasLogsSubscriptions implements stagedsync.ChainEventNotifier, logs are not read if nobody is subscribed to them
+func (e *Events) HasLogsSubscriptions() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return len(e.logsSubscriptions) > 0
+}
+
+// OnNewHeader implements stagedsync.ChainEventNotifier, a failing subscriber gets unsubscribed.
+// The subscribers are called without holding the lock, so they must not block: a slow one would delay the others
+// and the sync, see EthBackendServer.Subscribe.
+func (e *Events) OnNewHeader(header *types.Header) {
+	e.lock.Lock()
+	subs := make(map[int]HeaderSubscription, len(e.headerSubscriptions))
+	for id, sub := range e.headerSubscriptions {
+		subs[id] = sub
+	}
+	e.lock.Unlock()
+	for id, sub := range subs {
+		if err := sub(header); err != nil {
+			e.Unsubscribe(id)
+		}
+	}
+}
+
+// OnNewLogs implements stagedsync.ChainEventNotifier, a failing subscriber gets unsubscribed
+func (e *Events) OnNewLogs(logs types.Logs) {
+	e.lock.Lock()
+	subs := make(map[int]LogsSubscription, len(e.logsSubscriptions))
+	for id, sub := range e.logsSubscriptions {
+		subs[id] = sub
+	}
+	e.lock.Unlock()
+	for id, sub := range subs {
+		if err := sub(logs); err != nil {
+			e.Unsubscribe(id)
+		}
+	}
+}
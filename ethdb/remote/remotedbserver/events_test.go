@@ -0,0 +1,67 @@
+package remotedbserver
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents(t *testing.T) {
+	e := NewEvents()
+	require.False(t, e.HasLogsSubscriptions())
+
+	var headers []uint64
+	e.AddHeaderSubscription(func(h *types.Header) error {
+		headers = append(headers, h.Number.Uint64())
+		return nil
+	})
+	failing := 0
+	e.AddHeaderSubscription(func(h *types.Header) error {
+		failing++
+		return errors.New("stream is broken")
+	})
+	var logs types.Logs
+	logsID := e.AddLogsSubscription(func(l types.Logs) error {
+		logs = append(logs, l...)
+		return nil
+	})
+	require.True(t, e.HasLogsSubscriptions())
+
+	e.OnNewHeader(&types.Header{Number: big.NewInt(1)})
+	e.OnNewHeader(&types.Header{Number: big.NewInt(2)})
+	e.OnNewLogs(types.Logs{{BlockNumber: 2}})
+	require.Equal(t, []uint64{1, 2}, headers)
+	require.Equal(t, 1, failing, "failing subscriber is unsubscribed")
+	require.Equal(t, types.Logs{{BlockNumber: 2}}, logs)
+
+	e.Unsubscribe(logsID)
+	require.False(t, e.HasLogsSubscriptions())
+	e.OnNewLogs(types.Logs{{BlockNumber: 3}})
+	require.Len(t, logs, 1)
+}
+
+// TestEventsSubscriberWithoutLock - subscribers are called without holding the lock of the subscriptions
+func TestEventsSubscriberWithoutLock(t *testing.T) {
+	e := NewEvents()
+	var id int
+	calls := 0
+	id = e.AddHeaderSubscription(func(h *types.Header) error {
+		calls++
+		// e.g. the stream is closed by the client meanwhile
+		e.Unsubscribe(id)
+		e.AddLogsSubscription(func(types.Logs) error { return nil })
+		return nil
+	})
+	done := make(chan struct{})
+	go func() {
+		e.OnNewHeader(&types.Header{Number: big.NewInt(1)})
+		e.OnNewHeader(&types.Header{Number: big.NewInt(2)})
+		close(done)
+	}()
+	<-done
+	require.Equal(t, 1, calls)
+	require.True(t, e.HasLogsSubscriptions())
+}
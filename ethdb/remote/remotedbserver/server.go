@@ -1,6 +1,7 @@
 package remotedbserver
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"net"
@@ -23,13 +24,17 @@ import (
 
 const MaxTxTTL = 30 * time.Second
 
+// RangePageSize - max amount of pairs in one message of RANGE response
+const RangePageSize = 256
+
 type KvServer struct {
 	remote.UnimplementedKVServer // must be embedded to have forward compatible implementations.
 
 	kv ethdb.KV
 }
 
-func StartGrpc(kv ethdb.KV, eth core.Backend, addr string, creds *credentials.TransportCredentials) (*grpc.Server, error) {
+// StartGrpc - starts private API server, if auth is not nil - only clients known by auth are served
+func StartGrpc(kv ethdb.KV, eth core.Backend, events *Events, addr string, creds *credentials.TransportCredentials, auth *Auth) (*grpc.Server, error) {
 	log.Info("Starting private RPC server", "on", addr)
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -38,7 +43,7 @@ func StartGrpc(kv ethdb.KV, eth core.Backend, addr string, creds *credentials.Tr
 
 	kv2Srv := NewKvServer(kv)
 	dbSrv := NewDBServer(kv)
-	ethBackendSrv := NewEthBackendServer(eth)
+	ethBackendSrv := NewEthBackendServer(eth, events)
 	var (
 		streamInterceptors []grpc.StreamServerInterceptor
 		unaryInterceptors  []grpc.UnaryServerInterceptor
@@ -49,6 +54,10 @@ func StartGrpc(kv ethdb.KV, eth core.Backend, addr string, creds *credentials.Tr
 	}
 	streamInterceptors = append(streamInterceptors, grpc_recovery.StreamServerInterceptor())
 	unaryInterceptors = append(unaryInterceptors, grpc_recovery.UnaryServerInterceptor())
+	if auth != nil {
+		streamInterceptors = append(streamInterceptors, auth.StreamServerInterceptor())
+		unaryInterceptors = append(unaryInterceptors, auth.UnaryServerInterceptor())
+	}
 	var grpcServer *grpc.Server
 	cpus := uint32(runtime.GOMAXPROCS(-1))
 	opts := []grpc.ServerOption{
@@ -119,6 +128,12 @@ func (s *KvServer) Tx(stream remote.KV_TxServer) error {
 			}
 			return fmt.Errorf("server-side error: %w", recvErr)
 		}
+		if _, ok := readOps[in.Op]; !ok {
+			return denied(stream.Context(), "operation %s is not allowed", in.Op)
+		}
+		if in.Op == remote.Op_OPEN && !ClientFromContext(stream.Context()).CanReadBucket(in.BucketName) {
+			return denied(stream.Context(), "bucket %s is not allowed", in.BucketName)
+		}
 
 		//TODO: protect against client - which doesn't send any requests
 		select {
@@ -199,6 +214,11 @@ func (s *KvServer) Tx(stream remote.KV_TxServer) error {
 				return fmt.Errorf("server-side error: %w", err)
 			}
 			continue
+		case remote.Op_RANGE:
+			if err := handleRange(c, stream, in); err != nil {
+				return fmt.Errorf("server-side error: %w", err)
+			}
+			continue
 		default:
 		}
 
@@ -208,6 +228,12 @@ func (s *KvServer) Tx(stream remote.KV_TxServer) error {
 	}
 }
 
+// prevDupCursor - the DupSort cursors of LMDB and MDBX implement it, but ethdb.CursorDupSort doesn't require it
+type prevDupCursor interface {
+	PrevDup() ([]byte, []byte, error)
+	PrevNoDup() ([]byte, []byte, error)
+}
+
 func handleOp(c ethdb.Cursor, stream remote.KV_TxServer, in *remote.Cursor) error {
 	var k, v []byte
 	var err error
@@ -238,16 +264,10 @@ func handleOp(c ethdb.Cursor, stream remote.KV_TxServer, in *remote.Cursor) erro
 		k, v, err = c.(ethdb.CursorDupSort).NextNoDup()
 	case remote.Op_PREV:
 		k, v, err = c.Prev()
-	//case remote.Op_PREV_DUP:
-	//	k, v, err = c.(ethdb.CursorDupSort).Prev()
-	//	if err != nil {
-	//		return err
-	//	}
-	//case remote.Op_PREV_NO_DUP:
-	//	k, v, err = c.Prev()
-	//	if err != nil {
-	//		return err
-	//	}
+	case remote.Op_PREV_DUP:
+		k, v, err = c.(prevDupCursor).PrevDup()
+	case remote.Op_PREV_NO_DUP:
+		k, v, err = c.(prevDupCursor).PrevNoDup()
 	case remote.Op_SEEK_EXACT:
 		v, err = c.SeekExact(in.K)
 	case remote.Op_SEEK_BOTH_EXACT:
@@ -265,3 +285,65 @@ func handleOp(c ethdb.Cursor, stream remote.KV_TxServer, in *remote.Cursor) erro
 
 	return nil
 }
+
+// handleRange walks the cursor starting from in.K (or from the next key if in.K is empty)
+// and streams the pairs in pages of RangePageSize, the last page is always empty.
+// Only the pairs which key has in.FilterPrefix at in.FilterOffset are sent, others are skipped.
+// Walk stops after in.Limit pairs were sent - then the cursor stays on the last sent pair, so next RANGE request
+// without K continues from it. Otherwise walk stops at the first key out of in.Prefix, at the first key >= in.EndK,
+// or at the end of the bucket - then the cursor is past the last sent pair, and the client must re-position it
+// (see remoteCursor.syncRange).
+func handleRange(c ethdb.Cursor, stream remote.KV_TxServer, in *remote.Cursor) error {
+	var k, v []byte
+	var err error
+	if len(in.K) > 0 {
+		k, v, err = c.Seek(in.K)
+	} else {
+		k, v, err = c.Next()
+	}
+
+	page := &remote.Pair{}
+	var sent uint32
+	for ; k != nil; k, v, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if len(in.Prefix) > 0 && !bytes.HasPrefix(k, in.Prefix) {
+			break
+		}
+		if len(in.EndK) > 0 && bytes.Compare(k, in.EndK) >= 0 {
+			break
+		}
+		if len(in.FilterPrefix) > 0 && !hasPrefixAt(k, in.FilterPrefix, in.FilterOffset) {
+			continue
+		}
+
+		page.Ks = append(page.Ks, common.CopyBytes(k))
+		page.Vs = append(page.Vs, common.CopyBytes(v))
+		sent++
+		if len(page.Ks) == RangePageSize {
+			if err = stream.Send(page); err != nil {
+				return err
+			}
+			page = &remote.Pair{}
+		}
+		if in.Limit > 0 && sent >= in.Limit {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(page.Ks) > 0 {
+		if err = stream.Send(page); err != nil {
+			return err
+		}
+	}
+	return stream.Send(&remote.Pair{})
+}
+
+// hasPrefixAt - key has the prefix at the offset
+func hasPrefixAt(k, prefix []byte, offset uint32) bool {
+	return uint32(len(k)) >= offset && bytes.HasPrefix(k[offset:], prefix)
+}
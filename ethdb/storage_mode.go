@@ -1,10 +1,14 @@
 package ethdb
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/params"
 )
 
 type StorageMode struct {
@@ -12,6 +16,88 @@ type StorageMode struct {
 	Receipts   bool
 	TxIndex    bool
 	CallTraces bool
+	Witnesses  bool
+	BinaryTrie bool
+	Prune      PruneMode
+}
+
+// PruneMode - amount of recent blocks for which node keeps each type of data, 0 - keeps forever.
+// Data of older blocks is removed by staged sync.
+type PruneMode struct {
+	History    uint64 // history indices and change sets
+	Receipts   uint64 // receipts, logs and logs indices
+	TxIndex    uint64
+	CallTraces uint64
+	Witnesses  uint64
+}
+
+// MinPruneDistance - data needed to unwind the chain must be kept
+const MinPruneDistance = params.FullImmutabilityThreshold
+
+// PruneTo - returns block before which data can be removed, if head is far enough from genesis
+func PruneTo(distance uint64, head uint64) (uint64, bool) {
+	if distance == 0 || head <= distance {
+		return 0, false
+	}
+	return head - distance, true
+}
+
+func (m PruneMode) ToString() string {
+	var parts []string
+	if m.History > 0 {
+		parts = append(parts, fmt.Sprintf("h=%d", m.History))
+	}
+	if m.Receipts > 0 {
+		parts = append(parts, fmt.Sprintf("r=%d", m.Receipts))
+	}
+	if m.TxIndex > 0 {
+		parts = append(parts, fmt.Sprintf("t=%d", m.TxIndex))
+	}
+	if m.CallTraces > 0 {
+		parts = append(parts, fmt.Sprintf("c=%d", m.CallTraces))
+	}
+	if m.Witnesses > 0 {
+		parts = append(parts, fmt.Sprintf("w=%d", m.Witnesses))
+	}
+	return strings.Join(parts, ",")
+}
+
+// PruneModeFromString - parses comma separated list of `<data type>=<amount of blocks to keep>`,
+// data types are the same as for StorageModeFromString, for example: "h=90000,r=500000"
+func PruneModeFromString(flags string) (PruneMode, error) {
+	mode := PruneMode{}
+	if flags == "" {
+		return mode, nil
+	}
+	for _, part := range strings.Split(flags, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return mode, fmt.Errorf("expected <type>=<blocks>, got: %s", part)
+		}
+		distance, err := strconv.ParseUint(kv[1], 10, 64)
+		if err != nil {
+			return mode, fmt.Errorf("invalid amount of blocks for %s: %w", kv[0], err)
+		}
+		// witnesses are not needed to unwind the chain, so they can be pruned closer to the head
+		if distance > 0 && distance < MinPruneDistance && kv[0] != "w" {
+			return mode, fmt.Errorf("amount of blocks for %s must be at least %d, got: %d", kv[0], MinPruneDistance, distance)
+		}
+		switch kv[0] {
+		case "h":
+			mode.History = distance
+		case "r":
+			mode.Receipts = distance
+		case "t":
+			mode.TxIndex = distance
+		case "c":
+			mode.CallTraces = distance
+		case "w":
+			mode.Witnesses = distance
+		default:
+			return mode, fmt.Errorf("unexpected flag found: %s", kv[0])
+		}
+	}
+	return mode, nil
 }
 
 var DefaultStorageMode = StorageMode{History: true, Receipts: true, TxIndex: true, CallTraces: false}
@@ -30,6 +116,12 @@ func (m StorageMode) ToString() string {
 	if m.CallTraces {
 		modeString += "c"
 	}
+	if m.Witnesses {
+		modeString += "w"
+	}
+	if m.BinaryTrie {
+		modeString += "b"
+	}
 	return modeString
 }
 
@@ -45,6 +137,10 @@ func StorageModeFromString(flags string) (StorageMode, error) {
 			mode.TxIndex = true
 		case 'c':
 			mode.CallTraces = true
+		case 'w':
+			mode.Witnesses = true
+		case 'b':
+			mode.BinaryTrie = true
 		default:
 			return mode, fmt.Errorf("unexpected flag found: %c", flag)
 		}
@@ -83,9 +179,48 @@ func GetStorageModeFromDB(db Database) (StorageMode, error) {
 	}
 	sm.CallTraces = len(v) == 1 && v[0] == 1
 
+	v, err = db.Get(dbutils.DatabaseInfoBucket, dbutils.StorageModeWitnesses)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return StorageMode{}, err
+	}
+	sm.Witnesses = len(v) == 1 && v[0] == 1
+
+	v, err = db.Get(dbutils.DatabaseInfoBucket, dbutils.StorageModeBinaryTrie)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return StorageMode{}, err
+	}
+	sm.BinaryTrie = len(v) == 1 && v[0] == 1
+
+	if sm.Prune.History, err = getPruneDistance(db, dbutils.PruneModeHistory); err != nil {
+		return StorageMode{}, err
+	}
+	if sm.Prune.Receipts, err = getPruneDistance(db, dbutils.PruneModeReceipts); err != nil {
+		return StorageMode{}, err
+	}
+	if sm.Prune.TxIndex, err = getPruneDistance(db, dbutils.PruneModeTxIndex); err != nil {
+		return StorageMode{}, err
+	}
+	if sm.Prune.CallTraces, err = getPruneDistance(db, dbutils.PruneModeCallTraces); err != nil {
+		return StorageMode{}, err
+	}
+	if sm.Prune.Witnesses, err = getPruneDistance(db, dbutils.PruneModeWitnesses); err != nil {
+		return StorageMode{}, err
+	}
+
 	return sm, nil
 }
 
+func getPruneDistance(db Database, key []byte) (uint64, error) {
+	v, err := db.Get(dbutils.DatabaseInfoBucket, key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return 0, err
+	}
+	if len(v) != 8 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
 func SetStorageModeIfNotExist(db Database, sm StorageMode) error {
 	var (
 		err error
@@ -110,6 +245,41 @@ func SetStorageModeIfNotExist(db Database, sm StorageMode) error {
 		return err
 	}
 
+	err = setModeOnEmpty(db, dbutils.StorageModeWitnesses, sm.Witnesses)
+	if err != nil {
+		return err
+	}
+
+	err = setModeOnEmpty(db, dbutils.StorageModeBinaryTrie, sm.BinaryTrie)
+	if err != nil {
+		return err
+	}
+
+	err = setPruneDistanceOnEmpty(db, dbutils.PruneModeHistory, sm.Prune.History)
+	if err != nil {
+		return err
+	}
+
+	err = setPruneDistanceOnEmpty(db, dbutils.PruneModeReceipts, sm.Prune.Receipts)
+	if err != nil {
+		return err
+	}
+
+	err = setPruneDistanceOnEmpty(db, dbutils.PruneModeTxIndex, sm.Prune.TxIndex)
+	if err != nil {
+		return err
+	}
+
+	err = setPruneDistanceOnEmpty(db, dbutils.PruneModeCallTraces, sm.Prune.CallTraces)
+	if err != nil {
+		return err
+	}
+
+	err = setPruneDistanceOnEmpty(db, dbutils.PruneModeWitnesses, sm.Prune.Witnesses)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -130,3 +300,19 @@ func setModeOnEmpty(db Database, key []byte, currentValue bool) error {
 
 	return nil
 }
+
+func setPruneDistanceOnEmpty(db Database, key []byte, distance uint64) error {
+	_, err := db.Get(dbutils.DatabaseInfoBucket, key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+	if errors.Is(err, ErrKeyNotFound) {
+		val := make([]byte, 8)
+		binary.BigEndian.PutUint64(val, distance)
+		if err = db.Put(dbutils.DatabaseInfoBucket, key, val); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
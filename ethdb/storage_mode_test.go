@@ -23,6 +23,9 @@ func TestSetStorageModeIfNotExist(t *testing.T) {
 		true,
 		true,
 		true,
+		true,
+		true,
+		PruneMode{History: 90000, TxIndex: 100000, Witnesses: 1000},
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -38,8 +41,32 @@ func TestSetStorageModeIfNotExist(t *testing.T) {
 		true,
 		true,
 		true,
+		true,
+		true,
+		PruneMode{History: 90000, TxIndex: 100000, Witnesses: 1000},
 	}) {
 		spew.Dump(sm)
 		t.Fatal("not equal")
 	}
 }
+
+func TestPruneModeFromString(t *testing.T) {
+	pm, err := PruneModeFromString("h=90000,r=0,c=100000,w=128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pm, PruneMode{History: 90000, CallTraces: 100000, Witnesses: 128}) {
+		spew.Dump(pm)
+		t.Fatal("not equal")
+	}
+	if pm.ToString() != "h=90000,c=100000,w=128" {
+		t.Fatal("unexpected string", pm.ToString())
+	}
+
+	if _, err = PruneModeFromString("h=10"); err == nil {
+		t.Fatal("expected error for distance below MinPruneDistance")
+	}
+	if _, err = PruneModeFromString("x=100000"); err == nil {
+		t.Fatal("expected error for unknown data type")
+	}
+}
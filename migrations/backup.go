@@ -0,0 +1,241 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/common/etl"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+	"github.com/ugorji/go/codec"
+)
+
+// Before applying the migration, Migrator copies the buckets which it touches into dbutils.MigrationsBackup,
+// so the migration can be undone even if it has no Down function, or failed in the middle.
+// The list of the copied buckets is written after all of them are copied - the backup without it is incomplete.
+// The backup can only be restored while the buckets are not modified by anything else than the migration:
+// if the stages moved since the migration was applied, the backup is dropped (see DropStaleBackups).
+// Backups are made only on request (Migrator.Backup), e.g. by integration run_migrations.
+
+type backupInfo struct {
+	Buckets []string `codec:"1"`
+}
+
+func backupInfoKey(name string) []byte {
+	return []byte(name + "\x00")
+}
+
+func backupPrefix(name, bucket string) []byte {
+	return []byte(name + "\x00" + bucket + "\x00")
+}
+
+// readBackup returns nil if the migration has no complete backup
+func readBackup(db ethdb.Getter, name string) (*backupInfo, error) {
+	v, err := db.Get(dbutils.MigrationsBackup, backupInfoKey(name))
+	if err != nil {
+		if errors.Is(err, ethdb.ErrKeyNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	info := &backupInfo{}
+	if err := codec.NewDecoderBytes(v, &codec.CborHandle{}).Decode(info); err != nil {
+		return nil, fmt.Errorf("decoding backup of migration %s: %w", name, err)
+	}
+	return info, nil
+}
+
+// backup copies the existing buckets of the migration, the leftovers of the interrupted backup are dropped first
+func backup(db ethdb.Database, v Migration, tmpdir string, commit etl.LoadCommitHandler) error {
+	if err := dropBackup(db, v.Name, tmpdir, commit); err != nil {
+		return err
+	}
+	info := &backupInfo{}
+	for _, bucket := range v.Buckets {
+		if exists, err := db.(ethdb.BucketsMigrator).BucketExists(bucket); err != nil {
+			return err
+		} else if !exists {
+			continue
+		}
+		log.Info("Backing up bucket", "migration", v.Name, "bucket", bucket)
+		prefix := backupPrefix(v.Name, bucket)
+		var seq uint64
+		// keys are numbered to keep the order and the duplicates of DupSort buckets
+		extractFunc := func(k []byte, val []byte, next etl.ExtractNextFunc) error {
+			key := make([]byte, len(prefix)+8)
+			copy(key, prefix)
+			binary.BigEndian.PutUint64(key[len(prefix):], seq)
+			seq++
+			entry := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(k)+len(val))
+			entry = append(entry[:binary.PutUvarint(entry, uint64(len(k)))], k...)
+			return next(k, key, append(entry, val...))
+		}
+		if err := etl.Transform("backup_"+v.Name, db, bucket, dbutils.MigrationsBackup, tmpdir, extractFunc, etl.IdentityLoadFunc, etl.TransformArgs{OnLoadCommit: commit}); err != nil {
+			return fmt.Errorf("backing up bucket %s: %w", bucket, err)
+		}
+		info.Buckets = append(info.Buckets, bucket)
+	}
+	var data []byte
+	if err := codec.NewEncoderBytes(&data, &codec.CborHandle{}).Encode(info); err != nil {
+		return err
+	}
+	if err := db.Put(dbutils.MigrationsBackup, backupInfoKey(v.Name), data); err != nil {
+		return err
+	}
+	return commit(db, nil, false)
+}
+
+// restore replaces the buckets by their backup
+func restore(db ethdb.Database, name string, info *backupInfo, tmpdir string, commit etl.LoadCommitHandler) error {
+	for _, bucket := range info.Buckets {
+		log.Info("Restoring bucket", "migration", name, "bucket", bucket)
+		if err := db.(ethdb.BucketsMigrator).ClearBuckets(bucket); err != nil {
+			return err
+		}
+		if err := commit(db, nil, false); err != nil {
+			return err
+		}
+		prefix := backupPrefix(name, bucket)
+		extractFunc := func(k []byte, v []byte, next etl.ExtractNextFunc) error {
+			if !bytes.HasPrefix(k, prefix) {
+				return nil
+			}
+			keyLen, n := binary.Uvarint(v)
+			if n <= 0 || uint64(len(v)-n) < keyLen {
+				return fmt.Errorf("corrupted backup of bucket %s, key %x", bucket, k)
+			}
+			return next(k, common.CopyBytes(v[n:n+int(keyLen)]), common.CopyBytes(v[n+int(keyLen):]))
+		}
+		// not the identity, so the keys are put one by one - the duplicates of DupSort buckets can't be appended
+		loadFunc := func(k []byte, v []byte, _ etl.CurrentTableReader, next etl.LoadNextFunc) error {
+			return next(k, k, v)
+		}
+		if err := etl.Transform("restore_"+name, db, dbutils.MigrationsBackup, bucket, tmpdir, extractFunc, loadFunc, etl.TransformArgs{
+			ExtractStartKey: prefix,
+			OnLoadCommit:    commit,
+		}); err != nil {
+			return fmt.Errorf("restoring bucket %s: %w", bucket, err)
+		}
+	}
+	return nil
+}
+
+// dropBackup deletes the backup of the migration, starting from its list of buckets, so the interrupted deletion
+// leaves the incomplete backup
+func dropBackup(db ethdb.Database, name string, tmpdir string, commit etl.LoadCommitHandler) error {
+	if err := db.Delete(dbutils.MigrationsBackup, backupInfoKey(name), nil); err != nil {
+		return err
+	}
+	prefix := backupInfoKey(name)
+	extractFunc := func(k []byte, v []byte, next etl.ExtractNextFunc) error {
+		if !bytes.HasPrefix(k, prefix) {
+			return nil
+		}
+		return next(k, k, nil)
+	}
+	return etl.Transform("drop_backup_"+name, db, dbutils.MigrationsBackup, dbutils.MigrationsBackup, tmpdir, extractFunc, etl.IdentityLoadFunc, etl.TransformArgs{
+		ExtractStartKey: prefix,
+		ExtractEndKey:   []byte(name + "\x01"),
+		OnLoadCommit:    commit,
+	})
+}
+
+// DropStaleBackups drops the backups of applied migrations, if the stages moved since then - they can't be restored
+// anymore. It is called by the Finish stage, so the space is freed as soon as the stages move.
+func DropStaleBackups(db ethdb.Database, tmpdir string, commit etl.LoadCommitHandler) error {
+	applied, err := AppliedMigrations(db, true)
+	if err != nil {
+		return err
+	}
+	for name, payload := range applied {
+		info, err := readBackup(db, name)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			continue
+		}
+		if unchanged, err := stagesNotMovedSince(db, payload); err != nil {
+			return err
+		} else if unchanged {
+			continue
+		}
+		log.Info("Dropping backup of migration, stages moved since it was applied", "name", name)
+		if err = dropBackup(db, name, tmpdir, commit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stagesNotMovedSince compares the current progress of the stages with the payload of the applied migration
+func stagesNotMovedSince(db ethdb.Getter, payload []byte) (bool, error) {
+	current, err := MarshalMigrationPayload(db)
+	if err != nil {
+		return false, err
+	}
+	was, err := UnmarshalMigrationPayload(payload)
+	if err != nil {
+		return false, err
+	}
+	now, err := UnmarshalMigrationPayload(current)
+	if err != nil {
+		return false, err
+	}
+	if len(was) != len(now) {
+		return false, nil
+	}
+	for k, v := range was {
+		if !bytes.Equal(v, now[k]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// PendingMigrationPlan - what the pending migration is going to touch, see Migrator.DryRun
+type PendingMigrationPlan struct {
+	Name       string
+	Buckets    []string
+	Sizes      []uint64 // estimated by the pages of the bucket
+	Reversible bool     // has Down function, otherwise it can only be reverted from the backup
+}
+
+// DryRun reports the buckets touched by the pending migrations and their sizes, without modifying the database.
+// With the backup, the database grows by the sizes of these buckets.
+func (m *Migrator) DryRun(db ethdb.Database) ([]PendingMigrationPlan, error) {
+	pending, err := m.PendingMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	hasKV, ok := db.(ethdb.HasKV)
+	if !ok {
+		return nil, fmt.Errorf("%T doesn't implement ethdb.HasKV interface", db)
+	}
+	plans := make([]PendingMigrationPlan, 0, len(pending))
+	if err := hasKV.KV().View(context.Background(), func(tx ethdb.Tx) error {
+		for _, v := range pending {
+			plan := PendingMigrationPlan{Name: v.Name, Buckets: v.Buckets, Sizes: make([]uint64, len(v.Buckets)), Reversible: v.Down != nil}
+			for i, bucket := range v.Buckets {
+				if migrator, ok := tx.(ethdb.BucketMigrator); ok && !migrator.ExistsBucket(bucket) {
+					continue
+				}
+				size, err := tx.BucketSize(bucket)
+				if err != nil {
+					return fmt.Errorf("size of bucket %s: %w", bucket, err)
+				}
+				plan.Sizes[i] = size
+			}
+			plans = append(plans, plan)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
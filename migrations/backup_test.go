@@ -0,0 +1,204 @@
+package migrations
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/common/etl"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/stretchr/testify/require"
+)
+
+func fillBuckets(t *testing.T, db ethdb.Database) {
+	for i := 0; i < 100; i++ {
+		// keys of storage, PlainStateBucket converts them to DupSort
+		k := append(common.Hash{byte(i % 3)}.Bytes()[:20], bytes.Repeat([]byte{byte(i)}, 40)...)
+		require.NoError(t, db.Put(dbutils.PlainStateBucket, k, []byte(fmt.Sprintf("storage-%d", i))))
+		require.NoError(t, db.Put(dbutils.BlockReceiptsPrefix, dbutils.EncodeBlockNumber(uint64(i)), []byte(fmt.Sprintf("receipt-%d", i))))
+	}
+}
+
+func readBucket(t *testing.T, db ethdb.Database, bucket string) map[string]string {
+	content := map[string]string{}
+	require.NoError(t, db.Walk(bucket, nil, 0, func(k, v []byte) (bool, error) {
+		content[string(k)] = string(v)
+		return true, nil
+	}))
+	return content
+}
+
+// modifyBuckets is the migration which clears PlainStateBucket and rewrites receipts
+var modifyBuckets = Migration{
+	Name:    "modify_buckets",
+	Buckets: []string{dbutils.PlainStateBucket, dbutils.BlockReceiptsPrefix},
+	Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
+		if err := db.(ethdb.BucketsMigrator).ClearBuckets(dbutils.PlainStateBucket); err != nil {
+			return err
+		}
+		for i := 0; i < 100; i += 2 {
+			if err := db.Put(dbutils.BlockReceiptsPrefix, dbutils.EncodeBlockNumber(uint64(i)), []byte("migrated")); err != nil {
+				return err
+			}
+		}
+		return OnLoadCommit(db, nil, true)
+	},
+}
+
+func TestNoBackupByDefault(t *testing.T) {
+	require, db := require.New(t), ethdb.NewMemDatabase()
+	defer db.Close()
+	tmpdir, err := ioutil.TempDir("", "migrations")
+	require.NoError(err)
+	defer os.RemoveAll(tmpdir)
+	fillBuckets(t, db)
+
+	migrator := NewMigrator()
+	migrator.Migrations = []Migration{modifyBuckets}
+	require.NoError(migrator.Apply(db, tmpdir))
+	require.Empty(readBucket(t, db, dbutils.MigrationsBackup))
+	require.True(errors.Is(migrator.Revert(db, modifyBuckets.Name, tmpdir), ErrMigrationNotReversible))
+}
+
+func TestRevertFromBackup(t *testing.T) {
+	require, db := require.New(t), ethdb.NewMemDatabase()
+	defer db.Close()
+	tmpdir, err := ioutil.TempDir("", "migrations")
+	require.NoError(err)
+	defer os.RemoveAll(tmpdir)
+	fillBuckets(t, db)
+	plainState, receipts := readBucket(t, db, dbutils.PlainStateBucket), readBucket(t, db, dbutils.BlockReceiptsPrefix)
+
+	migrator := NewMigrator()
+	migrator.Backup = true
+	migrator.Migrations = []Migration{modifyBuckets}
+	require.NoError(migrator.Apply(db, tmpdir))
+	require.Empty(readBucket(t, db, dbutils.PlainStateBucket))
+	require.NotEqual(receipts, readBucket(t, db, dbutils.BlockReceiptsPrefix))
+
+	require.NoError(migrator.Revert(db, modifyBuckets.Name, tmpdir))
+	require.Equal(plainState, readBucket(t, db, dbutils.PlainStateBucket))
+	require.Equal(receipts, readBucket(t, db, dbutils.BlockReceiptsPrefix))
+	require.Empty(readBucket(t, db, dbutils.MigrationsBackup))
+	applied, err := AppliedMigrations(db, false)
+	require.NoError(err)
+	require.Empty(applied)
+
+	// apply again
+	require.NoError(migrator.Apply(db, tmpdir))
+	require.Empty(readBucket(t, db, dbutils.PlainStateBucket))
+	applied, err = AppliedMigrations(db, false)
+	require.NoError(err)
+	require.Equal(1, len(applied))
+}
+
+func TestRevertFailedMigration(t *testing.T) {
+	require, db := require.New(t), ethdb.NewMemDatabase()
+	defer db.Close()
+	tmpdir, err := ioutil.TempDir("", "migrations")
+	require.NoError(err)
+	defer os.RemoveAll(tmpdir)
+	fillBuckets(t, db)
+	plainState := readBucket(t, db, dbutils.PlainStateBucket)
+
+	errFailed := errors.New("failed")
+	migrator := NewMigrator()
+	migrator.Backup = true
+	migrator.Migrations = []Migration{{
+		Name:    "fail_in_the_middle",
+		Buckets: []string{dbutils.PlainStateBucket},
+		Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
+			if err := db.(ethdb.BucketsMigrator).ClearBuckets(dbutils.PlainStateBucket); err != nil {
+				return err
+			}
+			if err := OnLoadCommit(db, []byte("cleared"), false); err != nil {
+				return err
+			}
+			return errFailed
+		},
+	}}
+	require.True(errors.Is(migrator.Apply(db, tmpdir), errFailed))
+	require.Empty(readBucket(t, db, dbutils.PlainStateBucket))
+
+	require.NoError(migrator.Revert(db, "fail_in_the_middle", tmpdir))
+	require.Equal(plainState, readBucket(t, db, dbutils.PlainStateBucket))
+	require.Empty(readBucket(t, db, dbutils.Migrations), "progress is removed")
+}
+
+func TestRevertAfterStagesMoved(t *testing.T) {
+	require, db := require.New(t), ethdb.NewMemDatabase()
+	defer db.Close()
+	tmpdir, err := ioutil.TempDir("", "migrations")
+	require.NoError(err)
+	defer os.RemoveAll(tmpdir)
+	fillBuckets(t, db)
+	receipts := readBucket(t, db, dbutils.BlockReceiptsPrefix)
+
+	migrator := NewMigrator()
+	migrator.Backup = true
+	migrator.Migrations = []Migration{modifyBuckets}
+	require.NoError(migrator.Apply(db, tmpdir))
+	require.NotEmpty(readBucket(t, db, dbutils.MigrationsBackup))
+	require.NoError(stages.SaveStageProgress(db, stages.Execution, 42, nil))
+	require.True(errors.Is(migrator.Revert(db, modifyBuckets.Name, tmpdir), ErrMigrationNotReversible))
+
+	// the backup can't be restored anymore, and is dropped by the Finish stage of the sync cycle
+	require.NoError(DropStaleBackups(db, tmpdir, nil))
+	require.Empty(readBucket(t, db, dbutils.MigrationsBackup))
+
+	withDown := modifyBuckets
+	withDown.Down = func(db ethdb.Database, tmpdir string, OnLoadCommit etl.LoadCommitHandler) error {
+		for i := 0; i < 100; i += 2 {
+			if err := db.Put(dbutils.BlockReceiptsPrefix, dbutils.EncodeBlockNumber(uint64(i)), []byte(fmt.Sprintf("receipt-%d", i))); err != nil {
+				return err
+			}
+		}
+		return OnLoadCommit(db, nil, true)
+	}
+	migrator.Migrations = []Migration{withDown}
+	require.NoError(migrator.Revert(db, modifyBuckets.Name, tmpdir))
+	require.Equal(receipts, readBucket(t, db, dbutils.BlockReceiptsPrefix))
+	applied, err := AppliedMigrations(db, false)
+	require.NoError(err)
+	require.Empty(applied)
+}
+
+func TestDryRun(t *testing.T) {
+	require, db := require.New(t), ethdb.NewMemDatabase()
+	defer db.Close()
+	fillBuckets(t, db)
+	receipts := readBucket(t, db, dbutils.BlockReceiptsPrefix)
+
+	migrator := NewMigrator()
+	migrator.Migrations = []Migration{modifyBuckets, {
+		Name:    "drop_old_bucket",
+		Buckets: []string{dbutils.CurrentStateBucketOld1},
+		Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
+			return OnLoadCommit(db, nil, true)
+		},
+		Down: func(db ethdb.Database, tmpdir string, OnLoadCommit etl.LoadCommitHandler) error {
+			return OnLoadCommit(db, nil, true)
+		},
+	}}
+	plans, err := migrator.DryRun(db)
+	require.NoError(err)
+	require.Equal(2, len(plans))
+	require.Equal(modifyBuckets.Buckets, plans[0].Buckets)
+	require.False(plans[0].Reversible)
+	for _, size := range plans[0].Sizes {
+		require.NotZero(size)
+	}
+	require.Equal([]uint64{0}, plans[1].Sizes, "bucket doesn't exist")
+	require.True(plans[1].Reversible)
+
+	require.Equal(receipts, readBucket(t, db, dbutils.BlockReceiptsPrefix))
+	applied, err := AppliedMigrations(db, false)
+	require.NoError(err)
+	require.Empty(applied)
+}
@@ -11,7 +11,8 @@ import (
 )
 
 var dupSortHashState = Migration{
-	Name: "dupsort_hash_state",
+	Name:    "dupsort_hash_state",
+	Buckets: []string{dbutils.CurrentStateBucket, dbutils.CurrentStateBucketOld1},
 	Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
 		if exists, err := db.(ethdb.BucketsMigrator).BucketExists(dbutils.CurrentStateBucketOld1); err != nil {
 			return err
@@ -47,7 +48,8 @@ var dupSortHashState = Migration{
 }
 
 var dupSortPlainState = Migration{
-	Name: "dupsort_plain_state",
+	Name:    "dupsort_plain_state",
+	Buckets: []string{dbutils.PlainStateBucket, dbutils.PlainStateBucketOld1},
 	Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
 		if exists, err := db.(ethdb.BucketsMigrator).BucketExists(dbutils.PlainStateBucketOld1); err != nil {
 			return err
@@ -83,7 +85,8 @@ var dupSortPlainState = Migration{
 }
 
 var dupSortIH = Migration{
-	Name: "dupsort_intermediate_trie_hashes",
+	Name:    "dupsort_intermediate_trie_hashes",
+	Buckets: []string{dbutils.IntermediateTrieHashBucket, dbutils.IntermediateTrieHashBucketOld1},
 	Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
 		if err := db.(ethdb.BucketsMigrator).ClearBuckets(dbutils.IntermediateTrieHashBucket); err != nil {
 			return err
@@ -124,7 +127,8 @@ var dupSortIH = Migration{
 }
 
 var clearIndices = Migration{
-	Name: "clear_log_indices7",
+	Name:    "clear_log_indices7",
+	Buckets: []string{dbutils.LogAddressIndex, dbutils.LogTopicIndex, dbutils.SyncStageProgress, dbutils.SyncStageUnwind},
 	Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
 		if err := db.(ethdb.BucketsMigrator).ClearBuckets(dbutils.LogAddressIndex, dbutils.LogTopicIndex); err != nil {
 			return err
@@ -142,7 +146,8 @@ var clearIndices = Migration{
 }
 
 var resetIHBucketToRecoverDB = Migration{
-	Name: "reset_in_bucket_to_recover_db",
+	Name:    "reset_in_bucket_to_recover_db",
+	Buckets: []string{dbutils.IntermediateTrieHashBucket, dbutils.SyncStageProgress, dbutils.SyncStageUnwind},
 	Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
 		if err := db.(ethdb.BucketsMigrator).ClearBuckets(dbutils.IntermediateTrieHashBucket); err != nil {
 			return err
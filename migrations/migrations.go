@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path"
 
 	"github.com/ledgerwatch/turbo-geth/common"
@@ -54,6 +55,12 @@ import (
 //	},
 // - if you need migrate multiple buckets - create separate migration for each bucket
 // - write test where apply migration twice
+//
+// Reversibility
+// - list all the buckets which migration modifies, drops or clears in Buckets - Migrator copies them before applying
+// the migration, so it can be reverted (see Migrator.Revert) until the stages move
+// - provide Down if migration can be reverted after that. It has the same requirements as Up: idempotency and the call
+// of OnLoadCommit(db, nil, true) in the end
 var migrations = []Migration{
 	stagesToUseNamedKeys,
 	unwindStagesToUseNamedKeys,
@@ -69,14 +76,17 @@ var migrations = []Migration{
 }
 
 type Migration struct {
-	Name string
-	Up   func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommitOnLoadCommit etl.LoadCommitHandler) error
+	Name    string
+	Buckets []string // buckets which are modified by the migration, they are backed up before applying it
+	Up      func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommitOnLoadCommit etl.LoadCommitHandler) error
+	Down    func(db ethdb.Database, tmpdir string, OnLoadCommit etl.LoadCommitHandler) error // optional
 }
 
 var (
 	ErrMigrationNonUniqueName   = fmt.Errorf("please provide unique migration name")
 	ErrMigrationCommitNotCalled = fmt.Errorf("migraion commit function was not called")
 	ErrMigrationETLFilesDeleted = fmt.Errorf("db migration progress was interrupted after extraction step and ETL files was deleted, please contact development team for help or re-sync from scratch")
+	ErrMigrationNotReversible   = fmt.Errorf("migration has no Down function and can't be restored from backup - it was not backed up, or stages moved since it was applied")
 )
 
 func NewMigrator() *Migrator {
@@ -87,6 +97,7 @@ func NewMigrator() *Migrator {
 
 type Migrator struct {
 	Migrations []Migration
+	Backup     bool // copy Buckets of the migration before applying it, off by default - the database grows by their size
 }
 
 func AppliedMigrations(db ethdb.Database, withPayload bool) (map[string][]byte, error) {
@@ -165,6 +176,14 @@ func (m *Migrator) Apply(db ethdb.Database, tmpdir string) error {
 	}
 	defer tx.Rollback()
 
+	backupTmpdir := path.Join(tmpdir, "migrations_backup")
+	commit := func(_ ethdb.Putter, _ []byte, _ bool) error {
+		return tx.CommitAndBegin(context.Background())
+	}
+	if err := DropStaleBackups(tx, backupTmpdir, commit); err != nil {
+		return err
+	}
+
 	for i := range m.Migrations {
 		v := m.Migrations[i]
 		if _, ok := applied[v.Name]; ok {
@@ -179,6 +198,22 @@ func (m *Migrator) Apply(db ethdb.Database, tmpdir string) error {
 			return err
 		}
 
+		if m.Backup && len(v.Buckets) > 0 {
+			info, err := readBackup(tx, v.Name)
+			if err != nil {
+				return err
+			}
+			switch {
+			case info != nil: // backed up before the interruption
+			case progress != nil:
+				log.Warn("Can't backup migration, it was interrupted without backup", "name", v.Name)
+			default:
+				if err = backup(tx, v, backupTmpdir, commit); err != nil {
+					return err
+				}
+			}
+		}
+
 		if err = v.Up(tx, path.Join(tmpdir, "migrations", v.Name), progress, func(_ ethdb.Putter, key []byte, isDone bool) error {
 			if !isDone {
 				if key != nil {
@@ -225,6 +260,87 @@ func (m *Migrator) Apply(db ethdb.Database, tmpdir string) error {
 	return nil
 }
 
+// Revert undoes the migration and removes it from the applied ones, so it is applied again on the next start.
+// The buckets are restored from the backup if the stages didn't move since the migration was applied, otherwise
+// Down function is used. The migration which failed in the middle can only be restored from the backup.
+func (m *Migrator) Revert(db ethdb.Database, name string, tmpdir string) error {
+	tx, err := db.Begin(context.Background(), ethdb.RW)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	backupTmpdir := path.Join(tmpdir, "migrations_backup")
+	commit := func(_ ethdb.Putter, _ []byte, _ bool) error {
+		return tx.CommitAndBegin(context.Background())
+	}
+
+	applied, err := AppliedMigrations(tx, true)
+	if err != nil {
+		return err
+	}
+	payload, isApplied := applied[name]
+	info, err := readBackup(tx, name)
+	if err != nil {
+		return err
+	}
+	canRestore := info != nil
+	if canRestore && isApplied {
+		if canRestore, err = stagesNotMovedSince(tx, payload); err != nil {
+			return err
+		}
+	}
+	var down func(db ethdb.Database, tmpdir string, OnLoadCommit etl.LoadCommitHandler) error
+	for i := range m.Migrations {
+		if m.Migrations[i].Name == name {
+			down = m.Migrations[i].Down
+		}
+	}
+
+	switch {
+	case canRestore:
+		log.Info("Revert migration from backup", "name", name)
+		if err = restore(tx, name, info, backupTmpdir, commit); err != nil {
+			return err
+		}
+	case isApplied && down != nil:
+		log.Info("Revert migration", "name", name)
+		commitFuncCalled := false
+		if err = down(tx, path.Join(tmpdir, "migrations", name), func(_ ethdb.Putter, _ []byte, isDone bool) error {
+			if isDone {
+				commitFuncCalled = true
+			}
+			return tx.CommitAndBegin(context.Background())
+		}); err != nil {
+			return err
+		}
+		if !commitFuncCalled {
+			return fmt.Errorf("%w: %s", ErrMigrationCommitNotCalled, name)
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrMigrationNotReversible, name)
+	}
+
+	if err = tx.Delete(dbutils.Migrations, []byte(name), nil); err != nil {
+		return err
+	}
+	if err = tx.Delete(dbutils.Migrations, []byte("_progress_"+name), nil); err != nil {
+		return err
+	}
+	if err = dropBackup(tx, name, backupTmpdir, commit); err != nil {
+		return err
+	}
+	if _, err = tx.Commit(); err != nil {
+		return err
+	}
+	// ETL files of the interrupted migration, they can't be loaded after restoring
+	if err = os.RemoveAll(path.Join(tmpdir, "migrations", name)); err != nil {
+		return err
+	}
+	log.Info("Reverted migration", "name", name)
+	return nil
+}
+
 func MarshalMigrationPayload(db ethdb.Getter) ([]byte, error) {
 	s := map[string][]byte{}
 
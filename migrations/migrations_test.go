@@ -16,14 +16,14 @@ func TestApplyWithInit(t *testing.T) {
 	require, db := require.New(t), ethdb.NewMemDatabase()
 	migrations = []Migration{
 		{
-			"one",
-			func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
+			Name: "one",
+			Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
 				return OnLoadCommit(db, nil, true)
 			},
 		},
 		{
-			"two",
-			func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
+			Name: "two",
+			Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
 				return OnLoadCommit(db, nil, true)
 			},
 		},
@@ -55,15 +55,15 @@ func TestApplyWithoutInit(t *testing.T) {
 	require, db := require.New(t), ethdb.NewMemDatabase()
 	migrations = []Migration{
 		{
-			"one",
-			func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
+			Name: "one",
+			Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
 				t.Fatal("shouldn't been executed")
 				return nil
 			},
 		},
 		{
-			"two",
-			func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
+			Name: "two",
+			Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
 				return OnLoadCommit(db, nil, true)
 			},
 		},
@@ -98,14 +98,14 @@ func TestWhenNonFirstMigrationAlreadyApplied(t *testing.T) {
 	require, db := require.New(t), ethdb.NewMemDatabase()
 	migrations = []Migration{
 		{
-			"one",
-			func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
+			Name: "one",
+			Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
 				return OnLoadCommit(db, nil, true)
 			},
 		},
 		{
-			"two",
-			func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
+			Name: "two",
+			Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
 				t.Fatal("shouldn't been executed")
 				return nil
 			},
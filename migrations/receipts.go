@@ -20,7 +20,8 @@ import (
 )
 
 var receiptsCborEncode = Migration{
-	Name: "receipts_cbor_encode",
+	Name:    "receipts_cbor_encode",
+	Buckets: []string{dbutils.BlockReceiptsPrefix},
 	Up: func(db ethdb.Database, tmpdir string, progress []byte, CommitProgress etl.LoadCommitHandler) error {
 		logEvery := time.NewTicker(30 * time.Second)
 		defer logEvery.Stop()
@@ -96,7 +97,8 @@ var receiptsCborEncode = Migration{
 }
 
 var receiptsOnePerTx = Migration{
-	Name: "receipts_store_logs_separately",
+	Name:    "receipts_store_logs_separately",
+	Buckets: []string{dbutils.BlockReceiptsPrefix, dbutils.Log},
 	Up: func(db ethdb.Database, tmpdir string, progress []byte, CommitProgress etl.LoadCommitHandler) (err error) {
 		logEvery := time.NewTicker(30 * time.Second)
 		defer logEvery.Stop()
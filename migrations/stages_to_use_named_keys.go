@@ -23,7 +23,8 @@ var dbKeys = []stages.SyncStage{
 }
 
 var stagesToUseNamedKeys = Migration{
-	Name: "stages_to_use_named_keys",
+	Name:    "stages_to_use_named_keys",
+	Buckets: []string{dbutils.SyncStageProgress, dbutils.SyncStageProgressOld1},
 	Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
 
 		if exists, err := db.(ethdb.BucketsMigrator).BucketExists(dbutils.SyncStageProgressOld1); err != nil {
@@ -69,7 +70,8 @@ var stagesToUseNamedKeys = Migration{
 }
 
 var unwindStagesToUseNamedKeys = Migration{
-	Name: "unwind_stages_to_use_named_keys",
+	Name:    "unwind_stages_to_use_named_keys",
+	Buckets: []string{dbutils.SyncStageUnwind, dbutils.SyncStageUnwindOld1},
 	Up: func(db ethdb.Database, tmpdir string, progress []byte, OnLoadCommit etl.LoadCommitHandler) error {
 		if exists, err := db.(ethdb.BucketsMigrator).BucketExists(dbutils.SyncStageUnwindOld1); err != nil {
 			return err
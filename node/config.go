@@ -192,6 +192,9 @@ type Config struct {
 	// Address to listen to when launchig listener for remote database access
 	// empty string means not to start the listener
 	PrivateApiAddr string
+	// Path to the file with clients allowed to use private API and their permissions,
+	// empty string means that any client is allowed to read any bucket
+	PrivateApiAuthFile string
 
 	staticNodesWarning     bool
 	trustedNodesWarning    bool
@@ -0,0 +1,160 @@
+// Package changefeed contains events streamed by the ChangeFeed stage of staged sync to an external sink.
+//
+// Stream is a sequence of frames: 4 bytes of big-endian length + JSON-encoded Event.
+// Events are sent in order of blocks. After a restart of the node, the stream continues from the
+// last block saved in the progress of the stage, so the sink may receive some blocks again
+// and must apply them idempotently (by block number). Reorgs are delivered as "revert" events,
+// all blocks above `number` of the revert event are not canonical anymore.
+package changefeed
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/hexutil"
+)
+
+const (
+	EventBlock  = "block"
+	EventRevert = "revert"
+
+	MaxFrameSize = 256 * 1024 * 1024 // frames above this size are considered corrupted by the reader
+
+	dialTimeout  = 10 * time.Second
+	writeTimeout = time.Minute
+)
+
+// Event - diff of the state produced by one block, or revert of blocks
+type Event struct {
+	Type     string         `json:"type"`
+	Number   uint64         `json:"number"`
+	Hash     common.Hash    `json:"hash"`
+	Accounts []*AccountDiff `json:"accounts,omitempty"`
+	Storage  []*StorageDiff `json:"storage,omitempty"`
+	Codes    []*CodeDiff    `json:"codes,omitempty"`
+}
+
+// AccountDiff - new value of the account, Deleted is set if the account was removed by the block
+type AccountDiff struct {
+	Address     common.Address `json:"address"`
+	Deleted     bool           `json:"deleted,omitempty"`
+	Nonce       hexutil.Uint64 `json:"nonce"`
+	Balance     *hexutil.Big   `json:"balance"`
+	Incarnation hexutil.Uint64 `json:"incarnation"`
+	CodeHash    common.Hash    `json:"codeHash"`
+}
+
+// StorageDiff - new value of the storage slot, empty value means that the slot was cleared
+type StorageDiff struct {
+	Address     common.Address `json:"address"`
+	Incarnation hexutil.Uint64 `json:"incarnation"`
+	Key         common.Hash    `json:"key"`
+	Value       hexutil.Bytes  `json:"value"`
+}
+
+// CodeDiff - code of the contract deployed by the block
+type CodeDiff struct {
+	Address  common.Address `json:"address"`
+	CodeHash common.Hash    `json:"codeHash"`
+	Code     hexutil.Bytes  `json:"code"`
+}
+
+// Sink receives events of the ChangeFeed stage. Send must return error if the events are not delivered,
+// the stage saves its progress only after successful Send.
+type Sink interface {
+	Send(events ...*Event) error
+	Close() error
+}
+
+// WriteEvent writes one length-prefixed frame
+func WriteEvent(w io.Writer, ev *Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(len(data)))
+	if _, err = w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadEvent reads one length-prefixed frame, it is meant to be used by the sink
+func ReadEvent(r io.Reader) (*Event, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(prefix[:])
+	if size > MaxFrameSize {
+		return nil, fmt.Errorf("frame is too big: %d", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	ev := &Event{}
+	if err := json.Unmarshal(data, ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// Client - sink connected to the address in the form "unix:/path/to/socket" or "host:port".
+// Connection is established on the first Send and re-established after errors.
+type Client struct {
+	network string
+	addr    string
+	conn    net.Conn
+}
+
+var _ Sink = (*Client)(nil)
+
+func NewClient(addr string) *Client {
+	if strings.HasPrefix(addr, "unix:") {
+		return &Client{network: "unix", addr: strings.TrimPrefix(addr, "unix:")}
+	}
+	return &Client{network: "tcp", addr: addr}
+}
+
+func (c *Client) Send(events ...*Event) error {
+	if c.conn == nil {
+		conn, err := net.DialTimeout(c.network, c.addr, dialTimeout)
+		if err != nil {
+			return fmt.Errorf("connecting to change feed sink %s: %w", c.addr, err)
+		}
+		c.conn = conn
+	}
+	if err := c.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return c.fail(err)
+	}
+	for _, ev := range events {
+		if err := WriteEvent(c.conn, ev); err != nil {
+			return c.fail(err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) fail(err error) error {
+	c.conn.Close()
+	c.conn = nil
+	return fmt.Errorf("sending to change feed sink %s: %w", c.addr, err)
+}
+
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
@@ -0,0 +1,59 @@
+package changefeed
+
+import (
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "changefeed")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "sink.sock")
+
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer l.Close()
+	received := make(chan *Event, 10)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			ev, err := ReadEvent(conn)
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- ev
+		}
+	}()
+
+	block := &Event{
+		Type:     EventBlock,
+		Number:   10,
+		Hash:     common.HexToHash("0x01"),
+		Accounts: []*AccountDiff{{Address: common.HexToAddress("0x02"), Nonce: 1, Balance: (*hexutil.Big)(big.NewInt(100))}},
+		Storage:  []*StorageDiff{{Address: common.HexToAddress("0x02"), Incarnation: 1, Key: common.HexToHash("0x03"), Value: []byte{4}}},
+	}
+	revert := &Event{Type: EventRevert, Number: 9, Hash: common.HexToHash("0x05")}
+
+	c := NewClient("unix:" + socket)
+	require.NoError(t, c.Send(block, revert))
+	require.NoError(t, c.Close())
+
+	require.Equal(t, block, <-received)
+	require.Equal(t, revert, <-received)
+	_, ok := <-received
+	require.False(t, ok)
+}
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/turbo-geth/cmd/utils"
+	"github.com/ledgerwatch/turbo-geth/ethdb/backup"
+	"github.com/ledgerwatch/turbo-geth/log"
+	"github.com/urfave/cli"
+)
+
+var (
+	ChaindataFlag = cli.StringFlag{
+		Name:  "chaindata",
+		Usage: "Path to the database, <datadir>/tg/chaindata by default",
+	}
+	BackupToFlag = cli.StringFlag{
+		Name:  "to",
+		Usage: "Directory of the backup, must not exist",
+	}
+	BackupFromFlag = cli.StringFlag{
+		Name:  "from",
+		Usage: "Directory of the backup to restore from",
+	}
+	BackupDatabaseFlag = cli.StringFlag{
+		Name:  "backup.database",
+		Usage: "Database software of the backup: lmdb|mdbx, the same as --database by default",
+	}
+	BackupRateFlag = cli.StringFlag{
+		Name:  "rate",
+		Usage: "Limit of reading per second, for example 50MB, no limit by default",
+	}
+)
+
+// DatabaseCommand contains the commands working with the database of the node
+var DatabaseCommand = cli.Command{
+	Name:  "db",
+	Usage: "Database maintenance",
+	Subcommands: []cli.Command{
+		{
+			Name:  "backup",
+			Usage: "Make a consistent copy of the database, the node can keep running",
+			Description: `All buckets are copied in one read transaction, so the backup is the snapshot of the moment it started.
+The database grows while the backup runs, because the freed pages can't be reused until the read transaction is done.
+The backup is complete when backup.json manifest with the number of entries and checksum of every bucket is written.`,
+			Action: backupDatabase,
+			Flags:  []cli.Flag{ChaindataFlag, BackupToFlag, BackupDatabaseFlag, BackupRateFlag},
+		},
+		{
+			Name:        "restore",
+			Usage:       "Restore the database from the backup, the node must be stopped",
+			Description: "Checksums of the buckets are verified against the manifest of the backup. The database must not exist.",
+			Action:      restoreDatabase,
+			Flags:       []cli.Flag{ChaindataFlag, BackupFromFlag, BackupRateFlag},
+		},
+	},
+}
+
+func chaindataPath(ctx *cli.Context) string {
+	if path := ctx.String(ChaindataFlag.Name); path != "" {
+		return path
+	}
+	return filepath.Join(utils.MakeDataDir(ctx), "tg", "chaindata")
+}
+
+func backupOptions(ctx *cli.Context) (backup.Options, error) {
+	var opts backup.Options
+	if rate := ctx.String(BackupRateFlag.Name); rate != "" {
+		if err := opts.Rate.UnmarshalText([]byte(rate)); err != nil {
+			return opts, fmt.Errorf("invalid --%s: %w", BackupRateFlag.Name, err)
+		}
+	}
+	return opts, nil
+}
+
+func backupDatabase(ctx *cli.Context) error {
+	dir := ctx.String(BackupToFlag.Name)
+	if dir == "" {
+		return fmt.Errorf("--%s is required", BackupToFlag.Name)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		return fmt.Errorf("backup directory already exists: %s", dir)
+	}
+	opts, err := backupOptions(ctx)
+	if err != nil {
+		return err
+	}
+	chaindata := chaindataPath(ctx)
+	if _, err = os.Stat(chaindata); err != nil {
+		return err
+	}
+	backend := ctx.GlobalString(DatabaseFlag.Name)
+	backupBackend := ctx.String(BackupDatabaseFlag.Name)
+	if backupBackend == "" {
+		backupBackend = backend
+	}
+
+	src, buckets, err := backup.OpenExisting(chaindata, backend)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", chaindata, err)
+	}
+	defer src.Close()
+	dst, err := backup.Open(dir, backupBackend, false, buckets)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", dir, err)
+	}
+	log.Info("Backup started", "chaindata", chaindata, "to", dir, "database", backupBackend, "rate", opts.Rate.HR())
+	m, err := backup.Backup(utils.RootContext(), src, dst, opts)
+	dst.Close()
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	m.Source = chaindata
+	m.Backend = backupBackend
+	if err = backup.WriteManifest(dir, m); err != nil {
+		return err
+	}
+	log.Info("Backup done", "to", dir, "buckets", len(m.Buckets), "size", datasize.ByteSize(totalSize(m)).HR())
+	return nil
+}
+
+func restoreDatabase(ctx *cli.Context) error {
+	dir := ctx.String(BackupFromFlag.Name)
+	if dir == "" {
+		return fmt.Errorf("--%s is required", BackupFromFlag.Name)
+	}
+	opts, err := backupOptions(ctx)
+	if err != nil {
+		return err
+	}
+	m, err := backup.ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+	chaindata := chaindataPath(ctx)
+	if _, err = os.Stat(chaindata); !os.IsNotExist(err) {
+		return fmt.Errorf("database already exists: %s, remove it to restore", chaindata)
+	}
+	// the database appears only when it is restored and verified
+	tmp := chaindata + ".restoring"
+	if err = os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	backend := ctx.GlobalString(DatabaseFlag.Name)
+
+	src, err := backup.Open(dir, m.Backend, true, m.BucketNames())
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", dir, err)
+	}
+	defer src.Close()
+	dst, err := backup.Open(tmp, backend, false, m.BucketNames())
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", tmp, err)
+	}
+	log.Info("Restore started", "from", dir, "created", m.Created, "chaindata", chaindata, "database", backend)
+	err = backup.Restore(utils.RootContext(), src, dst, m, opts)
+	dst.Close()
+	if err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, chaindata); err != nil {
+		return err
+	}
+	log.Info("Restore done", "chaindata", chaindata, "buckets", len(m.Buckets), "size", datasize.ByteSize(totalSize(m)).HR())
+	return nil
+}
+
+func totalSize(m *backup.Manifest) uint64 {
+	var size uint64
+	for _, b := range m.Buckets {
+		size += b.Size
+	}
+	return size
+}
@@ -24,11 +24,21 @@ var DefaultFlags = []cli.Flag{
 	utils.TxPoolLifetimeFlag,
 	utils.TxLookupLimitFlag,
 	StorageModeFlag,
+	PruneModeFlag,
 	SnapshotModeFlag,
 	BatchSizeFlag,
+	ExecutionCacheFlag,
+	ChangeFeedAddrFlag,
+	StagePluginsFlag,
+	RemoteStagesFlag,
+	ShardDispatcherFlag,
+	ShardBitsFlag,
+	ShardIDFlag,
 	DatabaseFlag,
 	PrivateApiAddr,
+	PrivateApiAuth,
 	EtlBufferSizeFlag,
+	EtlCompressionFlag,
 	LMDBMapSizeFlag,
 	LMDBMaxFreelistReuseFlag,
 	TLSFlag,
@@ -26,26 +26,83 @@ var (
 		Usage: "Batch size for the execution stage",
 		Value: "512M",
 	}
+	ExecutionCacheFlag = cli.BoolFlag{
+		Name:  "exec.cache",
+		Usage: "Save warm state caches of the execution stage to the datadir at every commit and load them on restart (takes up to 4.5GB of RAM and disk)",
+	}
+	ChangeFeedAddrFlag = cli.StringFlag{
+		Name:  "changefeed.addr",
+		Usage: "Address of the sink for state diffs of every block, for example: unix:/tmp/changefeed.sock or 127.0.0.1:9095. Requires history (`h` in --storage-mode)",
+		Value: "",
+	}
+	StagePluginsFlag = cli.StringFlag{
+		Name:  "stages.plugins",
+		Usage: "Comma-separated list of Go plugins (.so files) with additional sync stages, the node must be compiled with -tags 'plugins'",
+		Value: "",
+	}
+	RemoteStagesFlag = cli.StringFlag{
+		Name:  "stages.remote",
+		Usage: "Comma-separated list of out-of-process sync stages in the form <id>:<after>@<host:port>, for example: com.example.erc20:LogIndex@127.0.0.1:9096",
+		Value: "",
+	}
+	ShardDispatcherFlag = cli.StringFlag{
+		Name:  "shard.dispatcher",
+		Usage: "Address of the dispatcher of the sharded execution, for example: 127.0.0.1:9092. Empty string means that the blocks are executed by this node alone",
+		Value: "",
+	}
+	ShardBitsFlag = cli.IntFlag{
+		Name:  "shard.bits",
+		Usage: "Number of the first bits of the address which identify the shard of the account, there are 2^bits shards",
+		Value: 1,
+	}
+	ShardIDFlag = cli.IntFlag{
+		Name:  "shard.id",
+		Usage: "Shard of this node in the sharded execution, from 0 to 2^shard.bits-1",
+		Value: 0,
+	}
 	EtlBufferSizeFlag = cli.StringFlag{
 		Name:  "etl.bufferSize",
 		Usage: "Buffer size for ETL operations.",
 		Value: etl.BufferOptimalSize.String(),
 	}
+	EtlCompressionFlag = cli.StringFlag{
+		Name:  "etl.compression",
+		Usage: "Compression of ETL temp files: none, snappy (fast) or zstd (smaller files)",
+		Value: etl.Compression.String(),
+	}
 
 	PrivateApiAddr = cli.StringFlag{
 		Name:  "private.api.addr",
 		Usage: "private api network address, for example: 127.0.0.1:9090, empty string means not to start the listener. do not expose to public network. serves remote database interface",
 		Value: "",
 	}
+	PrivateApiAuth = cli.StringFlag{
+		Name:  "private.api.auth",
+		Usage: "path to JSON file with clients allowed to use private api (identified by TLS certificate CN or token) and buckets allowed to each of them",
+		Value: "",
+	}
 
 	StorageModeFlag = cli.StringFlag{
 		Name: "storage-mode",
 		Usage: `Configures the storage mode of the app:
 * h - write history to the DB
 * r - write receipts to the DB
-* t - write tx lookup index to the DB`,
+* t - write tx lookup index to the DB
+* w - build block witnesses and write them to the DB (requires h)
+* b - maintain binary trie commitment of the state alongside the hexary one (experimental)`,
 		Value: ethdb.DefaultStorageMode.ToString(),
 	}
+	PruneModeFlag = cli.StringFlag{
+		Name: "prune",
+		Usage: `Amount of recent blocks for which data is kept, older data is removed. Comma separated list of <type>=<blocks>:
+* h - history and change sets
+* r - receipts, logs and logs index
+* t - tx lookup index
+* c - call traces index
+* w - block witnesses, can be less than 90000
+for example: h=90000,r=500000. 0 or omitted type - keep forever`,
+		Value: "",
+	}
 	SnapshotModeFlag = cli.StringFlag{
 		Name: "snapshot-mode",
 		Usage: `Configures the storage mode of the app:
@@ -101,6 +158,10 @@ func ApplyFlagsForEthConfig(ctx *cli.Context, cfg *eth.Config) {
 		utils.Fatalf(fmt.Sprintf("error while parsing mode: %v", err))
 	}
 	cfg.StorageMode = mode
+	cfg.StorageMode.Prune, err = ethdb.PruneModeFromString(ctx.GlobalString(PruneModeFlag.Name))
+	if err != nil {
+		utils.Fatalf(fmt.Sprintf("error while parsing prune mode: %v", err))
+	}
 	snMode, err := torrent.SnapshotModeFromString(ctx.GlobalString(SnapshotModeFlag.Name))
 	if err != nil {
 		utils.Fatalf(fmt.Sprintf("error while parsing mode: %v", err))
@@ -114,6 +175,8 @@ func ApplyFlagsForEthConfig(ctx *cli.Context, cfg *eth.Config) {
 			utils.Fatalf("Invalid batchSize provided: %v", err)
 		}
 	}
+	cfg.ExecutionCache = ctx.GlobalBool(ExecutionCacheFlag.Name)
+	cfg.ChangeFeedAddr = ctx.GlobalString(ChangeFeedAddrFlag.Name)
 
 	if ctx.GlobalString(EtlBufferSizeFlag.Name) != "" {
 		sizeVal := datasize.ByteSize(0)
@@ -124,6 +187,13 @@ func ApplyFlagsForEthConfig(ctx *cli.Context, cfg *eth.Config) {
 		}
 		etl.BufferOptimalSize = *size
 	}
+	if ctx.GlobalString(EtlCompressionFlag.Name) != "" {
+		compression, err := etl.ParseCompression(ctx.GlobalString(EtlCompressionFlag.Name))
+		if err != nil {
+			utils.Fatalf("Invalid etl.compression provided: %v", err)
+		}
+		etl.Compression = compression
+	}
 }
 
 func ApplyFlagsForNodeConfig(ctx *cli.Context, cfg *node.Config) {
@@ -165,6 +235,7 @@ func ApplyFlagsForNodeConfig(ctx *cli.Context, cfg *node.Config) {
 // read-only interface to the databae
 func setPrivateApi(ctx *cli.Context, cfg *node.Config) {
 	cfg.PrivateApiAddr = ctx.GlobalString(PrivateApiAddr.Name)
+	cfg.PrivateApiAuthFile = ctx.GlobalString(PrivateApiAuth.Name)
 	if ctx.GlobalBool(TLSFlag.Name) {
 		certFile := ctx.GlobalString(TLSCertFlag.Name)
 		keyFile := ctx.GlobalString(TLSKeyFlag.Name)
@@ -0,0 +1,413 @@
+package fsck
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/changeset"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/rlp"
+	"github.com/ledgerwatch/turbo-geth/turbo/trie"
+)
+
+// Checks - all known checks, in the order they run
+var Checks = []*Check{
+	{
+		Name:        "headers",
+		Description: "every block up to the Headers stage has the canonical header, linked to the previous one",
+		run:         checkHeaders,
+	},
+	{
+		Name:        "bodies",
+		Description: "every canonical header up to the Bodies stage has the body",
+		run:         checkBodies,
+	},
+	{
+		Name:        "senders",
+		Description: "number of senders equals number of transactions, up to the Senders stage",
+		Repair: func(db ethdb.Database) error {
+			return resetStages(db, []stages.SyncStage{stages.Senders}, dbutils.Senders)
+		},
+		run: checkSenders,
+	},
+	{
+		Name:        "tx_lookup",
+		Description: "every transaction up to the TxLookup stage points to its block",
+		Repair: func(db ethdb.Database) error {
+			return resetStages(db, []stages.SyncStage{stages.TxLookup}, dbutils.TxLookupPrefix)
+		},
+		run: checkTxLookup,
+	},
+	historyIndexCheck,
+	{
+		Name:        "hashed_state",
+		Description: "hashed state and contract codes match the plain ones, when the HashState stage reached the Execution",
+		Repair:      stagedsync.ResetHashState, // with the stages derived from the hashed state
+		run:         checkHashedState,
+	},
+	{
+		Name:        "state_root",
+		Description: "root computed from the intermediate hashes equals the root of the header at the IntermediateHashes stage",
+		Repair: func(db ethdb.Database) error {
+			// the witnesses are built from the intermediate hashes
+			return resetStages(db, []stages.SyncStage{stages.IntermediateHashes, stages.BlockWitness}, dbutils.IntermediateTrieHashBucket, dbutils.BlockWitnessBucket)
+		},
+		run: checkStateRoot,
+	},
+}
+
+var historyIndexCheck = &Check{
+	Name:        "history_index",
+	Description: "every key of the changesets is in the history index at its block, up to the history index stages",
+	Repair: func(db ethdb.Database) error {
+		return resetStages(db, []stages.SyncStage{stages.AccountHistoryIndex, stages.StorageHistoryIndex}, dbutils.AccountsHistoryBucket, dbutils.StorageHistoryBucket)
+	},
+	run: func(c *checker) error {
+		return checkHistoryIndex(c, accountHistoryIndex, storageHistoryIndex)
+	},
+}
+
+func checkHeaders(c *checker) error {
+	to, err := c.progress(stages.Headers)
+	if err != nil {
+		return err
+	}
+	var parent common.Hash
+	for n := uint64(0); n <= to; n++ {
+		if err = common.Stopped(c.quit); err != nil {
+			return err
+		}
+		hash, err := rawdb.ReadCanonicalHash(c.db, n)
+		if err != nil {
+			return err
+		}
+		if hash == (common.Hash{}) {
+			if to == 0 {
+				return c.skip("database has no genesis")
+			}
+			if err = c.fail(dbutils.HeaderPrefix, n, dbutils.HeaderHashKey(n), "no canonical hash"); err != nil {
+				return err
+			}
+			parent = common.Hash{}
+			continue
+		}
+		data := rawdb.ReadHeaderRLP(c.db, hash, n)
+		header := new(types.Header)
+		if len(data) == 0 {
+			err = c.fail(dbutils.HeaderPrefix, n, dbutils.HeaderKey(n, hash), "no canonical header")
+		} else if decodeErr := rlp.DecodeBytes(data, header); decodeErr != nil {
+			err = c.fail(dbutils.HeaderPrefix, n, dbutils.HeaderKey(n, hash), "invalid header RLP: %v", decodeErr)
+		} else if header.Hash() != hash || header.Number.Uint64() != n {
+			err = c.fail(dbutils.HeaderPrefix, n, dbutils.HeaderKey(n, hash), "header of block %d with hash %x is stored", header.Number.Uint64(), header.Hash())
+		} else if n > 0 && parent != (common.Hash{}) && header.ParentHash != parent {
+			err = c.fail(dbutils.HeaderPrefix, n, dbutils.HeaderKey(n, hash), "parent hash %x, previous canonical hash %x", header.ParentHash, parent)
+		}
+		if err != nil {
+			return err
+		}
+		parent = hash
+	}
+	return nil
+}
+
+// readBody returns nil if the block has no canonical hash - that is reported by the headers check,
+// or the body is missing or can't be decoded - that is reported by the bodies check
+func readBody(c *checker, n uint64) (common.Hash, *types.Body, error) {
+	hash, err := rawdb.ReadCanonicalHash(c.db, n)
+	if err != nil || hash == (common.Hash{}) {
+		return hash, nil, err
+	}
+	body, _ := decodeBody(c.db, hash, n)
+	return hash, body, nil
+}
+
+func decodeBody(db ethdb.Getter, hash common.Hash, n uint64) (*types.Body, error) {
+	data, err := db.Get(dbutils.BlockBodyPrefix, dbutils.BlockBodyKey(n, hash))
+	if err != nil {
+		return nil, err
+	}
+	if data, err = rawdb.DecompressBlockBody(data); err != nil {
+		return nil, err
+	}
+	body := new(types.Body)
+	if err = rlp.DecodeBytes(data, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func checkBodies(c *checker) error {
+	to, err := c.progress(stages.Bodies)
+	if err != nil {
+		return err
+	}
+	for n := uint64(0); n <= to; n++ {
+		if err = common.Stopped(c.quit); err != nil {
+			return err
+		}
+		hash, err := rawdb.ReadCanonicalHash(c.db, n)
+		if err != nil {
+			return err
+		}
+		if hash == (common.Hash{}) {
+			continue
+		}
+		if _, err = decodeBody(c.db, hash, n); errors.Is(err, ethdb.ErrKeyNotFound) {
+			err = c.fail(dbutils.BlockBodyPrefix, n, dbutils.BlockBodyKey(n, hash), "no body of the canonical header")
+		} else if err != nil {
+			err = c.fail(dbutils.BlockBodyPrefix, n, dbutils.BlockBodyKey(n, hash), "invalid body: %v", err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkSenders(c *checker) error {
+	to, err := c.progress(stages.Senders)
+	if err != nil {
+		return err
+	}
+	for n := uint64(1); n <= to; n++ {
+		if err = common.Stopped(c.quit); err != nil {
+			return err
+		}
+		hash, body, err := readBody(c, n)
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			continue
+		}
+		senders, err := c.db.Get(dbutils.Senders, dbutils.BlockBodyKey(n, hash))
+		if err != nil && !errors.Is(err, ethdb.ErrKeyNotFound) {
+			return err
+		}
+		if len(senders)%common.AddressLength != 0 {
+			err = c.fail(dbutils.Senders, n, dbutils.BlockBodyKey(n, hash), "length of senders %d is not multiple of the address length", len(senders))
+		} else if len(senders)/common.AddressLength != len(body.Transactions) {
+			err = c.fail(dbutils.Senders, n, dbutils.BlockBodyKey(n, hash), "%d senders of %d transactions", len(senders)/common.AddressLength, len(body.Transactions))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkTxLookup(c *checker) error {
+	to, err := c.progress(stages.TxLookup)
+	if err != nil {
+		return err
+	}
+	for n := uint64(1); n <= to; n++ {
+		if err = common.Stopped(c.quit); err != nil {
+			return err
+		}
+		_, body, err := readBody(c, n)
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			continue
+		}
+		blockNumBytes := new(big.Int).SetUint64(n).Bytes()
+		for _, txn := range body.Transactions {
+			v, err := c.db.Get(dbutils.TxLookupPrefix, txn.Hash().Bytes())
+			if errors.Is(err, ethdb.ErrKeyNotFound) {
+				err = c.fail(dbutils.TxLookupPrefix, n, txn.Hash().Bytes(), "transaction is not found")
+			} else if err == nil && !bytes.Equal(v, blockNumBytes) {
+				err = c.fail(dbutils.TxLookupPrefix, n, txn.Hash().Bytes(), "transaction points to block %d", new(big.Int).SetBytes(v).Uint64())
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type historyIndex struct {
+	csBucket string
+	stage    stages.SyncStage
+}
+
+var (
+	accountHistoryIndex = historyIndex{dbutils.PlainAccountChangeSetBucket, stages.AccountHistoryIndex}
+	storageHistoryIndex = historyIndex{dbutils.PlainStorageChangeSetBucket, stages.StorageHistoryIndex}
+)
+
+// HistoryIndexCheck returns the "history_index" check limited to one history index and its changesets,
+// plain or hashed ones
+func HistoryIndexCheck(csBucket string, indexBucket string) (*Check, error) {
+	var index historyIndex
+	switch {
+	case (csBucket == dbutils.PlainAccountChangeSetBucket || csBucket == dbutils.AccountChangeSetBucket) && indexBucket == dbutils.AccountsHistoryBucket:
+		index = accountHistoryIndex
+	case (csBucket == dbutils.PlainStorageChangeSetBucket || csBucket == dbutils.StorageChangeSetBucket) && indexBucket == dbutils.StorageHistoryBucket:
+		index = storageHistoryIndex
+	default:
+		return nil, fmt.Errorf("%s is not the history index of changeset bucket %s", indexBucket, csBucket)
+	}
+	check := *historyIndexCheck
+	check.run = func(c *checker) error {
+		return checkHistoryIndex(c, index)
+	}
+	return &check, nil
+}
+
+func checkHistoryIndex(c *checker, indices ...historyIndex) error {
+	for _, v := range indices {
+		to, err := c.progress(v.stage)
+		if err != nil {
+			return err
+		}
+		if to == 0 {
+			continue // the stage builds the index from scratch, including the changesets of genesis
+		}
+		indexBucket := changeset.Mapper[v.csBucket].IndexBucket
+		walkerAdapter := changeset.Mapper[v.csBucket].WalkerAdapter
+		index := c.tx.Cursor(indexBucket)
+		if err = ethdb.Walk(c.tx.Cursor(v.csBucket), nil, 0, func(k, cs []byte) (bool, error) {
+			if err := common.Stopped(c.quit); err != nil {
+				return false, err
+			}
+			blockNum, _ := dbutils.DecodeTimestamp(k)
+			if blockNum > to {
+				return false, nil
+			}
+			walkErr := walkerAdapter(cs).Walk(func(key, _ []byte) error {
+				return checkIndexChunk(c, index, indexBucket, key, blockNum)
+			})
+			if walkErr == nil || errors.Is(walkErr, errTooManyFindings) {
+				return true, walkErr
+			}
+			return true, c.fail(v.csBucket, blockNum, k, "invalid changeset: %v", walkErr)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkIndexChunk checks that the chunk of the index containing the block has it
+func checkIndexChunk(c *checker, index ethdb.Cursor, indexBucket string, key []byte, blockNum uint64) error {
+	k, chunk, err := index.Seek(dbutils.IndexChunkKey(key, blockNum))
+	if err != nil {
+		return err
+	}
+	if k == nil || !bytes.HasPrefix(k, dbutils.CompositeKeyWithoutIncarnation(key)) {
+		return c.fail(indexBucket, blockNum, key, "no index chunk")
+	}
+	if len(chunk) < 8 || (len(chunk)-8)%dbutils.ItemLen != 0 {
+		return c.fail(indexBucket, blockNum, k, "invalid index chunk of length %d", len(chunk))
+	}
+	if found, _, ok := dbutils.WrapHistoryIndex(chunk).Search(blockNum); !ok || found != blockNum {
+		return c.fail(indexBucket, blockNum, key, "block is not in the index")
+	}
+	return nil
+}
+
+func checkHashedState(c *checker) error {
+	hashState, err := c.progress(stages.HashState)
+	if err != nil {
+		return err
+	}
+	execution, err := c.progress(stages.Execution)
+	if err != nil {
+		return err
+	}
+	if hashState != execution {
+		return c.skip(fmt.Sprintf("HashState stage is at block %d, Execution at %d", hashState, execution))
+	}
+	if err = comparePlainWithHashed(c, hashState, dbutils.PlainStateBucket, dbutils.CurrentStateBucket, stagedsync.TransformPlainStateKey); err != nil {
+		return err
+	}
+	return comparePlainWithHashed(c, hashState, dbutils.PlainContractCodeBucket, dbutils.ContractCodeBucket, stagedsync.TransformContractCodeKey)
+}
+
+// comparePlainWithHashed checks that every entry of the plain bucket is in the hashed one, and that there are
+// no other entries in the hashed bucket
+func comparePlainWithHashed(c *checker, block uint64, plainBucket, hashedBucket string, transformKey func([]byte) ([]byte, error)) error {
+	var plainCount uint64
+	if err := ethdb.Walk(c.tx.Cursor(plainBucket), nil, 0, func(k, v []byte) (bool, error) {
+		if err := common.Stopped(c.quit); err != nil {
+			return false, err
+		}
+		plainCount++
+		hashedKey, err := transformKey(k)
+		if err != nil {
+			return true, c.fail(plainBucket, block, k, "%v", err)
+		}
+		hashedV, err := c.db.Get(hashedBucket, hashedKey)
+		if errors.Is(err, ethdb.ErrKeyNotFound) {
+			return true, c.fail(hashedBucket, block, hashedKey, "no hashed entry of plain key %x", k)
+		}
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(v, hashedV) {
+			return true, c.fail(hashedBucket, block, hashedKey, "value %x, plain value %x", hashedV, v)
+		}
+		return true, nil
+	}); err != nil {
+		return err
+	}
+	var hashedCount uint64
+	if err := ethdb.Walk(c.tx.Cursor(hashedBucket), nil, 0, func(k, v []byte) (bool, error) {
+		hashedCount++
+		return true, common.Stopped(c.quit)
+	}); err != nil {
+		return err
+	}
+	if hashedCount != plainCount {
+		return c.fail(hashedBucket, block, nil, "%d entries, %d in %s", hashedCount, plainCount, plainBucket)
+	}
+	return nil
+}
+
+func checkStateRoot(c *checker) error {
+	ih, err := c.progress(stages.IntermediateHashes)
+	if err != nil {
+		return err
+	}
+	hashState, err := c.progress(stages.HashState)
+	if err != nil {
+		return err
+	}
+	if ih == 0 {
+		return c.skip("IntermediateHashes stage is not done")
+	}
+	if ih != hashState {
+		return c.skip(fmt.Sprintf("IntermediateHashes stage is at block %d, HashState at %d", ih, hashState))
+	}
+	hash, err := rawdb.ReadCanonicalHash(c.db, ih)
+	if err != nil {
+		return err
+	}
+	header := rawdb.ReadHeader(c.db, hash, ih)
+	if header == nil {
+		return c.fail(dbutils.HeaderPrefix, ih, dbutils.HeaderKey(ih, hash), "no canonical header")
+	}
+	loader := trie.NewFlatDBTrieLoader("fsck", dbutils.CurrentStateBucket, dbutils.IntermediateTrieHashBucket)
+	if err = loader.Reset(trie.NewRetainList(0), nil /* HashCollector */, false); err != nil {
+		return err
+	}
+	root, err := loader.CalcTrieRoot(c.db, c.quit)
+	if err != nil {
+		return err
+	}
+	if root != header.Root {
+		return c.fail(dbutils.IntermediateTrieHashBucket, ih, nil, "state root %x, header root %x", root, header.Root)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// Package fsck checks the invariants which hold between the buckets of chaindata: every canonical header has a body,
+// senders match the transactions, changesets match the history indices, hashed state matches plain state,
+// intermediate hashes match the state root, etc.
+//
+// All checks run in one read transaction, so they see a consistent snapshot of the database and can run
+// while the node is syncing. The only checked blocks are the ones which the stage producing the bucket
+// has already reached. Keep in mind that the long read transaction prevents the database from reusing the freed
+// pages, so the database file grows while the check runs.
+//
+// The buckets which are derived by the stages from other buckets can be repaired: the stage is reset, and the next
+// sync cycle generates them from scratch.
+package fsck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+)
+
+// DefaultMaxFindings - the check stops after reporting that many findings, the rest are usually the consequences
+// of the same problem
+const DefaultMaxFindings = 100
+
+var errTooManyFindings = errors.New("too many findings")
+
+// Finding - the violation of the invariant found by the check
+type Finding struct {
+	Check  string
+	Bucket string // where the inconsistency is found
+	Block  uint64
+	Key    []byte // nil if the finding is not about a specific key
+	Msg    string
+}
+
+func (f Finding) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %s, bucket %s, block %d", f.Check, f.Msg, f.Bucket, f.Block)
+	if f.Key != nil {
+		fmt.Fprintf(&sb, ", key %x", f.Key)
+	}
+	return sb.String()
+}
+
+// Check verifies one invariant
+type Check struct {
+	Name        string
+	Description string
+	// Repair resets the stages producing the checked buckets, nil if the buckets can't be derived from anything else
+	Repair func(db ethdb.Database) error
+	run    func(c *checker) error
+}
+
+// Report - the result of Run
+type Report struct {
+	Findings []Finding
+	Skipped  map[string]string // the reasons the checks are skipped for, by name of the check
+}
+
+// Failed returns the names of the checks which found something, in the order they run
+func (r *Report) Failed() []string {
+	var names []string
+	for _, f := range r.Findings {
+		if len(names) == 0 || names[len(names)-1] != f.Check {
+			names = append(names, f.Check)
+		}
+	}
+	return names
+}
+
+type Options struct {
+	MaxFindings int // per check, DefaultMaxFindings if 0
+	Quit        <-chan struct{}
+}
+
+// checker is the state of the running check
+type checker struct {
+	name        string
+	db          ethdb.Database // read-only transaction
+	tx          ethdb.Tx
+	quit        <-chan struct{}
+	report      *Report
+	found       int
+	maxFindings int
+}
+
+func (c *checker) fail(bucket string, block uint64, key []byte, format string, args ...interface{}) error {
+	c.report.Findings = append(c.report.Findings, Finding{Check: c.name, Bucket: bucket, Block: block, Key: common.CopyBytes(key), Msg: fmt.Sprintf(format, args...)})
+	c.found++
+	if c.found >= c.maxFindings {
+		return errTooManyFindings
+	}
+	return nil
+}
+
+func (c *checker) skip(reason string) error {
+	c.report.Skipped[c.name] = reason
+	return nil
+}
+
+func (c *checker) progress(stage stages.SyncStage) (uint64, error) {
+	progress, _, err := stages.GetStageProgress(c.db, stage)
+	return progress, err
+}
+
+// Lookup returns the checks by their names, all of them if names are empty
+func Lookup(names []string) ([]*Check, error) {
+	if len(names) == 0 {
+		return Checks, nil
+	}
+	checks := make([]*Check, 0, len(names))
+	for _, name := range names {
+		var found *Check
+		for _, check := range Checks {
+			if check.Name == name {
+				found = check
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("unknown check: %s", name)
+		}
+		checks = append(checks, found)
+	}
+	return checks, nil
+}
+
+// Run runs the checks in one read transaction
+func Run(db ethdb.Database, checks []*Check, opts Options) (*Report, error) {
+	if opts.MaxFindings == 0 {
+		opts.MaxFindings = DefaultMaxFindings
+	}
+	tx, err := db.Begin(context.Background(), ethdb.RO)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := &Report{Skipped: map[string]string{}}
+	for _, check := range checks {
+		log.Info("Checking", "check", check.Name)
+		c := &checker{name: check.Name, db: tx, tx: tx.(ethdb.HasTx).Tx(), quit: opts.Quit, report: report, maxFindings: opts.MaxFindings}
+		if err := check.run(c); err != nil {
+			if !errors.Is(err, errTooManyFindings) {
+				return report, fmt.Errorf("%s: %w", check.Name, err)
+			}
+			log.Warn("Too many findings, the rest of the check is skipped", "check", check.Name)
+		}
+		if reason, ok := report.Skipped[check.Name]; ok {
+			log.Info("Check skipped", "check", check.Name, "reason", reason)
+		} else {
+			log.Info("Check done", "check", check.Name, "findings", c.found)
+		}
+	}
+	return report, nil
+}
+
+// Repair resets the stages of the failed checks in one write transaction, and returns the names of
+// the checks which can't be repaired
+func Repair(db ethdb.Database, report *Report) ([]string, error) {
+	failed := report.Failed()
+	checks, err := Lookup(failed)
+	if err != nil || len(checks) == 0 {
+		return nil, err
+	}
+	tx, err := db.Begin(context.Background(), ethdb.RW)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var unrepairable []string
+	for _, check := range checks {
+		if check.Repair == nil {
+			unrepairable = append(unrepairable, check.Name)
+			continue
+		}
+		log.Info("Repairing", "check", check.Name)
+		if err := check.Repair(tx); err != nil {
+			return nil, fmt.Errorf("repairing %s: %w", check.Name, err)
+		}
+	}
+	if _, err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return unrepairable, nil
+}
+
+// resetStages clears the buckets and resets the progress of the stages, so they generate the buckets from scratch
+func resetStages(db ethdb.Database, ss []stages.SyncStage, buckets ...string) error {
+	if err := db.(ethdb.BucketsMigrator).ClearBuckets(buckets...); err != nil {
+		return err
+	}
+	for _, stage := range ss {
+		if err := stages.SaveStageProgress(db, stage, 0, nil); err != nil {
+			return err
+		}
+		if err := stages.SaveStageUnwind(db, stage, 0, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
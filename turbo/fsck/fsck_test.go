@@ -0,0 +1,268 @@
+package fsck
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/consensus/ethash"
+	"github.com/ledgerwatch/turbo-geth/core"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/core/vm"
+	"github.com/ledgerwatch/turbo-geth/crypto"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/params"
+	"github.com/stretchr/testify/require"
+)
+
+// newSyncedDB creates the database with the blocks, which have transfers to many accounts and calls to the contract
+// storing the first word of the call data, and runs the stages producing the checked buckets
+func newSyncedDB(t *testing.T, blocks int, tmpdir string) *ethdb.ObjectDatabase {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	bank := crypto.PubkeyToAddress(key.PublicKey)
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{bank: {Balance: big.NewInt(1000000000000000000)}},
+	}
+	db := ethdb.NewMemDatabase()
+	genesis := gspec.MustCommit(db)
+	signer := types.HomesteadSigner{}
+
+	runtime := []byte{byte(vm.PUSH1), 0, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+	deploy := append([]byte{
+		byte(vm.PUSH1), byte(len(runtime)), byte(vm.PUSH1), 12, byte(vm.PUSH1), 0, byte(vm.CODECOPY),
+		byte(vm.PUSH1), byte(len(runtime)), byte(vm.PUSH1), 0, byte(vm.RETURN),
+	}, runtime...)
+	contract := crypto.CreateAddress(bank, 0)
+
+	genDB := ethdb.NewMemDatabase()
+	defer genDB.Close()
+	gspec.MustCommit(genDB)
+	chain, _, err := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), genDB, blocks, func(i int, block *core.BlockGen) {
+		var txs []*types.Transaction
+		if i == 0 {
+			txs = append(txs, types.NewContractCreation(block.TxNonce(bank), new(uint256.Int), 100000, new(uint256.Int), deploy))
+		} else {
+			txs = append(txs, types.NewTransaction(block.TxNonce(bank), contract, new(uint256.Int), 100000, new(uint256.Int), common.LeftPadBytes([]byte{byte(i)}, 32)))
+		}
+		for j := 0; j < 10; j++ {
+			receiver := common.BytesToAddress(crypto.Keccak256([]byte{byte(i), byte(j)}))
+			txs = append(txs, types.NewTransaction(block.TxNonce(bank)+uint64(len(txs)), receiver, uint256.NewInt().SetUint64(1000), params.TxGas, new(uint256.Int), nil))
+		}
+		for _, tx := range txs {
+			signedTx, err1 := types.SignTx(tx, signer, key)
+			require.NoError(t, err1)
+			block.AddTx(signedTx)
+		}
+	}, false /* intermediateHashes */)
+	require.NoError(t, err)
+
+	for _, block := range chain {
+		require.NoError(t, rawdb.WriteBlock(context.Background(), db, block))
+		require.NoError(t, rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64()))
+		senders := make([]common.Address, len(block.Transactions()))
+		for i, txn := range block.Transactions() {
+			senders[i], err = types.Sender(signer, txn)
+			require.NoError(t, err)
+		}
+		rawdb.WriteSenders(context.Background(), db, block.Hash(), block.NumberU64(), senders)
+	}
+	for _, stage := range []stages.SyncStage{stages.Headers, stages.Bodies, stages.Senders} {
+		require.NoError(t, stages.SaveStageProgress(db, stage, uint64(blocks), nil))
+	}
+	cc := &core.TinyChainContext{}
+	cc.SetDB(db)
+	cc.SetEngine(ethash.NewFaker())
+	require.NoError(t, stagedsync.SpawnExecuteBlocksStage(&stagedsync.StageState{Stage: stages.Execution}, db, gspec.Config, cc, &vm.Config{}, nil, stagedsync.ExecuteBlockStageParams{}))
+	require.NoError(t, stagedsync.SpawnHashStateStage(&stagedsync.StageState{Stage: stages.HashState}, db, tmpdir, nil))
+	require.NoError(t, stagedsync.SpawnIntermediateHashesStage(&stagedsync.StageState{Stage: stages.IntermediateHashes}, db, tmpdir, nil))
+	require.NoError(t, stagedsync.SpawnAccountHistoryIndex(&stagedsync.StageState{Stage: stages.AccountHistoryIndex}, db, tmpdir, nil))
+	require.NoError(t, stagedsync.SpawnStorageHistoryIndex(&stagedsync.StageState{Stage: stages.StorageHistoryIndex}, db, tmpdir, nil))
+	require.NoError(t, stagedsync.SpawnTxLookup(&stagedsync.StageState{Stage: stages.TxLookup}, db, tmpdir, nil))
+	return db
+}
+
+// corruptFirst replaces the value of the first entry of the bucket
+func corruptFirst(t *testing.T, db ethdb.Database, bucket string) {
+	tx, err := db.Begin(context.Background(), ethdb.RW)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	c := tx.(ethdb.HasTx).Tx().Cursor(bucket)
+	k, v, err := c.First()
+	require.NoError(t, err)
+	require.NotNil(t, k, "bucket %s is empty", bucket)
+	k, v = common.CopyBytes(k), common.CopyBytes(v)
+	v[len(v)-1] ^= 0xff
+	require.NoError(t, c.DeleteCurrent())
+	require.NoError(t, c.Put(k, v))
+	_, err = tx.Commit()
+	require.NoError(t, err)
+}
+
+// writeWitness pretends that the BlockWitness stage has built the witness of the last block
+func writeWitness(t *testing.T, db ethdb.Database) {
+	require.NoError(t, rawdb.WriteBlockWitness(db, 10, []byte{1}))
+	require.NoError(t, stages.SaveStageProgress(db, stages.BlockWitness, 10, nil))
+}
+
+func TestFsck(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "fsck")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	db := newSyncedDB(t, 10, tmpdir)
+	defer db.Close()
+
+	report, err := Run(db, Checks, Options{})
+	require.NoError(t, err)
+	require.Empty(t, report.Findings)
+	require.Empty(t, report.Skipped)
+
+	block3 := rawdb.ReadHeaderByNumber(db, 3)
+	for _, tc := range []struct {
+		check        string
+		corrupt      func()
+		findings     int
+		stages       []stages.SyncStage
+		buckets      []string
+		unrepairable bool
+	}{
+		{
+			check: "senders",
+			corrupt: func() {
+				senders := rawdb.ReadSenders(db, block3.Hash(), 3)
+				rawdb.WriteSenders(context.Background(), db, block3.Hash(), 3, senders[1:])
+			},
+			findings: 1,
+			stages:   []stages.SyncStage{stages.Senders},
+			buckets:  []string{dbutils.Senders},
+		},
+		{
+			check: "tx_lookup",
+			corrupt: func() {
+				body := rawdb.ReadBody(db, block3.Hash(), 3)
+				require.NoError(t, db.Delete(dbutils.TxLookupPrefix, body.Transactions[0].Hash().Bytes(), nil))
+				require.NoError(t, db.Put(dbutils.TxLookupPrefix, body.Transactions[1].Hash().Bytes(), big.NewInt(4).Bytes()))
+			},
+			findings: 2,
+			stages:   []stages.SyncStage{stages.TxLookup},
+			buckets:  []string{dbutils.TxLookupPrefix},
+		},
+		{
+			check: "history_index",
+			corrupt: func() {
+				k, _, err := db.Last(dbutils.StorageHistoryBucket)
+				require.NoError(t, err)
+				require.NoError(t, db.Delete(dbutils.StorageHistoryBucket, k, nil))
+			},
+			findings: 9, // the slot is changed by every block but the first one
+			stages:   []stages.SyncStage{stages.AccountHistoryIndex, stages.StorageHistoryIndex},
+			buckets:  []string{dbutils.AccountsHistoryBucket, dbutils.StorageHistoryBucket},
+		},
+		{
+			check: "state_root",
+			corrupt: func() {
+				writeWitness(t, db)
+				corruptFirst(t, db, dbutils.IntermediateTrieHashBucket)
+			},
+			findings: 1,
+			stages:   []stages.SyncStage{stages.IntermediateHashes, stages.BlockWitness},
+			buckets:  []string{dbutils.IntermediateTrieHashBucket, dbutils.BlockWitnessBucket},
+		},
+		{
+			check: "hashed_state",
+			corrupt: func() {
+				writeWitness(t, db)
+				require.NoError(t, stages.SaveStageProgress(db, stages.BinaryHashes, 10, nil))
+				require.NoError(t, db.Put(dbutils.BinaryStateRootBucket, dbutils.EncodeBlockNumber(10), common.Hash{3}.Bytes()))
+				corruptFirst(t, db, dbutils.CurrentStateBucket)
+				require.NoError(t, db.Put(dbutils.ContractCodeBucket, dbutils.GenerateStoragePrefix(common.Hash{1}.Bytes(), 1), common.Hash{2}.Bytes()))
+			},
+			findings: 2,
+			stages:   []stages.SyncStage{stages.HashState, stages.IntermediateHashes, stages.BinaryHashes, stages.BlockWitness},
+			buckets: []string{dbutils.CurrentStateBucket, dbutils.ContractCodeBucket, dbutils.IntermediateTrieHashBucket,
+				dbutils.BinaryStateRootBucket, dbutils.BlockWitnessBucket},
+		},
+		{
+			check: "bodies",
+			corrupt: func() {
+				require.NoError(t, db.Delete(dbutils.BlockBodyPrefix, dbutils.BlockBodyKey(3, block3.Hash()), nil))
+			},
+			findings:     1,
+			unrepairable: true,
+		},
+		{
+			check: "headers",
+			corrupt: func() {
+				require.NoError(t, db.Delete(dbutils.HeaderPrefix, dbutils.HeaderKey(3, block3.Hash()), nil))
+			},
+			findings:     1,
+			unrepairable: true,
+		},
+	} {
+		tc.corrupt()
+		checks, err := Lookup([]string{tc.check})
+		require.NoError(t, err)
+		report, err = Run(db, checks, Options{})
+		require.NoError(t, err)
+		require.Equal(t, tc.findings, len(report.Findings), "%s: %v", tc.check, report.Findings)
+		require.Equal(t, []string{tc.check}, report.Failed())
+
+		unrepairable, err := Repair(db, report)
+		require.NoError(t, err)
+		if tc.unrepairable {
+			require.Equal(t, []string{tc.check}, unrepairable)
+			continue
+		}
+		require.Empty(t, unrepairable)
+		for _, stage := range tc.stages {
+			progress, _, err := stages.GetStageProgress(db, stage)
+			require.NoError(t, err)
+			require.Zero(t, progress, "%s: stage %s", tc.check, stage)
+		}
+		for _, bucket := range tc.buckets {
+			require.NoError(t, db.Walk(bucket, nil, 0, func(k, v []byte) (bool, error) {
+				t.Fatalf("%s: bucket %s is not cleared", tc.check, bucket)
+				return false, nil
+			}))
+		}
+	}
+
+	report, err = Run(db, Checks, Options{MaxFindings: 1})
+	require.NoError(t, err)
+	require.Equal(t, []string{"headers", "bodies"}, report.Failed())
+	require.Contains(t, report.Skipped, "hashed_state", "HashState stage is reset")
+	require.Contains(t, report.Skipped, "state_root")
+}
+
+func TestHistoryIndexCheck(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "fsck")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	db := newSyncedDB(t, 3, tmpdir)
+	defer db.Close()
+
+	_, err = HistoryIndexCheck(dbutils.AccountChangeSetBucket, dbutils.StorageHistoryBucket)
+	require.Error(t, err)
+
+	k, _, err := db.Last(dbutils.StorageHistoryBucket)
+	require.NoError(t, err)
+	require.NoError(t, db.Delete(dbutils.StorageHistoryBucket, k, nil))
+	accounts, err := HistoryIndexCheck(dbutils.AccountChangeSetBucket, dbutils.AccountsHistoryBucket)
+	require.NoError(t, err)
+	storage, err := HistoryIndexCheck(dbutils.PlainStorageChangeSetBucket, dbutils.StorageHistoryBucket)
+	require.NoError(t, err)
+	report, err := Run(db, []*Check{accounts}, Options{})
+	require.NoError(t, err)
+	require.Empty(t, report.Findings)
+	report, err = Run(db, []*Check{storage}, Options{})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(report.Findings), "%v", report.Findings)
+}
@@ -0,0 +1,18 @@
+//+build plugins
+
+package plugins
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// Open loads Go plugins from the comma-separated list of paths, plugins register their stages on load
+func Open(paths string) error {
+	for _, path := range splitList(paths) {
+		if _, err := plugin.Open(path); err != nil {
+			return fmt.Errorf("loading plugin %s: %w", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//+build !plugins
+
+package plugins
+
+import "fmt"
+
+// Open fails if any plugins are given, the node is built without support of Go plugins
+func Open(paths string) error {
+	if list := splitList(paths); len(list) > 0 {
+		return fmt.Errorf("can't load plugins %v, to use Go plugins, compile with -tags 'plugins'", list)
+	}
+	return nil
+}
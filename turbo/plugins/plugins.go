@@ -0,0 +1,86 @@
+// Package plugins loads third-party sync stages: Go plugins, which register their stages with `Register`,
+// and out-of-process stages, which implement the `Stage` gRPC service.
+//
+// A Go plugin is built with `go build -buildmode=plugin` against the same version of turbo-geth as the node,
+// which is built with `-tags plugins` (loading of plugins needs dynamic linking of the node, so it's off by default),
+// and registers its stages from the `init` function:
+//
+//	func init() {
+//		plugins.Register(stagedsync.ExtraStage{
+//			ID:      stages.SyncStage("com.example.erc20-transfers"),
+//			After:   stages.LogIndex,
+//			Buckets: dbutils.BucketsCfg{"com.example.ERC20_TRANSFERS": {}},
+//			Forward: forward,
+//			Unwind:  unwind,
+//		})
+//	}
+package plugins
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+)
+
+//go:generate protoc --proto_path=. --go_out=.. --go-grpc_out=.. "stage.proto" -I=. -I=./../../build/include/google
+
+var (
+	registeredLock sync.Mutex
+	registered     []stagedsync.ExtraStage
+)
+
+// Register adds the stage to the list of stages, which is returned by `Registered`.
+// It is meant to be called from `init` of a Go plugin.
+func Register(stage stagedsync.ExtraStage) {
+	registeredLock.Lock()
+	defer registeredLock.Unlock()
+	registered = append(registered, stage)
+}
+
+// Registered returns the stages registered by the loaded plugins, in order of registration
+func Registered() []stagedsync.ExtraStage {
+	registeredLock.Lock()
+	defer registeredLock.Unlock()
+	return append([]stagedsync.ExtraStage{}, registered...)
+}
+
+// ParseRemote parses the comma-separated list of out-of-process stages in the form `<id>:<after>@<host:port>`,
+// for example `com.example.erc20-transfers:LogIndex@localhost:9095`.
+func ParseRemote(list string) ([]stagedsync.ExtraStage, error) {
+	var result []stagedsync.ExtraStage
+	for _, item := range splitList(list) {
+		at := strings.Index(item, "@")
+		colon := strings.Index(item, ":")
+		if at == -1 || colon == -1 || colon > at || colon == 0 || at == len(item)-1 {
+			return nil, fmt.Errorf("invalid remote stage %q, expected <id>:<after>@<host:port>", item)
+		}
+		after := stages.SyncStage(item[colon+1 : at])
+		result = append(result, Remote(stages.SyncStage(item[:colon]), after, item[at+1:]))
+	}
+	return result, nil
+}
+
+// Load returns all third-party stages: loads Go plugins and adds the out-of-process stages
+func Load(pluginPaths string, remoteStages string) ([]stagedsync.ExtraStage, error) {
+	if err := Open(pluginPaths); err != nil {
+		return nil, err
+	}
+	remote, err := ParseRemote(remoteStages)
+	if err != nil {
+		return nil, err
+	}
+	return append(Registered(), remote...), nil
+}
+
+func splitList(list string) []string {
+	var result []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
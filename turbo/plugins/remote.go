@@ -0,0 +1,132 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+)
+
+// Remote returns the stage, which is driven by the node and executed out of process, by the `Stage` gRPC service at `addr`.
+// The stage processes blocks up to the progress of the stage `after`. The node keeps the progress of the stage
+// and saves it after each `Progress` reply, the remote process reads chain data through the private API
+// of the node (see `--private.api.addr`) and keeps its output on its own. The transaction of the sync cycle
+// is committed before the remote stage is driven, so the remote process sees the blocks it is asked to process.
+func Remote(id stages.SyncStage, after stages.SyncStage, addr string) stagedsync.ExtraStage {
+	r := &remoteStage{addr: addr, after: after}
+	return stagedsync.ExtraStage{
+		ID:          id,
+		Description: "Out-of-process stage at " + addr,
+		After:       after,
+		DependsOn:   []stages.SyncStage{after},
+		Forward:     r.forward,
+		Unwind:      r.unwind,
+	}
+}
+
+type remoteStage struct {
+	addr   string
+	after  stages.SyncStage
+	client StageClient
+}
+
+func (r *remoteStage) connect() (StageClient, error) {
+	if r.client != nil {
+		return r.client, nil
+	}
+	// the connection is established in background and re-established after failures
+	conn, err := grpc.Dial(r.addr,
+		grpc.WithInsecure(),
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: backoff.DefaultConfig, MinConnectTimeout: 10 * time.Second}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to remote stage %s: %w", r.addr, err)
+	}
+	r.client = NewStageClient(conn)
+	return r.client, nil
+}
+
+func (r *remoteStage) forward(s *stagedsync.StageState, db ethdb.Database, quitCh <-chan struct{}) error {
+	client, err := r.connect()
+	if err != nil {
+		return err
+	}
+	to, _, err := stages.GetStageProgress(db, r.after)
+	if err != nil {
+		return err
+	}
+	if to <= s.BlockNumber {
+		s.Done()
+		return nil
+	}
+	// the remote process reads the blocks up to `to` through the private API, so they must be visible to other
+	// transactions, but when the whole cycle runs in one transaction they're not committed yet
+	if hasTx, ok := db.(ethdb.HasTx); ok && hasTx.Tx() != nil {
+		if err = db.(ethdb.DbWithPendingMutations).CommitAndBegin(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := withQuit(quitCh)
+	defer cancel()
+	stream, err := client.Forward(ctx, &ForwardRequest{From: s.BlockNumber, To: to})
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.Stage, err)
+	}
+	progress := s.BlockNumber
+	for {
+		reply, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.Stage, err)
+		}
+		if reply.Block < progress || reply.Block > to {
+			return fmt.Errorf("%s: progress %d is out of range [%d, %d]", s.Stage, reply.Block, progress, to)
+		}
+		progress = reply.Block
+		if err = s.Update(db, progress); err != nil {
+			return err
+		}
+	}
+	if progress < to {
+		log.Warn("Remote stage stopped before the target block", "stage", string(s.Stage), "block", progress, "target", to)
+	}
+	s.Done()
+	return nil
+}
+
+func (r *remoteStage) unwind(u *stagedsync.UnwindState, s *stagedsync.StageState, db ethdb.Database, quitCh <-chan struct{}) error {
+	client, err := r.connect()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withQuit(quitCh)
+	defer cancel()
+	if _, err = client.Unwind(ctx, &UnwindRequest{UnwindPoint: u.UnwindPoint}); err != nil {
+		return fmt.Errorf("%s: %w", s.Stage, err)
+	}
+	return u.Done(db)
+}
+
+// withQuit returns the context, which is cancelled when the node stops
+func withQuit(quitCh <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-quitCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
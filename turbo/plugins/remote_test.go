@@ -0,0 +1,119 @@
+package plugins
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type testStageServer struct {
+	UnimplementedStageServer
+	forwards    []*ForwardRequest
+	unwindPoint uint64
+	db          ethdb.Database // read by the stage as through the private API, nil if it doesn't read
+	seen        []uint64       // progress of Execution seen by the stage
+}
+
+func (s *testStageServer) Forward(req *ForwardRequest, stream Stage_ForwardServer) error {
+	s.forwards = append(s.forwards, req)
+	if s.db != nil {
+		progress, _, err := stages.GetStageProgress(s.db, stages.Execution)
+		if err != nil {
+			return err
+		}
+		s.seen = append(s.seen, progress)
+	}
+	for block := req.From + 10; block <= req.To; block += 10 {
+		if err := stream.Send(&Progress{Block: block}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *testStageServer) Unwind(_ context.Context, req *UnwindRequest) (*Progress, error) {
+	s.unwindPoint = req.UnwindPoint
+	return &Progress{Block: req.UnwindPoint}, nil
+}
+
+func startStageServer(t *testing.T, srv *testStageServer) (addr string, stop func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	RegisterStageServer(grpcServer, srv)
+	go grpcServer.Serve(l) //nolint:errcheck
+	return l.Addr().String(), grpcServer.Stop
+}
+
+func TestRemoteStage(t *testing.T) {
+	srv := &testStageServer{}
+	addr, stop := startStageServer(t, srv)
+	defer stop()
+
+	extra, err := ParseRemote("com.example.remote:Execution@" + addr)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(extra))
+	id := stages.SyncStage("com.example.remote")
+	require.Equal(t, id, extra[0].ID)
+	require.Equal(t, stages.Execution, extra[0].After)
+
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	require.NoError(t, stages.SaveStageProgress(db, stages.Execution, 100, nil))
+
+	require.NoError(t, extra[0].Forward(&stagedsync.StageState{Stage: id, BlockNumber: 0}, db, nil))
+	progress, _, err := stages.GetStageProgress(db, id)
+	require.NoError(t, err)
+	require.Equal(t, 100, int(progress))
+	require.Equal(t, 1, len(srv.forwards))
+	require.Equal(t, 0, int(srv.forwards[0].From))
+	require.Equal(t, 100, int(srv.forwards[0].To))
+
+	// nothing to do, the remote stage is not called
+	require.NoError(t, extra[0].Forward(&stagedsync.StageState{Stage: id, BlockNumber: 100}, db, nil))
+	require.Equal(t, 1, len(srv.forwards))
+
+	require.NoError(t, extra[0].Unwind(&stagedsync.UnwindState{Stage: id, UnwindPoint: 50}, &stagedsync.StageState{Stage: id, BlockNumber: 100}, db, nil))
+	require.Equal(t, 50, int(srv.unwindPoint))
+	progress, _, err = stages.GetStageProgress(db, id)
+	require.NoError(t, err)
+	require.Equal(t, 50, int(progress))
+
+	_, err = ParseRemote("com.example.remote@localhost:9000")
+	require.Error(t, err)
+}
+
+// TestRemoteStageInCycleTx - when the sync cycle runs in one transaction, the blocks the remote stage is asked
+// to process must be visible to the remote process
+func TestRemoteStageInCycleTx(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	srv := &testStageServer{db: db}
+	addr, stop := startStageServer(t, srv)
+	defer stop()
+	id := stages.SyncStage("com.example.remote")
+	stage := Remote(id, stages.Execution, addr)
+
+	tx, err := db.Begin(context.Background(), ethdb.RW)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	require.NoError(t, stages.SaveStageProgress(tx, stages.Execution, 100, nil))
+	require.NoError(t, stage.Forward(&stagedsync.StageState{Stage: id, BlockNumber: 0}, tx, nil))
+	require.Equal(t, []uint64{100}, srv.seen)
+
+	// the transaction stays usable for the rest of the cycle
+	progress, _, err := stages.GetStageProgress(tx, id)
+	require.NoError(t, err)
+	require.Equal(t, 100, int(progress))
+	require.NoError(t, stages.SaveStageProgress(tx, stages.Execution, 200, nil))
+	require.NoError(t, stage.Forward(&stagedsync.StageState{Stage: id, BlockNumber: 100}, tx, nil))
+	require.Equal(t, []uint64{100, 200}, srv.seen)
+	_, err = tx.Commit()
+	require.NoError(t, err)
+}
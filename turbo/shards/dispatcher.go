@@ -0,0 +1,207 @@
+package shards
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/turbo-geth/log"
+)
+
+// Dispatcher relays the state reads between the shards. Every shard executes all the blocks, but reads only the keys
+// of its own shard, and sends them to the dispatcher, which broadcasts them to all other shards.
+// The dispatcher waits for all shards to connect before relaying anything. If any shard fails (its stream breaks),
+// the session is aborted for all shards, so that none of them waits forever for the reads which will never come.
+// After all shards are disconnected, a new session can start - the shards which connect earlier wait for it.
+type Dispatcher struct {
+	UnimplementedDispatcherServer
+	shardCount int
+	latency    time.Duration // artificial latency of relaying, for testing
+
+	lock        sync.Mutex
+	connections map[int]Dispatcher_StartDispatchServer
+	nextConnID  int
+	ready       chan struct{} // closed when all shards of the session are connected
+	failed      chan struct{} // closed when the session is aborted
+	over        chan struct{} // closed when all shards of the started session are disconnected
+	err         error         // reason of the abort
+
+	// broadcasts are atomic, so that a read is relayed to every shard before any read which depends on it
+	// (sent by a shard after receiving the first one). It also makes sure that a stream is never sent to concurrently.
+	broadcastLock sync.Mutex
+}
+
+func NewDispatcher(shardCount int, latency time.Duration) *Dispatcher {
+	d := &Dispatcher{
+		shardCount:  shardCount,
+		latency:     latency,
+		connections: make(map[int]Dispatcher_StartDispatchServer),
+	}
+	d.resetSession()
+	return d
+}
+
+func (d *Dispatcher) resetSession() {
+	if d.over != nil {
+		close(d.over)
+	}
+	d.over = make(chan struct{})
+	d.ready = make(chan struct{})
+	d.failed = make(chan struct{})
+	d.err = nil
+}
+
+// addConnection adds the shard to the session which is not started yet, waiting for the previous session to be over
+// (e.g. the shards end the session to unwind, and the first of them reconnects before the last one disconnects)
+func (d *Dispatcher) addConnection(connection Dispatcher_StartDispatchServer) (int, chan struct{}, chan struct{}, error) {
+	for {
+		over, started := d.sessionStarted()
+		if !started {
+			break
+		}
+		select {
+		case <-over:
+		case <-connection.Context().Done():
+			return 0, nil, nil, connection.Context().Err()
+		}
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	select {
+	case <-d.ready:
+		// another shard has started the next session meanwhile
+		return 0, nil, nil, fmt.Errorf("session of %d shards is in progress", d.shardCount)
+	default:
+	}
+	connID := d.nextConnID
+	d.nextConnID++
+	d.connections[connID] = connection
+	if len(d.connections) == d.shardCount {
+		close(d.ready)
+	}
+	return connID, d.ready, d.failed, nil
+}
+
+// sessionStarted returns whether the current session has started (or failed) and the channel which is closed
+// when it is over
+func (d *Dispatcher) sessionStarted() (chan struct{}, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.err != nil {
+		return d.over, true
+	}
+	select {
+	case <-d.ready:
+		return d.over, true
+	default:
+		return d.over, false
+	}
+}
+
+func (d *Dispatcher) removeConnection(connID int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.connections, connID)
+	select {
+	case <-d.ready:
+		// the session is over when all its shards are gone
+		if len(d.connections) == 0 {
+			d.resetSession()
+		}
+	default:
+		// the session has not started, the shards which are still waiting are not affected
+	}
+}
+
+// abort aborts the current session, all connected shards receive the error
+func (d *Dispatcher) abort(err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.err != nil {
+		return
+	}
+	d.err = err
+	close(d.failed)
+}
+
+func (d *Dispatcher) sessionErr() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.err
+}
+
+func (d *Dispatcher) broadcast(connID int, stateRead *StateRead) error {
+	d.broadcastLock.Lock()
+	defer d.broadcastLock.Unlock()
+	d.lock.Lock()
+	list := make([]Dispatcher_StartDispatchServer, 0, len(d.connections))
+	for id, conn := range d.connections {
+		if id != connID {
+			list = append(list, conn)
+		}
+	}
+	d.lock.Unlock()
+	if len(list) != d.shardCount-1 {
+		return fmt.Errorf("only %d of %d shards are connected", len(list)+1, d.shardCount)
+	}
+	for _, conn := range list {
+		if err := conn.Send(stateRead); err != nil {
+			return fmt.Errorf("could not send broadcast from connection id %d: %w", connID, err)
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) StartDispatch(connection Dispatcher_StartDispatchServer) error {
+	connID, ready, failed, err := d.addConnection(connection)
+	if err != nil {
+		return err
+	}
+	defer d.removeConnection(connID)
+
+	select {
+	case <-ready:
+	case <-failed:
+		return d.sessionErr()
+	case <-connection.Context().Done():
+		return connection.Context().Err()
+	}
+
+	relayErr := make(chan error, 1)
+	go func() {
+		relayErr <- d.relay(connID, connection)
+	}()
+	select {
+	case err = <-relayErr:
+		if err != nil {
+			log.Warn("Shard failed, aborting dispatch session", "connection", connID, "err", err)
+			d.abort(fmt.Errorf("shard connection %d: %w", connID, err))
+		}
+		return err
+	case <-failed:
+		// returning closes the stream, so the shard stops waiting for the reads
+		return d.sessionErr()
+	}
+}
+
+// relay broadcasts the reads of the shard until it closes its stream
+func (d *Dispatcher) relay(connID int, connection Dispatcher_StartDispatchServer) error {
+	for {
+		stateRead, err := connection.Recv()
+		if errors.Is(err, io.EOF) {
+			// the shard is done, the other shards do not wait for it anymore
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if d.latency > 0 {
+			time.Sleep(d.latency)
+		}
+		if err = d.broadcast(connID, stateRead); err != nil {
+			return err
+		}
+	}
+}
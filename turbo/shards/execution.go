@@ -0,0 +1,426 @@
+package shards
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/core"
+	"github.com/ledgerwatch/turbo-geth/core/state"
+	"github.com/ledgerwatch/turbo-geth/core/types/accounts"
+	"github.com/ledgerwatch/turbo-geth/core/vm"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
+	"github.com/ledgerwatch/turbo-geth/params"
+	"google.golang.org/grpc"
+)
+
+// Sharded execution: every shard executes all the blocks on its own database, but owns only the plain state of
+// the accounts of its shard (the ones with the first `shardBits` bits of the address equal to the shard ID).
+// Only these accounts are read from the database and written to it (together with their change sets), the reads
+// of other accounts are received from their shards via the Dispatcher. After every block the shards wait for each
+// other, so a failed or diverged shard is detected at the block where it happened.
+
+// kinds of the relayed reads, the first byte of the key
+const (
+	accountRead byte = iota + 1
+	storageRead
+	codeRead
+	codeSizeRead
+	incarnationRead
+)
+
+// Session is the connection of a shard to the dispatcher
+type Session struct {
+	client    Dispatcher_StartDispatchClient
+	shardBits int
+	shardID   byte
+}
+
+func NewSession(client Dispatcher_StartDispatchClient, shardBits int, shardID byte) *Session {
+	return &Session{client: client, shardBits: shardBits, shardID: shardID}
+}
+
+func (s *Session) isMyShard(address common.Address) bool {
+	return (address[0] >> (8 - s.shardBits)) == s.shardID
+}
+
+// Close tells the dispatcher that the shard is done. A shard which fails should cancel the context
+// of the stream instead, so that the dispatcher aborts the session for other shards.
+func (s *Session) Close() error {
+	return s.client.CloseSend()
+}
+
+// kinds of the messages of the shards to each other, which are not state reads
+const (
+	minMessage byte = iota + 1
+	blockMessage
+	doneMessage
+)
+
+// exchange sends the value to all other shards and returns the values of all shards, indexed by the shard ID.
+// The messages of the shards to each other have empty keys, so they never match the keys of the state reads.
+func (s *Session) exchange(kind byte, value uint64) ([]uint64, error) {
+	shardCount := 1 << s.shardBits
+	values := make([]uint64, shardCount)
+	received := make([]bool, shardCount)
+	values[s.shardID] = value
+	received[s.shardID] = true
+	v := make([]byte, 10)
+	v[0] = kind
+	v[1] = s.shardID
+	binary.BigEndian.PutUint64(v[2:], value)
+	if err := s.client.Send(&StateRead{V: v}); err != nil {
+		return nil, fmt.Errorf("sending to other shards: %w", err)
+	}
+	for i := 1; i < shardCount; i++ {
+		stateRead, err := s.client.Recv()
+		if err != nil {
+			return nil, fmt.Errorf("waiting for other shards: %w", err)
+		}
+		if len(stateRead.K) != 0 || len(stateRead.V) != 10 {
+			return nil, fmt.Errorf("received state read %x while waiting for other shards", stateRead.K)
+		}
+		id := int(stateRead.V[1])
+		if stateRead.V[0] != kind {
+			return nil, fmt.Errorf("unexpected message of kind %d from shard %d, expected kind %d", stateRead.V[0], id, kind)
+		}
+		if id >= shardCount || received[id] {
+			return nil, fmt.Errorf("unexpected message from shard %d", id)
+		}
+		received[id] = true
+		values[id] = binary.BigEndian.Uint64(stateRead.V[2:])
+	}
+	return values, nil
+}
+
+// agree checks that all shards have the same value
+func (s *Session) agree(kind byte, value uint64) error {
+	values, err := s.exchange(kind, value)
+	if err != nil {
+		return err
+	}
+	for id, v := range values {
+		if v != value {
+			return fmt.Errorf("shard %d is at block %d instead of %d", id, v, value)
+		}
+	}
+	return nil
+}
+
+// Min returns the lowest of the values of all shards
+func (s *Session) Min(value uint64) (uint64, error) {
+	values, err := s.exchange(minMessage, value)
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range values {
+		if v < value {
+			value = v
+		}
+	}
+	return value, nil
+}
+
+// Barrier waits for all shards to finish the block
+func (s *Session) Barrier(blockNum uint64) error {
+	return s.agree(blockMessage, blockNum)
+}
+
+// Done waits for all shards to finish, at the same block
+func (s *Session) Done(blockNum uint64) error {
+	return s.agree(doneMessage, blockNum)
+}
+
+// ShardReader reads the keys of its shard from the local state and sends them to other shards,
+// the keys of other shards are received from them
+type ShardReader struct {
+	local   state.StateReader
+	session *Session
+}
+
+func NewShardReader(local state.StateReader, session *Session) *ShardReader {
+	return &ShardReader{local: local, session: session}
+}
+
+func (r *ShardReader) relay(address common.Address, key []byte, read func() ([]byte, error)) ([]byte, error) {
+	if !r.session.isMyShard(address) {
+		stateRead, err := r.session.client.Recv()
+		if err != nil {
+			return nil, fmt.Errorf("receiving %x from other shard: %w", key, err)
+		}
+		if !bytes.Equal(stateRead.K, key) {
+			return nil, fmt.Errorf("read mismatched key, expected %x, got %x", key, stateRead.K)
+		}
+		return stateRead.V, nil
+	}
+	v, err := read()
+	if err != nil {
+		return nil, err
+	}
+	if err = r.session.client.Send(&StateRead{K: key, V: v}); err != nil {
+		return nil, fmt.Errorf("sending %x to other shards: %w", key, err)
+	}
+	return v, nil
+}
+
+func (r *ShardReader) ReadAccountData(address common.Address) (*accounts.Account, error) {
+	enc, err := r.relay(address, append([]byte{accountRead}, address[:]...), func() ([]byte, error) {
+		acc, err := r.local.ReadAccountData(address)
+		if err != nil || acc == nil {
+			return nil, err
+		}
+		enc := make([]byte, acc.EncodingLengthForStorage())
+		acc.EncodeForStorage(enc)
+		return enc, nil
+	})
+	if err != nil || len(enc) == 0 {
+		return nil, err
+	}
+	var acc accounts.Account
+	if err = acc.DecodeForStorage(enc); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+func (r *ShardReader) ReadAccountStorage(address common.Address, incarnation uint64, key *common.Hash) ([]byte, error) {
+	k := make([]byte, 1+common.AddressLength+common.IncarnationLength+common.HashLength)
+	k[0] = storageRead
+	copy(k[1:], address[:])
+	binary.BigEndian.PutUint64(k[1+common.AddressLength:], incarnation)
+	copy(k[1+common.AddressLength+common.IncarnationLength:], key[:])
+	enc, err := r.relay(address, k, func() ([]byte, error) {
+		return r.local.ReadAccountStorage(address, incarnation, key)
+	})
+	if err != nil || len(enc) == 0 {
+		return nil, err
+	}
+	return enc, nil
+}
+
+func (r *ShardReader) ReadAccountCode(address common.Address, codeHash common.Hash) ([]byte, error) {
+	return r.relay(address, append([]byte{codeRead}, address[:]...), func() ([]byte, error) {
+		return r.local.ReadAccountCode(address, codeHash)
+	})
+}
+
+func (r *ShardReader) ReadAccountCodeSize(address common.Address, codeHash common.Hash) (int, error) {
+	enc, err := r.relay(address, append([]byte{codeSizeRead}, address[:]...), func() ([]byte, error) {
+		size, err := r.local.ReadAccountCodeSize(address, codeHash)
+		if err != nil {
+			return nil, err
+		}
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(size))
+		return b[:], nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(enc) != 8 {
+		return 0, fmt.Errorf("invalid code size of %x: %x", address, enc)
+	}
+	return int(binary.BigEndian.Uint64(enc)), nil
+}
+
+func (r *ShardReader) ReadAccountIncarnation(address common.Address) (uint64, error) {
+	enc, err := r.relay(address, append([]byte{incarnationRead}, address[:]...), func() ([]byte, error) {
+		incarnation, err := r.local.ReadAccountIncarnation(address)
+		if err != nil {
+			return nil, err
+		}
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], incarnation)
+		return b[:], nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(enc) != 8 {
+		return 0, fmt.Errorf("invalid incarnation of %x: %x", address, enc)
+	}
+	return binary.BigEndian.Uint64(enc), nil
+}
+
+// ShardWriter writes only the accounts of its shard
+type ShardWriter struct {
+	inner   state.WriterWithChangeSets
+	session *Session
+}
+
+func NewShardWriter(inner state.WriterWithChangeSets, session *Session) *ShardWriter {
+	return &ShardWriter{inner: inner, session: session}
+}
+
+func (w *ShardWriter) UpdateAccountData(ctx context.Context, address common.Address, original, account *accounts.Account) error {
+	if !w.session.isMyShard(address) {
+		return nil
+	}
+	return w.inner.UpdateAccountData(ctx, address, original, account)
+}
+
+func (w *ShardWriter) UpdateAccountCode(address common.Address, incarnation uint64, codeHash common.Hash, code []byte) error {
+	if !w.session.isMyShard(address) {
+		return nil
+	}
+	return w.inner.UpdateAccountCode(address, incarnation, codeHash, code)
+}
+
+func (w *ShardWriter) DeleteAccount(ctx context.Context, address common.Address, original *accounts.Account) error {
+	if !w.session.isMyShard(address) {
+		return nil
+	}
+	return w.inner.DeleteAccount(ctx, address, original)
+}
+
+func (w *ShardWriter) WriteAccountStorage(ctx context.Context, address common.Address, incarnation uint64, key *common.Hash, original, value *uint256.Int) error {
+	if !w.session.isMyShard(address) {
+		return nil
+	}
+	return w.inner.WriteAccountStorage(ctx, address, incarnation, key, original, value)
+}
+
+func (w *ShardWriter) CreateContract(address common.Address) error {
+	if !w.session.isMyShard(address) {
+		return nil
+	}
+	return w.inner.CreateContract(address)
+}
+
+func (w *ShardWriter) WriteChangeSets() error {
+	return w.inner.WriteChangeSets()
+}
+
+func (w *ShardWriter) WriteHistory() error {
+	return w.inner.WriteHistory()
+}
+
+func (w *ShardWriter) ChangeSetWriter() *state.ChangeSetWriter {
+	if hasChangeSet, ok := w.inner.(stagedsync.HasChangeSetWriter); ok {
+		return hasChangeSet.ChangeSetWriter()
+	}
+	return nil
+}
+
+// ExecuteBlocks runs the Execution stage as a shard of the sharded execution. The shards start from the same block:
+// if they don't (e.g. one committed a batch before another shard failed), the ones which are ahead unwind to
+// the progress of the least advanced one by the unwinder - together with the stages which depend on the execution,
+// and all shards end the session. The stage runs again in the next session then, after the unwind.
+// The shards stop at the same block too, the lowest of the Senders stage progress (and `params.ToBlock`, if set)
+// over all shards.
+func ExecuteBlocks(s *stagedsync.StageState, u stagedsync.Unwinder, db ethdb.Database, session *Session, chainConfig *params.ChainConfig, chainContext *core.TinyChainContext, vmConfig *vm.Config, quit <-chan struct{}, params stagedsync.ExecuteBlockStageParams) error {
+	positions, err := session.exchange(minMessage, s.BlockNumber)
+	if err != nil {
+		return err
+	}
+	from := s.BlockNumber
+	for _, p := range positions {
+		if p < from {
+			from = p
+		}
+	}
+	for id, p := range positions {
+		if p == from {
+			continue
+		}
+		if from < s.BlockNumber {
+			log.Info("Unwinding to the progress of other shards", "shard", session.shardID, "from", s.BlockNumber, "to", from)
+			return u.UnwindTo(from, db)
+		}
+		log.Info("Waiting for other shards to unwind", "shard", session.shardID, "other", id, "from", p, "to", from)
+		return nil
+	}
+
+	to, _, err := stages.GetStageProgress(db, stages.Senders)
+	if err != nil {
+		return err
+	}
+	if params.ToBlock > 0 && params.ToBlock < to {
+		to = params.ToBlock
+	}
+	if to, err = session.Min(to); err != nil {
+		return err
+	}
+	if to <= from {
+		return nil
+	}
+
+	readerBuilder, writerBuilder := params.ReaderBuilder, params.WriterBuilder
+	params.ToBlock = to
+	params.ReaderBuilder = func(db ethdb.Getter) state.StateReader {
+		if readerBuilder != nil {
+			return NewShardReader(readerBuilder(db), session)
+		}
+		return NewShardReader(state.NewPlainStateReader(db), session)
+	}
+	params.WriterBuilder = func(db ethdb.Database, changeSetsDB ethdb.Database, blockNumber uint64) state.WriterWithChangeSets {
+		if writerBuilder != nil {
+			return NewShardWriter(writerBuilder(db, changeSetsDB, blockNumber), session)
+		}
+		return NewShardWriter(state.NewPlainStateWriter(db, changeSetsDB, blockNumber), session)
+	}
+	params.BlockHook = session.Barrier
+	if err = stagedsync.SpawnExecuteBlocksStage(s, db, chainConfig, chainContext, vmConfig, quit, params); err != nil {
+		return err
+	}
+
+	// The stage may stop early (on a missing block), the other shards must not wait for this shard then
+	progress, _, err := stages.GetStageProgress(db, stages.Execution)
+	if err != nil {
+		return err
+	}
+	if progress != to {
+		// makes the other shards fail at the next block
+		_, err = session.exchange(doneMessage, progress)
+		return fmt.Errorf("execution stopped at block %d instead of %d: %v", progress, to, err)
+	}
+	return session.Done(to)
+}
+
+// NewExecutor returns the function which executes the blocks in the Execution stage of the node as the shard
+// of the sharded execution, see `stagedsync.OptionalParameters.ExecuteBlocks`. Every run of the stage is a session
+// of the dispatcher at `addr`, so the Execution stage of all shards runs at the same time.
+func NewExecutor(addr string, shardBits int, shardID int) (stagedsync.ExecuteBlocksFunc, error) {
+	if shardBits < 1 || shardBits > 8 {
+		return nil, fmt.Errorf("shard bits must be from 1 to 8, got %d", shardBits)
+	}
+	if shardID < 0 || shardID >= 1<<shardBits {
+		return nil, fmt.Errorf("shard ID %d is out of range for %d shard bits", shardID, shardBits)
+	}
+	return func(s *stagedsync.StageState, u stagedsync.Unwinder, db ethdb.Database, chainConfig *params.ChainConfig, chainContext *core.TinyChainContext, vmConfig *vm.Config, quit <-chan struct{}, params stagedsync.ExecuteBlockStageParams) error {
+		// the stream is cancelled on failure or shutdown, so that the dispatcher aborts the session for the other shards
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-quit:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		conn, err := grpc.DialContext(ctx, addr,
+			grpc.WithInsecure(),
+			grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(int(5*datasize.MB))),
+		)
+		if err != nil {
+			return fmt.Errorf("creating client connection to shard dispatcher: %w", err)
+		}
+		defer conn.Close()
+		client, err := NewDispatcherClient(conn).StartDispatch(ctx)
+		if err != nil {
+			return fmt.Errorf("starting shard dispatch: %w", err)
+		}
+		session := NewSession(client, shardBits, byte(shardID))
+		if err = ExecuteBlocks(s, u, db, session, chainConfig, chainContext, vmConfig, quit, params); err != nil {
+			return err
+		}
+		return session.Close()
+	}, nil
+}
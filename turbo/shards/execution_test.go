@@ -0,0 +1,425 @@
+package shards
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/consensus/ethash"
+	"github.com/ledgerwatch/turbo-geth/core"
+	"github.com/ledgerwatch/turbo-geth/core/rawdb"
+	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/core/vm"
+	"github.com/ledgerwatch/turbo-geth/crypto"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/params"
+	"github.com/ledgerwatch/turbo-geth/turbo/fsck"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// generateTestChain generates blocks with transfers to many accounts and calls to a few contracts,
+// which store the first word of the call data into the slot 0
+func generateTestChain(t testing.TB, blocks int) (*core.Genesis, []*types.Block) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	bank := crypto.PubkeyToAddress(key.PublicKey)
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{bank: {Balance: big.NewInt(1000000000000000000)}},
+	}
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	genesis := gspec.MustCommit(db)
+	signer := types.HomesteadSigner{}
+
+	runtime := []byte{byte(vm.PUSH1), 0, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+	deploy := append([]byte{
+		byte(vm.PUSH1), byte(len(runtime)), byte(vm.PUSH1), 12, byte(vm.PUSH1), 0, byte(vm.CODECOPY),
+		byte(vm.PUSH1), byte(len(runtime)), byte(vm.PUSH1), 0, byte(vm.RETURN),
+	}, runtime...)
+	var contracts []common.Address
+	for nonce := uint64(0); nonce < 4; nonce++ {
+		contracts = append(contracts, crypto.CreateAddress(bank, nonce))
+	}
+
+	chain, _, err := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, blocks, func(i int, block *core.BlockGen) {
+		var txs []*types.Transaction
+		if i == 0 {
+			for range contracts {
+				txs = append(txs, types.NewContractCreation(block.TxNonce(bank)+uint64(len(txs)), new(uint256.Int), 100000, new(uint256.Int), deploy))
+			}
+		} else {
+			contract := contracts[i%len(contracts)]
+			txs = append(txs, types.NewTransaction(block.TxNonce(bank), contract, new(uint256.Int), 100000, new(uint256.Int), common.LeftPadBytes([]byte{byte(i % 3)}, 32)))
+		}
+		for j := 0; j < 5; j++ {
+			receiver := common.BytesToAddress(crypto.Keccak256([]byte{byte(i), byte(j)}))
+			txs = append(txs, types.NewTransaction(block.TxNonce(bank)+uint64(len(txs)), receiver, uint256.NewInt().SetUint64(1000), params.TxGas, new(uint256.Int), nil))
+		}
+		for _, tx := range txs {
+			signedTx, err1 := types.SignTx(tx, signer, key)
+			require.NoError(t, err1)
+			block.AddTx(signedTx)
+		}
+	}, false /* intermediateHashes */)
+	require.NoError(t, err)
+	return gspec, chain
+}
+
+// newTestDB creates the database with the genesis state, the blocks and their senders
+func newTestDB(t testing.TB, gspec *core.Genesis, chain []*types.Block) *ethdb.ObjectDatabase {
+	db := ethdb.NewMemDatabase()
+	gspec.MustCommit(db)
+	signer := types.HomesteadSigner{}
+	for _, block := range chain {
+		require.NoError(t, rawdb.WriteBlock(context.Background(), db, block))
+		require.NoError(t, rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64()))
+		senders := make([]common.Address, len(block.Transactions()))
+		for i, txn := range block.Transactions() {
+			var err error
+			senders[i], err = types.Sender(signer, txn)
+			require.NoError(t, err)
+		}
+		rawdb.WriteSenders(context.Background(), db, block.Hash(), block.NumberU64(), senders)
+	}
+	require.NoError(t, stages.SaveStageProgress(db, stages.Senders, uint64(len(chain)), nil))
+	return db
+}
+
+func executeBlocks(db ethdb.Database, config *params.ChainConfig, toBlock uint64) error {
+	cc := &core.TinyChainContext{}
+	cc.SetEngine(ethash.NewFaker())
+	progress, _, err := stages.GetStageProgress(db, stages.Execution)
+	if err != nil {
+		return err
+	}
+	return stagedsync.SpawnExecuteBlocksStage(&stagedsync.StageState{Stage: stages.Execution, BlockNumber: progress}, db, config, cc, &vm.Config{}, nil, stagedsync.ExecuteBlockStageParams{ToBlock: toBlock})
+}
+
+func startDispatcher(t testing.TB, shardCount int) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	RegisterDispatcherServer(grpcServer, NewDispatcher(shardCount, 0))
+	go grpcServer.Serve(l) //nolint:errcheck
+	return l.Addr().String(), grpcServer.Stop
+}
+
+// executeShard runs the sharded execution of the shard up to the block, as the Execution stage of the node does
+func executeShard(addr string, db ethdb.Database, config *params.ChainConfig, shardBits int, shardID byte, toBlock uint64) error {
+	executeBlocks, err := NewExecutor(addr, shardBits, int(shardID))
+	if err != nil {
+		return err
+	}
+	cc := &core.TinyChainContext{}
+	cc.SetEngine(ethash.NewFaker())
+	progress, _, err := stages.GetStageProgress(db, stages.Execution)
+	if err != nil {
+		return err
+	}
+	s := &stagedsync.StageState{Stage: stages.Execution, BlockNumber: progress}
+	return executeBlocks(s, noUnwind{}, db, config, cc, &vm.Config{}, nil, stagedsync.ExecuteBlockStageParams{ToBlock: toBlock})
+}
+
+// noUnwind fails the execution of the shards which are not aligned, they are aligned by the sync,
+// see TestShardedExecutionStage
+type noUnwind struct{}
+
+func (noUnwind) UnwindTo(blockNumber uint64, _ ethdb.Database) error {
+	return fmt.Errorf("unexpected unwind to block %d", blockNumber)
+}
+
+func executeShards(addr string, dbs []*ethdb.ObjectDatabase, config *params.ChainConfig, shardBits int, toBlock uint64) []error {
+	errs := make([]error, len(dbs))
+	done := make(chan struct{})
+	for i := range dbs {
+		go func(i int) {
+			errs[i] = executeShard(addr, dbs[i], config, shardBits, byte(i), toBlock)
+			done <- struct{}{}
+		}(i)
+	}
+	for range dbs {
+		<-done
+	}
+	return errs
+}
+
+// requireShardState checks that the plain state of the accounts of the shard is the same as in the reference database
+func requireShardState(t *testing.T, expected, db ethdb.Database, shardBits int, shardID byte) {
+	session := NewSession(nil, shardBits, shardID)
+	collect := func(db ethdb.Database, bucket string) map[string]string {
+		m := make(map[string]string)
+		require.NoError(t, db.Walk(bucket, nil, 0, func(k, v []byte) (bool, error) {
+			if session.isMyShard(common.BytesToAddress(k[:common.AddressLength])) {
+				m[string(k)] = string(v)
+			}
+			return true, nil
+		}))
+		return m
+	}
+	for _, bucket := range []string{dbutils.PlainStateBucket, dbutils.PlainContractCodeBucket} {
+		require.Equal(t, collect(expected, bucket), collect(db, bucket), "shard %d, bucket %s", shardID, bucket)
+	}
+}
+
+func TestShardedExecution(t *testing.T) {
+	gspec, chain := generateTestChain(t, 30)
+	reference := newTestDB(t, gspec, chain)
+	defer reference.Close()
+	const shardBits = 1
+	dbs := make([]*ethdb.ObjectDatabase, 1<<shardBits)
+	for i := range dbs {
+		dbs[i] = newTestDB(t, gspec, chain)
+		defer dbs[i].Close()
+	}
+	addr, stop := startDispatcher(t, len(dbs))
+	defer stop()
+
+	require.NoError(t, executeBlocks(reference, gspec.Config, 15))
+	for i, err := range executeShards(addr, dbs, gspec.Config, shardBits, 15) {
+		require.NoError(t, err, "shard %d", i)
+	}
+	for i, db := range dbs {
+		progress, _, err := stages.GetStageProgress(db, stages.Execution)
+		require.NoError(t, err)
+		require.Equal(t, 15, int(progress))
+		requireShardState(t, reference, db, shardBits, byte(i))
+	}
+}
+
+func TestShardedExecutionFailure(t *testing.T) {
+	gspec, chain := generateTestChain(t, 5)
+	db := newTestDB(t, gspec, chain)
+	defer db.Close()
+	addr, stop := startDispatcher(t, 2)
+	defer stop()
+
+	// The other shard connects, but fails right away
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = NewDispatcherClient(conn).StartDispatch(ctx)
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- executeShard(addr, db, gspec.Config, 1, 0, 0)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	select {
+	case err = <-errCh:
+		require.Error(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("shard is still waiting for the failed shard")
+	}
+	progress, _, err := stages.GetStageProgress(db, stages.Execution)
+	require.NoError(t, err)
+	require.Equal(t, 0, int(progress))
+
+	// The next session of the dispatcher is not affected
+	other := newTestDB(t, gspec, chain)
+	defer other.Close()
+	var errs []error
+	for i := 0; i < 50; i++ {
+		// the dispatcher may be still finishing the failed session
+		if errs = executeShards(addr, []*ethdb.ObjectDatabase{db, other}, gspec.Config, 1, 0); errs[0] == nil && errs[1] == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Equal(t, []error{nil, nil}, errs)
+}
+
+func benchmarkExecution(b *testing.B, shardBits int) {
+	gspec, chain := generateTestChain(b, 100)
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		dbs := make([]*ethdb.ObjectDatabase, 1<<shardBits)
+		for i := range dbs {
+			dbs[i] = newTestDB(b, gspec, chain)
+		}
+		addr, stop := startDispatcher(b, len(dbs))
+		b.StartTimer()
+		var errs []error
+		if shardBits == 0 {
+			errs = []error{executeBlocks(dbs[0], gspec.Config, 0)}
+		} else {
+			errs = executeShards(addr, dbs, gspec.Config, shardBits, 0)
+		}
+		b.StopTimer()
+		stop()
+		for i := range dbs {
+			dbs[i].Close()
+			if errs[i] != nil {
+				b.Fatal(fmt.Errorf("shard %d: %w", i, errs[i]))
+			}
+		}
+		b.StartTimer()
+	}
+}
+
+// BenchmarkExecution is the single-process execution, to compare the sharded execution with
+func BenchmarkExecution(b *testing.B) {
+	benchmarkExecution(b, 0)
+}
+
+func BenchmarkShardedExecution2(b *testing.B) {
+	benchmarkExecution(b, 1)
+}
+
+func BenchmarkShardedExecution4(b *testing.B) {
+	benchmarkExecution(b, 2)
+}
+
+// shardStages are the default stages, the download stages just pass the blocks of the test database
+func shardStages(unwound func(stage stages.SyncStage, unwindPoint uint64)) stagedsync.StageBuilders {
+	builders := stagedsync.DefaultStages()
+	for i := range builders {
+		id, build := builders[i].ID, builders[i].Build
+		switch {
+		case bytes.Equal(id, stages.Headers), bytes.Equal(id, stages.BlockHashes), bytes.Equal(id, stages.Bodies), bytes.Equal(id, stages.Senders):
+			builders[i].Build = func(world stagedsync.StageParameters) *stagedsync.Stage {
+				return &stagedsync.Stage{
+					ID:         id,
+					ExecFunc:   func(s *stagedsync.StageState, u stagedsync.Unwinder) error { s.Done(); return nil },
+					UnwindFunc: func(u *stagedsync.UnwindState, s *stagedsync.StageState) error { return u.Skip(world.TX) },
+				}
+			}
+		default:
+			builders[i].Build = func(world stagedsync.StageParameters) *stagedsync.Stage {
+				stage := build(world)
+				unwind := stage.UnwindFunc
+				stage.UnwindFunc = func(u *stagedsync.UnwindState, s *stagedsync.StageState) error {
+					unwound(u.Stage, u.UnwindPoint)
+					return unwind(u, s)
+				}
+				return stage
+			}
+		}
+	}
+	return builders
+}
+
+// TestShardedExecutionStage - the nodes run the sharded execution in their Execution stage, together with all other stages
+func TestShardedExecutionStage(t *testing.T) {
+	gspec, chain := generateTestChain(t, 20)
+	reference := newTestDB(t, gspec, chain)
+	defer reference.Close()
+	require.NoError(t, executeBlocks(reference, gspec.Config, 0))
+	const shardBits = 1
+	addr, stop := startDispatcher(t, 1<<shardBits)
+	defer stop()
+	tmpdir, err := ioutil.TempDir("", "shards")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	var mu sync.Mutex
+	unwound := make([]map[string]uint64, 1<<shardBits)
+	storageMode := ethdb.StorageMode{History: true, Receipts: true, TxIndex: true, CallTraces: true, Witnesses: true, BinaryTrie: true}
+	cc := &core.TinyChainContext{}
+	cc.SetEngine(ethash.NewFaker())
+	dbs := make([]*ethdb.ObjectDatabase, 1<<shardBits)
+	syncs := make([]*stagedsync.State, len(dbs))
+	prepare := func() {
+		for i := range dbs {
+			i := i
+			unwound[i] = make(map[string]uint64)
+			builders := shardStages(func(stage stages.SyncStage, unwindPoint uint64) {
+				mu.Lock()
+				defer mu.Unlock()
+				unwound[i][string(stage)] = unwindPoint
+			})
+			executeBlocks, err := NewExecutor(addr, shardBits, i)
+			require.NoError(t, err)
+			syncs[i], err = stagedsync.New(builders, stagedsync.DefaultUnwindOrder(), stagedsync.OptionalParameters{ExecuteBlocks: executeBlocks, PartialState: true}).Prepare(nil, gspec.Config, cc, &vm.Config{}, dbs[i], dbs[i], "", storageMode, tmpdir, 1024, "", nil, nil, nil, nil, nil, nil)
+			require.NoError(t, err)
+		}
+	}
+	run := func() {
+		errs := make(chan error, len(dbs))
+		for i := range dbs {
+			go func(st *stagedsync.State, db ethdb.Database) { errs <- st.Run(db, db) }(syncs[i], dbs[i])
+		}
+		for range dbs {
+			select {
+			case err := <-errs:
+				require.NoError(t, err)
+			case <-time.After(time.Minute):
+				t.Fatal("shards are waiting for each other")
+			}
+		}
+	}
+	for i := range dbs {
+		dbs[i] = newTestDB(t, gspec, chain)
+		defer dbs[i].Close()
+		require.NoError(t, stages.SaveStageProgress(dbs[i], stages.Senders, 10, nil))
+	}
+	prepare()
+	run()
+
+	// One of the shards is behind, the other one unwinds to it first, together with the stages after Execution
+	for _, i := range stagedsync.DefaultUnwindOrder() {
+		u := &stagedsync.UnwindState{Stage: stagedsync.DefaultStages()[i].ID, UnwindPoint: 5}
+		require.NoError(t, syncs[0].SetCurrentStage(u.Stage))
+		require.NoError(t, syncs[0].UnwindStage(u, dbs[0], dbs[0]))
+	}
+	for i := range dbs {
+		require.NoError(t, stages.SaveStageProgress(dbs[i], stages.Senders, 20, nil))
+	}
+	prepare()
+	run()
+	require.Empty(t, unwound[0])
+	for _, stage := range []stages.SyncStage{stages.Execution, stages.AccountHistoryIndex, stages.StorageHistoryIndex, stages.LogIndex, stages.TxLookup} {
+		unwindPoint, ok := unwound[1][string(stage)]
+		require.True(t, ok, "stage %s is not unwound", stage)
+		require.Equal(t, 5, int(unwindPoint), "stage %s", stage)
+	}
+
+	for i, db := range dbs {
+		for _, stage := range []stages.SyncStage{stages.Execution, stages.AccountHistoryIndex, stages.StorageHistoryIndex, stages.LogIndex, stages.TxLookup, stages.Finish} {
+			progress, _, err := stages.GetStageProgress(db, stage)
+			require.NoError(t, err)
+			require.Equal(t, 20, int(progress), "shard %d, stage %s", i, stage)
+		}
+		// the stages which need the whole state are disabled
+		for _, stage := range []stages.SyncStage{stages.HashState, stages.IntermediateHashes, stages.CallTraces, stages.BlockWitness, stages.BinaryHashes} {
+			progress, _, err := stages.GetStageProgress(db, stage)
+			require.NoError(t, err)
+			require.Zero(t, progress, "shard %d, stage %s", i, stage)
+		}
+		requireShardState(t, reference, db, shardBits, byte(i))
+		// the accounts of other shards are not written
+		require.Less(t, countEntries(t, db, dbutils.PlainStateBucket), countEntries(t, reference, dbutils.PlainStateBucket))
+		checks, err := fsck.Lookup([]string{"history_index", "tx_lookup"})
+		require.NoError(t, err)
+		report, err := fsck.Run(db, checks, fsck.Options{})
+		require.NoError(t, err)
+		require.Empty(t, report.Findings, "shard %d", i)
+	}
+
+	_, err = NewExecutor(addr, shardBits, 2)
+	require.EqualError(t, err, "shard ID 2 is out of range for 1 shard bits")
+}
+
+func countEntries(t *testing.T, db ethdb.Database, bucket string) int {
+	var count int
+	require.NoError(t, db.Walk(bucket, nil, 0, func(k, v []byte) (bool, error) {
+		count++
+		return true, nil
+	}))
+	return count
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+	"path/filepath"
 
 	"github.com/ledgerwatch/turbo-geth/common"
 	"github.com/ledgerwatch/turbo-geth/common/dbutils"
@@ -24,7 +25,7 @@ var (
 	HeaderCanonical = stages.SyncStage("snapshot_canonical")
 )
 
-func PostProcessing(db ethdb.Database, mode SnapshotMode) error {
+func PostProcessing(db ethdb.Database, mode SnapshotMode, snapshotDir string) error {
 	if mode.Headers {
 		err := GenerateHeaderIndexes(context.Background(), db)
 		if err != nil {
@@ -37,6 +38,12 @@ func PostProcessing(db ethdb.Database, mode SnapshotMode) error {
 			return err
 		}
 	}
+	if mode.State {
+		err := PostProcessState(db, filepath.Join(snapshotDir, StateSnapshotName), os.TempDir())
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -67,6 +74,93 @@ func PostProcessBodies(db ethdb.Database) error {
 	return nil
 }
 
+// stagesFromSnapshot - the stages which need the history of the state or the receipts of blocks, they start from the block
+// of the state snapshot like Execution does.
+var stagesFromSnapshot = []stages.SyncStage{
+	stages.Execution,
+	stages.AccountHistoryIndex,
+	stages.StorageHistoryIndex,
+	stages.LogIndex,
+	stages.CallTraces,
+	stages.ChangeFeed,
+	stages.BlockWitness,
+}
+
+// PostProcessState copies the state snapshot into the database of a fresh node, and sets progress of Execution and
+// the stages which need the history (see stagesFromSnapshot) to the block of the snapshot, so they start from that block
+// instead of genesis. Hashed state and intermediate hashes are built by their stages from scratch, and the state root
+// is verified against the header. History of the state before the block of the snapshot is not available, so the chain
+// can't be unwound below that block.
+func PostProcessState(db ethdb.Database, path string, tmpdir string) error {
+	executedTo, _, err := stages.GetStageProgress(db, stages.Execution)
+	if err != nil {
+		return err
+	}
+	if executedTo > 0 {
+		return nil
+	}
+	if _, err = os.Stat(path); os.IsNotExist(err) {
+		log.Warn("State snapshot not found, execution starts from genesis", "path", path)
+		return nil
+	}
+	if StateSnapshotHash != "" {
+		if err = VerifySnapshot(path, StateSnapshotHash); err != nil {
+			return err
+		}
+	}
+
+	snKV, err := ethdb.NewLMDB().Path(path).WithBucketsConfig(StateSnapshotBuckets).ReadOnly().Open()
+	if err != nil {
+		return fmt.Errorf("opening state snapshot: %w", err)
+	}
+	snDB := ethdb.NewObjectDatabase(snKV)
+	defer snDB.Close()
+
+	numberBytes, err := snDB.Get(dbutils.SnapshotInfoBucket, []byte(dbutils.SnapshotStateHeadNumber))
+	if err != nil {
+		return fmt.Errorf("reading block of state snapshot: %w", err)
+	}
+	hashBytes, err := snDB.Get(dbutils.SnapshotInfoBucket, []byte(dbutils.SnapshotStateHeadHash))
+	if err != nil {
+		return fmt.Errorf("reading block of state snapshot: %w", err)
+	}
+	number := big.NewInt(0).SetBytes(numberBytes).Uint64()
+	hash := common.BytesToHash(hashBytes)
+	canonical, err := rawdb.ReadCanonicalHash(db, number)
+	if err != nil {
+		return err
+	}
+	if canonical == (common.Hash{}) {
+		// the headers snapshot is not used, or the headers are not downloaded yet - the snapshot is copied on the
+		// restart of the node which has the headers, if it doesn't execute blocks from genesis before that
+		log.Warn("State snapshot is skipped, there is no header of its block yet, execution starts from genesis", "block", number)
+		return nil
+	}
+	if canonical != hash {
+		return fmt.Errorf("state snapshot is at block %d %x, but canonical block is %x (headers are required up to the block of the snapshot)", number, hash, canonical)
+	}
+
+	log.Info("Copying state snapshot", "block", number)
+	for _, bucket := range []string{dbutils.PlainStateBucket, dbutils.PlainContractCodeBucket, dbutils.CodeBucket} {
+		collector := etl.NewCollector(tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize))
+		if err = snDB.Walk(bucket, nil, 0, func(k, v []byte) (bool, error) {
+			return true, collector.Collect(k, v)
+		}); err != nil {
+			collector.Close("State snapshot")
+			return err
+		}
+		if err = collector.Load("State snapshot", db, bucket, etl.IdentityLoadFunc, etl.TransformArgs{}); err != nil {
+			return err
+		}
+	}
+	for _, stage := range stagesFromSnapshot {
+		if err = stages.SaveStageProgress(db, stage, number, nil); err != nil {
+			return err
+		}
+	}
+	return stages.SaveStageProgress(db, stages.StateSnapshot, number, nil)
+}
+
 func GenerateHeaderIndexes(ctx context.Context, db ethdb.Database) error {
 	var hash common.Hash
 	var number uint64
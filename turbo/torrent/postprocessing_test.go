@@ -1,7 +1,9 @@
 package torrent
 
 import (
+	"bytes"
 	"context"
+	"io/ioutil"
 	"math/big"
 	"os"
 	"testing"
@@ -9,6 +11,7 @@ import (
 	"github.com/ledgerwatch/turbo-geth/common/dbutils"
 	"github.com/ledgerwatch/turbo-geth/core/rawdb"
 	"github.com/ledgerwatch/turbo-geth/core/types"
+	"github.com/ledgerwatch/turbo-geth/eth/stagedsync/stages"
 	"github.com/ledgerwatch/turbo-geth/ethdb"
 	"github.com/ledgerwatch/turbo-geth/rlp"
 )
@@ -102,3 +105,122 @@ func generateHeaders(n int) []types.Header {
 	}
 	return headers
 }
+
+// createStateSnapshot creates the state snapshot at the block of the header, with 10 accounts and one code
+func createStateSnapshot(t *testing.T, head types.Header) string {
+	snPath, err := ioutil.TempDir("", "state_snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snKV := ethdb.NewLMDB().Path(snPath).WithBucketsConfig(StateSnapshotBuckets).MustOpen()
+	err = snKV.Update(context.Background(), func(tx ethdb.Tx) error {
+		for i := byte(0); i < 10; i++ {
+			if innerErr := tx.Cursor(dbutils.PlainStateBucket).Put([]byte{i}, []byte{i, i}); innerErr != nil {
+				return innerErr
+			}
+		}
+		if innerErr := tx.Cursor(dbutils.CodeBucket).Put([]byte{1}, []byte{2}); innerErr != nil {
+			return innerErr
+		}
+		c := tx.Cursor(dbutils.SnapshotInfoBucket)
+		if innerErr := c.Put([]byte(dbutils.SnapshotStateHeadHash), head.Hash().Bytes()); innerErr != nil {
+			return innerErr
+		}
+		return c.Put([]byte(dbutils.SnapshotStateHeadNumber), head.Number.Bytes())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snKV.Close()
+	return snPath
+}
+
+func TestPostProcessState(t *testing.T) {
+	headers := generateHeaders(10)
+	head := headers[len(headers)-1]
+	snPath := createStateSnapshot(t, head)
+	defer os.RemoveAll(snPath)
+
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	if err := rawdb.WriteCanonicalHash(db, head.Hash(), head.Number.Uint64()); err != nil {
+		t.Fatal(err)
+	}
+	if err := PostProcessState(db, snPath, os.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := byte(0); i < 10; i++ {
+		v, err1 := db.Get(dbutils.PlainStateBucket, []byte{i})
+		if err1 != nil {
+			t.Fatal(i, err1)
+		}
+		if !bytes.Equal(v, []byte{i, i}) {
+			t.Error(i, "incorrect value", v)
+		}
+	}
+	code, err := db.Get(dbutils.CodeBucket, []byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(code, []byte{2}) {
+		t.Error("incorrect code", code)
+	}
+	for _, stage := range append(stagesFromSnapshot, stages.StateSnapshot) {
+		progress, _, err := stages.GetStageProgress(db, stage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if progress != head.Number.Uint64() {
+			t.Errorf("incorrect progress of %s: %d", stage, progress)
+		}
+	}
+	for _, stage := range []stages.SyncStage{stages.HashState, stages.IntermediateHashes} {
+		progress, _, err := stages.GetStageProgress(db, stage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if progress != 0 {
+			t.Errorf("%s must start from scratch, progress: %d", stage, progress)
+		}
+	}
+}
+
+func TestPostProcessStateWithoutHeaders(t *testing.T) {
+	headers := generateHeaders(10)
+	head := headers[len(headers)-1]
+	snPath := createStateSnapshot(t, head)
+	defer os.RemoveAll(snPath)
+
+	// the state snapshot is downloaded without the headers snapshot, it is skipped
+	db := ethdb.NewMemDatabase()
+	defer db.Close()
+	if err := PostProcessState(db, snPath, os.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Get(dbutils.PlainStateBucket, []byte{1}); err != ethdb.ErrKeyNotFound {
+		t.Error("state is copied without the headers", err)
+	}
+	executedTo, _, err := stages.GetStageProgress(db, stages.Execution)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if executedTo != 0 {
+		t.Error("incorrect execution progress", executedTo)
+	}
+
+	// it is copied on the restart, when the headers are there
+	if err = rawdb.WriteCanonicalHash(db, head.Hash(), head.Number.Uint64()); err != nil {
+		t.Fatal(err)
+	}
+	if err = PostProcessState(db, snPath, os.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	executedTo, _, err = stages.GetStageProgress(db, stages.Execution)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if executedTo != head.Number.Uint64() {
+		t.Error("incorrect execution progress", executedTo)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
 	"github.com/anacrolix/torrent/metainfo"
 	"github.com/anacrolix/torrent/storage"
 	"io"
@@ -124,11 +125,15 @@ func (cli *Client) Run(db ethdb.Database) error {
 		})
 	}
 	if cli.snMode.State {
-		eg.Go(func() error {
-			return cli.AddTorrent(ctx, db, StateSnapshotName, StateSnapshotHash)
-		})
+		if StateSnapshotHash == "" {
+			log.Warn("State snapshot is not published, only local snapshot can be used", "path", filepath.Join(cli.snapshotsDir, StateSnapshotName))
+		} else {
+			eg.Go(func() error {
+				return cli.AddTorrent(ctx, db, StateSnapshotName, StateSnapshotHash)
+			})
+		}
 	}
-	if cli.snMode.Receipts {
+	if cli.snMode.Receipts && ReceiptsSnapshotHash != "" {
 		eg.Go(func() error {
 			return cli.AddTorrent(ctx, db, ReceiptsSnapshotName, ReceiptsSnapshotHash)
 		})
@@ -229,9 +234,72 @@ func WrapBySnapshots(kv ethdb.KV, snapshotDir string, mode SnapshotMode) (ethdb.
 				DB(kv).MustOpen()
 		}
 	}
+
+	if mode.Receipts {
+		path := filepath.Join(snapshotDir, ReceiptsSnapshotName)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			// receipts snapshot isn't published yet, it can be generated locally with `state receiptsSnapshot`
+			log.Warn("Receipts snapshot not found", "path", path)
+			return kv, nil
+		}
+		snapshotKV, err := ethdb.NewLMDB().Path(path).WithBucketsConfig(ReceiptsSnapshotBuckets).ReadOnly().Open()
+		if err != nil {
+			log.Error("Can't open receipts snapshot", "err", err)
+			return nil, err
+		}
+		kv = ethdb.NewSnapshotKV().SnapshotDB(snapshotKV).
+			For(dbutils.BlockReceiptsPrefix).
+			For(dbutils.Log).
+			For(dbutils.SnapshotInfoBucket).
+			DB(kv).MustOpen()
+	}
 	return kv, nil
 }
 
+// ReceiptsSnapshotBuckets - buckets of the receipts snapshot: receipts and logs of the blocks up to the block of the snapshot
+func ReceiptsSnapshotBuckets(defaultBuckets dbutils.BucketsCfg) dbutils.BucketsCfg {
+	return dbutils.BucketsCfg{
+		dbutils.BlockReceiptsPrefix: dbutils.BucketConfigItem{},
+		dbutils.Log:                 dbutils.BucketConfigItem{},
+		dbutils.SnapshotInfoBucket:  dbutils.BucketConfigItem{},
+	}
+}
+
+// StateSnapshotBuckets - buckets of the state snapshot: plain state and contract codes at the block of the snapshot
+func StateSnapshotBuckets(defaultBuckets dbutils.BucketsCfg) dbutils.BucketsCfg {
+	return dbutils.BucketsCfg{
+		dbutils.PlainStateBucket:        defaultBuckets[dbutils.PlainStateBucket],
+		dbutils.PlainContractCodeBucket: dbutils.BucketConfigItem{},
+		dbutils.CodeBucket:              dbutils.BucketConfigItem{},
+		dbutils.SnapshotInfoBucket:      dbutils.BucketConfigItem{},
+	}
+}
+
+// SnapshotInfoHash returns metainfo hash of the LMDB snapshot in the directory, the same one the snapshot is distributed with
+func SnapshotInfoHash(root string) (metainfo.Hash, error) {
+	info, err := BuildInfoBytesForLMDBSnapshot(root)
+	if err != nil {
+		return metainfo.Hash{}, err
+	}
+	mi := metainfo.MetaInfo{}
+	if mi.InfoBytes, err = bencode.Marshal(info); err != nil {
+		return metainfo.Hash{}, err
+	}
+	return mi.HashInfoBytes(), nil
+}
+
+// VerifySnapshot checks that the snapshot in the directory has the expected metainfo hash
+func VerifySnapshot(root string, expectedHash string) error {
+	hash, err := SnapshotInfoHash(root)
+	if err != nil {
+		return err
+	}
+	if hash != metainfo.NewHashFromHex(expectedHash) {
+		return fmt.Errorf("snapshot %s has metainfo hash %s, expected %s", root, hash.HexString(), expectedHash)
+	}
+	return nil
+}
+
 func BuildInfoBytesForLMDBSnapshot(root string) (metainfo.Info, error) {
 	path := root + "/" + LmdbFilename
 	fi, err := os.Stat(path)
@@ -1,10 +1,15 @@
 package torrent
 
 import (
-	"github.com/ledgerwatch/turbo-geth/ethdb"
-	"github.com/ledgerwatch/turbo-geth/log"
+	"bytes"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/ledgerwatch/turbo-geth/common/dbutils"
+	"github.com/ledgerwatch/turbo-geth/ethdb"
+	"github.com/ledgerwatch/turbo-geth/log"
 )
 
 func TestTorrentAddTorrent(t *testing.T) {
@@ -24,3 +29,50 @@ func TestTorrentAddTorrent(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestWrapBySnapshotsReceipts(t *testing.T) {
+	snapshotDir, err := ioutil.TempDir("", "receipts_snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(snapshotDir)
+
+	snKV := ethdb.NewLMDB().Path(filepath.Join(snapshotDir, ReceiptsSnapshotName)).WithBucketsConfig(ReceiptsSnapshotBuckets).MustOpen()
+	snDB := ethdb.NewObjectDatabase(snKV)
+	if err = snDB.Put(dbutils.BlockReceiptsPrefix, dbutils.ReceiptsKey(1), []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err = snDB.Put(dbutils.Log, dbutils.LogKey(1, 0), []byte{2}); err != nil {
+		t.Fatal(err)
+	}
+	snDB.Close()
+
+	kv := ethdb.NewLMDB().InMem().MustOpen()
+	kv, err = WrapBySnapshots(kv, snapshotDir, SnapshotMode{Receipts: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := ethdb.NewObjectDatabase(kv)
+	defer db.Close()
+	if err = db.Put(dbutils.BlockReceiptsPrefix, dbutils.ReceiptsKey(2), []byte{3}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		bucket string
+		key    []byte
+		value  []byte
+	}{
+		{dbutils.BlockReceiptsPrefix, dbutils.ReceiptsKey(1), []byte{1}},
+		{dbutils.BlockReceiptsPrefix, dbutils.ReceiptsKey(2), []byte{3}},
+		{dbutils.Log, dbutils.LogKey(1, 0), []byte{2}},
+	} {
+		v, err := db.Get(tc.bucket, tc.key)
+		if err != nil {
+			t.Fatal(tc.bucket, tc.key, err)
+		}
+		if !bytes.Equal(v, tc.value) {
+			t.Error(tc.bucket, tc.key, "incorrect value", v)
+		}
+	}
+}
@@ -24,29 +24,10 @@ import (
 const callTimeout = 5 * time.Minute
 
 func DoCall(ctx context.Context, args ethapi.CallArgs, tx ethdb.Tx, dbReader ethdb.Getter, blockNrOrHash rpc.BlockNumberOrHash, overrides *map[common.Address]ethapi.Account, GasCap uint64) (*core.ExecutionResult, error) {
-	// todo: Pending state is only known by the miner
-	/*
-		if blockNrOrHash.BlockNumber != nil && *blockNrOrHash.BlockNumber == rpc.PendingBlockNumber {
-			block, state, _ := b.eth.miner.Pending()
-			return state, block.Header(), nil
-		}
-	*/
-	blockNumber, hash, err := rpchelper.GetBlockNumber(blockNrOrHash, dbReader)
+	state, header, err := CallState(tx, dbReader, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
-	var stateReader state.StateReader
-	if num, ok := blockNrOrHash.Number(); ok && num == rpc.LatestBlockNumber {
-		stateReader = state.NewPlainStateReader(dbReader)
-	} else {
-		stateReader = state.NewPlainDBState(tx, blockNumber)
-	}
-	state := state.New(stateReader)
-
-	header := rawdb.ReadHeader(dbReader, hash, blockNumber)
-	if header == nil {
-		return nil, fmt.Errorf("block %d(%x) not found", blockNumber, hash)
-	}
 
 	// Override the fields of specified contracts before execution.
 	if overrides != nil {
@@ -121,6 +102,33 @@ func DoCall(ctx context.Context, args ethapi.CallArgs, tx ethdb.Tx, dbReader eth
 	return result, nil
 }
 
+// CallState returns the state (and the header of the block) on top of which the calls at the given block are executed
+func CallState(tx ethdb.Tx, dbReader ethdb.Getter, blockNrOrHash rpc.BlockNumberOrHash) (*state.IntraBlockState, *types.Header, error) {
+	// todo: Pending state is only known by the miner
+	/*
+		if blockNrOrHash.BlockNumber != nil && *blockNrOrHash.BlockNumber == rpc.PendingBlockNumber {
+			block, state, _ := b.eth.miner.Pending()
+			return state, block.Header(), nil
+		}
+	*/
+	blockNumber, hash, err := rpchelper.GetBlockNumber(blockNrOrHash, dbReader)
+	if err != nil {
+		return nil, nil, err
+	}
+	var stateReader state.StateReader
+	if num, ok := blockNrOrHash.Number(); ok && num == rpc.LatestBlockNumber {
+		stateReader = state.NewPlainStateReader(dbReader)
+	} else {
+		stateReader = state.NewPlainDBState(tx, blockNumber)
+	}
+
+	header := rawdb.ReadHeader(dbReader, hash, blockNumber)
+	if header == nil {
+		return nil, nil, fmt.Errorf("block %d(%x) not found", blockNumber, hash)
+	}
+	return state.New(stateReader), header, nil
+}
+
 func GetEvmContext(msg core.Message, header *types.Header, requireCanonical bool, dbReader rawdb.DatabaseReader) vm.Context {
 	return vm.Context{
 		CanTransfer: core.CanTransfer,
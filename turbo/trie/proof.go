@@ -20,6 +20,9 @@ func (t *Trie) Prove(key []byte, fromLevel int, storage bool) ([][]byte, error)
 	defer returnHasherToPool(hasher)
 	// Collect all nodes on the path to key.
 	key = keybytesToHex(key)
+	if t.binary {
+		key = keyHexToBin(key)
+	}
 	key = key[:len(key)-1] // Remove terminator
 	tn := t.root
 	for len(key) > 0 && tn != nil {
@@ -1,6 +1,8 @@
 package trie
 
 import (
+	"bytes"
+
 	"github.com/ledgerwatch/turbo-geth/common"
 )
 
@@ -57,3 +59,122 @@ func normalize(n byte) byte {
 	}
 	return 0
 }
+
+// keybytesToBin transforms a key or a key prefix into the binary representation without terminator
+func keybytesToBin(key []byte) []byte {
+	bin := make([]byte, len(key)*8)
+	for i, b := range key {
+		for shift := 7; shift >= 0; shift-- {
+			bin[i*8+7-shift] = (b >> uint(shift)) & 1
+		}
+	}
+	return bin
+}
+
+// binToKeybytes transforms a binary key prefix of whole bytes back into the key bytes
+func binToKeybytes(bin []byte) []byte {
+	key := make([]byte, len(bin)/8)
+	for i := range key {
+		for j := 0; j < 8; j++ {
+			key[i] = key[i]<<1 | bin[i*8+j]
+		}
+	}
+	return key
+}
+
+// AddSubTrieHash places the hash of the branch node at the key prefix, without resolving the subtrie.
+// None of the other keys of the trie may start with this prefix.
+func (b *BinaryTrie) AddSubTrieHash(keyPrefix []byte, hash common.Hash) {
+	t := b.Trie()
+	_, t.root = t.insert(t.root, keybytesToBin(keyPrefix), hashNode{hash: common.CopyBytes(hash[:])})
+}
+
+// SubTrieHash returns the hash of the node at the key prefix, if there is a branch node at this prefix,
+// which is referenced by its hash (not embedded into the parent node).
+// Such hashes can be placed back into the trie with AddSubTrieHash.
+func (b *BinaryTrie) SubTrieHash(keyPrefix []byte) (common.Hash, bool) {
+	bin := keybytesToBin(keyPrefix)
+	nd := b.root
+	for len(bin) > 0 {
+		switch n := nd.(type) {
+		case *shortNode:
+			if len(n.Key) > len(bin) || !bytes.Equal(n.Key, bin[:len(n.Key)]) {
+				return common.Hash{}, false
+			}
+			nd = n.Val
+			bin = bin[len(n.Key):]
+		case *duoNode:
+			i1, i2 := n.childrenIdx()
+			switch bin[0] {
+			case i1:
+				nd = n.child1
+			case i2:
+				nd = n.child2
+			default:
+				return common.Hash{}, false
+			}
+			bin = bin[1:]
+		case *fullNode:
+			nd = n.Children[bin[0]]
+			bin = bin[1:]
+		default:
+			return common.Hash{}, false
+		}
+	}
+	h := b.Trie().getHasher()
+	defer returnHasherToPool(h)
+	return branchHash(h, nd)
+}
+
+// SubTrieHashes calls f with the key prefix and the hash of every branch node of the trie at a prefix of whole bytes,
+// except the root, as SubTrieHash would return them.
+func (b *BinaryTrie) SubTrieHashes(f func(keyPrefix []byte, hash common.Hash) error) error {
+	h := b.Trie().getHasher()
+	defer returnHasherToPool(h)
+	return subTrieHashes(h, b.root, nil, f)
+}
+
+func subTrieHashes(h *hasher, nd node, bin []byte, f func(keyPrefix []byte, hash common.Hash) error) error {
+	if len(bin) > 0 && len(bin)%8 == 0 {
+		if hash, ok := branchHash(h, nd); ok {
+			if err := f(binToKeybytes(bin), hash); err != nil {
+				return err
+			}
+		}
+	}
+	bin = bin[:len(bin):len(bin)] // children must not share the buffer
+	switch n := nd.(type) {
+	case *shortNode:
+		return subTrieHashes(h, n.Val, append(bin, n.Key...), f)
+	case *duoNode:
+		i1, i2 := n.childrenIdx()
+		if err := subTrieHashes(h, n.child1, append(bin, i1), f); err != nil {
+			return err
+		}
+		return subTrieHashes(h, n.child2, append(bin, i2), f)
+	case *fullNode:
+		for i, child := range n.Children[:16] {
+			if child == nil {
+				continue
+			}
+			if err := subTrieHashes(h, child, append(bin, byte(i)), f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// branchHash returns the hash of the node if it is a branch node referenced by its hash
+func branchHash(h *hasher, nd node) (common.Hash, bool) {
+	switch nd.(type) {
+	case *duoNode, *fullNode:
+	default:
+		return common.Hash{}, false
+	}
+	var hash common.Hash
+	if n, err := h.hash(nd, false, hash[:]); err != nil || n != common.HashLength {
+		return common.Hash{}, false
+	}
+	return hash, true
+}
@@ -0,0 +1,112 @@
+package trie
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/turbo-geth/common"
+	"github.com/ledgerwatch/turbo-geth/crypto"
+	"github.com/ledgerwatch/turbo-geth/rlp"
+)
+
+func TestBinarySubTrieHash(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	full := NewBinary(common.Hash{})
+	byPrefix := make(map[byte][][]byte)
+	for i := 0; i < 300; i++ {
+		k := make([]byte, 32)
+		rnd.Read(k)
+		if i%3 == 0 {
+			// A few prefixes with exactly one key, which are not branch nodes
+			k[0] = byte(i % 7)
+		}
+		full.Update(k, []byte{byte(i + 1)})
+		byPrefix[k[0]] = append(byPrefix[k[0]], k)
+	}
+
+	// Subtries with branch nodes at the prefix are replaced by their hashes, keys of other subtries are added as is
+	top := (*BinaryTrie)(NewBinary(common.Hash{}))
+	var hashed int
+	for prefix, keys := range byPrefix {
+		sub := NewBinary(common.Hash{})
+		for _, k := range keys {
+			v, _ := full.Get(k)
+			sub.Update(k, v)
+		}
+		if hash, ok := (*BinaryTrie)(sub).SubTrieHash([]byte{prefix}); ok {
+			top.AddSubTrieHash([]byte{prefix}, hash)
+			hashed++
+			continue
+		}
+		for _, k := range keys {
+			v, _ := full.Get(k)
+			top.Trie().Update(k, v)
+		}
+	}
+	assert.True(t, hashed > 0)
+	assert.Equal(t, full.Hash(), top.Trie().Hash())
+}
+
+func TestBinarySubTrieHashes(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	tr := (*BinaryTrie)(NewBinary(common.Hash{}))
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = make([]byte, 32)
+		rnd.Read(keys[i])
+		tr.Trie().Update(keys[i], []byte{byte(i + 1)})
+	}
+	hashes := make(map[string]common.Hash)
+	require.NoError(t, tr.SubTrieHashes(func(keyPrefix []byte, hash common.Hash) error {
+		expected, ok := tr.SubTrieHash(keyPrefix)
+		require.True(t, ok, "%x", keyPrefix)
+		require.Equal(t, expected, hash, "%x", keyPrefix)
+		hashes[string(keyPrefix)] = hash
+		return nil
+	}))
+	// branches deeper than one byte are reported too
+	var deep int
+	for prefix := range hashes {
+		if len(prefix) > 1 {
+			deep++
+		}
+	}
+	assert.True(t, deep > 0)
+
+	// the trie built of the top level hashes and the keys outside of them has the same root
+	top := (*BinaryTrie)(NewBinary(common.Hash{}))
+	for prefix, hash := range hashes {
+		if len(prefix) == 1 {
+			top.AddSubTrieHash([]byte(prefix), hash)
+		}
+	}
+	for i, k := range keys {
+		if _, ok := hashes[string(k[:1])]; !ok {
+			top.Trie().Update(k, []byte{byte(i + 1)})
+		}
+	}
+	assert.Equal(t, tr.Trie().Hash(), top.Trie().Hash())
+}
+
+func TestBinaryProve(t *testing.T) {
+	tr := NewBinary(common.Hash{})
+	keys := make([][]byte, 20)
+	for i := range keys {
+		keys[i] = crypto.Keccak256([]byte{byte(i)})
+		tr.Update(keys[i], []byte{byte(i + 1)})
+	}
+	root := tr.Hash()
+	for i, k := range keys {
+		proof, err := tr.Prove(k, 0, false)
+		require.NoError(t, err)
+		require.NotEmpty(t, proof)
+		// The first node of the proof is the root, the last one is the leaf with the value
+		assert.Equal(t, root[:], crypto.Keccak256(proof[0]))
+		var leaf [][]byte
+		require.NoError(t, rlp.DecodeBytes(proof[len(proof)-1], &leaf))
+		assert.Equal(t, []byte{byte(i + 1)}, leaf[1])
+	}
+}